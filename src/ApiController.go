@@ -2,54 +2,154 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"devChallengeExcel/contracts"
 	"errors"
 	"fmt"
 	json "github.com/bytedance/sonic"
+	"github.com/gin-contrib/sse"
 	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+	"hash/fnv"
+	"io"
 	"net/http"
+	"net/url"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
 type ApiController struct {
-	SheetRepository   contracts.SheetRepository
-	WebhookDispatcher contracts.WebhookDispatcher
-	Executor          contracts.ExpressionExecutor
-	Hostname          string
+	SheetRepository    contracts.SheetRepository
+	WebhookDispatcher  contracts.WebhookDispatcher
+	Executor           contracts.ExpressionExecutor
+	ExternalRefFetcher contracts.ExternalRefFetcher
+	EgressPolicy       contracts.EgressPolicy
+	OutboundClient     contracts.OutboundClient
+	WebhookSigner      contracts.WebhookSigner
+	ChangeEventStream  contracts.ChangeEventStream
+	Hostname           string
+
+	// background tasks which outlive their requests, Shutdown waits for them
+	background sync.WaitGroup
 }
 
+// CellEndpointParams sheet ids starting with `__` are reserved for internal buckets of the storage (webhooks, settings, etc.)
 type CellEndpointParams struct {
-	SheetId string `uri:"sheet_id" binding:"required"`
+	SheetId string `uri:"sheet_id" binding:"required,startsnotwith=__"`
 	CellId  string `uri:"cell_id" binding:"required"`
 }
 
 type SheetEndpointParams struct {
-	SheetId string `uri:"sheet_id" binding:"required"`
+	SheetId string `uri:"sheet_id" binding:"required,startsnotwith=__"`
 }
 
 type SetCellRequest struct {
 	Value string `json:"value" binding:"required"`
 }
 
+type SheetSettingsRequest struct {
+	Iterative     bool    `json:"iterative"`
+	MaxIterations int     `json:"max_iterations" binding:"omitempty,min=1,max=32767"`
+	Epsilon       float64 `json:"epsilon" binding:"omitempty,gt=0"`
+}
+
+type SubscriptionEndpointParams struct {
+	CellEndpointParams
+	SubscriptionId string `uri:"subscription_id"`
+}
+
+type SheetSubscriptionEndpointParams struct {
+	SheetEndpointParams
+	SubscriptionId string `uri:"subscription_id"`
+}
+
+// DeliveryLogQuery number of recent delivery attempts in the response
+type DeliveryLogQuery struct {
+	Limit int `form:"limit" binding:"min=1,max=1000"`
+}
+
+// DefaultDeliveryLogLimit recent delivery attempts in the response when limit is not set
+const DefaultDeliveryLogLimit = 50
+
+// SheetQuery `at` evaluates the sheet as it was at the moment (RFC 3339 timestamp) according to history of cells
+type SheetQuery struct {
+	At *time.Time `form:"at" time_format:"2006-01-02T15:04:05Z07:00"`
+}
+
+// HistoryQuery number of recent versions of the cell in the response
+type HistoryQuery struct {
+	Limit int `form:"limit" binding:"min=1,max=1000"`
+}
+
+// DefaultHistoryLimit recent versions of the cell in the response when limit is not set
+const DefaultHistoryLimit = 50
+
+// UndoQuery number of the last writes to undo (or redo)
+type UndoQuery struct {
+	Steps int `form:"steps" binding:"min=1,max=100"`
+}
+
+type DeadLetterEndpointParams struct {
+	DeadLetterId string `uri:"dead_letter_id" binding:"required"`
+}
+
 type WebhookConfig struct {
-	WebhookUrl string `json:"webhook_url" binding:"required"`
+	WebhookUrl  string `json:"webhook_url" binding:"required"`
+	Description string `json:"description" binding:"max=1024"`
+	contracts.WebhookOptions
+}
+
+// ChangeEventsQuery filter of the change event stream and event to resume after
+type ChangeEventsQuery struct {
+	// Cells comma separated cell ids, all cells of the sheet when it is empty
+	Cells string `form:"cells"`
+	// LastEventId resumes the stream, browsers send Last-Event-ID header on reconnect instead
+	LastEventId uint64 `form:"last_event_id"`
+}
+
+// ChangeEventsKeepAliveInterval comment is sent to idle event stream, so proxies do not close it
+const ChangeEventsKeepAliveInterval = 15 * time.Second
+
+// MaxWebhookConditionLength limit of the condition expression of the subscription
+const MaxWebhookConditionLength = 1024
+
+// MaxWebhookDebounce limit of the debounce window of the subscription
+const MaxWebhookDebounce = time.Minute
+
+// SheetWebhookConfig subscription of the sheet: all cells, listed cells or A1 range
+type SheetWebhookConfig struct {
+	WebhookConfig
+	Cells []string `json:"cells"`
+	Range string   `json:"range"`
 }
 
 // https://regex101.com/r/N5SLnV/2
 
-func NewApiController(sheetRepository contracts.SheetRepository, webhookDispatcher contracts.WebhookDispatcher, executor contracts.ExpressionExecutor) *ApiController {
+func NewApiController(
+	sheetRepository contracts.SheetRepository, webhookDispatcher contracts.WebhookDispatcher,
+	executor contracts.ExpressionExecutor, externalRefFetcher contracts.ExternalRefFetcher,
+	egressPolicy contracts.EgressPolicy, outboundClient contracts.OutboundClient,
+	webhookSigner contracts.WebhookSigner, changeEventStream contracts.ChangeEventStream,
+) *ApiController {
 	hostname, err := os.Hostname()
 	if err != nil {
 		hostname = "localhost"
 	}
 
 	return &ApiController{
-		SheetRepository:   sheetRepository,
-		WebhookDispatcher: webhookDispatcher,
-		Executor:          executor,
-		Hostname:          hostname + ListenPort,
+		SheetRepository:    sheetRepository,
+		WebhookDispatcher:  webhookDispatcher,
+		Executor:           executor,
+		ExternalRefFetcher: externalRefFetcher,
+		EgressPolicy:       egressPolicy,
+		OutboundClient:     outboundClient,
+		WebhookSigner:      webhookSigner,
+		ChangeEventStream:  changeEventStream,
+		Hostname:           hostname + ListenPort,
 	}
 }
 
@@ -70,55 +170,117 @@ func (api *ApiController) GetCellAction(c *gin.Context) {
 	} else if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 	} else {
+		// ETag allows other instances to revalidate cached external_ref result
+		eTag := makeCellETag(response)
+		c.Header("ETag", eTag)
+		if c.GetHeader("If-None-Match") == eTag {
+			c.Status(http.StatusNotModified)
+			return
+		}
+
 		c.JSON(http.StatusOK, response)
 	}
 }
 
+func uniqueStrings(values []string) []string {
+	unique := make([]string, 0, len(values))
+	for _, value := range values {
+		if !slices.Contains(unique, value) {
+			unique = append(unique, value)
+		}
+	}
+
+	return unique
+}
+
+func makeCellETag(cell *contracts.Cell) string {
+	hash := fnv.New64a()
+	_, _ = hash.Write([]byte(cell.Value))
+	_, _ = hash.Write([]byte{0})
+	_, _ = hash.Write([]byte(cell.Result))
+
+	return `"` + strconv.FormatUint(hash.Sum64(), 16) + `"`
+}
+
 func (api *ApiController) SetCellAction(c *gin.Context) {
 	params := CellEndpointParams{}
 	request := SetCellRequest{}
-	var response *contracts.Cell
-	var isUpdated bool
 
 	err := c.ShouldBindUri(&params)
 	if err == nil {
 		err = c.ShouldBindJSON(&request)
 	}
+	if err == nil {
+		err = api.validateCellValue(request.Value)
+	}
 
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	response, err, isUpdated = api.SheetRepository.SetCell(params.SheetId, params.CellId, request.Value, true)
+	response, err := api.setCell(&params, request.Value)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, response)
+	} else {
+		c.JSON(http.StatusCreated, response)
+	}
+}
+
+// validateCellValue checks urls of external_ref and external_json functions of the value
+func (api *ApiController) validateCellValue(value string) error {
+	err := api.validateUrls(api.Executor.ExtractExternalRefs(value))
+	if err == nil {
+		err = api.validateUrls(api.Executor.ExtractExternalJsonUrls(value))
+	}
+
+	return err
+}
+
+// setCell stores the cell and watches its external refs. On error the result of returned cell is the error message
+func (api *ApiController) setCell(params *CellEndpointParams, value string) (*contracts.Cell, error) {
+	response, err, isUpdated := api.SheetRepository.SetCell(params.SheetId, params.CellId, value, true)
 
 	if isUpdated {
-		go api.SubscribeExternalRefsToWebhook(&params, response)
+		api.ExternalRefFetcher.WatchCell(
+			params.SheetId, params.CellId,
+			api.Executor.ExtractExternalRefs(response.Value), api.Executor.ExtractExternalJsonUrls(response.Value),
+		)
+		api.goBackground(func() {
+			api.SubscribeExternalRefsToWebhook(params, response)
+		})
 	}
 
 	if err != nil {
 		if response == nil {
 			response = &contracts.Cell{}
 		}
-		response.Value = request.Value
+		response.Value = value
 		response.Result = err.Error()
-		c.JSON(http.StatusUnprocessableEntity, response)
-	} else {
-		c.JSON(http.StatusCreated, response)
 	}
+
+	return response, err
 }
 
 func (api *ApiController) GetSheetAction(c *gin.Context) {
 	params := SheetEndpointParams{}
+	query := SheetQuery{}
 	response := &contracts.CellList{}
 
 	err := c.ShouldBindUri(&params)
+	if err == nil {
+		err = c.ShouldBindQuery(&query)
+	}
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	response, err = api.SheetRepository.GetCellList(params.SheetId)
+	if query.At != nil {
+		response, err = api.SheetRepository.GetCellListAt(params.SheetId, *query.At)
+	} else {
+		response, err = api.SheetRepository.GetCellList(params.SheetId)
+	}
 
 	if errors.Is(err, contracts.SheetNotFoundError) {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
@@ -130,6 +292,90 @@ func (api *ApiController) GetSheetAction(c *gin.Context) {
 	}
 }
 
+// GetCellHistoryAction returns recent versions of the cell, newest first
+func (api *ApiController) GetCellHistoryAction(c *gin.Context) {
+	params := CellEndpointParams{}
+	query := HistoryQuery{Limit: DefaultHistoryLimit}
+
+	err := c.ShouldBindUri(&params)
+	if err == nil {
+		err = c.ShouldBindQuery(&query)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	versions, err := api.SheetRepository.GetCellHistory(params.SheetId, params.CellId, query.Limit)
+
+	if errors.Is(err, contracts.SheetNotFoundError) || errors.Is(err, contracts.CellNotFoundError) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	} else {
+		c.JSON(http.StatusOK, versions)
+	}
+}
+
+// UndoAction reverts the last writes of cells of the sheet, responds with the written versions of the cells
+func (api *ApiController) UndoAction(c *gin.Context) {
+	api.applyUndoStack(c, api.SheetRepository.Undo)
+}
+
+// RedoAction writes again the last undone writes of cells of the sheet
+func (api *ApiController) RedoAction(c *gin.Context) {
+	api.applyUndoStack(c, api.SheetRepository.Redo)
+}
+
+func (api *ApiController) applyUndoStack(c *gin.Context, apply func(sheetId string, steps int) ([]contracts.CellVersion, error)) {
+	params := SheetEndpointParams{}
+	query := UndoQuery{Steps: 1}
+
+	err := c.ShouldBindUri(&params)
+	if err == nil {
+		err = c.ShouldBindQuery(&query)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	versions, err := apply(params.SheetId, query.Steps)
+
+	if errors.Is(err, contracts.SheetNotFoundError) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	} else if errors.Is(err, contracts.NothingToUndoError) || errors.Is(err, contracts.NothingToRedoError) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	} else if errors.Is(err, contracts.UndoEvaluationError) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	} else {
+		api.watchRestoredCells(params.SheetId, versions)
+		c.JSON(http.StatusOK, versions)
+	}
+}
+
+// watchRestoredCells watches external refs of the cells according to their restored values (the last version of each cell)
+func (api *ApiController) watchRestoredCells(sheetId string, versions []contracts.CellVersion) {
+	restored := map[string]contracts.CellVersion{}
+	for _, version := range versions {
+		restored[api.SheetRepository.GetCanonicalCellId(version.CellId)] = version
+	}
+
+	for canonicalCellId, version := range restored {
+		params := &CellEndpointParams{SheetId: sheetId, CellId: version.CellId}
+		api.ExternalRefFetcher.WatchCell(
+			sheetId, version.CellId,
+			api.Executor.ExtractExternalRefs(version.Value), api.Executor.ExtractExternalJsonUrls(version.Value),
+		)
+		cell := &contracts.Cell{CanonicalKey: canonicalCellId, Value: version.Value}
+		api.goBackground(func() {
+			api.SubscribeExternalRefsToWebhook(params, cell)
+		})
+	}
+}
+
 func (api *ApiController) SubscribeAction(c *gin.Context) {
 	params := CellEndpointParams{}
 	webhookRequestConfig := WebhookConfig{}
@@ -138,61 +384,522 @@ func (api *ApiController) SubscribeAction(c *gin.Context) {
 	if err == nil {
 		err = c.ShouldBindJSON(&webhookRequestConfig)
 	}
+	if err == nil {
+		err = api.validateUrls([]string{webhookRequestConfig.WebhookUrl})
+	}
+	if err == nil {
+		err = api.validateWebhookOptions(&webhookRequestConfig.WebhookOptions)
+	}
 
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	var cell *contracts.Cell
-	cell, err = api.SheetRepository.GetCell(params.SheetId, params.CellId)
-	if errors.Is(err, contracts.CellNotFoundError) || errors.Is(err, contracts.SheetNotFoundError) {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	cell, ok := api.getCellForSubscriptions(c, &params)
+	if !ok {
+		return
+	}
+
+	subscription, err := api.WebhookDispatcher.Subscribe(
+		api.SheetRepository.GetCanonicalSheetId(params.SheetId), cell.CanonicalKey,
+		webhookRequestConfig.WebhookUrl, webhookRequestConfig.Description, webhookRequestConfig.WebhookOptions,
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, subscription)
+}
+
+func (api *ApiController) GetSubscriptionsAction(c *gin.Context) {
+	params := CellEndpointParams{}
+
+	err := c.ShouldBindUri(&params)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cell, ok := api.getCellForSubscriptions(c, &params)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"subscriptions": api.WebhookDispatcher.GetSubscriptions(api.SheetRepository.GetCanonicalSheetId(params.SheetId), cell.CanonicalKey),
+	})
+}
+
+// UnsubscribeAction removes subscription by id (`/subscriptions/:subscription_id`)
+// or all subscriptions with the url (`/subscriptions?webhook_url=`)
+func (api *ApiController) UnsubscribeAction(c *gin.Context) {
+	params := SubscriptionEndpointParams{}
+
+	err := c.ShouldBindUri(&params)
+	if err == nil && params.SubscriptionId == "" && c.Query("webhook_url") == "" {
+		err = errors.New("subscription id or webhook_url is required")
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
+	}
+
+	cell, ok := api.getCellForSubscriptions(c, &params.CellEndpointParams)
+	if !ok {
+		return
+	}
+
+	canonicalSheetId := api.SheetRepository.GetCanonicalSheetId(params.SheetId)
+	if params.SubscriptionId != "" {
+		err = api.WebhookDispatcher.Unsubscribe(canonicalSheetId, cell.CanonicalKey, params.SubscriptionId)
+	} else {
+		err = api.WebhookDispatcher.UnsubscribeUrl(canonicalSheetId, cell.CanonicalKey, c.Query("webhook_url"))
+	}
+
+	if errors.Is(err, contracts.WebhookSubscriptionNotFoundError) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 	} else if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	} else {
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func (api *ApiController) SubscribeSheetAction(c *gin.Context) {
+	params := SheetEndpointParams{}
+	webhookRequestConfig := SheetWebhookConfig{}
+
+	err := c.ShouldBindUri(&params)
+	if err == nil {
+		err = c.ShouldBindJSON(&webhookRequestConfig)
+	}
+	if err == nil {
+		err = api.validateUrls([]string{webhookRequestConfig.WebhookUrl})
+	}
+	if err == nil {
+		err = api.validateWebhookOptions(&webhookRequestConfig.WebhookOptions)
+	}
+
+	var scope contracts.WebhookScope
+	if err == nil {
+		scope, err = api.makeWebhookScope(&webhookRequestConfig)
+	}
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	subscription, err := api.WebhookDispatcher.SubscribeSheet(
+		api.SheetRepository.GetCanonicalSheetId(params.SheetId),
+		webhookRequestConfig.WebhookUrl, webhookRequestConfig.Description, scope, webhookRequestConfig.WebhookOptions,
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, subscription)
+}
+
+// makeWebhookScope validates range and canonicalizes cells of sheet subscription
+func (api *ApiController) makeWebhookScope(config *SheetWebhookConfig) (scope contracts.WebhookScope, err error) {
+	if config.Range != "" && len(config.Cells) != 0 {
+		return scope, errors.New("cells and range can not be combined")
+	}
+
+	if config.Range != "" {
+		if _, err = ParseCellRange(config.Range); err != nil {
+			return
+		}
+		scope.Range = strings.ToUpper(strings.TrimSpace(config.Range))
+	}
+
+	scope.Cells, err = api.makeCanonicalCellIds(config.Cells)
+
+	return
+}
+
+// makeCanonicalCellIds validates cell ids and returns unique canonical ones (nil for empty list)
+func (api *ApiController) makeCanonicalCellIds(cellIds []string) (canonicalCellIds []string, err error) {
+	for _, cellId := range cellIds {
+		if cellId == "" || strings.ContainsAny(cellId, contracts.CellIdBlacklist) {
+			return nil, fmt.Errorf("cell_id `%s`: %w", cellId, contracts.CellIdBlacklistError)
+		}
+		canonicalCellIds = append(canonicalCellIds, api.SheetRepository.GetCanonicalCellId(cellId))
+	}
+	if len(canonicalCellIds) != 0 {
+		canonicalCellIds = uniqueStrings(canonicalCellIds)
+	}
+
+	return
+}
+
+// ChangeEventsAction streams changed cells of the sheet as Server-Sent Events (payload is the same as webhook of the cell).
+// Id of the event is its sequence in the sheet, the stream is resumed from the replay buffer after Last-Event-ID.
+// When the buffer does not have the events anymore, `reset` event is sent first and the client should reload the sheet
+func (api *ApiController) ChangeEventsAction(c *gin.Context) {
+	params := SheetEndpointParams{}
+	query := ChangeEventsQuery{}
+
+	err := c.ShouldBindUri(&params)
+	if err == nil {
+		err = c.ShouldBindQuery(&query)
+	}
+	if lastEventId := c.GetHeader("Last-Event-ID"); err == nil && lastEventId != "" {
+		query.LastEventId, err = strconv.ParseUint(lastEventId, 10, 64)
+	}
+
+	var cells []string
+	if err == nil && query.Cells != "" {
+		cells, err = api.makeCanonicalCellIds(strings.Split(query.Cells, ","))
+	}
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
 	canonicalSheetId := api.SheetRepository.GetCanonicalSheetId(params.SheetId)
-	api.WebhookDispatcher.SetWebhookUrl(canonicalSheetId, cell.CanonicalKey, webhookRequestConfig.WebhookUrl)
+	subscription := api.ChangeEventStream.Subscribe(canonicalSheetId, cells, query.LastEventId)
+	defer api.ChangeEventStream.Unsubscribe(subscription)
+
+	c.Header("Content-Type", sse.ContentType)
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	if subscription.Reset {
+		c.Render(-1, sse.Event{Event: "reset", Data: gin.H{"sheet_id": canonicalSheetId}})
+	}
+	for _, event := range subscription.Replay {
+		renderChangeEvent(c, event)
+	}
+	c.Writer.Flush()
+
+	keepAlive := time.NewTicker(ChangeEventsKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event, ok := <-subscription.Events:
+			if !ok {
+				// listener fell behind, the client reconnects with Last-Event-ID
+				return false
+			}
+			renderChangeEvent(c, event)
+		case <-keepAlive.C:
+			_, _ = io.WriteString(w, ": keep-alive\n\n")
+		case <-c.Request.Context().Done():
+			return false
+		}
 
-	webhookResponseConfig := WebhookConfig{
-		WebhookUrl: api.WebhookDispatcher.GetWebhookUrl(canonicalSheetId, cell.CanonicalKey),
+		return true
+	})
+}
+
+// LiveSessionAction upgrades connection to WebSocket session of the sheet (see LiveSession)
+func (api *ApiController) LiveSessionAction(c *gin.Context) {
+	params := SheetEndpointParams{}
+
+	err := c.ShouldBindUri(&params)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	server := websocket.Server{Handler: func(conn *websocket.Conn) {
+		NewLiveSession(api, conn, params.SheetId).Run()
+	}}
+	server.ServeHTTP(c.Writer, c.Request)
+}
+
+func renderChangeEvent(c *gin.Context, event contracts.ChangeEvent) {
+	data, _ := json.Marshal(event.WebhookEvent)
+	c.Render(-1, sse.Event{Id: strconv.FormatUint(event.Sequence, 10), Data: string(data)})
+}
+
+func (api *ApiController) GetSheetSubscriptionsAction(c *gin.Context) {
+	params := SheetEndpointParams{}
+
+	err := c.ShouldBindUri(&params)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	c.JSON(http.StatusCreated, webhookResponseConfig)
+	c.JSON(http.StatusOK, gin.H{
+		"subscriptions": api.WebhookDispatcher.GetSheetSubscriptions(api.SheetRepository.GetCanonicalSheetId(params.SheetId)),
+	})
 }
 
+func (api *ApiController) UnsubscribeSheetAction(c *gin.Context) {
+	params := SheetSubscriptionEndpointParams{}
+
+	err := c.ShouldBindUri(&params)
+	if err == nil && params.SubscriptionId == "" && c.Query("webhook_url") == "" {
+		err = errors.New("subscription id or webhook_url is required")
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	canonicalSheetId := api.SheetRepository.GetCanonicalSheetId(params.SheetId)
+	if params.SubscriptionId != "" {
+		err = api.WebhookDispatcher.UnsubscribeSheet(canonicalSheetId, params.SubscriptionId)
+	} else {
+		err = api.WebhookDispatcher.UnsubscribeSheetUrl(canonicalSheetId, c.Query("webhook_url"))
+	}
+
+	if errors.Is(err, contracts.WebhookSubscriptionNotFoundError) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	} else {
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// GetDeliveriesAction returns delivery status and recent delivery attempts of the subscription of the cell
+func (api *ApiController) GetDeliveriesAction(c *gin.Context) {
+	params := SubscriptionEndpointParams{}
+	query := DeliveryLogQuery{Limit: DefaultDeliveryLogLimit}
+
+	err := c.ShouldBindUri(&params)
+	if err == nil {
+		err = c.ShouldBindQuery(&query)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	deliveryLog, err := api.WebhookDispatcher.GetDeliveryLog(
+		api.SheetRepository.GetCanonicalSheetId(params.SheetId), api.SheetRepository.GetCanonicalCellId(params.CellId),
+		params.SubscriptionId, query.Limit,
+	)
+	api.renderDeliveryLog(c, deliveryLog, err)
+}
+
+// GetSheetSubscriptionDeliveriesAction returns delivery status and recent delivery attempts of the subscription of the sheet
+func (api *ApiController) GetSheetSubscriptionDeliveriesAction(c *gin.Context) {
+	params := SheetSubscriptionEndpointParams{}
+	query := DeliveryLogQuery{Limit: DefaultDeliveryLogLimit}
+
+	err := c.ShouldBindUri(&params)
+	if err == nil {
+		err = c.ShouldBindQuery(&query)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	deliveryLog, err := api.WebhookDispatcher.GetSheetSubscriptionDeliveryLog(
+		api.SheetRepository.GetCanonicalSheetId(params.SheetId), params.SubscriptionId, query.Limit,
+	)
+	api.renderDeliveryLog(c, deliveryLog, err)
+}
+
+// GetSheetDeliveriesAction returns delivery status of all subscriptions of the sheet and its cells and their recent delivery attempts
+func (api *ApiController) GetSheetDeliveriesAction(c *gin.Context) {
+	params := SheetEndpointParams{}
+	query := DeliveryLogQuery{Limit: DefaultDeliveryLogLimit}
+
+	err := c.ShouldBindUri(&params)
+	if err == nil {
+		err = c.ShouldBindQuery(&query)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	deliveryLog, err := api.WebhookDispatcher.GetSheetDeliveryLog(api.SheetRepository.GetCanonicalSheetId(params.SheetId), query.Limit)
+	api.renderDeliveryLog(c, deliveryLog, err)
+}
+
+func (api *ApiController) renderDeliveryLog(c *gin.Context, deliveryLog *contracts.WebhookDeliveryLog, err error) {
+	if errors.Is(err, contracts.WebhookSubscriptionNotFoundError) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	} else {
+		c.JSON(http.StatusOK, deliveryLog)
+	}
+}
+
+// getCellForSubscriptions responds with error when the cell can't be found
+func (api *ApiController) getCellForSubscriptions(c *gin.Context, params *CellEndpointParams) (*contracts.Cell, bool) {
+	cell, err := api.SheetRepository.GetCell(params.SheetId, params.CellId)
+	if errors.Is(err, contracts.CellNotFoundError) || errors.Is(err, contracts.SheetNotFoundError) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return nil, false
+	} else if err != nil && cell.Value == "" {
+		// evaluation error doesn't matter for subscriptions
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	return cell, true
+}
+
+// SubscribeExternalRefsToWebhook subscribes the cell to external cells of its formula
+// and unsubscribes it from external cells which are not referenced anymore
 func (api *ApiController) SubscribeExternalRefsToWebhook(params *CellEndpointParams, cell *contracts.Cell) {
-	// parse external refs
-	externalsRefs := api.Executor.ExtractExternalRefs(cell.Value)
-	if externalsRefs == nil || len(externalsRefs) == 0 {
+	externalRefs := uniqueStrings(api.Executor.ExtractExternalRefs(cell.Value))
+
+	previousExternalRefs, err := api.SheetRepository.SetExternalRefSubscriptions(params.SheetId, params.CellId, externalRefs)
+	if err != nil {
+		logger.Println("failed to store subscriptions:", err)
+	}
+
+	webhookUrl := api.makeExternalRefWebhookUrl(api.SheetRepository.GetCanonicalSheetId(params.SheetId), cell.CanonicalKey)
+	for _, externalRef := range externalRefs {
+		api.sendSubscribeRequest(externalRef, webhookUrl)
+	}
+
+	for _, externalRef := range previousExternalRefs {
+		if !slices.Contains(externalRefs, externalRef) {
+			api.sendUnsubscribeRequest(externalRef, webhookUrl)
+		}
+	}
+}
+
+// goBackground runs the task without waiting for it, Shutdown waits until it is completed
+func (api *ApiController) goBackground(task func()) {
+	api.background.Add(1)
+	go func() {
+		defer api.background.Done()
+		task()
+	}()
+}
+
+func (api *ApiController) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		api.background.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// unsubscribeExternalRefs unsubscribes deleted cells from external cells
+func (api *ApiController) unsubscribeExternalRefs(canonicalSheetId string, externalRefSubscriptions contracts.ExternalRefSubscriptions) {
+	for canonicalCellId, externalRefs := range externalRefSubscriptions {
+		webhookUrl := api.makeExternalRefWebhookUrl(canonicalSheetId, canonicalCellId)
+		for _, externalRef := range externalRefs {
+			api.sendUnsubscribeRequest(externalRef, webhookUrl)
+		}
+	}
+}
+
+// makeExternalRefWebhookUrl canonical ids keep the url the same regardless of case of requested ids
+func (api *ApiController) makeExternalRefWebhookUrl(canonicalSheetId string, canonicalCellId string) string {
+	return "http://" + api.Hostname + "/api/" + ApiVersion + "/" +
+		url.PathEscape(canonicalSheetId) + "/" + url.PathEscape(canonicalCellId) + "/" + externalRefWebhookPath
+}
+
+func (api *ApiController) sendSubscribeRequest(externalRef string, webhookUrl string) {
+	payload, _ := json.Marshal(WebhookConfig{
+		WebhookUrl:  webhookUrl,
+		Description: "external_ref of " + api.Hostname,
+	})
+
+	externalRefSubscribeEndpoint := strings.TrimSuffix(externalRef, "/") + "/" + subscribePath
+	request, err := http.NewRequest(http.MethodPost, externalRefSubscribeEndpoint, bytes.NewReader(payload))
+	if err != nil {
+		logger.Println("failed to subscribe:", err)
+		return
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := api.OutboundClient.Do(request)
+	if err != nil {
+		logger.Println("failed to subscribe:", err)
+		return
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusCreated {
+		logger.Println("failed to create subscribe:", response.Status)
+	} else {
+		logger.Printf("subscribed to %s (webhook %s)", externalRefSubscribeEndpoint, webhookUrl)
+	}
+}
+
+func (api *ApiController) sendUnsubscribeRequest(externalRef string, webhookUrl string) {
+	externalRefSubscriptionsEndpoint := strings.TrimSuffix(externalRef, "/") + "/" + subscriptionsPath
+	request, err := http.NewRequest(http.MethodDelete, externalRefSubscriptionsEndpoint+"?webhook_url="+url.QueryEscape(webhookUrl), nil)
+	if err != nil {
+		logger.Println("failed to unsubscribe:", err)
 		return
 	}
 
-	webhookUrl := "http://" + api.Hostname + "/api/" + ApiVersion + "/" + params.SheetId + "/" + params.CellId + "/" + externalRefWebhookPath
+	response, err := api.OutboundClient.Do(request)
+	if err != nil {
+		logger.Println("failed to unsubscribe:", err)
+		return
+	}
+	defer response.Body.Close()
 
-	webhookConfig := WebhookConfig{
-		WebhookUrl: webhookUrl,
+	// subscription could be already removed together with external cell
+	if response.StatusCode != http.StatusNoContent && response.StatusCode != http.StatusNotFound {
+		logger.Println("failed to unsubscribe:", response.Status)
+	}
+}
+
+func (api *ApiController) GetExternalRefSubscriptionsAction(c *gin.Context) {
+	params := CellEndpointParams{}
+
+	err := c.ShouldBindUri(&params)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
-	payload, _ := json.Marshal(webhookConfig)
 
-	client := http.Client{
-		Timeout: time.Second * 4,
+	externalRefs, err := api.SheetRepository.GetExternalRefSubscriptions(params.SheetId, params.CellId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	} else {
+		c.JSON(http.StatusOK, gin.H{"external_refs": externalRefs})
 	}
-	for _, externalRef := range externalsRefs {
-		externalRefSubscribeEndpoint := strings.TrimSuffix(externalRef, "/") + "/" + subscribePath
-		response, err := client.Post(externalRefSubscribeEndpoint, "application/json", bytes.NewReader(payload))
-		if err != nil {
-			fmt.Println("failed to subscribe:", err)
-		} else if response.StatusCode != http.StatusCreated {
-			fmt.Println("failed to create subscribe:", response.Status)
-		} else {
-			fmt.Printf("subscribed to %s (webhook %s)\n", externalRefSubscribeEndpoint, webhookUrl)
+}
+
+// validateUrls rejects urls of outgoing requests which are not allowed by egress policy
+func (api *ApiController) validateUrls(urls []string) error {
+	for _, url := range urls {
+		if err := api.EgressPolicy.ValidateUrl(url); err != nil {
+			return err
 		}
 	}
 
+	return nil
+}
+
+// validateWebhookOptions checks debounce window and that condition of the subscription is a valid boolean expression
+func (api *ApiController) validateWebhookOptions(options *contracts.WebhookOptions) error {
+	if options.Debounce() > MaxWebhookDebounce {
+		return fmt.Errorf("debounce_ms is longer than %d", MaxWebhookDebounce.Milliseconds())
+	}
+
+	if options.Condition == "" {
+		return nil
+	}
+
+	if len(options.Condition) > MaxWebhookConditionLength {
+		return fmt.Errorf("condition is longer than %d characters", MaxWebhookConditionLength)
+	}
+
+	return api.Executor.ValidateCondition(options.Condition)
 }
 
 func (api *ApiController) ExternalRefWebhookAction(c *gin.Context) {
@@ -205,15 +912,200 @@ func (api *ApiController) ExternalRefWebhookAction(c *gin.Context) {
 		return
 	}
 
+	// only peer instances with shared secret can force recalculation
+	body, err := c.GetRawData()
+	if err == nil {
+		err = api.WebhookSigner.Verify(c.Request, body)
+	}
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
 	cell, _ := api.SheetRepository.GetCell(params.SheetId, params.CellId)
+	externalRefs := api.Executor.ExtractExternalRefs(cell.Value)
 
-	response, err, _ = api.SheetRepository.SetCell(params.SheetId, params.CellId, cell.Value, false)
+	// the change has already passed through this cell (or passed too many cells): external_ref chain is circular
+	origin := contracts.ChangeOrigin{Trace: parseChangeTrace(c.GetHeader(ChangeTraceHeader))}
+	originId := api.Hostname + "/" + api.SheetRepository.GetCanonicalSheetId(params.SheetId) + "/" + cell.CanonicalKey
+	if origin.Contains(originId) || len(origin.Trace) >= MaxChangeTraceLength {
+		circularErr := fmt.Errorf("%w: %s", ExternalRefCircularError, strings.Join(append(origin.Trace, originId), " -> "))
+		for _, externalRef := range externalRefs {
+			api.ExternalRefFetcher.MarkCircular(externalRef, circularErr)
+		}
+	} else {
+		// external cell is changed, so cached results are outdated. Fresh result is fetched in background.
+		origin.Trace = append(origin.Trace, originId)
+		for _, externalRef := range externalRefs {
+			api.ExternalRefFetcher.Invalidate(externalRef, origin)
+		}
+	}
+	api.ExternalRefFetcher.WatchCell(params.SheetId, params.CellId, externalRefs, api.Executor.ExtractExternalJsonUrls(cell.Value))
+
+	response, err = api.SheetRepository.RecalculateCell(params.SheetId, params.CellId, origin)
 
 	if errors.Is(err, contracts.CellNotFoundError) || errors.Is(err, contracts.SheetNotFoundError) {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	} else if errors.Is(err, ExternalRefCircularError) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
 	} else if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 	} else {
 		c.JSON(http.StatusOK, response)
 	}
 }
+
+func (api *ApiController) DeleteCellAction(c *gin.Context) {
+	params := CellEndpointParams{}
+
+	err := c.ShouldBindUri(&params)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	externalRefSubscriptions, err := api.SheetRepository.DeleteCell(params.SheetId, params.CellId)
+
+	if errors.Is(err, contracts.CellNotFoundError) || errors.Is(err, contracts.SheetNotFoundError) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	} else {
+		api.ExternalRefFetcher.WatchCell(params.SheetId, params.CellId, []string{}, []string{})
+		canonicalSheetId := api.SheetRepository.GetCanonicalSheetId(params.SheetId)
+		api.goBackground(func() {
+			api.unsubscribeExternalRefs(canonicalSheetId, externalRefSubscriptions)
+		})
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func (api *ApiController) DeleteSheetAction(c *gin.Context) {
+	params := SheetEndpointParams{}
+
+	err := c.ShouldBindUri(&params)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	externalRefSubscriptions, err := api.SheetRepository.DeleteSheet(params.SheetId)
+
+	if errors.Is(err, contracts.SheetNotFoundError) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	} else {
+		api.ExternalRefFetcher.UnwatchSheet(params.SheetId)
+		canonicalSheetId := api.SheetRepository.GetCanonicalSheetId(params.SheetId)
+		api.goBackground(func() {
+			api.unsubscribeExternalRefs(canonicalSheetId, externalRefSubscriptions)
+		})
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func (api *ApiController) StatusAction(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"circuit_breakers": api.OutboundClient.CircuitBreakers(),
+	})
+}
+
+func (api *ApiController) GetDeadLettersAction(c *gin.Context) {
+	deadLetters, err := api.WebhookDispatcher.GetDeadLetters()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	} else {
+		c.JSON(http.StatusOK, gin.H{"dead_letters": deadLetters})
+	}
+}
+
+func (api *ApiController) ReplayDeadLetterAction(c *gin.Context) {
+	params := DeadLetterEndpointParams{}
+
+	err := c.ShouldBindUri(&params)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = api.WebhookDispatcher.ReplayDeadLetter(params.DeadLetterId)
+
+	if errors.Is(err, contracts.WebhookDeadLetterNotFoundError) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	} else {
+		c.Status(http.StatusAccepted)
+	}
+}
+
+func (api *ApiController) DeleteDeadLetterAction(c *gin.Context) {
+	params := DeadLetterEndpointParams{}
+
+	err := c.ShouldBindUri(&params)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = api.WebhookDispatcher.DeleteDeadLetter(params.DeadLetterId)
+
+	if errors.Is(err, contracts.WebhookDeadLetterNotFoundError) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	} else {
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func (api *ApiController) GetSettingsAction(c *gin.Context) {
+	params := SheetEndpointParams{}
+
+	err := c.ShouldBindUri(&params)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	settings, err := api.SheetRepository.GetSettings(params.SheetId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	} else {
+		c.JSON(http.StatusOK, settings)
+	}
+}
+
+func (api *ApiController) SetSettingsAction(c *gin.Context) {
+	params := SheetEndpointParams{}
+	request := SheetSettingsRequest{}
+
+	err := c.ShouldBindUri(&params)
+	if err == nil {
+		err = c.ShouldBindJSON(&request)
+	}
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	settings := contracts.NewSheetSettings()
+	settings.Iterative = request.Iterative
+	if request.MaxIterations != 0 {
+		settings.MaxIterations = request.MaxIterations
+	}
+	if request.Epsilon != 0 {
+		settings.Epsilon = request.Epsilon
+	}
+
+	response, err := api.SheetRepository.SetSettings(params.SheetId, settings)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	} else {
+		c.JSON(http.StatusCreated, response)
+	}
+}
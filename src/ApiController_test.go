@@ -1,16 +1,24 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"devChallengeExcel/contracts"
 	"devChallengeExcel/mocks"
 	"errors"
+	"fmt"
 	json "github.com/bytedance/sonic"
+	"github.com/gin-contrib/sse"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestApiController_GetCellAction(t *testing.T) {
@@ -33,7 +41,7 @@ func TestApiController_GetCellAction(t *testing.T) {
 				Result: "value1",
 			}, nil)
 
-		apiController := NewApiController(sheetRepository, nil, nil)
+		apiController := NewApiController(sheetRepository, nil, nil, nil, nil, nil, nil, nil)
 
 		w := requestToGetCellAction(apiController)
 		response, err := _parseJsonBody(w)
@@ -46,11 +54,33 @@ func TestApiController_GetCellAction(t *testing.T) {
 		assert.Equal(t, response["result"], "value1")
 	})
 
+	t.Run("not modified", func(t *testing.T) {
+		cell := &contracts.Cell{Value: "value1", Result: "value1"}
+		sheetRepository := mocks.NewSheetRepository(t)
+		sheetRepository.On("GetCell", "sheet1", "cell1").Return(cell, nil)
+
+		router := SetupRouter(NewApiController(sheetRepository, nil, nil, nil, nil, nil, nil, nil))
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/api/"+ApiVersion+"/sheet1/cell1", nil)
+		req.Header.Set("If-None-Match", makeCellETag(cell))
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotModified, w.Code)
+		assert.Empty(t, w.Body.String())
+		assert.Equal(t, makeCellETag(cell), w.Header().Get("ETag"))
+
+		w = requestToGetCellAction(NewApiController(sheetRepository, nil, nil, nil, nil, nil, nil, nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, makeCellETag(cell), w.Header().Get("ETag"))
+		assert.NotEqual(t, makeCellETag(cell), makeCellETag(&contracts.Cell{Value: "value1", Result: "value2"}))
+	})
+
 	t.Run("cell not found", func(t *testing.T) {
 		sheetRepository := mocks.NewSheetRepository(t)
 		sheetRepository.On("GetCell", "sheet1", "cell1").Return(nil, contracts.CellNotFoundError)
 
-		apiController := NewApiController(sheetRepository, nil, nil)
+		apiController := NewApiController(sheetRepository, nil, nil, nil, nil, nil, nil, nil)
 
 		w := requestToGetCellAction(apiController)
 		response, err := _parseJsonBody(w)
@@ -65,7 +95,7 @@ func TestApiController_GetCellAction(t *testing.T) {
 		sheetRepository := mocks.NewSheetRepository(t)
 		sheetRepository.On("GetCell", "sheet1", "cell1").Return(nil, contracts.SheetNotFoundError)
 
-		apiController := NewApiController(sheetRepository, nil, nil)
+		apiController := NewApiController(sheetRepository, nil, nil, nil, nil, nil, nil, nil)
 
 		w := requestToGetCellAction(apiController)
 		response, err := _parseJsonBody(w)
@@ -80,7 +110,7 @@ func TestApiController_GetCellAction(t *testing.T) {
 		sheetRepository := mocks.NewSheetRepository(t)
 		sheetRepository.On("GetCell", "sheet1", "cell1").Return(nil, errors.New("test"))
 
-		apiController := NewApiController(sheetRepository, nil, nil)
+		apiController := NewApiController(sheetRepository, nil, nil, nil, nil, nil, nil, nil)
 
 		w := requestToGetCellAction(apiController)
 
@@ -113,7 +143,11 @@ func TestApiController_SetCellAction(t *testing.T) {
 		sheetRepository.On("SetCell", "sheet1", "cell1", "value1", true).
 			Return(&contracts.Cell{Value: "value1"}, nil, false)
 
-		apiController := NewApiController(sheetRepository, nil, nil)
+		executor := mocks.NewExpressionExecutor(t)
+		executor.On("ExtractExternalRefs", "value1").Return([]string{})
+		executor.On("ExtractExternalJsonUrls", "value1").Return([]string{})
+
+		apiController := NewApiController(sheetRepository, nil, executor, nil, nil, nil, nil, nil)
 
 		w := requestToSetCellAction(apiController, map[string]string{"value": "value1"})
 		response, err := _parseJsonBody(w)
@@ -124,12 +158,37 @@ func TestApiController_SetCellAction(t *testing.T) {
 		assert.Equal(t, response["value"], "value1")
 	})
 
+	t.Run("success update", func(t *testing.T) {
+		sheetRepository := mocks.NewSheetRepository(t)
+		sheetRepository.On("SetCell", "sheet1", "cell1", "value1", true).
+			Return(&contracts.Cell{Value: "value1"}, nil, true)
+		// subscriptions are updated in background
+		sheetRepository.On("SetExternalRefSubscriptions", "sheet1", "cell1", []string{}).Return([]string{}, nil).Maybe()
+		sheetRepository.On("GetCanonicalSheetId", "sheet1").Return("sheet1").Maybe()
+
+		executor := mocks.NewExpressionExecutor(t)
+		executor.On("ExtractExternalRefs", "value1").Return([]string{})
+		executor.On("ExtractExternalJsonUrls", "value1").Return([]string{})
+
+		fetcher := mocks.NewExternalRefFetcher(t)
+		fetcher.On("WatchCell", "sheet1", "cell1", []string{}, []string{}).Return().Once()
+
+		apiController := NewApiController(sheetRepository, nil, executor, fetcher, nil, nil, nil, nil)
+
+		w := requestToSetCellAction(apiController, map[string]string{"value": "value1"})
+		assert.Equal(t, http.StatusCreated, w.Code)
+	})
+
 	t.Run("error", func(t *testing.T) {
 		sheetRepository := mocks.NewSheetRepository(t)
 		sheetRepository.On("SetCell", "sheet1", "cell1", "value1", true).
 			Return(nil, errors.New("test"), false)
 
-		apiController := NewApiController(sheetRepository, nil, nil)
+		executor := mocks.NewExpressionExecutor(t)
+		executor.On("ExtractExternalRefs", "value1").Return([]string{})
+		executor.On("ExtractExternalJsonUrls", "value1").Return([]string{})
+
+		apiController := NewApiController(sheetRepository, nil, executor, nil, nil, nil, nil, nil)
 
 		w := requestToSetCellAction(apiController, map[string]string{"value": "value1"})
 		response, err := _parseJsonBody(w)
@@ -142,6 +201,489 @@ func TestApiController_SetCellAction(t *testing.T) {
 		assert.Equal(t, response["result"], "test")
 	})
 
+	t.Run("egress_policy_violation", func(t *testing.T) {
+		egressPolicy, _ := NewEgressPolicy(EgressPolicyConfig{
+			AllowedSchemes: DefaultEgressAllowedSchemes,
+			DeniedCidrs:    DefaultEgressDeniedCidrs,
+		})
+
+		deniedUrl := "http://169.254.169.254/latest"
+		for value, urls := range map[string][2][]string{
+			`=external_ref("` + deniedUrl + `")`:         {{deniedUrl}, {}},
+			`=external_json("` + deniedUrl + `", "$.a")`: {{}, {deniedUrl}},
+		} {
+			executor := mocks.NewExpressionExecutor(t)
+			executor.On("ExtractExternalRefs", value).Return(urls[0])
+			executor.On("ExtractExternalJsonUrls", value).Return(urls[1]).Maybe()
+
+			apiController := NewApiController(mocks.NewSheetRepository(t), nil, executor, nil, egressPolicy, nil, nil, nil)
+
+			w := requestToSetCellAction(apiController, map[string]string{"value": value})
+			response, err := _parseJsonBody(w)
+
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, response["error"], EgressPolicyError.Error())
+		}
+	})
+}
+
+func TestApiController_SubscribeAction(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	request := func(apiController contracts.ApiController, webhookUrl string) *httptest.ResponseRecorder {
+		router := SetupRouter(apiController)
+
+		w := httptest.NewRecorder()
+		body := bytes.NewReader([]byte(`{"webhook_url": "` + webhookUrl + `", "description": "test"}`))
+		req, _ := http.NewRequest(http.MethodPost, "/api/"+ApiVersion+"/sheet1/cell1/"+subscribePath, body)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("success", func(t *testing.T) {
+		webhookUrl := "http://10.0.0.1/webhook"
+		createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+		sheetRepository := mocks.NewSheetRepository(t)
+		sheetRepository.On("GetCell", "sheet1", "cell1").Return(&contracts.Cell{CanonicalKey: "cell1"}, nil)
+		sheetRepository.On("GetCanonicalSheetId", "sheet1").Return("sheet1")
+
+		webhookDispatcher := mocks.NewWebhookDispatcher(t)
+		webhookDispatcher.On("Subscribe", "sheet1", "cell1", webhookUrl, "test", contracts.WebhookOptions{}).Return(&contracts.WebhookSubscription{
+			Id: "id1", WebhookUrl: webhookUrl, Description: "test", CreatedAt: createdAt,
+		}, nil).Once()
+
+		egressPolicy := mocks.NewEgressPolicy(t)
+		egressPolicy.On("ValidateUrl", webhookUrl).Return(nil)
+
+		w := request(NewApiController(sheetRepository, webhookDispatcher, nil, nil, egressPolicy, nil, nil, nil), webhookUrl)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.JSONEq(t, `{
+			"id": "id1", "webhook_url": "http://10.0.0.1/webhook", "description": "test",
+			"always_notify": false, "sequence": 0, "created_at": "2024-01-02T03:04:05Z", "last_delivery": null
+		}`, w.Body.String())
+	})
+
+	t.Run("empty_url", func(t *testing.T) {
+		w := request(NewApiController(mocks.NewSheetRepository(t), nil, nil, nil, nil, nil, nil, nil), "")
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("not_found", func(t *testing.T) {
+		sheetRepository := mocks.NewSheetRepository(t)
+		sheetRepository.On("GetCell", "sheet1", "cell1").Return(&contracts.Cell{}, contracts.CellNotFoundError)
+
+		egressPolicy := mocks.NewEgressPolicy(t)
+		egressPolicy.On("ValidateUrl", mock.Anything).Return(nil)
+
+		w := request(NewApiController(sheetRepository, nil, nil, nil, egressPolicy, nil, nil, nil), "http://10.0.0.1/webhook")
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("storage_error", func(t *testing.T) {
+		sheetRepository := mocks.NewSheetRepository(t)
+		sheetRepository.On("GetCell", "sheet1", "cell1").Return(&contracts.Cell{CanonicalKey: "cell1"}, nil)
+		sheetRepository.On("GetCanonicalSheetId", "sheet1").Return("sheet1")
+
+		webhookDispatcher := mocks.NewWebhookDispatcher(t)
+		webhookDispatcher.On("Subscribe", "sheet1", "cell1", mock.Anything, "test", contracts.WebhookOptions{}).Return(nil, errors.New("test")).Once()
+
+		egressPolicy := mocks.NewEgressPolicy(t)
+		egressPolicy.On("ValidateUrl", mock.Anything).Return(nil)
+
+		w := request(NewApiController(sheetRepository, webhookDispatcher, nil, nil, egressPolicy, nil, nil, nil), "http://10.0.0.1/webhook")
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+
+	t.Run("egress_policy_violation", func(t *testing.T) {
+		egressPolicy, _ := NewEgressPolicy(EgressPolicyConfig{
+			AllowedSchemes: DefaultEgressAllowedSchemes,
+			DeniedCidrs:    DefaultEgressDeniedCidrs,
+		})
+
+		for _, webhookUrl := range []string{"http://localhost:8080/webhook", "file:///etc/passwd", "http://[::1]/webhook"} {
+			w := request(NewApiController(mocks.NewSheetRepository(t), nil, nil, nil, egressPolicy, nil, nil, nil), webhookUrl)
+			response, err := _parseJsonBody(w)
+
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusBadRequest, w.Code, webhookUrl)
+			assert.Contains(t, response["error"], EgressPolicyError.Error())
+		}
+	})
+}
+
+func TestApiController_GetSubscriptionsAction(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	request := func(apiController contracts.ApiController) *httptest.ResponseRecorder {
+		router := SetupRouter(apiController)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/api/"+ApiVersion+"/Sheet1/Cell1/"+subscriptionsPath, nil)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("success", func(t *testing.T) {
+		sheetRepository := mocks.NewSheetRepository(t)
+		// evaluation error doesn't prevent subscriptions management
+		sheetRepository.On("GetCell", "Sheet1", "Cell1").Return(&contracts.Cell{CanonicalKey: "cell1", Value: "=1/"}, errors.New("test"))
+		sheetRepository.On("GetCanonicalSheetId", "Sheet1").Return("sheet1")
+
+		webhookDispatcher := mocks.NewWebhookDispatcher(t)
+		webhookDispatcher.On("GetSubscriptions", "sheet1", "cell1").Return([]contracts.WebhookSubscription{{
+			Id:         "id1",
+			WebhookUrl: "http://remote/webhook",
+			CreatedAt:  time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+			LastDelivery: &contracts.WebhookDelivery{
+				DeliveredAt:   time.Date(2024, 1, 2, 3, 5, 0, 0, time.UTC),
+				StatusCode:    http.StatusBadGateway,
+				Error:         "unexpected response status: 502 Bad Gateway",
+				FailureStreak: 3,
+			},
+		}})
+
+		w := request(NewApiController(sheetRepository, webhookDispatcher, nil, nil, nil, nil, nil, nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"subscriptions": [{
+			"id": "id1", "webhook_url": "http://remote/webhook", "description": "", "always_notify": false, "sequence": 0, "created_at": "2024-01-02T03:04:05Z",
+			"last_delivery": {
+				"delivered_at": "2024-01-02T03:05:00Z", "success": false, "status_code": 502,
+				"error": "unexpected response status: 502 Bad Gateway", "success_streak": 0, "failure_streak": 3
+			}
+		}]}`, w.Body.String())
+	})
+
+	t.Run("not_found", func(t *testing.T) {
+		sheetRepository := mocks.NewSheetRepository(t)
+		sheetRepository.On("GetCell", "Sheet1", "Cell1").Return(&contracts.Cell{}, contracts.SheetNotFoundError)
+
+		w := request(NewApiController(sheetRepository, nil, nil, nil, nil, nil, nil, nil))
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		sheetRepository := mocks.NewSheetRepository(t)
+		sheetRepository.On("GetCell", "Sheet1", "Cell1").Return(&contracts.Cell{}, errors.New("test"))
+
+		w := request(NewApiController(sheetRepository, nil, nil, nil, nil, nil, nil, nil))
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+}
+
+func TestApiController_UnsubscribeAction(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	request := func(apiController contracts.ApiController, path string) *httptest.ResponseRecorder {
+		router := SetupRouter(apiController)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodDelete, "/api/"+ApiVersion+"/sheet1/cell1/"+subscriptionsPath+path, nil)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	newSheetRepository := func(t *testing.T) *mocks.SheetRepository {
+		sheetRepository := mocks.NewSheetRepository(t)
+		sheetRepository.On("GetCell", "sheet1", "cell1").Return(&contracts.Cell{CanonicalKey: "cell1"}, nil)
+		sheetRepository.On("GetCanonicalSheetId", "sheet1").Return("sheet1")
+		return sheetRepository
+	}
+
+	t.Run("by_id", func(t *testing.T) {
+		webhookDispatcher := mocks.NewWebhookDispatcher(t)
+		webhookDispatcher.On("Unsubscribe", "sheet1", "cell1", "id1").Return(nil).Once()
+		webhookDispatcher.On("Unsubscribe", "sheet1", "cell1", "id2").Return(contracts.WebhookSubscriptionNotFoundError).Once()
+		webhookDispatcher.On("Unsubscribe", "sheet1", "cell1", "id3").Return(errors.New("test")).Once()
+
+		apiController := NewApiController(newSheetRepository(t), webhookDispatcher, nil, nil, nil, nil, nil, nil)
+		assert.Equal(t, http.StatusNoContent, request(apiController, "/id1").Code)
+		assert.Equal(t, http.StatusNotFound, request(apiController, "/id2").Code)
+		assert.Equal(t, http.StatusInternalServerError, request(apiController, "/id3").Code)
+	})
+
+	t.Run("by_url", func(t *testing.T) {
+		webhookDispatcher := mocks.NewWebhookDispatcher(t)
+		webhookDispatcher.On("UnsubscribeUrl", "sheet1", "cell1", "http://remote/webhook?a=1").Return(nil).Once()
+
+		apiController := NewApiController(newSheetRepository(t), webhookDispatcher, nil, nil, nil, nil, nil, nil)
+		assert.Equal(t, http.StatusNoContent, request(apiController, "?webhook_url=http%3A%2F%2Fremote%2Fwebhook%3Fa%3D1").Code)
+	})
+
+	t.Run("validation", func(t *testing.T) {
+		apiController := NewApiController(mocks.NewSheetRepository(t), nil, nil, nil, nil, nil, nil, nil)
+		assert.Equal(t, http.StatusBadRequest, request(apiController, "").Code)
+	})
+}
+
+func TestApiController_SheetSubscriptionActions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	request := func(apiController contracts.ApiController, method string, path string, body string) *httptest.ResponseRecorder {
+		router := SetupRouter(apiController)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, "/api/"+ApiVersion+"/Sheet1/"+path, bytes.NewReader([]byte(body)))
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	newSheetRepository := func(t *testing.T) *mocks.SheetRepository {
+		sheetRepository := mocks.NewSheetRepository(t)
+		sheetRepository.On("GetCanonicalSheetId", "Sheet1").Return("sheet1").Maybe()
+		sheetRepository.On("GetCanonicalCellId", mock.Anything).Return(strings.ToLower).Maybe()
+		return sheetRepository
+	}
+
+	newEgressPolicy := func(t *testing.T) *mocks.EgressPolicy {
+		egressPolicy := mocks.NewEgressPolicy(t)
+		egressPolicy.On("ValidateUrl", "http://10.0.0.1/webhook").Return(nil).Maybe()
+		return egressPolicy
+	}
+
+	t.Run("subscribe", func(t *testing.T) {
+		createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		webhookDispatcher := mocks.NewWebhookDispatcher(t)
+		webhookDispatcher.On("SubscribeSheet", "sheet1", "http://10.0.0.1/webhook", "test", contracts.WebhookScope{Cells: []string{"a1", "price"}}, contracts.WebhookOptions{}).
+			Return(&contracts.WebhookSubscription{
+				Id: "id1", WebhookUrl: "http://10.0.0.1/webhook", Description: "test", CreatedAt: createdAt,
+				WebhookScope: contracts.WebhookScope{Cells: []string{"a1", "price"}},
+			}, nil).Once()
+		webhookDispatcher.On("SubscribeSheet", "sheet1", "http://10.0.0.1/webhook", "", contracts.WebhookScope{Range: "A1:C10"}, contracts.WebhookOptions{AlwaysNotify: true, Condition: "new > old", DebounceMs: 500}).
+			Return(&contracts.WebhookSubscription{Id: "id2"}, nil).Once()
+		webhookDispatcher.On("SubscribeSheet", "sheet1", "http://10.0.0.1/webhook", "", contracts.WebhookScope{}, contracts.WebhookOptions{}).
+			Return(nil, errors.New("test")).Once()
+
+		apiController := NewApiController(
+			newSheetRepository(t), webhookDispatcher, NewExpressionExecutor(NewCanonicalizer()), nil, newEgressPolicy(t), nil, nil, nil,
+		)
+
+		w := request(apiController, http.MethodPost, sheetSubscribePath,
+			`{"webhook_url": "http://10.0.0.1/webhook", "description": "test", "cells": ["A1", "Price", "a1"]}`)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.JSONEq(t, `{
+			"id": "id1", "webhook_url": "http://10.0.0.1/webhook", "description": "test", "cells": ["a1", "price"],
+			"always_notify": false, "sequence": 0, "created_at": "2024-01-02T03:04:05Z", "last_delivery": null
+		}`, w.Body.String())
+
+		w = request(apiController, http.MethodPost, sheetSubscribePath, `{"webhook_url": "http://10.0.0.1/webhook", "range": " a1:c10", "always_notify": true, "condition": "new > old", "debounce_ms": 500}`)
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		w = request(apiController, http.MethodPost, sheetSubscribePath, `{"webhook_url": "http://10.0.0.1/webhook"}`)
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+
+	t.Run("subscribe_validation", func(t *testing.T) {
+		apiController := NewApiController(
+			newSheetRepository(t), nil, NewExpressionExecutor(NewCanonicalizer()), nil, newEgressPolicy(t), nil, nil, nil,
+		)
+
+		for _, body := range []string{
+			`{}`,
+			`{"webhook_url": "http://10.0.0.1/webhook", "range": "A1:B"}`,
+			`{"webhook_url": "http://10.0.0.1/webhook", "range": "A1:B2", "cells": ["a1"]}`,
+			`{"webhook_url": "http://10.0.0.1/webhook", "cells": ["a1+b1"]}`,
+			`{"webhook_url": "http://10.0.0.1/webhook", "cells": [""]}`,
+			`{"webhook_url": "http://10.0.0.1/webhook", "debounce_ms": 60001}`,
+			`{"webhook_url": "http://10.0.0.1/webhook", "debounce_ms": -1}`,
+			`{"webhook_url": "http://10.0.0.1/webhook", "condition": "new <"}`,
+			`{"webhook_url": "http://10.0.0.1/webhook", "condition": "new + 1"}`,
+			`{"webhook_url": "http://10.0.0.1/webhook", "condition": "A1 > 1"}`,
+			`{"webhook_url": "http://10.0.0.1/webhook", "condition": "` + strings.Repeat("new > 1 || ", 100) + `false"}`,
+		} {
+			assert.Equal(t, http.StatusBadRequest, request(apiController, http.MethodPost, sheetSubscribePath, body).Code, body)
+		}
+	})
+
+	t.Run("list", func(t *testing.T) {
+		webhookDispatcher := mocks.NewWebhookDispatcher(t)
+		webhookDispatcher.On("GetSheetSubscriptions", "sheet1").Return([]contracts.WebhookSubscription{{
+			Id: "id1", WebhookUrl: "http://remote/webhook", CreatedAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+			WebhookScope: contracts.WebhookScope{Range: "A1:C10"},
+		}})
+
+		w := request(NewApiController(newSheetRepository(t), webhookDispatcher, nil, nil, nil, nil, nil, nil), http.MethodGet, sheetSubscriptionsPath, "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"subscriptions": [{
+			"id": "id1", "webhook_url": "http://remote/webhook", "description": "", "range": "A1:C10",
+			"always_notify": false, "sequence": 0, "created_at": "2024-01-02T03:04:05Z", "last_delivery": null
+		}]}`, w.Body.String())
+	})
+
+	t.Run("unsubscribe", func(t *testing.T) {
+		webhookDispatcher := mocks.NewWebhookDispatcher(t)
+		webhookDispatcher.On("UnsubscribeSheet", "sheet1", "id1").Return(nil).Once()
+		webhookDispatcher.On("UnsubscribeSheet", "sheet1", "id2").Return(contracts.WebhookSubscriptionNotFoundError).Once()
+		webhookDispatcher.On("UnsubscribeSheetUrl", "sheet1", "http://remote/webhook").Return(nil).Once()
+
+		apiController := NewApiController(newSheetRepository(t), webhookDispatcher, nil, nil, nil, nil, nil, nil)
+		assert.Equal(t, http.StatusNoContent, request(apiController, http.MethodDelete, sheetSubscriptionsPath+"/id1", "").Code)
+		assert.Equal(t, http.StatusNotFound, request(apiController, http.MethodDelete, sheetSubscriptionsPath+"/id2", "").Code)
+		assert.Equal(t, http.StatusNoContent, request(apiController, http.MethodDelete, sheetSubscriptionsPath+"?webhook_url=http%3A%2F%2Fremote%2Fwebhook", "").Code)
+		assert.Equal(t, http.StatusBadRequest, request(apiController, http.MethodDelete, sheetSubscriptionsPath, "").Code)
+	})
+}
+
+func TestApiController_DeliveriesActions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	request := func(apiController contracts.ApiController, path string) *httptest.ResponseRecorder {
+		router := SetupRouter(apiController)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/api/"+ApiVersion+"/Sheet1/"+path, nil)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	sheetRepository := mocks.NewSheetRepository(t)
+	sheetRepository.On("GetCanonicalSheetId", "Sheet1").Return("sheet1")
+	sheetRepository.On("GetCanonicalCellId", mock.Anything).Return(strings.ToLower).Maybe()
+
+	attemptedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	deliveryLog := &contracts.WebhookDeliveryLog{
+		Subscriptions: []contracts.WebhookSubscriptionStatus{{
+			Id: "id1", CellId: "a1", WebhookUrl: "http://remote/webhook",
+			LastDelivery: &contracts.WebhookDelivery{DeliveredAt: attemptedAt, StatusCode: http.StatusBadGateway, Error: "test", FailureStreak: 2},
+		}},
+		Deliveries: []contracts.WebhookDeliveryAttempt{{
+			SubscriptionId: "id1", CellId: "a1", WebhookId: "0000000000000001", Attempt: 2, WebhookUrl: "http://remote/webhook",
+			AttemptedAt: attemptedAt, StatusCode: http.StatusBadGateway, LatencyMs: 12, Error: "test", PayloadHash: "abc",
+		}},
+	}
+
+	webhookDispatcher := mocks.NewWebhookDispatcher(t)
+	webhookDispatcher.On("GetDeliveryLog", "sheet1", "a1", "id1", DefaultDeliveryLogLimit).Return(deliveryLog, nil).Once()
+	webhookDispatcher.On("GetDeliveryLog", "sheet1", "a1", "id2", 5).
+		Return(nil, contracts.WebhookSubscriptionNotFoundError).Once()
+	webhookDispatcher.On("GetSheetSubscriptionDeliveryLog", "sheet1", "id3", 10).
+		Return(&contracts.WebhookDeliveryLog{}, nil).Once()
+	webhookDispatcher.On("GetSheetDeliveryLog", "sheet1", DefaultDeliveryLogLimit).Return(nil, errors.New("test")).Once()
+
+	apiController := NewApiController(sheetRepository, webhookDispatcher, nil, nil, nil, nil, nil, nil)
+
+	w := request(apiController, "A1/"+subscriptionsPath+"/id1/"+deliveriesPath)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{
+		"subscriptions": [{
+			"id": "id1", "cell_id": "a1", "webhook_url": "http://remote/webhook",
+			"last_delivery": {
+				"delivered_at": "2024-01-02T03:04:05Z", "success": false, "status_code": 502, "error": "test",
+				"success_streak": 0, "failure_streak": 2
+			}
+		}],
+		"deliveries": [{
+			"subscription_id": "id1", "cell_id": "a1", "webhook_id": "0000000000000001", "attempt": 2,
+			"webhook_url": "http://remote/webhook", "attempted_at": "2024-01-02T03:04:05Z", "success": false,
+			"status_code": 502, "latency_ms": 12, "error": "test", "payload_hash": "abc"
+		}]
+	}`, w.Body.String())
+
+	assert.Equal(t, http.StatusNotFound, request(apiController, "A1/"+subscriptionsPath+"/id2/"+deliveriesPath+"?limit=5").Code)
+	assert.Equal(t, http.StatusOK, request(apiController, sheetSubscriptionsPath+"/id3/"+deliveriesPath+"?limit=10").Code)
+	assert.Equal(t, http.StatusInternalServerError, request(apiController, sheetDeliveriesPath).Code)
+
+	for _, limit := range []string{"0", "1001", "many"} {
+		assert.Equal(t, http.StatusBadRequest, request(apiController, sheetDeliveriesPath+"?limit="+limit).Code, limit)
+	}
+}
+
+func TestApiController_DeleteAction(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	request := func(apiController contracts.ApiController, path string) *httptest.ResponseRecorder {
+		router := SetupRouter(apiController)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodDelete, "/api/"+ApiVersion+path, nil)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("cell", func(t *testing.T) {
+		unsubscribed := make(chan string, 1)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			unsubscribed <- r.Method + " " + r.URL.Path + " " + r.URL.Query().Get("webhook_url")
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer server.Close()
+
+		sheetRepository := mocks.NewSheetRepository(t)
+		sheetRepository.On("DeleteCell", "sheet1", "cell1").Return(contracts.ExternalRefSubscriptions{
+			"cell1": {server.URL + "/api/v1/remote/a1"},
+		}, nil).Once()
+		sheetRepository.On("GetCanonicalSheetId", "sheet1").Return("sheet1")
+
+		fetcher := mocks.NewExternalRefFetcher(t)
+		fetcher.On("WatchCell", "sheet1", "cell1", []string{}, []string{}).Return().Once()
+
+		apiController := NewApiController(sheetRepository, nil, nil, fetcher, nil, _makeLoopbackOutboundClient(t), nil, nil)
+		apiController.Hostname = "api:8080"
+		w := request(apiController, "/sheet1/cell1")
+
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		select {
+		case unsubscribeRequest := <-unsubscribed:
+			assert.Equal(t, "DELETE /api/v1/remote/a1/subscriptions http://api:8080/api/v1/sheet1/cell1/externalRefWebhook", unsubscribeRequest)
+		case <-time.After(time.Second):
+			assert.Fail(t, "external ref is not unsubscribed")
+		}
+	})
+
+	t.Run("sheet", func(t *testing.T) {
+		sheetRepository := mocks.NewSheetRepository(t)
+		sheetRepository.On("DeleteSheet", "sheet1").Return(contracts.ExternalRefSubscriptions{}, nil).Once()
+		sheetRepository.On("GetCanonicalSheetId", "sheet1").Return("sheet1")
+
+		fetcher := mocks.NewExternalRefFetcher(t)
+		fetcher.On("UnwatchSheet", "sheet1").Return().Once()
+
+		w := request(NewApiController(sheetRepository, nil, nil, fetcher, nil, nil, nil, nil), "/sheet1")
+
+		assert.Equal(t, http.StatusNoContent, w.Code)
+	})
+
+	t.Run("not_found", func(t *testing.T) {
+		sheetRepository := mocks.NewSheetRepository(t)
+		sheetRepository.On("DeleteCell", "sheet1", "cell1").Return(nil, contracts.CellNotFoundError).Once()
+		sheetRepository.On("DeleteSheet", "sheet1").Return(nil, contracts.SheetNotFoundError).Once()
+
+		apiController := NewApiController(sheetRepository, nil, nil, nil, nil, nil, nil, nil)
+		assert.Equal(t, http.StatusNotFound, request(apiController, "/sheet1/cell1").Code)
+		assert.Equal(t, http.StatusNotFound, request(apiController, "/sheet1").Code)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		sheetRepository := mocks.NewSheetRepository(t)
+		sheetRepository.On("DeleteCell", "sheet1", "cell1").Return(nil, errors.New("test")).Once()
+		sheetRepository.On("DeleteSheet", "sheet1").Return(nil, errors.New("test")).Once()
+
+		apiController := NewApiController(sheetRepository, nil, nil, nil, nil, nil, nil, nil)
+		assert.Equal(t, http.StatusInternalServerError, request(apiController, "/sheet1/cell1").Code)
+		assert.Equal(t, http.StatusInternalServerError, request(apiController, "/sheet1").Code)
+	})
+
+	t.Run("reserved_sheet_id", func(t *testing.T) {
+		// internal buckets are not reachable as sheets
+		apiController := NewApiController(mocks.NewSheetRepository(t), nil, nil, nil, nil, nil, nil, nil)
+		assert.Equal(t, http.StatusBadRequest, request(apiController, "/__webhooks").Code)
+		assert.Equal(t, http.StatusBadRequest, request(apiController, "/__settings/cell1").Code)
+
+		router := SetupRouter(apiController)
+		w := httptest.NewRecorder()
+		body, _ := json.Marshal(map[string]string{"value": "1"})
+		req, _ := http.NewRequest(http.MethodPost, "/api/"+ApiVersion+"/__webhooks/x", bytes.NewReader(body))
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
 }
 
 func TestApiController_GetSheetAction(t *testing.T) {
@@ -171,7 +713,7 @@ func TestApiController_GetSheetAction(t *testing.T) {
 		sheetRepository := mocks.NewSheetRepository(t)
 		sheetRepository.On("GetCellList", "sheet1").Return(list, nil)
 
-		apiController := NewApiController(sheetRepository, nil, nil)
+		apiController := NewApiController(sheetRepository, nil, nil, nil, nil, nil, nil, nil)
 
 		w := requestToGetSheetAction(apiController)
 		response, err := _parseJsonBody(w)
@@ -195,7 +737,7 @@ func TestApiController_GetSheetAction(t *testing.T) {
 		sheetRepository := mocks.NewSheetRepository(t)
 		sheetRepository.On("GetCellList", "sheet1").Return(nil, contracts.SheetNotFoundError)
 
-		apiController := NewApiController(sheetRepository, nil, nil)
+		apiController := NewApiController(sheetRepository, nil, nil, nil, nil, nil, nil, nil)
 
 		w := requestToGetSheetAction(apiController)
 		response, err := _parseJsonBody(w)
@@ -211,7 +753,7 @@ func TestApiController_GetSheetAction(t *testing.T) {
 		sheetRepository := mocks.NewSheetRepository(t)
 		sheetRepository.On("GetCellList", "sheet1").Return(nil, errors.New("test"))
 
-		apiController := NewApiController(sheetRepository, nil, nil)
+		apiController := NewApiController(sheetRepository, nil, nil, nil, nil, nil, nil, nil)
 
 		w := requestToGetSheetAction(apiController)
 		response, err := _parseJsonBody(w)
@@ -224,7 +766,699 @@ func TestApiController_GetSheetAction(t *testing.T) {
 	})
 }
 
-func _parseJsonBody(w *httptest.ResponseRecorder) (response map[string]any, err error) {
-	err = json.Unmarshal(w.Body.Bytes(), &response)
-	return
+func TestApiController_GetSheetAtAction(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	request := func(apiController contracts.ApiController, query string) *httptest.ResponseRecorder {
+		router := SetupRouter(apiController)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/api/"+ApiVersion+"/sheet1?"+query, nil)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("success", func(t *testing.T) {
+		at := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+		list := &contracts.CellList{"cell1": {Value: "=1+1", Result: "2"}}
+
+		sheetRepository := mocks.NewSheetRepository(t)
+		sheetRepository.On("GetCellListAt", "sheet1", mock.MatchedBy(at.Equal)).Return(list, nil)
+
+		w := request(NewApiController(sheetRepository, nil, nil, nil, nil, nil, nil, nil), "at=2024-05-01T12:00:00%2B02:00")
+		response, err := _parseJsonBody(w)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, map[string]any{"value": "=1+1", "result": "2"}, response["cell1"])
+	})
+
+	t.Run("invalid_at", func(t *testing.T) {
+		w := request(NewApiController(mocks.NewSheetRepository(t), nil, nil, nil, nil, nil, nil, nil), "at=yesterday")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("not_found_sheet", func(t *testing.T) {
+		sheetRepository := mocks.NewSheetRepository(t)
+		sheetRepository.On("GetCellListAt", "sheet1", mock.Anything).Return(nil, contracts.SheetNotFoundError)
+
+		w := request(NewApiController(sheetRepository, nil, nil, nil, nil, nil, nil, nil), "at=2024-05-01T10:00:00Z")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestApiController_GetCellHistoryAction(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	request := func(apiController contracts.ApiController, query string) *httptest.ResponseRecorder {
+		router := SetupRouter(apiController)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/api/"+ApiVersion+"/sheet1/cell1/"+historyPath+query, nil)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("success", func(t *testing.T) {
+		timestamp := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+		versions := []contracts.CellVersion{
+			{Version: 2, CellId: "cell1", Deleted: true, Source: contracts.ChangeCauseDirectEdit, Timestamp: timestamp},
+			{Version: 1, CellId: "cell1", Value: "=1+1", Result: "2", Source: contracts.ChangeCauseDirectEdit, Timestamp: timestamp},
+		}
+
+		sheetRepository := mocks.NewSheetRepository(t)
+		sheetRepository.On("GetCellHistory", "sheet1", "cell1", DefaultHistoryLimit).Return(versions, nil)
+
+		w := request(NewApiController(sheetRepository, nil, nil, nil, nil, nil, nil, nil), "")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		response := make([]contracts.CellVersion, 0)
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, versions, response)
+	})
+
+	t.Run("limit", func(t *testing.T) {
+		sheetRepository := mocks.NewSheetRepository(t)
+		sheetRepository.On("GetCellHistory", "sheet1", "cell1", 5).Return([]contracts.CellVersion{}, nil)
+
+		w := request(NewApiController(sheetRepository, nil, nil, nil, nil, nil, nil, nil), "?limit=5")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "[]", w.Body.String())
+
+		w = request(NewApiController(mocks.NewSheetRepository(t), nil, nil, nil, nil, nil, nil, nil), "?limit=0")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		for err, code := range map[error]int{
+			contracts.SheetNotFoundError: http.StatusNotFound,
+			contracts.CellNotFoundError:  http.StatusNotFound,
+			errors.New("test"):           http.StatusInternalServerError,
+		} {
+			sheetRepository := mocks.NewSheetRepository(t)
+			sheetRepository.On("GetCellHistory", "sheet1", "cell1", DefaultHistoryLimit).Return(nil, err)
+
+			w := request(NewApiController(sheetRepository, nil, nil, nil, nil, nil, nil, nil), "")
+			assert.Equal(t, code, w.Code, err.Error())
+		}
+	})
+}
+
+func TestApiController_UndoAction(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	request := func(apiController contracts.ApiController, path string) *httptest.ResponseRecorder {
+		router := SetupRouter(apiController)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/api/"+ApiVersion+"/sheet1/"+path, nil)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("success", func(t *testing.T) {
+		versions := []contracts.CellVersion{
+			{Version: 3, CellId: "cell1", Value: "=1", Result: "1", Source: contracts.ChangeCauseUndo},
+			{Version: 2, CellId: "cell2", Deleted: true, Source: contracts.ChangeCauseUndo},
+			{Version: 4, CellId: "Cell1", Value: "=2", Result: "2", Source: contracts.ChangeCauseUndo},
+		}
+
+		sheetRepository := mocks.NewSheetRepository(t)
+		sheetRepository.On("Undo", "sheet1", 3).Return(versions, nil)
+		sheetRepository.On("GetCanonicalCellId", mock.Anything).Return(strings.ToLower)
+		// subscriptions are updated in background
+		sheetRepository.On("SetExternalRefSubscriptions", "sheet1", mock.Anything, []string{}).Return([]string{}, nil).Maybe()
+		sheetRepository.On("GetCanonicalSheetId", "sheet1").Return("sheet1").Maybe()
+
+		executor := mocks.NewExpressionExecutor(t)
+		executor.On("ExtractExternalRefs", mock.Anything).Return([]string{})
+		executor.On("ExtractExternalJsonUrls", mock.Anything).Return([]string{})
+
+		// external refs are watched according to the last version of each cell
+		fetcher := mocks.NewExternalRefFetcher(t)
+		fetcher.On("WatchCell", "sheet1", "Cell1", []string{}, []string{}).Return().Once()
+		fetcher.On("WatchCell", "sheet1", "cell2", []string{}, []string{}).Return().Once()
+
+		w := request(NewApiController(sheetRepository, nil, executor, fetcher, nil, nil, nil, nil), undoPath+"?steps=3")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		response := make([]contracts.CellVersion, 0)
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, versions, response)
+		executor.AssertCalled(t, "ExtractExternalRefs", "=2")
+		executor.AssertNotCalled(t, "ExtractExternalRefs", "=1")
+	})
+
+	t.Run("redo", func(t *testing.T) {
+		sheetRepository := mocks.NewSheetRepository(t)
+		sheetRepository.On("Redo", "sheet1", 1).Return([]contracts.CellVersion{}, nil)
+
+		w := request(NewApiController(sheetRepository, nil, nil, nil, nil, nil, nil, nil), redoPath)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "[]", w.Body.String())
+	})
+
+	t.Run("invalid_steps", func(t *testing.T) {
+		for _, steps := range []string{"0", "101", "many"} {
+			w := request(NewApiController(mocks.NewSheetRepository(t), nil, nil, nil, nil, nil, nil, nil), undoPath+"?steps="+steps)
+			assert.Equal(t, http.StatusBadRequest, w.Code, steps)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		for err, code := range map[error]int{
+			contracts.SheetNotFoundError:  http.StatusNotFound,
+			contracts.NothingToUndoError:  http.StatusConflict,
+			contracts.NothingToRedoError:  http.StatusConflict,
+			contracts.UndoEvaluationError: http.StatusUnprocessableEntity,
+			errors.New("test"):            http.StatusInternalServerError,
+		} {
+			sheetRepository := mocks.NewSheetRepository(t)
+			sheetRepository.On("Undo", "sheet1", 1).Return(nil, err)
+
+			w := request(NewApiController(sheetRepository, nil, nil, nil, nil, nil, nil, nil), undoPath)
+			assert.Equal(t, code, w.Code, err.Error())
+		}
+	})
+}
+
+func TestApiController_Shutdown(t *testing.T) {
+	apiController := NewApiController(nil, nil, nil, nil, nil, nil, nil, nil)
+
+	release := make(chan struct{})
+	apiController.goBackground(func() {
+		<-release
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+	defer cancel()
+	assert.ErrorIs(t, apiController.Shutdown(ctx), context.DeadlineExceeded)
+
+	close(release)
+	assert.NoError(t, apiController.Shutdown(context.Background()))
+}
+
+func TestApiController_SubscribeExternalRefsToWebhook(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var mutex sync.Mutex
+	subscribeRequests := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		config := WebhookConfig{}
+		if r.Method == http.MethodDelete {
+			config.WebhookUrl = r.URL.Query().Get("webhook_url")
+			w.WriteHeader(http.StatusNoContent)
+		} else {
+			_ = json.ConfigDefault.NewDecoder(r.Body).Decode(&config)
+			w.WriteHeader(http.StatusCreated)
+		}
+
+		mutex.Lock()
+		subscribeRequests[r.Method+" "+r.URL.Path] = config.WebhookUrl
+		mutex.Unlock()
+	}))
+	defer server.Close()
+
+	remoteA1 := server.URL + "/api/v1/remote/a1"
+	remoteA2 := server.URL + "/api/v1/remote/a2"
+	remoteA3 := server.URL + "/api/v1/remote/a3"
+	cell := &contracts.Cell{CanonicalKey: "cell1", Value: "=external_ref(...)"}
+
+	executor := mocks.NewExpressionExecutor(t)
+	executor.On("ExtractExternalRefs", cell.Value).Return([]string{remoteA1, remoteA2, remoteA1})
+
+	sheetRepository := mocks.NewSheetRepository(t)
+	sheetRepository.On("SetExternalRefSubscriptions", "Sheet1", "Cell1", []string{remoteA1, remoteA2}).
+		Return([]string{remoteA2, remoteA3}, nil).Once()
+	sheetRepository.On("GetCanonicalSheetId", "Sheet1").Return("sheet1")
+
+	apiController := NewApiController(sheetRepository, nil, executor, nil, nil, _makeLoopbackOutboundClient(t), nil, nil)
+	apiController.Hostname = "api:8080"
+	apiController.SubscribeExternalRefsToWebhook(&CellEndpointParams{SheetId: "Sheet1", CellId: "Cell1"}, cell)
+
+	// webhook url is built from canonical ids
+	webhookUrl := "http://api:8080/api/v1/sheet1/cell1/externalRefWebhook"
+	assert.Equal(t, map[string]string{
+		"POST /api/v1/remote/a1/subscribe":       webhookUrl,
+		"POST /api/v1/remote/a2/subscribe":       webhookUrl,
+		"DELETE /api/v1/remote/a3/subscriptions": webhookUrl,
+	}, subscribeRequests)
+}
+
+func TestApiController_GetExternalRefSubscriptionsAction(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	request := func(apiController contracts.ApiController) *httptest.ResponseRecorder {
+		router := SetupRouter(apiController)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/api/"+ApiVersion+"/sheet1/cell1/"+externalRefSubscriptionsPath, nil)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("success", func(t *testing.T) {
+		sheetRepository := mocks.NewSheetRepository(t)
+		sheetRepository.On("GetExternalRefSubscriptions", "sheet1", "cell1").Return([]string{"http://remote/api/v1/sheet1/a1"}, nil)
+
+		w := request(NewApiController(sheetRepository, nil, nil, nil, nil, nil, nil, nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"external_refs": ["http://remote/api/v1/sheet1/a1"]}`, w.Body.String())
+	})
+
+	t.Run("error", func(t *testing.T) {
+		sheetRepository := mocks.NewSheetRepository(t)
+		sheetRepository.On("GetExternalRefSubscriptions", "sheet1", "cell1").Return(nil, errors.New("test"))
+
+		w := request(NewApiController(sheetRepository, nil, nil, nil, nil, nil, nil, nil))
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+}
+
+func TestApiController_ExternalRefWebhookAction(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	signer := NewWebhookSigner("secret", time.Minute)
+	payload := []byte(`{"value": "5", "result": "5"}`)
+
+	makeTracedRequest := func(sign *WebhookSigner, trace []string) *http.Request {
+		req, _ := http.NewRequest(http.MethodPost, "/api/"+ApiVersion+"/sheet1/cell1/"+externalRefWebhookPath, bytes.NewReader(payload))
+		if len(trace) != 0 {
+			req.Header.Set(ChangeTraceHeader, formatChangeTrace(trace))
+		}
+		sign.Sign(req, payload)
+		return req
+	}
+
+	makeRequest := func(sign *WebhookSigner) *http.Request {
+		return makeTracedRequest(sign, nil)
+	}
+
+	newApiController := func(sheetRepository contracts.SheetRepository, executor contracts.ExpressionExecutor, fetcher contracts.ExternalRefFetcher) *ApiController {
+		apiController := NewApiController(sheetRepository, nil, executor, fetcher, nil, nil, signer, nil)
+		apiController.Hostname = "local"
+		return apiController
+	}
+
+	sendRequest := func(apiController contracts.ApiController, req *http.Request) *httptest.ResponseRecorder {
+		router := SetupRouter(apiController)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	request := func(apiController contracts.ApiController) *httptest.ResponseRecorder {
+		return sendRequest(apiController, makeRequest(signer))
+	}
+
+	value := `=external_ref("http://remote/api/v1/sheet1/cell1")`
+
+	t.Run("success", func(t *testing.T) {
+		sheetRepository := mocks.NewSheetRepository(t)
+		origin := contracts.ChangeOrigin{Trace: []string{"remote/sheet1/cell1", "local/sheet1/cell1"}}
+		sheetRepository.On("GetCell", "sheet1", "cell1").Return(&contracts.Cell{CanonicalKey: "cell1", Value: value, Result: "1"}, nil)
+		sheetRepository.On("GetCanonicalSheetId", "sheet1").Return("sheet1")
+		sheetRepository.On("RecalculateCell", "sheet1", "cell1", origin).Return(&contracts.Cell{Value: value, Result: "2"}, nil)
+
+		executor := mocks.NewExpressionExecutor(t)
+		executor.On("ExtractExternalRefs", value).Return([]string{"http://remote/api/v1/sheet1/cell1"})
+		executor.On("ExtractExternalJsonUrls", value).Return([]string{})
+
+		fetcher := mocks.NewExternalRefFetcher(t)
+		fetcher.On("Invalidate", "http://remote/api/v1/sheet1/cell1", origin).Return().Once()
+		fetcher.On("WatchCell", "sheet1", "cell1", []string{"http://remote/api/v1/sheet1/cell1"}, []string{}).Return().Once()
+
+		w := sendRequest(newApiController(sheetRepository, executor, fetcher), makeTracedRequest(signer, origin.Trace[:1]))
+		response, err := _parseJsonBody(w)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "2", response["result"])
+	})
+
+	t.Run("circular", func(t *testing.T) {
+		tooLongTrace := make([]string, MaxChangeTraceLength)
+		for i := range tooLongTrace {
+			tooLongTrace[i] = fmt.Sprintf("remote%d/sheet1/cell1", i)
+		}
+
+		for _, trace := range [][]string{{"local/sheet1/cell1", "remote/sheet1/cell1"}, tooLongTrace} {
+			origin := contracts.ChangeOrigin{Trace: trace}
+			circularErr := fmt.Errorf("cell cell1: %w", ExternalRefCircularError)
+
+			sheetRepository := mocks.NewSheetRepository(t)
+			sheetRepository.On("GetCell", "sheet1", "cell1").Return(&contracts.Cell{CanonicalKey: "cell1", Value: value, Result: "1"}, nil)
+			sheetRepository.On("GetCanonicalSheetId", "sheet1").Return("sheet1")
+			sheetRepository.On("RecalculateCell", "sheet1", "cell1", origin).Return(&contracts.Cell{Value: value, Result: CircularResult}, circularErr)
+
+			executor := mocks.NewExpressionExecutor(t)
+			executor.On("ExtractExternalRefs", value).Return([]string{"http://remote/api/v1/sheet1/cell1"})
+			executor.On("ExtractExternalJsonUrls", value).Return([]string{})
+
+			fetcher := mocks.NewExternalRefFetcher(t)
+			fetcher.On("MarkCircular", "http://remote/api/v1/sheet1/cell1", mock.MatchedBy(func(err error) bool {
+				return errors.Is(err, ExternalRefCircularError)
+			})).Return().Once()
+			fetcher.On("WatchCell", "sheet1", "cell1", []string{"http://remote/api/v1/sheet1/cell1"}, []string{}).Return().Once()
+
+			w := sendRequest(newApiController(sheetRepository, executor, fetcher), makeTracedRequest(signer, trace))
+
+			assert.Equal(t, http.StatusConflict, w.Code)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		sheetRepository := mocks.NewSheetRepository(t)
+		sheetRepository.On("GetCell", "sheet1", "cell1").Return(&contracts.Cell{CanonicalKey: "cell1"}, contracts.CellNotFoundError)
+		sheetRepository.On("GetCanonicalSheetId", "sheet1").Return("sheet1")
+		sheetRepository.On("RecalculateCell", "sheet1", "cell1", mock.Anything).Return(nil, contracts.CellNotFoundError)
+
+		executor := mocks.NewExpressionExecutor(t)
+		executor.On("ExtractExternalRefs", "").Return([]string{})
+		executor.On("ExtractExternalJsonUrls", "").Return([]string{})
+
+		fetcher := mocks.NewExternalRefFetcher(t)
+		fetcher.On("WatchCell", "sheet1", "cell1", []string{}, []string{}).Return().Once()
+
+		w := request(newApiController(sheetRepository, executor, fetcher))
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("unauthorized", func(t *testing.T) {
+		apiController := NewApiController(mocks.NewSheetRepository(t), nil, nil, nil, nil, nil, signer, nil)
+
+		// unsigned
+		w := sendRequest(apiController, makeRequest(NewWebhookSigner("", time.Minute)))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		// other secret
+		w = sendRequest(apiController, makeRequest(NewWebhookSigner("other", time.Minute)))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		// replay
+		req := makeRequest(signer)
+		replayedReq := makeRequest(signer)
+		replayedReq.Header = req.Header.Clone()
+		signer.markSeen(req.Header.Get(WebhookIdHeader))
+		w = sendRequest(apiController, replayedReq)
+		response, err := _parseJsonBody(w)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, response["error"], "already received")
+	})
+
+	t.Run("without_secret", func(t *testing.T) {
+		sheetRepository := mocks.NewSheetRepository(t)
+		origin := contracts.ChangeOrigin{Trace: []string{"local/sheet1/cell1"}}
+		sheetRepository.On("GetCell", "sheet1", "cell1").Return(&contracts.Cell{CanonicalKey: "cell1", Value: value, Result: "1"}, nil)
+		sheetRepository.On("GetCanonicalSheetId", "sheet1").Return("sheet1")
+		sheetRepository.On("RecalculateCell", "sheet1", "cell1", origin).Return(&contracts.Cell{Value: value, Result: "2"}, nil)
+
+		executor := mocks.NewExpressionExecutor(t)
+		executor.On("ExtractExternalRefs", value).Return([]string{"http://remote/api/v1/sheet1/cell1"})
+		executor.On("ExtractExternalJsonUrls", value).Return([]string{})
+
+		fetcher := mocks.NewExternalRefFetcher(t)
+		fetcher.On("Invalidate", "http://remote/api/v1/sheet1/cell1", origin).Return().Once()
+		fetcher.On("WatchCell", "sheet1", "cell1", []string{"http://remote/api/v1/sheet1/cell1"}, []string{}).Return().Once()
+
+		apiController := NewApiController(sheetRepository, nil, executor, fetcher, nil, nil, NewWebhookSigner("", time.Minute), nil)
+		apiController.Hostname = "local"
+
+		// unsigned requests are accepted
+		w := sendRequest(apiController, makeRequest(NewWebhookSigner("", time.Minute)))
+		response, err := _parseJsonBody(w)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "2", response["result"])
+	})
+}
+
+func TestApiController_StatusAction(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	openedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	outboundClient := mocks.NewOutboundClient(t)
+	outboundClient.On("CircuitBreakers").Return([]contracts.CircuitBreakerStatus{
+		{Host: "remote:8080", State: contracts.CircuitBreakerOpen, Failures: 5, OpenedAt: &openedAt},
+	})
+
+	router := SetupRouter(NewApiController(nil, nil, nil, nil, nil, outboundClient, nil, nil))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/api/"+ApiVersion+"/_status", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"circuit_breakers": [{"host": "remote:8080", "state": "open", "failures": 5, "opened_at": "2024-01-02T03:04:05Z"}]}`, w.Body.String())
+}
+
+func TestApiController_DeadLettersActions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	request := func(apiController contracts.ApiController, method string, path string) *httptest.ResponseRecorder {
+		router := SetupRouter(apiController)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, "/api/"+ApiVersion+"/"+deadLettersPath+path, nil)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("list", func(t *testing.T) {
+		webhookDispatcher := mocks.NewWebhookDispatcher(t)
+		webhookDispatcher.On("GetDeadLetters").Return([]contracts.WebhookOutboxEntry{{
+			Id:             "0000000000000001",
+			SheetId:        "sheet1",
+			CellId:         "a1",
+			SubscriptionId: "id1",
+			WebhookUrl:     "http://remote/webhook",
+			Payload:        []byte(`{"value":"1","result":"1"}`),
+			CreatedAt:      time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+			Attempts:       8,
+			NextAttemptAt:  time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+			LastAttemptAt:  time.Date(2024, 1, 2, 3, 14, 5, 0, time.UTC),
+			LastError:      "unexpected response status: 500 Internal Server Error",
+		}}, nil).Once()
+		webhookDispatcher.On("GetDeadLetters").Return(nil, errors.New("test")).Once()
+
+		apiController := NewApiController(nil, webhookDispatcher, nil, nil, nil, nil, nil, nil)
+		w := request(apiController, http.MethodGet, "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"dead_letters": [{
+			"id": "0000000000000001", "sheet_id": "sheet1", "cell_id": "a1", "subscription_id": "id1",
+			"webhook_url": "http://remote/webhook", "payload": {"value": "1", "result": "1"},
+			"created_at": "2024-01-02T03:04:05Z", "attempts": 8, "next_attempt_at": "2024-01-02T03:04:05Z",
+			"last_attempt_at": "2024-01-02T03:14:05Z", "last_error": "unexpected response status: 500 Internal Server Error"
+		}]}`, w.Body.String())
+
+		assert.Equal(t, http.StatusInternalServerError, request(apiController, http.MethodGet, "").Code)
+	})
+
+	t.Run("replay", func(t *testing.T) {
+		webhookDispatcher := mocks.NewWebhookDispatcher(t)
+		webhookDispatcher.On("ReplayDeadLetter", "id1").Return(nil).Once()
+		webhookDispatcher.On("ReplayDeadLetter", "id2").Return(contracts.WebhookDeadLetterNotFoundError).Once()
+		webhookDispatcher.On("ReplayDeadLetter", "id3").Return(errors.New("test")).Once()
+
+		apiController := NewApiController(nil, webhookDispatcher, nil, nil, nil, nil, nil, nil)
+		assert.Equal(t, http.StatusAccepted, request(apiController, http.MethodPost, "/id1/replay").Code)
+		assert.Equal(t, http.StatusNotFound, request(apiController, http.MethodPost, "/id2/replay").Code)
+		assert.Equal(t, http.StatusInternalServerError, request(apiController, http.MethodPost, "/id3/replay").Code)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		webhookDispatcher := mocks.NewWebhookDispatcher(t)
+		webhookDispatcher.On("DeleteDeadLetter", "id1").Return(nil).Once()
+		webhookDispatcher.On("DeleteDeadLetter", "id2").Return(contracts.WebhookDeadLetterNotFoundError).Once()
+
+		apiController := NewApiController(nil, webhookDispatcher, nil, nil, nil, nil, nil, nil)
+		assert.Equal(t, http.StatusNoContent, request(apiController, http.MethodDelete, "/id1").Code)
+		assert.Equal(t, http.StatusNotFound, request(apiController, http.MethodDelete, "/id2").Code)
+	})
+}
+
+func TestApiController_SettingsActions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	request := func(apiController contracts.ApiController, method string, body string) *httptest.ResponseRecorder {
+		router := SetupRouter(apiController)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, "/api/"+ApiVersion+"/sheet1/"+settingsPath, bytes.NewReader([]byte(body)))
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("get", func(t *testing.T) {
+		settings := contracts.NewSheetSettings()
+		sheetRepository := mocks.NewSheetRepository(t)
+		sheetRepository.On("GetSettings", "sheet1").Return(&settings, nil)
+
+		w := request(NewApiController(sheetRepository, nil, nil, nil, nil, nil, nil, nil), http.MethodGet, "")
+		response, err := _parseJsonBody(w)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, false, response["iterative"])
+		assert.Equal(t, float64(contracts.DefaultMaxIterations), response["max_iterations"])
+		assert.Equal(t, contracts.DefaultEpsilon, response["epsilon"])
+	})
+
+	t.Run("get_error", func(t *testing.T) {
+		sheetRepository := mocks.NewSheetRepository(t)
+		sheetRepository.On("GetSettings", "sheet1").Return(nil, errors.New("test"))
+
+		w := request(NewApiController(sheetRepository, nil, nil, nil, nil, nil, nil, nil), http.MethodGet, "")
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+
+	t.Run("set_with_defaults", func(t *testing.T) {
+		expected := contracts.NewSheetSettings()
+		expected.Iterative = true
+
+		sheetRepository := mocks.NewSheetRepository(t)
+		sheetRepository.On("SetSettings", "sheet1", expected).Return(&expected, nil)
+
+		w := request(NewApiController(sheetRepository, nil, nil, nil, nil, nil, nil, nil), http.MethodPost, `{"iterative": true}`)
+		response, err := _parseJsonBody(w)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, true, response["iterative"])
+	})
+
+	t.Run("set", func(t *testing.T) {
+		expected := contracts.SheetSettings{Iterative: true, MaxIterations: 50, Epsilon: 0.1}
+
+		sheetRepository := mocks.NewSheetRepository(t)
+		sheetRepository.On("SetSettings", "sheet1", expected).Return(&expected, nil)
+
+		w := request(NewApiController(sheetRepository, nil, nil, nil, nil, nil, nil, nil), http.MethodPost, `{"iterative": true, "max_iterations": 50, "epsilon": 0.1}`)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+	})
+
+	t.Run("set_error", func(t *testing.T) {
+		sheetRepository := mocks.NewSheetRepository(t)
+		sheetRepository.On("SetSettings", "sheet1", mock.Anything).Return(nil, errors.New("test"))
+
+		w := request(NewApiController(sheetRepository, nil, nil, nil, nil, nil, nil, nil), http.MethodPost, `{"iterative": false}`)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+
+	t.Run("validation", func(t *testing.T) {
+		sheetRepository := mocks.NewSheetRepository(t)
+		apiController := NewApiController(sheetRepository, nil, nil, nil, nil, nil, nil, nil)
+
+		for _, body := range []string{`{"iterative": true, "max_iterations": -1}`, `{"iterative": true, "epsilon": -0.1}`, `not json`} {
+			w := request(apiController, http.MethodPost, body)
+			assert.Equal(t, http.StatusBadRequest, w.Code, body)
+		}
+	})
+}
+
+func _parseJsonBody(w *httptest.ResponseRecorder) (response map[string]any, err error) {
+	err = json.Unmarshal(w.Body.Bytes(), &response)
+	return
+}
+
+func TestApiController_ChangeEventsAction(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	sheetRepository := mocks.NewSheetRepository(t)
+	sheetRepository.On("GetCanonicalSheetId", "Sheet1").Return("sheet1").Maybe()
+	sheetRepository.On("GetCanonicalCellId", mock.Anything).Return(strings.ToLower).Maybe()
+
+	stream := NewChangeEventStream(10)
+	server := httptest.NewServer(SetupRouter(NewApiController(sheetRepository, nil, nil, nil, nil, nil, nil, stream)))
+	defer server.Close()
+
+	connect := func(t *testing.T, query string, lastEventId string) (*http.Response, *bufio.Reader) {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/api/"+ApiVersion+"/Sheet1/"+changeEventsPath+query, nil)
+		if lastEventId != "" {
+			req.Header.Set("Last-Event-ID", lastEventId)
+		}
+		res, err := (&http.Client{Timeout: 5 * time.Second}).Do(req)
+		assert.NoError(t, err)
+		return res, bufio.NewReader(res.Body)
+	}
+
+	stream.Notify("sheet1", _makeCellChanges([]*contracts.Cell{
+		{CanonicalKey: "a1", Value: "1", Result: "1"},
+		{CanonicalKey: "b1", Value: "=A1+1", Result: "2"},
+	}), contracts.ChangeOrigin{})
+
+	t.Run("resume", func(t *testing.T) {
+		res, reader := connect(t, "?cells=B1,a1", "1")
+		defer res.Body.Close()
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, sse.ContentType, res.Header.Get("Content-Type"))
+
+		event := _readServerSentEvent(t, reader)
+		assert.Equal(t, "2", event["id"])
+		payload := contracts.WebhookEvent{}
+		assert.NoError(t, json.UnmarshalString(event["data"], &payload))
+		assert.Equal(t, uint64(2), payload.Sequence)
+		assert.Equal(t, "B1", payload.CellId)
+		assert.Equal(t, &contracts.Cell{Value: "=A1+1", Result: "2"}, payload.Current)
+
+		stream.Notify("sheet1", _makeCellChanges([]*contracts.Cell{
+			{CanonicalKey: "c1", Value: "3", Result: "3"},
+			{CanonicalKey: "a1", Value: "5", Result: "5"},
+		}), contracts.ChangeOrigin{})
+
+		// c1 is filtered out
+		event = _readServerSentEvent(t, reader)
+		assert.Equal(t, "4", event["id"])
+		assert.Contains(t, event["data"], `"cell_id":"A1"`)
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		res, reader := connect(t, "", "100")
+		defer res.Body.Close()
+
+		event := _readServerSentEvent(t, reader)
+		assert.Equal(t, "reset", event["event"])
+		assert.JSONEq(t, `{"sheet_id": "sheet1"}`, event["data"])
+
+		stream.Notify("sheet1", _makeCellChanges([]*contracts.Cell{{CanonicalKey: "d1", Value: "1", Result: "1"}}), contracts.ChangeOrigin{})
+		assert.Equal(t, "5", _readServerSentEvent(t, reader)["id"])
+	})
+
+	t.Run("validation", func(t *testing.T) {
+		for query, lastEventId := range map[string]string{"": "abc", "?cells=a1%2Bb1": "", "?last_event_id=-1": ""} {
+			res, _ := connect(t, query, lastEventId)
+			assert.Equal(t, http.StatusBadRequest, res.StatusCode, query)
+			_ = res.Body.Close()
+		}
+	})
+}
+
+// _readServerSentEvent returns fields of the next event, comments are skipped
+func _readServerSentEvent(t *testing.T, reader *bufio.Reader) map[string]string {
+	event := map[string]string{}
+	for {
+		line, err := reader.ReadString('\n')
+		if !assert.NoError(t, err) {
+			return event
+		}
+
+		line = strings.TrimRight(line, "\n")
+		if line == "" && len(event) != 0 {
+			return event
+		}
+		if line == "" || strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		name, value, _ := strings.Cut(line, ":")
+		event[name] = strings.TrimPrefix(value, " ")
+	}
 }
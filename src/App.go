@@ -1,31 +1,58 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"io"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 )
 
 const ExitCodeMainError = 1
 
 const ListenPort = ":8080"
 
+// RunApp serves API until SIGINT or SIGTERM, then shuts down gracefully: active requests are completed,
+// due webhooks are sent (the rest stays in outbox) and the database is closed
 func RunApp() error {
 	gin.SetMode(gin.ReleaseMode)
 
-	serviceContainer, err := BuildServiceContainer(os.Getenv("DATABASE_FILEPATH"))
+	config := NewConfigFromEnv()
+	serviceContainer, err := BuildServiceContainer(config)
+	if err != nil {
+		return err
+	}
+	if config.WebhookSecret == "" {
+		logger.Println("WARNING: WEBHOOK_SECRET is not set: webhooks are sent unsigned and externalRefWebhook accepts unsigned requests")
+	}
 
-	if err == nil {
-		serviceContainer.WebhookDispatcher.Start()
-		defer serviceContainer.WebhookDispatcher.Close()
-		defer serviceContainer.Database.Close()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-		err = http.ListenAndServe(ListenPort, serviceContainer.Router)
-	}
+	server := &http.Server{Addr: ListenPort, Handler: serviceContainer.Router}
+	// event streams and live sessions never end by themselves, they are disconnected to let active requests complete
+	server.RegisterOnShutdown(serviceContainer.ChangeEventStream.Close)
+
+	lifecycle := NewLifecycle(server, config.ShutdownTimeout)
+	// requests may leave subscriptions to external refs in background, they store urls in the database
+	lifecycle.OnShutdown("external ref subscriptions", serviceContainer.ApiController.Shutdown)
+	// external refs recalculate cells and notify webhooks, so they are stopped first
+	lifecycle.OnShutdown("external refs", func(context.Context) error {
+		serviceContainer.ExternalRefFetcher.Close()
+		return nil
+	})
+	lifecycle.OnShutdown("webhooks", serviceContainer.WebhookDispatcher.Shutdown)
+	lifecycle.OnShutdown("database", func(context.Context) error {
+		return serviceContainer.Database.Close()
+	})
+
+	serviceContainer.WebhookDispatcher.Start()
+	serviceContainer.ExternalRefFetcher.Start()
 
-	return err
+	return lifecycle.Run(ctx)
 }
 
 func HandleExitError(errStream io.Writer, err error) int {
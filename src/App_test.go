@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"os"
 	"runtime"
+	"syscall"
 	"testing"
 	"time"
 )
@@ -21,18 +22,19 @@ func TestRunApp(t *testing.T) {
 		_ = os.Setenv("DATABASE_FILEPATH", f.Name())
 		defer os.Unsetenv("DATABASE_FILEPATH")
 
-		var appErr error
+		appErr := make(chan error, 1)
 		go func() {
-			appErr = RunApp()
+			appErr <- RunApp()
 		}()
 		runtime.Gosched()
 
 		var err error
 		var res *http.Response
 		for i := 0; i < 3; i++ {
-			if appErr != nil {
-				t.Errorf("RunApp() error = %v", appErr)
-				break
+			select {
+			case runErr := <-appErr:
+				t.Errorf("RunApp() error = %v", runErr)
+			default:
 			}
 
 			time.Sleep(50 * time.Millisecond)
@@ -50,18 +52,29 @@ func TestRunApp(t *testing.T) {
 		assert.Equal(t, http.StatusOK, res.StatusCode)
 		body, err := io.ReadAll(res.Body)
 		assert.Equal(t, "health", string(body))
+
+		// graceful shutdown
+		assert.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGTERM))
+		select {
+		case err = <-appErr:
+			assert.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			assert.Fail(t, "RunApp() is not stopped")
+		}
 	})
 
 	t.Run("fail", func(t *testing.T) {
 		os.Unsetenv("DATABASE_FILEPATH")
 
-		var err error
+		appErr := make(chan error, 1)
 		go func() {
-			err = RunApp()
+			appErr <- RunApp()
 		}()
-		runtime.Gosched()
-		if err == nil {
-			time.Sleep(50 * time.Millisecond)
+
+		var err error
+		select {
+		case err = <-appErr:
+		case <-time.After(time.Second):
 		}
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "no such file or directory")
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"devChallengeExcel/contracts"
+	"errors"
+	"go.etcd.io/bbolt"
+)
+
+// BoltStorage is contracts.Storage in single file (bbolt B+tree)
+type BoltStorage struct {
+	db *bbolt.DB
+}
+
+type boltStorageTx struct {
+	tx *bbolt.Tx
+}
+
+type boltStorageBucket struct {
+	bucket *bbolt.Bucket
+}
+
+// boltStorageCursor skips nested buckets (bbolt returns them with nil value).
+// It works around moving back over pages emptied by Delete in the same transaction: bbolt Prev stops at empty page
+// and Last never returns when all pages are empty
+type boltStorageCursor struct {
+	cursor *bbolt.Cursor
+	// current key of the cursor (copy)
+	current []byte
+}
+
+func NewBoltStorage(db *bbolt.DB) *BoltStorage {
+	return &BoltStorage{db: db}
+}
+
+func OpenBoltStorage(path string) (*BoltStorage, error) {
+	db, err := bbolt.Open(path, 0600, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewBoltStorage(db), nil
+}
+
+// Path returns path to file of the database
+func (s *BoltStorage) Path() string {
+	return s.db.Path()
+}
+
+func (s *BoltStorage) View(fn func(tx contracts.StorageTx) error) error {
+	return mapBoltError(s.db.View(func(tx *bbolt.Tx) error {
+		return fn(&boltStorageTx{tx: tx})
+	}))
+}
+
+func (s *BoltStorage) Update(fn func(tx contracts.StorageTx) error) error {
+	return mapBoltError(s.db.Update(func(tx *bbolt.Tx) error {
+		return fn(&boltStorageTx{tx: tx})
+	}))
+}
+
+func (s *BoltStorage) Batch(fn func(tx contracts.StorageTx) error) error {
+	return mapBoltError(s.db.Batch(func(tx *bbolt.Tx) error {
+		return fn(&boltStorageTx{tx: tx})
+	}))
+}
+
+func (s *BoltStorage) Close() error {
+	return s.db.Close()
+}
+
+func (t *boltStorageTx) Bucket(name []byte) contracts.StorageBucket {
+	return wrapBoltBucket(t.tx.Bucket(name))
+}
+
+func (t *boltStorageTx) CreateBucketIfNotExists(name []byte) (contracts.StorageBucket, error) {
+	bucket, err := t.tx.CreateBucketIfNotExists(name)
+	if err != nil {
+		return nil, mapBoltError(err)
+	}
+
+	return wrapBoltBucket(bucket), nil
+}
+
+func (t *boltStorageTx) DeleteBucket(name []byte) error {
+	return mapBoltError(t.tx.DeleteBucket(name))
+}
+
+func (t *boltStorageTx) ForEachBucket(fn func(name []byte) error) error {
+	return t.tx.ForEach(func(name []byte, _ *bbolt.Bucket) error {
+		return fn(name)
+	})
+}
+
+func (b *boltStorageBucket) Get(key []byte) []byte {
+	return b.bucket.Get(key)
+}
+
+func (b *boltStorageBucket) Put(key []byte, value []byte) error {
+	return mapBoltError(b.bucket.Put(key, value))
+}
+
+func (b *boltStorageBucket) Delete(key []byte) error {
+	return mapBoltError(b.bucket.Delete(key))
+}
+
+func (b *boltStorageBucket) Bucket(name []byte) contracts.StorageBucket {
+	return wrapBoltBucket(b.bucket.Bucket(name))
+}
+
+func (b *boltStorageBucket) CreateBucketIfNotExists(name []byte) (contracts.StorageBucket, error) {
+	bucket, err := b.bucket.CreateBucketIfNotExists(name)
+	if err != nil {
+		return nil, mapBoltError(err)
+	}
+
+	return wrapBoltBucket(bucket), nil
+}
+
+func (b *boltStorageBucket) DeleteBucket(name []byte) error {
+	return mapBoltError(b.bucket.DeleteBucket(name))
+}
+
+func (b *boltStorageBucket) ForEach(fn func(key []byte, value []byte) error) error {
+	return b.bucket.ForEach(func(key []byte, value []byte) error {
+		if value == nil {
+			return nil
+		}
+
+		return fn(key, value)
+	})
+}
+
+func (b *boltStorageBucket) ForEachBucket(fn func(name []byte) error) error {
+	return b.bucket.ForEachBucket(fn)
+}
+
+func (b *boltStorageBucket) Cursor() contracts.StorageCursor {
+	return &boltStorageCursor{cursor: b.bucket.Cursor()}
+}
+
+func (b *boltStorageBucket) NextSequence() (uint64, error) {
+	sequence, err := b.bucket.NextSequence()
+	return sequence, mapBoltError(err)
+}
+
+func (c *boltStorageCursor) First() ([]byte, []byte) {
+	key, value := c.cursor.First()
+	return c.skipBuckets(key, value, c.cursor.Next)
+}
+
+func (c *boltStorageCursor) Last() ([]byte, []byte) {
+	if key, _ := c.cursor.First(); key == nil {
+		return c.skipBuckets(nil, nil, c.prev)
+	}
+
+	key, value := c.cursor.Last()
+	return c.skipBuckets(key, value, c.prev)
+}
+
+func (c *boltStorageCursor) Next() ([]byte, []byte) {
+	key, value := c.cursor.Next()
+	return c.skipBuckets(key, value, c.cursor.Next)
+}
+
+func (c *boltStorageCursor) Prev() ([]byte, []byte) {
+	key, value := c.prev()
+	return c.skipBuckets(key, value, c.prev)
+}
+
+func (c *boltStorageCursor) Seek(seek []byte) ([]byte, []byte) {
+	key, value := c.cursor.Seek(seek)
+	return c.skipBuckets(key, value, c.cursor.Next)
+}
+
+func (c *boltStorageCursor) skipBuckets(key []byte, value []byte, move func() ([]byte, []byte)) ([]byte, []byte) {
+	for key != nil && value == nil {
+		key, value = move()
+	}
+	c.current = append(c.current[:0], key...)
+
+	return key, value
+}
+
+// prev checks the beginning of the bucket by moving forward from the first key to the current one (Next skips empty pages)
+func (c *boltStorageCursor) prev() ([]byte, []byte) {
+	key, value := c.cursor.Prev()
+	if key != nil || len(c.current) == 0 {
+		return key, value
+	}
+
+	var previous []byte
+	for key, value = c.cursor.First(); key != nil && bytes.Compare(key, c.current) < 0; key, value = c.cursor.Next() {
+		if value != nil {
+			previous = key
+		}
+	}
+	if previous == nil {
+		return nil, nil
+	}
+
+	return c.cursor.Seek(previous)
+}
+
+func wrapBoltBucket(bucket *bbolt.Bucket) contracts.StorageBucket {
+	if bucket == nil {
+		return nil
+	}
+
+	return &boltStorageBucket{bucket: bucket}
+}
+
+// mapBoltError replaces errors of bbolt with errors of contracts, so they are the same for all storages
+func mapBoltError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, bbolt.ErrBucketNotFound):
+		return contracts.StorageBucketNotFoundError
+	case errors.Is(err, bbolt.ErrIncompatibleValue):
+		return contracts.StorageIncompatibleValueError
+	case errors.Is(err, bbolt.ErrKeyRequired):
+		return contracts.StorageKeyRequiredError
+	case errors.Is(err, bbolt.ErrBucketNameRequired):
+		return contracts.StorageBucketNameRequiredError
+	case errors.Is(err, bbolt.ErrTxNotWritable):
+		return contracts.StorageTxNotWritableError
+	case errors.Is(err, bbolt.ErrDatabaseNotOpen):
+		return contracts.StorageClosedError
+	}
+
+	return err
+}
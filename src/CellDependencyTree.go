@@ -2,7 +2,7 @@ package main
 
 import (
 	"bytes"
-	"go.etcd.io/bbolt"
+	"devChallengeExcel/contracts"
 )
 
 type CellDependencyTree struct{}
@@ -11,11 +11,11 @@ const Delimiter = byte(0x00)
 
 var bucketPrefix = [4]byte{'_', '_', 'd', '_'}
 
-func (t *CellDependencyTree) SetDependsOn(tx *bbolt.Tx, sheetId []byte, dependantCellId string, dependingOnCellIds []string) (err error) {
+func (t *CellDependencyTree) SetDependsOn(tx contracts.StorageTx, sheetId []byte, dependantCellId string, dependingOnCellIds []string) (err error) {
 	cellDependingListKey := t.makeDependingListKey(dependantCellId)
 
 	bucketId := t.makeBucketId(sheetId)
-	var bucket *bbolt.Bucket
+	var bucket contracts.StorageBucket
 	bucket, err = tx.CreateBucketIfNotExists(bucketId)
 	if err != nil {
 		return err
@@ -66,7 +66,7 @@ func (t *CellDependencyTree) SetDependsOn(tx *bbolt.Tx, sheetId []byte, dependan
 	return bucket.Put(cellDependingListKey, bytes.Join(newDependingOnCellIds, []byte{Delimiter}))
 }
 
-func (t *CellDependencyTree) GetDependants(tx *bbolt.Tx, sheetId []byte, dependingOnCellId string) []string {
+func (t *CellDependencyTree) GetDependants(tx contracts.StorageTx, sheetId []byte, dependingOnCellId string) []string {
 	bucketId := t.makeBucketId(sheetId)
 
 	bucket := tx.Bucket(bucketId)
@@ -79,6 +79,31 @@ func (t *CellDependencyTree) GetDependants(tx *bbolt.Tx, sheetId []byte, dependi
 	})
 }
 
+// GetDependsOn returns cells which the cell depends on directly (as they were passed to SetDependsOn)
+func (t *CellDependencyTree) GetDependsOn(tx contracts.StorageTx, sheetId []byte, dependantCellId string) []string {
+	dependingOnCellIds := make([]string, 0)
+
+	bucket := tx.Bucket(t.makeBucketId(sheetId))
+	if bucket == nil {
+		return dependingOnCellIds
+	}
+
+	list := bucket.Get(t.makeDependingListKey(dependantCellId))
+	if len(list) == 0 {
+		return dependingOnCellIds
+	}
+
+	for _, dependingOnCellId := range bytes.Split(list, []byte{Delimiter}) {
+		dependingOnCellIds = append(dependingOnCellIds, string(dependingOnCellId))
+	}
+
+	return dependingOnCellIds
+}
+
+func (t *CellDependencyTree) DeleteSheet(tx contracts.StorageTx, sheetId []byte) error {
+	return ignoreBucketNotFound(tx.DeleteBucket(t.makeBucketId(sheetId)))
+}
+
 func (t *CellDependencyTree) makeBucketId(sheetId []byte) []byte {
 	if sheetId == nil || len(sheetId) == 0 {
 		return nil
@@ -87,7 +112,7 @@ func (t *CellDependencyTree) makeBucketId(sheetId []byte) []byte {
 	return append(bucketPrefix[:], sheetId...)
 }
 
-func (t *CellDependencyTree) fetchDependantsRecursive(bucket *bbolt.Bucket, dependingOnCellId string, alreadyFetched map[string]bool) []string {
+func (t *CellDependencyTree) fetchDependantsRecursive(bucket contracts.StorageBucket, dependingOnCellId string, alreadyFetched map[string]bool) []string {
 	dependants := t.fetchCellDependants(bucket, dependingOnCellId)
 
 	for _, dependantCellId := range dependants {
@@ -100,7 +125,7 @@ func (t *CellDependencyTree) fetchDependantsRecursive(bucket *bbolt.Bucket, depe
 	return dependants
 }
 
-func (t *CellDependencyTree) fetchCellDependants(bucket *bbolt.Bucket, dependingOnCellId string) []string {
+func (t *CellDependencyTree) fetchCellDependants(bucket contracts.StorageBucket, dependingOnCellId string) []string {
 	dependantCellIds := make([]string, 0, 5)
 	c := bucket.Cursor()
 
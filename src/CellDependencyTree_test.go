@@ -1,36 +1,45 @@
 package main
 
 import (
+	"devChallengeExcel/contracts"
 	"github.com/stretchr/testify/assert"
-	"go.etcd.io/bbolt"
 	"testing"
 )
 
 type TransactionCellDependencyTreeDecorator struct {
 	t  *testing.T
-	db *bbolt.DB
+	db contracts.Storage
 	CellDependencyTree
 }
 
 func (tree *TransactionCellDependencyTreeDecorator) SetDependsOn(sheetId []byte, dependantCellId string, dependingOnCellIds []string) (returnErr error) {
-	tx, err := tree.db.Begin(true)
+	err := tree.db.Update(func(tx contracts.StorageTx) error {
+		returnErr = tree.CellDependencyTree.SetDependsOn(tx, sheetId, dependantCellId, dependingOnCellIds)
+		return nil
+	})
 	assert.NoError(tree.t, err)
-
-	returnErr = tree.CellDependencyTree.SetDependsOn(tx, sheetId, dependantCellId, dependingOnCellIds)
-	assert.NoError(tree.t, tx.Commit())
 	return
 }
 
 func (tree *TransactionCellDependencyTreeDecorator) GetDependants(sheetId []byte, dependingOnCellId string) (returnList []string) {
-	tx, err := tree.db.Begin(false)
+	err := tree.db.View(func(tx contracts.StorageTx) error {
+		returnList = tree.CellDependencyTree.GetDependants(tx, sheetId, dependingOnCellId)
+		return nil
+	})
 	assert.NoError(tree.t, err)
+	return
+}
 
-	returnList = tree.CellDependencyTree.GetDependants(tx, sheetId, dependingOnCellId)
-	assert.NoError(tree.t, tx.Rollback())
+func (tree *TransactionCellDependencyTreeDecorator) GetDependsOn(sheetId []byte, dependantCellId string) (returnList []string) {
+	err := tree.db.View(func(tx contracts.StorageTx) error {
+		returnList = tree.CellDependencyTree.GetDependsOn(tx, sheetId, dependantCellId)
+		return nil
+	})
+	assert.NoError(tree.t, err)
 	return
 }
 
-func NewTransactionCellDependencyTreeDecorator(t *testing.T, db *bbolt.DB) *TransactionCellDependencyTreeDecorator {
+func NewTransactionCellDependencyTreeDecorator(t *testing.T, db contracts.Storage) *TransactionCellDependencyTreeDecorator {
 	return &TransactionCellDependencyTreeDecorator{t, db, CellDependencyTree{}}
 }
 
@@ -100,12 +109,12 @@ func TestCellDependencyTree_GetDependants(t *testing.T) {
 		sheetId := []byte(t.Name())
 		bucketId := tree.makeBucketId(sheetId)
 
-		err := db.Update(func(tx *bbolt.Tx) error {
+		err := db.Update(func(tx contracts.StorageTx) error {
 			bucket, err := tx.CreateBucketIfNotExists(bucketId)
 			if err != nil {
 				return err
 			}
-			_, err = bucket.CreateBucket(tree.makeDependantKey("cell1", "cell2"))
+			_, err = bucket.CreateBucketIfNotExists(tree.makeDependantKey("cell1", "cell2"))
 			return err
 		})
 		assert.NoError(t, err)
@@ -121,16 +130,16 @@ func TestCellDependencyTree_GetDependants(t *testing.T) {
 
 		err := tree.SetDependsOn(sheetId, "cell1", []string{"cell3"})
 
-		err = db.Update(func(tx *bbolt.Tx) error {
-			var bucket *bbolt.Bucket
+		err = db.Update(func(tx contracts.StorageTx) error {
+			var bucket contracts.StorageBucket
 			bucket, err = tx.CreateBucketIfNotExists(bucketId)
 			assert.NoError(t, err)
 
-			_, err = bucket.CreateBucket(tree.makeDependantKey("cell1", "cell2"))
+			_, err = bucket.CreateBucketIfNotExists(tree.makeDependantKey("cell1", "cell2"))
 			assert.NoError(t, err)
 
 			_ = bucket.Delete(tree.makeDependantKey("cell1", "cell3"))
-			_, err = bucket.CreateBucket(tree.makeDependantKey("cell1", "cell3"))
+			_, err = bucket.CreateBucketIfNotExists(tree.makeDependantKey("cell1", "cell3"))
 			assert.NoError(t, err)
 
 			return nil
@@ -140,3 +149,22 @@ func TestCellDependencyTree_GetDependants(t *testing.T) {
 		assert.Error(t, err)
 	})
 }
+
+func TestCellDependencyTree_GetDependsOn(t *testing.T) {
+	db, closeDb := _createTmpDb()
+	defer closeDb()
+
+	tree := NewTransactionCellDependencyTreeDecorator(t, db)
+	sheetId := []byte(t.Name())
+
+	assert.Empty(t, tree.GetDependsOn(sheetId, "cell1"))
+
+	err := tree.SetDependsOn(sheetId, "cell1", []string{"cell100", "cell2"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"cell100", "cell2"}, tree.GetDependsOn(sheetId, "cell1"))
+	assert.Empty(t, tree.GetDependsOn(sheetId, "cell2"))
+
+	err = tree.SetDependsOn(sheetId, "cell1", []string{})
+	assert.NoError(t, err)
+	assert.Empty(t, tree.GetDependsOn(sheetId, "cell1"))
+}
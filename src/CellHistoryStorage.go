@@ -0,0 +1,165 @@
+package main
+
+import (
+	"devChallengeExcel/contracts"
+	"fmt"
+	json "github.com/bytedance/sonic"
+	"time"
+)
+
+const DefaultCellHistoryMaxVersions = 100
+
+// CellHistoryConfig retention policy of the cell history. Zero value means unlimited
+type CellHistoryConfig struct {
+	// MaxVersions kept per cell, older versions are removed
+	MaxVersions int
+	// MaxAge of kept versions. The latest version of the cell is kept regardless of its age
+	MaxAge time.Duration
+}
+
+// CellHistoryStorage keeps append-only history of writes of cells.
+// Single bucket with nested bucket per sheet and per cell (canonical id), key is zero padded hex version number,
+// so versions are ordered by time. Old versions are removed according to the retention policy when new one is added
+type CellHistoryStorage struct {
+	config CellHistoryConfig
+}
+
+var historyBucketId = []byte("__history")
+
+// Add assigns the next version number to the version of the cell and stores it
+func (s *CellHistoryStorage) Add(tx contracts.StorageTx, sheetId []byte, cellId []byte, version *contracts.CellVersion) error {
+	bucket, err := tx.CreateBucketIfNotExists(historyBucketId)
+	if err == nil {
+		bucket, err = bucket.CreateBucketIfNotExists(sheetId)
+	}
+	if err == nil {
+		bucket, err = bucket.CreateBucketIfNotExists(cellId)
+	}
+	if err != nil {
+		return err
+	}
+
+	version.Version, err = bucket.NextSequence()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(version)
+	if err != nil {
+		return err
+	}
+
+	if err = bucket.Put(makeCellVersionKey(version.Version), data); err != nil {
+		return err
+	}
+
+	return s.prune(bucket, version)
+}
+
+// Get returns up to limit recent versions of the cell, newest first
+func (s *CellHistoryStorage) Get(tx contracts.StorageTx, sheetId []byte, cellId []byte, limit int) []contracts.CellVersion {
+	versions := make([]contracts.CellVersion, 0)
+
+	bucket := s.getSheetBucket(tx, sheetId)
+	if bucket != nil {
+		bucket = bucket.Bucket(cellId)
+	}
+	if bucket == nil {
+		return versions
+	}
+
+	cursor := bucket.Cursor()
+	for key, data := cursor.Last(); key != nil && len(versions) < limit; key, data = cursor.Prev() {
+		version := contracts.CellVersion{}
+		if json.Unmarshal(data, &version) == nil {
+			versions = append(versions, version)
+		}
+	}
+
+	return versions
+}
+
+// GetAt returns the latest version of each cell of the sheet written at or before the moment, deleted cells are skipped
+// (key is canonical cell id)
+func (s *CellHistoryStorage) GetAt(tx contracts.StorageTx, sheetId []byte, at time.Time) map[string]contracts.CellVersion {
+	versions := map[string]contracts.CellVersion{}
+
+	bucket := s.getSheetBucket(tx, sheetId)
+	if bucket == nil {
+		return versions
+	}
+
+	_ = bucket.ForEachBucket(func(cellId []byte) error {
+		cursor := bucket.Bucket(cellId).Cursor()
+		for key, data := cursor.Last(); key != nil; key, data = cursor.Prev() {
+			version := contracts.CellVersion{}
+			if json.Unmarshal(data, &version) != nil || version.Timestamp.After(at) {
+				continue
+			}
+			if !version.Deleted {
+				versions[string(cellId)] = version
+			}
+			break
+		}
+		return nil
+	})
+
+	return versions
+}
+
+// DeleteSheet removes history of all cells of the sheet
+func (s *CellHistoryStorage) DeleteSheet(tx contracts.StorageTx, sheetId []byte) error {
+	bucket := tx.Bucket(historyBucketId)
+	if bucket == nil {
+		return nil
+	}
+
+	return ignoreBucketNotFound(bucket.DeleteBucket(sheetId))
+}
+
+// prune removes the oldest versions beyond max number of versions and the expired ones, except the latest version.
+// Versions are removed from the oldest one only, so numbers of kept versions are contiguous
+func (s *CellHistoryStorage) prune(bucket contracts.StorageBucket, latest *contracts.CellVersion) error {
+	expiredBefore := time.Time{}
+	if s.config.MaxAge > 0 {
+		expiredBefore = latest.Timestamp.Add(-s.config.MaxAge)
+	}
+
+	// cursor is not used for deletion: deleting with cursor skips the next key
+	expired := make([][]byte, 0)
+	cursor := bucket.Cursor()
+	for key, data := cursor.First(); key != nil; key, data = cursor.Next() {
+		version := contracts.CellVersion{}
+		_ = json.Unmarshal(data, &version)
+		if version.Version == latest.Version {
+			break
+		}
+
+		isBeyondMaxVersions := s.config.MaxVersions > 0 && latest.Version-version.Version >= uint64(s.config.MaxVersions)
+		if !isBeyondMaxVersions && !version.Timestamp.Before(expiredBefore) {
+			break
+		}
+		expired = append(expired, key)
+	}
+
+	for _, key := range expired {
+		if err := bucket.Delete(key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *CellHistoryStorage) getSheetBucket(tx contracts.StorageTx, sheetId []byte) contracts.StorageBucket {
+	bucket := tx.Bucket(historyBucketId)
+	if bucket == nil {
+		return nil
+	}
+
+	return bucket.Bucket(sheetId)
+}
+
+func makeCellVersionKey(version uint64) []byte {
+	return []byte(fmt.Sprintf("%016x", version))
+}
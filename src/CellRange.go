@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var CellRangeError = errors.New("invalid cell range, A1 notation is expected (e.g. A1:C10)")
+
+// a1CellRegex cell id in A1 notation: column letters and row number
+var a1CellRegex = regexp.MustCompile(`^([a-z]+)([0-9]+)$`)
+
+// CellRange rectangle of cells in A1 notation (e.g. `A1:C10`), bounds are inclusive
+type CellRange struct {
+	FromColumn int
+	FromRow    int
+	ToColumn   int
+	ToRow      int
+}
+
+// ParseCellRange parses range `A1:C10` (case-insensitive). Single cell `B2` is range of one cell
+func ParseCellRange(cellRange string) (*CellRange, error) {
+	from, to, found := strings.Cut(strings.ToLower(strings.TrimSpace(cellRange)), ":")
+	if !found {
+		to = from
+	}
+
+	fromColumn, fromRow, okFrom := parseA1Cell(from)
+	toColumn, toRow, okTo := parseA1Cell(to)
+	if !okFrom || !okTo {
+		return nil, fmt.Errorf("%w: %s", CellRangeError, cellRange)
+	}
+
+	return &CellRange{
+		FromColumn: min(fromColumn, toColumn),
+		FromRow:    min(fromRow, toRow),
+		ToColumn:   max(fromColumn, toColumn),
+		ToRow:      max(fromRow, toRow),
+	}, nil
+}
+
+// Contains checks canonical cell id, cells which are not in A1 notation are out of any range
+func (r *CellRange) Contains(canonicalCellId string) bool {
+	column, row, ok := parseA1Cell(canonicalCellId)
+
+	return ok && column >= r.FromColumn && column <= r.ToColumn && row >= r.FromRow && row <= r.ToRow
+}
+
+// parseA1Cell returns 1-based column (`a` is 1, `aa` is 27) and row of lower case cell id
+func parseA1Cell(cellId string) (column int, row int, ok bool) {
+	matches := a1CellRegex.FindStringSubmatch(cellId)
+	// 7 letters is enough for any sane sheet and keeps column in int range
+	if matches == nil || len(matches[1]) > 7 {
+		return
+	}
+
+	row, err := strconv.Atoi(matches[2])
+	if err != nil {
+		return
+	}
+
+	for _, letter := range matches[1] {
+		column = column*26 + int(letter-'a') + 1
+	}
+
+	return column, row, true
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseCellRange(t *testing.T) {
+	cellRange, err := ParseCellRange("B2:AA10")
+	assert.NoError(t, err)
+	assert.Equal(t, &CellRange{FromColumn: 2, FromRow: 2, ToColumn: 27, ToRow: 10}, cellRange)
+
+	// reversed bounds
+	cellRange, err = ParseCellRange("c3:a1")
+	assert.NoError(t, err)
+	assert.Equal(t, &CellRange{FromColumn: 1, FromRow: 1, ToColumn: 3, ToRow: 3}, cellRange)
+
+	cellRange, err = ParseCellRange("D4")
+	assert.NoError(t, err)
+	assert.Equal(t, &CellRange{FromColumn: 4, FromRow: 4, ToColumn: 4, ToRow: 4}, cellRange)
+
+	for _, invalid := range []string{"", "A", "1", "A1:", "A1:B", "A1:B2:C3", "price", "A1B2:C3", "AAAAAAAA1"} {
+		_, err = ParseCellRange(invalid)
+		assert.ErrorIs(t, err, CellRangeError, invalid)
+	}
+}
+
+func TestCellRange_Contains(t *testing.T) {
+	cellRange, _ := ParseCellRange("B2:AA10")
+
+	for _, cellId := range []string{"b2", "aa10", "c5", "z2"} {
+		assert.True(t, cellRange.Contains(cellId), cellId)
+	}
+
+	for _, cellId := range []string{"a1", "a5", "b1", "b11", "ab5", "price", "b2_r$46$r_1", "B2"} {
+		assert.False(t, cellRange.Contains(cellId), cellId)
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"devChallengeExcel/contracts"
+	"slices"
+	"sync"
+	"time"
+)
+
+// DefaultChangeEventStreamBufferSize events of each sheet which are kept for replay (Last-Event-ID)
+const DefaultChangeEventStreamBufferSize = 1000
+
+// changeEventListenerBufferSize events which listener can fall behind, then it is disconnected
+// and should resume the stream with the last event id
+const changeEventListenerBufferSize = 64
+
+// ChangeEventStream in-memory stream of cell changes for Server-Sent Events.
+// Each sheet numbers its events, the last events are buffered to resume the stream after reconnect
+type ChangeEventStream struct {
+	bufferSize int
+
+	mutex     sync.Mutex
+	sheets    map[string]*sheetChangeEvents
+	listeners map[*contracts.ChangeStreamSubscription]*changeEventListener
+	closed    bool
+}
+
+type sheetChangeEvents struct {
+	lastEventId uint64
+	// buffer the last events, it is compacted when it grows twice as large as buffer size
+	buffer []contracts.ChangeEvent
+}
+
+type changeEventListener struct {
+	canonicalSheetId string
+	// cells of the filter, all cells when it is empty
+	cells  map[string]bool
+	events chan contracts.ChangeEvent
+}
+
+func NewChangeEventStream(bufferSize int) *ChangeEventStream {
+	return &ChangeEventStream{
+		bufferSize: bufferSize,
+		sheets:     map[string]*sheetChangeEvents{},
+		listeners:  map[*contracts.ChangeStreamSubscription]*changeEventListener{},
+	}
+}
+
+func (s *ChangeEventStream) Notify(canonicalSheetId string, changes []contracts.CellChange, _ contracts.ChangeOrigin) {
+	now := time.Now().UTC()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	sheet := s.getSheet(canonicalSheetId)
+	for _, change := range changes {
+		if !change.IsResultChanged() {
+			continue
+		}
+
+		sheet.lastEventId++
+		event := contracts.ChangeEvent{
+			WebhookEvent:    newWebhookEvent(canonicalSheetId, change, now),
+			CanonicalCellId: change.Cell.CanonicalKey,
+		}
+		event.Sequence = sheet.lastEventId
+		sheet.add(event, s.bufferSize)
+
+		for subscription, listener := range s.listeners {
+			if listener.canonicalSheetId != canonicalSheetId || !listener.accepts(event) {
+				continue
+			}
+
+			select {
+			case listener.events <- event:
+			default:
+				// slow listener is disconnected instead of blocking changes of the sheet
+				close(listener.events)
+				delete(s.listeners, subscription)
+			}
+		}
+	}
+}
+
+func (s *ChangeEventStream) Subscribe(canonicalSheetId string, canonicalCellIds []string, lastEventId uint64) *contracts.ChangeStreamSubscription {
+	listener := &changeEventListener{
+		canonicalSheetId: canonicalSheetId,
+		cells:            map[string]bool{},
+		events:           make(chan contracts.ChangeEvent, changeEventListenerBufferSize),
+	}
+	for _, cellId := range canonicalCellIds {
+		listener.cells[cellId] = true
+	}
+	subscription := &contracts.ChangeStreamSubscription{
+		Replay: make([]contracts.ChangeEvent, 0),
+		Events: listener.events,
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		close(listener.events)
+		return subscription
+	}
+
+	if lastEventId > 0 {
+		sheet := s.getSheet(canonicalSheetId)
+		buffered := sheet.window(s.bufferSize)
+
+		firstEventId := sheet.lastEventId + 1
+		if len(buffered) > 0 {
+			firstEventId = buffered[0].Sequence
+		}
+		subscription.Reset = lastEventId > sheet.lastEventId || lastEventId+1 < firstEventId
+
+		if !subscription.Reset {
+			for _, event := range buffered {
+				if event.Sequence > lastEventId && listener.accepts(event) {
+					subscription.Replay = append(subscription.Replay, event)
+				}
+			}
+		}
+	}
+
+	s.listeners[subscription] = listener
+
+	return subscription
+}
+
+func (s *ChangeEventStream) Unsubscribe(subscription *contracts.ChangeStreamSubscription) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	listener, ok := s.listeners[subscription]
+	if !ok {
+		return
+	}
+
+	close(listener.events)
+	delete(s.listeners, subscription)
+}
+
+// Close disconnects all listeners (e.g. on shutdown), listeners which subscribe later are disconnected immediately
+func (s *ChangeEventStream) Close() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.closed = true
+	for subscription, listener := range s.listeners {
+		close(listener.events)
+		delete(s.listeners, subscription)
+	}
+}
+
+// getSheet the caller holds the mutex
+func (s *ChangeEventStream) getSheet(canonicalSheetId string) *sheetChangeEvents {
+	sheet, ok := s.sheets[canonicalSheetId]
+	if !ok {
+		sheet = &sheetChangeEvents{}
+		s.sheets[canonicalSheetId] = sheet
+	}
+
+	return sheet
+}
+
+func (sheet *sheetChangeEvents) add(event contracts.ChangeEvent, bufferSize int) {
+	sheet.buffer = append(sheet.buffer, event)
+	if len(sheet.buffer) > 2*bufferSize {
+		sheet.buffer = slices.Clone(sheet.window(bufferSize))
+	}
+}
+
+// window the last buffered events
+func (sheet *sheetChangeEvents) window(bufferSize int) []contracts.ChangeEvent {
+	return sheet.buffer[max(0, len(sheet.buffer)-bufferSize):]
+}
+
+func (listener *changeEventListener) accepts(event contracts.ChangeEvent) bool {
+	return len(listener.cells) == 0 || listener.cells[event.CanonicalCellId]
+}
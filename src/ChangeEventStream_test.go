@@ -0,0 +1,129 @@
+package main
+
+import (
+	"devChallengeExcel/contracts"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestChangeEventStream_Notify(t *testing.T) {
+	stream := NewChangeEventStream(10)
+	all := stream.Subscribe("sheet1", nil, 0)
+	cells := stream.Subscribe("sheet1", []string{"b1"}, 0)
+	other := stream.Subscribe("sheet2", nil, 0)
+
+	changes := _makeCellChanges([]*contracts.Cell{
+		{CanonicalKey: "a1", Value: "1", Result: "1"},
+		{CanonicalKey: "b1", Value: "=A1", Result: "1"},
+		{CanonicalKey: "c1", Value: "=max(A1, 5)", Result: "5"},
+	})
+	// result of c1 is not changed
+	changes[2].Previous = &contracts.Cell{CanonicalKey: "c1", Value: "=max(A1, 5)", Result: "5"}
+	stream.Notify("sheet1", changes, contracts.ChangeOrigin{})
+
+	first := <-all.Events
+	assert.Equal(t, uint64(1), first.Sequence)
+	assert.Equal(t, "a1", first.CanonicalCellId)
+	assert.Equal(t, "A1", first.CellId)
+	assert.Equal(t, contracts.CellChangedEventType, first.Type)
+	assert.Equal(t, "sheet1", first.SheetId)
+	assert.Equal(t, uint64(2), (<-all.Events).Sequence)
+	assert.Empty(t, all.Events)
+
+	assert.Equal(t, "b1", (<-cells.Events).CanonicalCellId)
+	assert.Empty(t, cells.Events)
+	assert.Empty(t, other.Events)
+
+	stream.Notify("sheet2", _makeCellChanges([]*contracts.Cell{{CanonicalKey: "a1", Value: "1", Result: "1"}}), contracts.ChangeOrigin{})
+	// each sheet has own sequence
+	assert.Equal(t, uint64(1), (<-other.Events).Sequence)
+
+	stream.Unsubscribe(all)
+	_, ok := <-all.Events
+	assert.False(t, ok)
+	stream.Unsubscribe(all)
+}
+
+func TestChangeEventStream_Replay(t *testing.T) {
+	stream := NewChangeEventStream(3)
+	for i := 0; i < 10; i++ {
+		stream.Notify("sheet1", _makeCellChanges([]*contracts.Cell{
+			{CanonicalKey: "a1", Value: "1", Result: "1"},
+			{CanonicalKey: "b1", Value: "1", Result: "1"},
+		}), contracts.ChangeOrigin{})
+	}
+
+	sequences := func(subscription *contracts.ChangeStreamSubscription) (result []uint64) {
+		for _, event := range subscription.Replay {
+			result = append(result, event.Sequence)
+		}
+		return
+	}
+
+	subscription := stream.Subscribe("sheet1", nil, 17)
+	assert.False(t, subscription.Reset)
+	assert.Equal(t, []uint64{18, 19, 20}, sequences(subscription))
+
+	subscription = stream.Subscribe("sheet1", []string{"a1"}, 17)
+	assert.Equal(t, []uint64{19}, sequences(subscription))
+
+	subscription = stream.Subscribe("sheet1", nil, 20)
+	assert.False(t, subscription.Reset)
+	assert.Empty(t, subscription.Replay)
+
+	subscription = stream.Subscribe("sheet1", nil, 0)
+	assert.False(t, subscription.Reset)
+	assert.Empty(t, subscription.Replay)
+
+	// events are not in the buffer anymore
+	subscription = stream.Subscribe("sheet1", nil, 16)
+	assert.True(t, subscription.Reset)
+	assert.Empty(t, subscription.Replay)
+
+	// stream is restarted
+	subscription = stream.Subscribe("sheet2", nil, 5)
+	assert.True(t, subscription.Reset)
+}
+
+func TestChangeEventStream_SlowListener(t *testing.T) {
+	stream := NewChangeEventStream(10)
+	subscription := stream.Subscribe("sheet1", nil, 0)
+
+	for i := 0; i <= changeEventListenerBufferSize; i++ {
+		stream.Notify("sheet1", _makeCellChanges([]*contracts.Cell{{CanonicalKey: "a1", Value: "1", Result: "1"}}), contracts.ChangeOrigin{})
+	}
+
+	received := 0
+	for range subscription.Events {
+		received++
+	}
+	assert.Equal(t, changeEventListenerBufferSize, received)
+	assert.Empty(t, stream.listeners)
+
+	// the listener resumes with the last received event
+	subscription = stream.Subscribe("sheet1", nil, uint64(received))
+	assert.False(t, subscription.Reset)
+	assert.Len(t, subscription.Replay, 1)
+}
+
+func TestChangeEventStream_Close(t *testing.T) {
+	stream := NewChangeEventStream(10)
+	subscription := stream.Subscribe("sheet1", nil, 0)
+	stream.Notify("sheet1", _makeCellChanges([]*contracts.Cell{{CanonicalKey: "a1", Value: "1", Result: "1"}}), contracts.ChangeOrigin{})
+
+	stream.Close()
+
+	received := 0
+	for range subscription.Events {
+		received++
+	}
+	assert.Equal(t, 1, received)
+	assert.Empty(t, stream.listeners)
+
+	// new listener is disconnected immediately
+	subscription = stream.Subscribe("sheet1", nil, 0)
+	_, ok := <-subscription.Events
+	assert.False(t, ok)
+	assert.Empty(t, stream.listeners)
+	stream.Unsubscribe(subscription)
+}
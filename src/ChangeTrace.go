@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+)
+
+// ChangeTraceHeader carries contracts.ChangeOrigin trace with webhooks between instances
+const ChangeTraceHeader = "X-Change-Trace"
+
+// MaxChangeTraceLength the change which passed through more cells is considered as circular
+const MaxChangeTraceLength = 32
+
+func formatChangeTrace(trace []string) string {
+	escaped := make([]string, len(trace))
+	for index, originId := range trace {
+		escaped[index] = url.QueryEscape(originId)
+	}
+
+	return strings.Join(escaped, ",")
+}
+
+func parseChangeTrace(header string) []string {
+	trace := make([]string, 0)
+	if header == "" {
+		return trace
+	}
+
+	for _, escaped := range strings.Split(header, ",") {
+		if originId, err := url.QueryUnescape(strings.TrimSpace(escaped)); err == nil && originId != "" {
+			trace = append(trace, originId)
+		}
+	}
+
+	return trace
+}
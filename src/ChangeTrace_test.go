@@ -0,0 +1,16 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestChangeTrace(t *testing.T) {
+	trace := []string{"host1:8080/sheet1/a1", "host2:8080/sheet,2/b1", "host3/sheet 3/c1"}
+
+	header := formatChangeTrace(trace)
+	assert.Equal(t, trace, parseChangeTrace(header))
+
+	assert.Equal(t, []string{}, parseChangeTrace(""))
+	assert.Equal(t, []string{"a", "b"}, parseChangeTrace("a, ,b,%zz"))
+}
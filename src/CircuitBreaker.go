@@ -0,0 +1,88 @@
+package main
+
+import (
+	"devChallengeExcel/contracts"
+	"sync"
+	"time"
+)
+
+// CircuitBreaker opens after `threshold` consecutive failures and rejects requests for `openDuration`.
+// Then single trial request is allowed (half-open): success closes the breaker, failure opens it again.
+type CircuitBreaker struct {
+	mutex         sync.Mutex
+	threshold     int
+	openDuration  time.Duration
+	state         string
+	failures      int
+	openedAt      time.Time
+	trialInFlight bool
+}
+
+func NewCircuitBreaker(threshold int, openDuration time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		threshold:    threshold,
+		openDuration: openDuration,
+		state:        contracts.CircuitBreakerClosed,
+	}
+}
+
+func (b *CircuitBreaker) Allow() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	switch b.state {
+	case contracts.CircuitBreakerOpen:
+		if time.Since(b.openedAt) < b.openDuration {
+			return false
+		}
+		b.state = contracts.CircuitBreakerHalfOpen
+		b.trialInFlight = true
+		return true
+	case contracts.CircuitBreakerHalfOpen:
+		if b.trialInFlight {
+			return false
+		}
+		b.trialInFlight = true
+		return true
+	}
+
+	return true
+}
+
+func (b *CircuitBreaker) Success() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.state = contracts.CircuitBreakerClosed
+	b.failures = 0
+	b.trialInFlight = false
+}
+
+func (b *CircuitBreaker) Failure() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.failures++
+	b.trialInFlight = false
+	if b.state == contracts.CircuitBreakerHalfOpen || b.failures >= b.threshold {
+		b.state = contracts.CircuitBreakerOpen
+		b.openedAt = time.Now()
+	}
+}
+
+func (b *CircuitBreaker) Status(host string) contracts.CircuitBreakerStatus {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	status := contracts.CircuitBreakerStatus{
+		Host:     host,
+		State:    b.state,
+		Failures: b.failures,
+	}
+	if b.state != contracts.CircuitBreakerClosed {
+		openedAt := b.openedAt
+		status.OpenedAt = &openedAt
+	}
+
+	return status
+}
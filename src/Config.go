@@ -0,0 +1,115 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Config struct {
+	// StorageBackend of the database: bolt (default), sqlite or memory (data is lost on restart)
+	StorageBackend string
+	// DatabaseFilepath path to file with database (bbolt or SQLite)
+	DatabaseFilepath string
+	// ShutdownTimeout deadline of graceful shutdown: active requests and due webhooks are completed within it
+	ShutdownTimeout time.Duration
+	// ExternalRefCacheTtl how long result of external_ref is used without revalidation
+	ExternalRefCacheTtl time.Duration
+	// ExternalJsonPollInterval how often watched external_json documents are refreshed
+	ExternalJsonPollInterval time.Duration
+	// Egress policy of external_ref and webhook urls
+	Egress EgressPolicyConfig
+	// Outbound retries and circuit breaker of external_ref requests
+	Outbound OutboundClientConfig
+	// WebhookSecret shared secret of peer instances to sign webhooks. Empty secret disables signatures
+	WebhookSecret string
+	// WebhookSignatureTolerance max age of signed webhook
+	WebhookSignatureTolerance time.Duration
+	// WebhookRetry retries of webhooks which are not delivered
+	WebhookRetry WebhookRetryConfig
+	// WebhookDeliveryLogSize delivery attempts kept per subscription
+	WebhookDeliveryLogSize int
+	// CellHistory retention policy of versions of cells
+	CellHistory CellHistoryConfig
+	// UndoStackSize writes of cells kept per sheet to undo
+	UndoStackSize int
+	// ChangeEventStreamBufferSize events of each sheet kept to resume event stream (Last-Event-ID)
+	ChangeEventStreamBufferSize int
+}
+
+const (
+	StorageBackendBolt   = "bolt"
+	StorageBackendSqlite = "sqlite"
+	StorageBackendMemory = "memory"
+)
+
+const DefaultExternalRefCacheTtl = 30 * time.Second
+const DefaultExternalJsonPollInterval = time.Minute
+
+func NewConfigFromEnv() Config {
+	return Config{
+		StorageBackend:           os.Getenv("STORAGE_BACKEND"),
+		DatabaseFilepath:         os.Getenv("DATABASE_FILEPATH"),
+		ShutdownTimeout:          getEnvDuration("SHUTDOWN_TIMEOUT", DefaultShutdownTimeout),
+		ExternalRefCacheTtl:      getEnvDuration("EXTERNAL_REF_CACHE_TTL", DefaultExternalRefCacheTtl),
+		ExternalJsonPollInterval: getEnvDuration("EXTERNAL_JSON_POLL_INTERVAL", DefaultExternalJsonPollInterval),
+		Egress: EgressPolicyConfig{
+			AllowedSchemes: getEnvList("EGRESS_ALLOWED_SCHEMES", DefaultEgressAllowedSchemes),
+			AllowedHosts:   getEnvList("EGRESS_ALLOWED_HOSTS", nil),
+			DeniedHosts:    getEnvList("EGRESS_DENIED_HOSTS", nil),
+			AllowedCidrs:   getEnvList("EGRESS_ALLOWED_CIDRS", nil),
+			DeniedCidrs:    getEnvList("EGRESS_DENIED_CIDRS", DefaultEgressDeniedCidrs),
+		},
+		WebhookSecret:             os.Getenv("WEBHOOK_SECRET"),
+		WebhookSignatureTolerance: getEnvDuration("WEBHOOK_SIGNATURE_TOLERANCE", DefaultWebhookSignatureTolerance),
+		WebhookRetry: WebhookRetryConfig{
+			MaxAttempts:     getEnvInt("WEBHOOK_MAX_ATTEMPTS", DefaultWebhookMaxAttempts),
+			RetryBackoff:    getEnvDuration("WEBHOOK_RETRY_BACKOFF", DefaultWebhookRetryBackoff),
+			MaxRetryBackoff: getEnvDuration("WEBHOOK_MAX_RETRY_BACKOFF", DefaultWebhookMaxRetryBackoff),
+		},
+		WebhookDeliveryLogSize: getEnvInt("WEBHOOK_DELIVERY_LOG_SIZE", DefaultWebhookDeliveryLogSize),
+		CellHistory: CellHistoryConfig{
+			MaxVersions: getEnvInt("HISTORY_MAX_VERSIONS", DefaultCellHistoryMaxVersions),
+			MaxAge:      getEnvDuration("HISTORY_MAX_AGE", 0),
+		},
+		UndoStackSize:               getEnvInt("UNDO_STACK_SIZE", DefaultUndoStackSize),
+		ChangeEventStreamBufferSize: getEnvInt("EVENT_STREAM_BUFFER_SIZE", DefaultChangeEventStreamBufferSize),
+		Outbound: OutboundClientConfig{
+			MaxAttempts:                getEnvInt("EXTERNAL_REF_MAX_ATTEMPTS", DefaultOutboundMaxAttempts),
+			RetryBackoff:               getEnvDuration("EXTERNAL_REF_RETRY_BACKOFF", DefaultOutboundRetryBackoff),
+			CircuitBreakerThreshold:    getEnvInt("CIRCUIT_BREAKER_THRESHOLD", DefaultOutboundCircuitBreakerThreshold),
+			CircuitBreakerOpenDuration: getEnvDuration("CIRCUIT_BREAKER_OPEN_DURATION", DefaultOutboundCircuitBreakerOpenDuration),
+		},
+	}
+}
+
+func getEnvDuration(name string, defaultValue time.Duration) time.Duration {
+	if value, err := time.ParseDuration(os.Getenv(name)); err == nil {
+		return value
+	}
+
+	return defaultValue
+}
+
+func getEnvInt(name string, defaultValue int) int {
+	if value, err := strconv.Atoi(os.Getenv(name)); err == nil {
+		return value
+	}
+
+	return defaultValue
+}
+
+// getEnvList parses comma separated list. Set variable to "-" for empty list
+func getEnvList(name string, defaultValue []string) []string {
+	value, ok := os.LookupEnv(name)
+	if !ok || value == "" {
+		return defaultValue
+	}
+
+	if value == "-" {
+		return []string{}
+	}
+
+	return strings.Split(value, ",")
+}
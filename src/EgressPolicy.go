@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var EgressPolicyError = errors.New("url is not allowed by egress policy")
+
+// DefaultEgressDeniedCidrs loopback, link-local (incl. cloud metadata 169.254.169.254), unspecified and multicast addresses
+var DefaultEgressDeniedCidrs = []string{
+	"127.0.0.0/8", "::1/128",
+	"169.254.0.0/16", "fe80::/10",
+	"0.0.0.0/8", "::/128",
+	"224.0.0.0/4", "ff00::/8",
+}
+
+var DefaultEgressAllowedSchemes = []string{"http", "https"}
+
+type EgressPolicyConfig struct {
+	// AllowedSchemes e.g. http, https
+	AllowedSchemes []string
+	// AllowedHosts when not empty, only these hosts are allowed. `*.example.com` matches subdomains
+	AllowedHosts []string
+	// DeniedHosts are always rejected. `*.example.com` matches subdomains
+	DeniedHosts []string
+	// AllowedCidrs are allowed even if they are in DeniedCidrs. When not empty, other addresses are rejected
+	AllowedCidrs []string
+	// DeniedCidrs are rejected, unless they are in AllowedCidrs
+	DeniedCidrs []string
+}
+
+// EgressPolicy protects outgoing requests from SSRF.
+// Url is validated when it is accepted, and resolved IP is validated again on dial (safe against DNS rebinding).
+type EgressPolicy struct {
+	schemes         map[string]bool
+	allowedHosts    []string
+	deniedHosts     []string
+	allowedNetworks []*net.IPNet
+	deniedNetworks  []*net.IPNet
+	resolver        *net.Resolver
+}
+
+func NewEgressPolicy(config EgressPolicyConfig) (policy *EgressPolicy, err error) {
+	policy = &EgressPolicy{
+		schemes:      map[string]bool{},
+		allowedHosts: normalizeHosts(config.AllowedHosts),
+		deniedHosts:  normalizeHosts(config.DeniedHosts),
+		resolver:     net.DefaultResolver,
+	}
+
+	for _, scheme := range config.AllowedSchemes {
+		policy.schemes[strings.ToLower(strings.TrimSpace(scheme))] = true
+	}
+
+	if policy.allowedNetworks, err = parseCidrs(config.AllowedCidrs); err != nil {
+		return nil, err
+	}
+	if policy.deniedNetworks, err = parseCidrs(config.DeniedCidrs); err != nil {
+		return nil, err
+	}
+
+	return
+}
+
+func (p *EgressPolicy) ValidateUrl(rawUrl string) error {
+	parsedUrl, err := p.validateUrlHost(rawUrl)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*2)
+	defer cancel()
+
+	// unresolvable host is not rejected here: request fails anyway and dialer checks the address
+	addresses, err := p.resolver.LookupIPAddr(ctx, parsedUrl.Hostname())
+	if err != nil {
+		return nil
+	}
+
+	for _, address := range addresses {
+		if !p.isAllowedIp(address.IP) {
+			return makeEgressPolicyError(rawUrl, "address "+address.IP.String()+" is denied")
+		}
+	}
+
+	return nil
+}
+
+func (p *EgressPolicy) NewClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		// address is already resolved here, so DNS answer can't be changed after the check
+		Control: func(network string, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+
+			if ip := net.ParseIP(host); ip == nil || !p.isAllowedIp(ip) {
+				return makeEgressPolicyError(address, "address is denied")
+			}
+
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// proxy would connect to the target instead of the dialer
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(request *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+
+			_, err := p.validateUrlHost(request.URL.String())
+			return err
+		},
+	}
+}
+
+// validateUrlHost checks scheme and host names (without DNS resolving)
+func (p *EgressPolicy) validateUrlHost(rawUrl string) (*url.URL, error) {
+	parsedUrl, err := url.Parse(rawUrl)
+	if err != nil {
+		return nil, makeEgressPolicyError(rawUrl, err.Error())
+	}
+
+	if !p.schemes[strings.ToLower(parsedUrl.Scheme)] {
+		return nil, makeEgressPolicyError(rawUrl, "scheme '"+parsedUrl.Scheme+"' is not allowed")
+	}
+
+	host := strings.ToLower(strings.TrimSuffix(parsedUrl.Hostname(), "."))
+	if host == "" {
+		return nil, makeEgressPolicyError(rawUrl, "host is empty")
+	}
+
+	if matchHost(p.deniedHosts, host) {
+		return nil, makeEgressPolicyError(rawUrl, "host '"+host+"' is denied")
+	}
+
+	if len(p.allowedHosts) != 0 && !matchHost(p.allowedHosts, host) {
+		return nil, makeEgressPolicyError(rawUrl, "host '"+host+"' is not in allow list")
+	}
+
+	if ip := net.ParseIP(host); ip != nil && !p.isAllowedIp(ip) {
+		return nil, makeEgressPolicyError(rawUrl, "address "+ip.String()+" is denied")
+	}
+
+	return parsedUrl, nil
+}
+
+func (p *EgressPolicy) isAllowedIp(ip net.IP) bool {
+	if containsIp(p.allowedNetworks, ip) {
+		return true
+	}
+
+	if containsIp(p.deniedNetworks, ip) {
+		return false
+	}
+
+	return len(p.allowedNetworks) == 0
+}
+
+func makeEgressPolicyError(rawUrl string, reason string) error {
+	return fmt.Errorf("%w: %s (%s)", EgressPolicyError, rawUrl, reason)
+}
+
+func matchHost(patterns []string, host string) bool {
+	for _, pattern := range patterns {
+		if pattern == host {
+			return true
+		}
+
+		if suffix, ok := strings.CutPrefix(pattern, "*"); ok && strings.HasSuffix(host, suffix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func normalizeHosts(hosts []string) []string {
+	normalized := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		host = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(host), "."))
+		if host != "" {
+			normalized = append(normalized, host)
+		}
+	}
+
+	return normalized
+}
+
+func containsIp(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseCidrs accepts CIDR blocks and single IP addresses
+func parseCidrs(cidrs []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		cidr = strings.TrimSpace(cidr)
+		if cidr == "" {
+			continue
+		}
+
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid CIDR %s", cidr)
+			}
+			if ip4 := ip.To4(); ip4 != nil {
+				ip = ip4
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, network)
+	}
+
+	return networks, nil
+}
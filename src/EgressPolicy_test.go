@@ -0,0 +1,142 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestEgressPolicy_ValidateUrl(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		policy, err := NewEgressPolicy(EgressPolicyConfig{
+			AllowedSchemes: DefaultEgressAllowedSchemes,
+			DeniedCidrs:    DefaultEgressDeniedCidrs,
+		})
+		assert.NoError(t, err)
+
+		for _, allowedUrl := range []string{
+			"http://10.0.0.5:8080/api/v1/sheet1/a1",
+			"HTTPS://192.168.1.1/api/v1/sheet1/a1",
+			"http://172.16.0.1/webhook",
+		} {
+			assert.NoError(t, policy.ValidateUrl(allowedUrl), allowedUrl)
+		}
+
+		for _, deniedUrl := range []string{
+			"http://127.0.0.1:8080/api/v1/sheet1/a1",
+			"http://localhost:8080/api/v1/sheet1/a1",
+			"http://169.254.169.254/latest/meta-data",
+			"http://[::1]/webhook",
+			"http://[fe80::1]/webhook",
+			"http://0.0.0.0/webhook",
+			"http://[::ffff:127.0.0.1]/webhook",
+			"ftp://10.0.0.5/file",
+			"file:///etc/passwd",
+			"/relative/url",
+			":not-url",
+		} {
+			assert.ErrorIs(t, policy.ValidateUrl(deniedUrl), EgressPolicyError, deniedUrl)
+		}
+	})
+
+	t.Run("hosts", func(t *testing.T) {
+		policy, err := NewEgressPolicy(EgressPolicyConfig{
+			AllowedSchemes: []string{"https"},
+			AllowedHosts:   []string{"*.example.com", "api.local."},
+			DeniedHosts:    []string{"admin.example.com"},
+		})
+		assert.NoError(t, err)
+
+		// host names are checked without DNS resolving
+		validateHost := func(rawUrl string) error {
+			_, err := policy.validateUrlHost(rawUrl)
+			return err
+		}
+
+		assert.NoError(t, validateHost("https://sheets.example.com/api/v1/sheet1/a1"))
+		assert.NoError(t, validateHost("https://API.local/api/v1/sheet1/a1"))
+		assert.ErrorIs(t, validateHost("https://admin.example.com/api"), EgressPolicyError)
+		assert.ErrorIs(t, validateHost("https://example.org/api"), EgressPolicyError)
+		assert.ErrorIs(t, validateHost("http://sheets.example.com/api"), EgressPolicyError)
+		assert.ErrorContains(t, validateHost("https://example.org/api"), "not in allow list")
+	})
+
+	t.Run("cidrs", func(t *testing.T) {
+		policy, err := NewEgressPolicy(EgressPolicyConfig{
+			AllowedSchemes: DefaultEgressAllowedSchemes,
+			AllowedCidrs:   []string{"10.1.0.0/16", "127.0.0.1"},
+			DeniedCidrs:    DefaultEgressDeniedCidrs,
+		})
+		assert.NoError(t, err)
+
+		assert.NoError(t, policy.ValidateUrl("http://10.1.2.3/webhook"))
+		assert.NoError(t, policy.ValidateUrl("http://127.0.0.1/webhook"))
+		assert.ErrorIs(t, policy.ValidateUrl("http://127.0.0.2/webhook"), EgressPolicyError)
+		assert.ErrorIs(t, policy.ValidateUrl("http://10.2.0.1/webhook"), EgressPolicyError)
+	})
+
+	t.Run("invalid_config", func(t *testing.T) {
+		_, err := NewEgressPolicy(EgressPolicyConfig{DeniedCidrs: []string{"10.0.0.0/33"}})
+		assert.Error(t, err)
+
+		_, err = NewEgressPolicy(EgressPolicyConfig{AllowedCidrs: []string{"not-ip"}})
+		assert.Error(t, err)
+	})
+}
+
+func TestEgressPolicy_NewClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "http://169.254.169.254/latest", http.StatusFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	t.Run("denied_on_dial", func(t *testing.T) {
+		policy, _ := NewEgressPolicy(EgressPolicyConfig{
+			AllowedSchemes: DefaultEgressAllowedSchemes,
+			DeniedCidrs:    DefaultEgressDeniedCidrs,
+		})
+
+		// e.g. host name resolved to loopback after the url was validated (DNS rebinding)
+		_, err := policy.NewClient(time.Second).Get(server.URL)
+		assert.ErrorIs(t, err, EgressPolicyError)
+	})
+
+	t.Run("allowed", func(t *testing.T) {
+		response, err := _makeLoopbackEgressPolicy(t).NewClient(time.Second).Get(server.URL)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+	})
+
+	t.Run("denied_redirect", func(t *testing.T) {
+		_, err := _makeLoopbackEgressPolicy(t).NewClient(time.Second).Get(server.URL + "/redirect")
+		assert.ErrorIs(t, err, EgressPolicyError)
+	})
+}
+
+func TestGetEnvList(t *testing.T) {
+	t.Setenv("EGRESS_ALLOWED_HOSTS", "a.com,*.b.com")
+	assert.Equal(t, []string{"a.com", "*.b.com"}, getEnvList("EGRESS_ALLOWED_HOSTS", nil))
+
+	t.Setenv("EGRESS_DENIED_CIDRS", "-")
+	assert.Equal(t, []string{}, getEnvList("EGRESS_DENIED_CIDRS", DefaultEgressDeniedCidrs))
+
+	assert.Equal(t, DefaultEgressAllowedSchemes, getEnvList("EGRESS_NOT_DEFINED", DefaultEgressAllowedSchemes))
+}
+
+// _makeLoopbackEgressPolicy allows test servers on loopback
+func _makeLoopbackEgressPolicy(t *testing.T) *EgressPolicy {
+	policy, err := NewEgressPolicy(EgressPolicyConfig{
+		AllowedSchemes: DefaultEgressAllowedSchemes,
+		AllowedCidrs:   []string{"127.0.0.0/8", "::1/128"},
+		DeniedCidrs:    DefaultEgressDeniedCidrs,
+	})
+	assert.NoError(t, err)
+
+	return policy
+}
@@ -16,27 +16,48 @@ type ExpressionExecutor struct {
 	canonicalizer   contracts.Canonicalizer
 	compilerOptions []expr.Option
 	vmPool          sync.Pool
+	// conditions compiled webhook conditions (key is condition)
+	conditions sync.Map
+}
+
+// conditionEnv variables of webhook condition: results of the cell before (nil for new cell) and after the change
+type conditionEnv struct {
+	Old any `expr:"old"`
+	New any `expr:"new"`
 }
 
 const FormulaPrefix = "="
 
 const FormulaExecutionInProcess = '\n'
 
+// PendingResult result of formula which waits for external_ref to be fetched
+const PendingResult = "#PENDING"
+
+// CircularResult result of formula which external_ref chain returns to itself through other instances
+const CircularResult = "#CIRC!"
+
+// externalRefPending marks variable which result is pending
+type externalRefPending struct{}
+
 var ExpressionError = errors.New("expression error")
 
 var CircularReferenceError = fmt.Errorf("%w: %s", ExpressionError, "circular reference detected")
 
+var IterationNotConvergedError = fmt.Errorf("%w: %s", ExpressionError, "iterative calculation does not converge")
+
+var ConditionNotBooleanError = fmt.Errorf("%w: %s", ExpressionError, "condition result is not boolean")
+
 var ExpressionFunctions = []expr.Option{
 	maxFunction,
 	minFunction,
 	sumFunction,
 	avgFunction,
-	externalRefFunction,
 }
 
 type FindExternalRefsFunc func(expression string) []string
 
-func NewExpressionExecutor(canonicalizer contracts.Canonicalizer) *ExpressionExecutor {
+// NewExpressionExecutor functions - additional functions (e.g. external_ref) besides ExpressionFunctions
+func NewExpressionExecutor(canonicalizer contracts.Canonicalizer, functions ...expr.Option) *ExpressionExecutor {
 	options := append(
 		[]expr.Option{
 			expr.Env(map[string]any{}),
@@ -46,6 +67,7 @@ func NewExpressionExecutor(canonicalizer contracts.Canonicalizer) *ExpressionExe
 		},
 		ExpressionFunctions...,
 	)
+	options = append(options, functions...)
 
 	return &ExpressionExecutor{
 		canonicalizer:   canonicalizer,
@@ -69,13 +91,12 @@ func (e *ExpressionExecutor) MultiEvaluate(expressions contracts.ExpressionsMap,
 	for cellId, expression := range expressions {
 		currentErr = nil
 		if e.IsFormula(*expression) {
-			vars[cellId] = FormulaExecutionInProcess
-			vars[cellId], currentErr = e.doEvaluate(*expression, cellValuesFromExpression, vars)
+			currentErr = e.evaluateCell(cellId, *expression, cellValuesFromExpression, vars, nil)
 			*expressions[cellId] = e.outputToString(vars[cellId], currentErr)
 		}
 
-		if currentErr == nil && isNumeric(cellId) && !isNumeric(expression) {
-			currentErr = contracts.CellIdNumericError
+		if currentErr == nil {
+			currentErr = checkCellResult(cellId, *expression)
 		}
 
 		if firstErr == nil && currentErr != nil {
@@ -89,6 +110,27 @@ func (e *ExpressionExecutor) MultiEvaluate(expressions contracts.ExpressionsMap,
 	return firstErr
 }
 
+// evaluateCell evaluates formula of the cell into vars
+func (e *ExpressionExecutor) evaluateCell(cellId string, expression string, sheet contracts.CellValuesGetter, vars map[string]any, it *iteration) (err error) {
+	vars[cellId] = FormulaExecutionInProcess
+	vars[cellId], err = e.doEvaluate(expression, sheet, vars, it)
+	if errors.Is(err, ExternalRefPendingError) {
+		// pending is not an error, the cell will be recalculated when external_ref is fetched
+		vars[cellId], err = externalRefPending{}, nil
+	}
+
+	return
+}
+
+// checkCellResult cell with numeric id must have numeric result
+func checkCellResult(cellId string, result string) error {
+	if isNumeric(cellId) && !isNumeric(result) && result != PendingResult {
+		return contracts.CellIdNumericError
+	}
+
+	return nil
+}
+
 func (e *ExpressionExecutor) Evaluate(expression string, sheet contracts.CellValuesGetter) (string, error) {
 	// not formula
 	if !e.IsFormula(expression) {
@@ -96,8 +138,10 @@ func (e *ExpressionExecutor) Evaluate(expression string, sheet contracts.CellVal
 	}
 
 	vars := make(map[string]any)
-	output, err := e.doEvaluate(expression, sheet, vars)
-	if err != nil {
+	output, err := e.doEvaluate(expression, sheet, vars, nil)
+	if errors.Is(err, ExternalRefPendingError) {
+		return PendingResult, nil
+	} else if err != nil {
 		err = fmt.Errorf("%s: %w", expression, err)
 	}
 	return e.outputToString(output, err), err
@@ -123,22 +167,96 @@ func (e *ExpressionExecutor) ExtractDependingOnList(expression string) []string
 }
 
 func (e *ExpressionExecutor) ExtractExternalRefs(expression string) []string {
+	return e.findExternalRefs(expression).externalRefs
+}
+
+func (e *ExpressionExecutor) ExtractExternalJsonUrls(expression string) []string {
+	return e.findExternalRefs(expression).externalJsonUrls
+}
+
+func (e *ExpressionExecutor) findExternalRefs(expression string) *FindExternalRefsVisitor {
+	finder := &FindExternalRefsVisitor{
+		externalRefs:     make([]string, 0, 20),
+		externalJsonUrls: make([]string, 0),
+	}
+
 	// not formula
 	if !e.IsFormula(expression) {
-		return make([]string, 0)
+		return finder
 	}
 
 	program, err := e.compile(expression)
 	if err != nil {
-		return make([]string, 0)
+		return finder
 	}
 
-	finder := &FindExternalRefsVisitor{
-		externalRefs: make([]string, 0, 20),
-	}
 	node := program.Node()
 	ast.Walk(&node, finder)
-	return finder.externalRefs
+	return finder
+}
+
+// ValidateCondition compiles the condition and evaluates it with numeric results, because types of results are known
+// only in runtime: the condition which is not boolean for numbers is rejected, runtime errors are not
+func (e *ExpressionExecutor) ValidateCondition(condition string) error {
+	program, err := e.compileCondition(condition)
+	if err != nil {
+		return err
+	}
+
+	output, err := expr.Run(program, conditionEnv{Old: 0, New: 0})
+	if _, ok := output.(bool); err == nil && !ok {
+		return ConditionNotBooleanError
+	}
+
+	return nil
+}
+
+func (e *ExpressionExecutor) EvaluateCondition(condition string, previousResult *string, result string) (bool, error) {
+	program, err := e.compileCondition(condition)
+	if err != nil {
+		return false, err
+	}
+
+	env := conditionEnv{New: parseValue(result)}
+	if previousResult != nil {
+		env.Old = parseValue(*previousResult)
+	}
+
+	output, err := expr.Run(program, env)
+	if err != nil {
+		return false, fmt.Errorf("%w: %w", ExpressionError, err)
+	}
+
+	holds, ok := output.(bool)
+	if !ok {
+		return false, ConditionNotBooleanError
+	}
+
+	return holds, nil
+}
+
+// compileCondition condition is not canonicalized (it is not a formula of the cell), so string literals keep their case
+func (e *ExpressionExecutor) compileCondition(condition string) (*vm.Program, error) {
+	if program, ok := e.conditions.Load(condition); ok {
+		return program.(*vm.Program), nil
+	}
+
+	options := append([]expr.Option{expr.Env(conditionEnv{}), expr.AsBool(), expr.DisableAllBuiltins()}, ExpressionFunctions...)
+	program, err := expr.Compile(condition, options...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ExpressionError, err)
+	}
+	e.conditions.Store(condition, program)
+
+	return program, nil
+}
+
+func (e *ExpressionExecutor) WithIteration(maxIterations int, epsilon float64) contracts.ExpressionExecutor {
+	return &IterativeExpressionExecutor{
+		ExpressionExecutor: e,
+		maxIterations:      maxIterations,
+		epsilon:            epsilon,
+	}
 }
 
 func (e *ExpressionExecutor) compile(expression string) (*vm.Program, error) {
@@ -148,13 +266,13 @@ func (e *ExpressionExecutor) compile(expression string) (*vm.Program, error) {
 	)
 }
 
-func (e *ExpressionExecutor) doEvaluate(expression string, sheet contracts.CellValuesGetter, vars map[string]any) (out any, err error) {
+func (e *ExpressionExecutor) doEvaluate(expression string, sheet contracts.CellValuesGetter, vars map[string]any, it *iteration) (out any, err error) {
 	program, err := e.compile(expression)
 	if err != nil {
 		return "", err
 	}
 
-	err = e.lookupAndFillVars(program, sheet, vars)
+	err = e.lookupAndFillVars(program, sheet, vars, it)
 	if err != nil {
 		return "", err
 	}
@@ -172,8 +290,9 @@ func (e *ExpressionExecutor) IsFormula(expression string) bool {
 /**
  * Retrieve variable which are used in expression and still not filled
  * @param program
+ * @param it - state of iterative calculation, nil when circular references are forbidden
  */
-func (e *ExpressionExecutor) lookupAndFillVars(program *vm.Program, valuesGetter contracts.CellValuesGetter, vars map[string]any) error {
+func (e *ExpressionExecutor) lookupAndFillVars(program *vm.Program, valuesGetter contracts.CellValuesGetter, vars map[string]any, it *iteration) error {
 	variablesNamesToFetch := make([]string, 0, len(program.Constants))
 	constantIndexes := make([]int, 0, len(program.Constants))
 
@@ -188,8 +307,16 @@ func (e *ExpressionExecutor) lookupAndFillVars(program *vm.Program, valuesGetter
 		if _, ok = vars[variableName]; !ok {
 			variablesNamesToFetch = append(variablesNamesToFetch, variableName)
 			constantIndexes = append(constantIndexes, constantIndex)
+		} else if _, ok = vars[variableName].(externalRefPending); ok {
+			return ExternalRefPendingError
 		} else if vars[variableName] == FormulaExecutionInProcess {
-			return fmt.Errorf("%s: %w", variableName, CircularReferenceError)
+			if it == nil {
+				return fmt.Errorf("%s: %w", variableName, CircularReferenceError)
+			}
+			// circular reference is resolved with result of previous iteration
+			it.hasCycle = true
+			vars[variableName] = it.previousValue(variableName)
+			e.overrideNumberConstant(program, constantIndex, vars[variableName])
 		} else {
 			e.overrideNumberConstant(program, constantIndex, vars[variableName])
 		}
@@ -201,8 +328,6 @@ func (e *ExpressionExecutor) lookupAndFillVars(program *vm.Program, valuesGetter
 	fetchedValues := valuesGetter(variablesNamesToFetch)
 
 	var stringValueRef *string
-	var floatValue float64
-	var intValue int64
 	var err error
 	for index, variableName = range variablesNamesToFetch {
 		constantIndex = constantIndexes[index]
@@ -213,17 +338,16 @@ func (e *ExpressionExecutor) lookupAndFillVars(program *vm.Program, valuesGetter
 		} else if e.IsFormula(*stringValueRef) {
 			// prevent recursive call - mark this variable as in process
 			vars[variableName] = FormulaExecutionInProcess
-			vars[variableName], err = e.doEvaluate(*stringValueRef, valuesGetter, vars)
-			if err != nil {
+			vars[variableName], err = e.doEvaluate(*stringValueRef, valuesGetter, vars, it)
+			if errors.Is(err, ExternalRefPendingError) {
+				vars[variableName] = externalRefPending{}
+				return err
+			} else if err != nil {
 				return err
 			}
 
-		} else if intValue, err = strconv.ParseInt(*stringValueRef, 10, 64); err == nil {
-			vars[variableName] = intValue
-		} else if floatValue, err = strconv.ParseFloat(*stringValueRef, 64); err == nil {
-			vars[variableName] = floatValue
 		} else {
-			vars[variableName] = *stringValueRef
+			vars[variableName] = parseValue(*stringValueRef)
 		}
 
 		e.overrideNumberConstant(program, constantIndex, vars[variableName])
@@ -241,7 +365,11 @@ func (e *ExpressionExecutor) overrideNumberConstant(program *vm.Program, constan
 }
 
 func (e *ExpressionExecutor) outputToString(output any, err error) string {
-	if err != nil {
+	if errors.Is(err, ExternalRefPendingError) {
+		return PendingResult
+	} else if errors.Is(err, ExternalRefCircularError) {
+		return CircularResult
+	} else if err != nil {
 		return "ERROR: " + err.Error()
 	}
 
@@ -258,11 +386,24 @@ func (e *ExpressionExecutor) toString(input any) string {
 		return strconv.FormatFloat(input.(float64), 'f', -1, 64)
 	case string:
 		return input.(string)
+	case externalRefPending:
+		return PendingResult
 	default:
 		return ""
 	}
 }
 
+// parseValue converts value of the cell (or of external source) into number when it is numeric
+func parseValue(value string) any {
+	if intValue, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return intValue
+	} else if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+		return floatValue
+	}
+
+	return value
+}
+
 func isNumeric(input any) bool {
 
 	switch (input).(type) {
@@ -3,6 +3,8 @@ package main
 import (
 	"devChallengeExcel/contracts"
 	"devChallengeExcel/mocks"
+	"fmt"
+	"github.com/expr-lang/expr"
 	"github.com/stretchr/testify/assert"
 	"strings"
 	"testing"
@@ -351,6 +353,54 @@ func TestExpressionExecutor_MultiEvaluate(t *testing.T) {
 	})
 }
 
+func TestExpressionExecutor_PendingExternalRef(t *testing.T) {
+	pendingFunction := expr.Function(ExternalRefFunctionName, func(args ...any) (any, error) {
+		return nil, ExternalRefPendingError
+	})
+
+	t.Run("evaluate", func(t *testing.T) {
+		valuesGetter := func(cellIds []string) []*string {
+			values := make([]*string, len(cellIds))
+			for index, cellId := range cellIds {
+				if cellId == "a1" {
+					values[index] = _makeStringRef(`=external_ref("http://remote") + 1`)
+				}
+			}
+			return values
+		}
+
+		executor := NewExpressionExecutor(NewCanonicalizer(), pendingFunction)
+		actual, err := executor.Evaluate("=A1 * 2", valuesGetter)
+
+		assert.NoError(t, err)
+		assert.Equal(t, PendingResult, actual)
+
+		actual, err = executor.WithIteration(10, 0.1).Evaluate("=A1 * 2", valuesGetter)
+		assert.NoError(t, err)
+		assert.Equal(t, PendingResult, actual)
+	})
+
+	t.Run("multi_evaluate", func(t *testing.T) {
+		expressions := contracts.ExpressionsMap{
+			"a1":  _makeStringRef(`=external_ref("http://remote")`),
+			"a2":  _makeStringRef("=A1 + 1"),
+			"a3":  _makeStringRef("=A2 + 1"),
+			"123": _makeStringRef("=A1"),
+			"b1":  _makeStringRef("=5"),
+		}
+
+		executor := NewExpressionExecutor(NewCanonicalizer(), pendingFunction)
+		err := executor.MultiEvaluate(expressions, nil, true)
+
+		assert.NoError(t, err)
+		assert.Equal(t, PendingResult, *expressions["a1"])
+		assert.Equal(t, PendingResult, *expressions["a2"])
+		assert.Equal(t, PendingResult, *expressions["a3"])
+		assert.Equal(t, PendingResult, *expressions["123"])
+		assert.Equal(t, "5", *expressions["b1"])
+	})
+}
+
 func TestExpressionExecutor_outputToString(t *testing.T) {
 	executor := NewExpressionExecutor(NewCanonicalizer())
 
@@ -358,6 +408,8 @@ func TestExpressionExecutor_outputToString(t *testing.T) {
 	assert.Equal(t, "text", executor.outputToString("text", nil))
 	assert.Equal(t, "5", executor.outputToString(5, nil))
 	assert.Equal(t, "5.5", executor.outputToString(5.5, nil))
+	assert.Equal(t, PendingResult, executor.outputToString(nil, ExternalRefPendingError))
+	assert.Equal(t, CircularResult, executor.outputToString(nil, fmt.Errorf("a1: %w", ExternalRefCircularError)))
 }
 
 func TestExpressionExecutor_ExtractDependingOnList(t *testing.T) {
@@ -377,6 +429,65 @@ func TestExpressionExecutor_ExtractDependingOnList(t *testing.T) {
 
 }
 
+func TestExpressionExecutor_ExtractExternalRefs(t *testing.T) {
+	fetcher := NewExternalRefFetcher(0, 0, nil, NewCanonicalizer())
+	executor := NewExpressionExecutor(NewCanonicalizer(), fetcher.Function(), fetcher.JsonFunction())
+
+	expression := `=external_ref("http://remote/api/v1/sheet1/a1") * external_json("http://fx/rates", "$.rates.EUR") + A1`
+	assert.Equal(t, []string{"http://remote/api/v1/sheet1/a1"}, executor.ExtractExternalRefs(expression))
+	assert.Equal(t, []string{"http://fx/rates"}, executor.ExtractExternalJsonUrls(expression))
+
+	assert.Equal(t, []string{}, executor.ExtractExternalRefs("=A1"))
+	assert.Equal(t, []string{}, executor.ExtractExternalJsonUrls("external_json(\"http://fx/rates\", \"$.a\")"))
+	assert.Equal(t, []string{}, executor.ExtractExternalJsonUrls("=external_json("))
+}
+
+func TestExpressionExecutor_EvaluateCondition(t *testing.T) {
+	executor := NewExpressionExecutor(NewCanonicalizer())
+
+	testCases := []struct {
+		condition      string
+		previousResult *string
+		result         string
+		expected       bool
+	}{
+		{"new < 10 && old >= 10", _makeStringRef("10"), "9", true},
+		{"new < 10 && old >= 10", _makeStringRef("9"), "8", false},
+		{"new < 10 && old >= 10", _makeStringRef("12"), "11", false},
+		{"old != nil && new < 10 && old >= 10", nil, "5", false},
+		{"old == nil", nil, "5", true},
+		{"new > old * 2", _makeStringRef("1.5"), "3.5", true},
+		{`new == "ok" && old != "ok"`, _makeStringRef("fail"), "ok", true},
+		{"max(new, old) > 100", _makeStringRef("150"), "4", true},
+	}
+
+	for _, testCase := range testCases {
+		holds, err := executor.EvaluateCondition(testCase.condition, testCase.previousResult, testCase.result)
+		assert.NoError(t, err, testCase.condition)
+		assert.Equal(t, testCase.expected, holds, testCase.condition)
+	}
+
+	// types of values are checked in runtime
+	_, err := executor.EvaluateCondition("new < 10", nil, "text")
+	assert.ErrorIs(t, err, ExpressionError)
+	_, err = executor.EvaluateCondition("new < 10 && old >= 10", nil, "5")
+	assert.ErrorIs(t, err, ExpressionError)
+	_, err = executor.EvaluateCondition(`new == "ok" ? 1 : true`, nil, "ok")
+	assert.ErrorIs(t, err, ConditionNotBooleanError)
+}
+
+func TestExpressionExecutor_ValidateCondition(t *testing.T) {
+	executor := NewExpressionExecutor(NewCanonicalizer())
+
+	assert.NoError(t, executor.ValidateCondition("new < 10 && old >= 10"))
+	assert.NoError(t, executor.ValidateCondition("new != old"))
+
+	assert.ErrorIs(t, executor.ValidateCondition("new <"), ExpressionError)
+	assert.ErrorIs(t, executor.ValidateCondition("new + 1"), ConditionNotBooleanError)
+	// cells of the sheet are not available in condition
+	assert.ErrorIs(t, executor.ValidateCondition("A1 > 1"), ExpressionError)
+}
+
 func TestIsNumeric(t *testing.T) {
 	assert.True(t, isNumeric(_makeStringRef("123")))
 	assert.True(t, isNumeric("123"))
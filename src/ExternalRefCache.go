@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ExternalRefCache shared cache of external_ref results.
+// Stale entry is kept as the last known value and to revalidate it with conditional request (ETag / Last-Modified).
+type ExternalRefCache struct {
+	ttl     time.Duration
+	mutex   sync.RWMutex
+	entries map[string]*ExternalRefCacheEntry
+}
+
+type ExternalRefCacheEntry struct {
+	Value        any
+	Err          error
+	ETag         string
+	LastModified string
+	ExpiresAt    time.Time
+}
+
+func NewExternalRefCache(ttl time.Duration) *ExternalRefCache {
+	return &ExternalRefCache{
+		ttl:     ttl,
+		entries: map[string]*ExternalRefCacheEntry{},
+	}
+}
+
+func (entry *ExternalRefCacheEntry) IsFresh() bool {
+	return time.Now().Before(entry.ExpiresAt)
+}
+
+// IsSameResult compares fetched results (not cache metadata)
+func (entry *ExternalRefCacheEntry) IsSameResult(other *ExternalRefCacheEntry) bool {
+	return other != nil && resultToString(entry.Value, entry.Err) == resultToString(other.Value, other.Err)
+}
+
+func (cache *ExternalRefCache) Get(url string) *ExternalRefCacheEntry {
+	cache.mutex.RLock()
+	defer cache.mutex.RUnlock()
+
+	return cache.entries[url]
+}
+
+func (cache *ExternalRefCache) Set(url string, value any, err error, eTag string, lastModified string) *ExternalRefCacheEntry {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	entry := &ExternalRefCacheEntry{
+		Value:        value,
+		Err:          err,
+		ETag:         eTag,
+		LastModified: lastModified,
+		ExpiresAt:    time.Now().Add(cache.ttl),
+	}
+	cache.entries[url] = entry
+
+	return entry
+}
+
+// Invalidate marks entry as stale. Its value is still used as the last known value until it is refreshed.
+func (cache *ExternalRefCache) Invalidate(url string) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	if entry, ok := cache.entries[url]; ok {
+		staleEntry := *entry
+		staleEntry.ExpiresAt = time.Time{}
+		cache.entries[url] = &staleEntry
+	}
+}
+
+func resultToString(value any, err error) string {
+	if err != nil {
+		return "ERROR: " + err.Error()
+	}
+
+	if stringValueRef, ok := value.(*string); ok {
+		return *stringValueRef
+	}
+
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,440 @@
+package main
+
+import (
+	"devChallengeExcel/contracts"
+	encodingJson "encoding/json"
+	"errors"
+	"fmt"
+	json "github.com/bytedance/sonic"
+	"github.com/expr-lang/expr"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const ExternalRefFunctionName = "external_ref"
+const ExternalJsonFunctionName = "external_json"
+
+// externalJsonKeyPrefix separates JSON documents from external cells with the same url (in cache and watchers)
+const externalJsonKeyPrefix = "json "
+
+var ExternalRefPendingError = errors.New("external ref is pending")
+
+var ExternalRefCircularError = fmt.Errorf("%w: %s", ExpressionError, "circular external_ref between instances")
+
+// ExternalRefFetcher fetches result of cell from other sheet / API instance (external_ref)
+// and arbitrary JSON documents (external_json).
+// Fetch never blocks: it returns cached (or the last known) result and refreshes stale result in background.
+// When refreshed result is changed, cells which watch the url are recalculated with OnUpdate handler.
+// JSON APIs can't call our webhook, so watched JSON documents are polled.
+type ExternalRefFetcher struct {
+	client        contracts.OutboundClient
+	canonicalizer contracts.Canonicalizer
+	cache         *ExternalRefCache
+	pollInterval  time.Duration
+	stopPolling   chan struct{}
+	// background refreshes and recalculations, Close waits for them
+	background sync.WaitGroup
+
+	mutex    sync.Mutex
+	closed   bool
+	inFlight map[string]bool
+	watchers map[string]map[ExternalRefWatcher]bool
+	watched  map[ExternalRefWatcher][]string
+	// origins of the changes (webhooks) which are not propagated to watchers yet
+	origins  map[string]contracts.ChangeOrigin
+	circular map[string]error
+	onUpdate func(sheetId string, cellId string, origin contracts.ChangeOrigin)
+}
+
+// ExternalRefWatcher cell with external_ref in formula (canonical ids)
+type ExternalRefWatcher struct {
+	SheetId string
+	CellId  string
+}
+
+func NewExternalRefFetcher(
+	cacheTtl time.Duration, pollInterval time.Duration, client contracts.OutboundClient, canonicalizer contracts.Canonicalizer,
+) *ExternalRefFetcher {
+	return &ExternalRefFetcher{
+		client:        client,
+		canonicalizer: canonicalizer,
+		cache:         NewExternalRefCache(cacheTtl),
+		pollInterval:  pollInterval,
+		stopPolling:   make(chan struct{}),
+		inFlight:      map[string]bool{},
+		watchers:      map[string]map[ExternalRefWatcher]bool{},
+		watched:       map[ExternalRefWatcher][]string{},
+		origins:       map[string]contracts.ChangeOrigin{},
+		circular:      map[string]error{},
+		onUpdate:      func(sheetId string, cellId string, origin contracts.ChangeOrigin) {},
+	}
+}
+
+// Fetch returns ExternalRefPendingError when result has never been fetched yet
+func (f *ExternalRefFetcher) Fetch(url string) (any, error) {
+	return f.fetch(url)
+}
+
+// FetchJson selects value from JSON document by JSONPath-style selector (see SelectJsonPath)
+func (f *ExternalRefFetcher) FetchJson(url string, path string) (any, error) {
+	document, err := f.fetch(externalJsonKeyPrefix + url)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := SelectJsonPath(document, path)
+	if err != nil {
+		return nil, err
+	}
+
+	return jsonValueToVar(value, path)
+}
+
+func (f *ExternalRefFetcher) fetch(key string) (any, error) {
+	f.mutex.Lock()
+	circularErr := f.circular[key]
+	f.mutex.Unlock()
+	if circularErr != nil {
+		return nil, circularErr
+	}
+
+	cached := f.cache.Get(key)
+	if cached == nil || !cached.IsFresh() {
+		f.refreshInBackground(key)
+	}
+
+	if cached == nil {
+		return nil, ExternalRefPendingError
+	}
+
+	return cached.Value, cached.Err
+}
+
+// Invalidate origin is passed to OnUpdate handler when the refreshed result is changed
+func (f *ExternalRefFetcher) Invalidate(url string, origin contracts.ChangeOrigin) {
+	f.mutex.Lock()
+	delete(f.circular, url)
+	f.origins[url] = origin
+	f.mutex.Unlock()
+
+	f.cache.Invalidate(url)
+}
+
+// MarkCircular fails external_ref(url) with err until the url is invalidated again
+func (f *ExternalRefFetcher) MarkCircular(url string, err error) {
+	f.mutex.Lock()
+	f.circular[url] = err
+	f.mutex.Unlock()
+}
+
+func (f *ExternalRefFetcher) OnUpdate(handler func(sheetId string, cellId string, origin contracts.ChangeOrigin)) {
+	f.onUpdate = handler
+}
+
+// WatchCell replaces list of urls which are referenced by the cell. Ids are canonicalized, so the cell has single watcher
+// regardless of case of requested ids
+func (f *ExternalRefFetcher) WatchCell(sheetId string, cellId string, urls []string, jsonUrls []string) {
+	watcher := ExternalRefWatcher{SheetId: strings.ToLower(sheetId), CellId: f.canonicalizer.Canonicalize(cellId)}
+	resolvedUrls := make([]string, 0)
+
+	urls = append(make([]string, 0, len(urls)+len(jsonUrls)), urls...)
+	for _, jsonUrl := range jsonUrls {
+		urls = append(urls, externalJsonKeyPrefix+jsonUrl)
+	}
+
+	f.mutex.Lock()
+	previousUrls := map[string]bool{}
+	for _, url := range f.watched[watcher] {
+		previousUrls[url] = true
+	}
+	f.unwatch(watcher)
+
+	if len(urls) != 0 {
+		f.watched[watcher] = urls
+	}
+
+	for _, url := range urls {
+		if _, ok := f.watchers[url]; !ok {
+			f.watchers[url] = map[ExternalRefWatcher]bool{}
+		}
+		f.watchers[url][watcher] = true
+
+		if !previousUrls[url] && f.cache.Get(url) != nil {
+			resolvedUrls = append(resolvedUrls, url)
+		}
+	}
+	f.mutex.Unlock()
+
+	// result of new url could arrive before the cell started to watch it
+	if len(resolvedUrls) != 0 {
+		f.runInBackground(func() {
+			f.onUpdate(watcher.SheetId, watcher.CellId, contracts.ChangeOrigin{})
+		})
+	}
+}
+
+// UnwatchSheet removes watchers of all cells of the sheet (e.g. the sheet is deleted)
+func (f *ExternalRefFetcher) UnwatchSheet(sheetId string) {
+	sheetId = strings.ToLower(sheetId)
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	for watcher := range f.watched {
+		if watcher.SheetId == sheetId {
+			f.unwatch(watcher)
+		}
+	}
+}
+
+// unwatch removes the watcher from watchers of its urls, mutex should be locked
+func (f *ExternalRefFetcher) unwatch(watcher ExternalRefWatcher) {
+	for _, url := range f.watched[watcher] {
+		delete(f.watchers[url], watcher)
+		if len(f.watchers[url]) == 0 {
+			delete(f.watchers, url)
+		}
+	}
+	delete(f.watched, watcher)
+}
+
+// Refresh fetches result of external cell synchronously and notifies watchers when the result is changed
+func (f *ExternalRefFetcher) Refresh(url string) {
+	f.refresh(url)
+}
+
+// RefreshJson fetches JSON document synchronously and notifies watchers when the document is changed
+func (f *ExternalRefFetcher) RefreshJson(url string) {
+	f.refresh(externalJsonKeyPrefix + url)
+}
+
+// Start polls watched JSON documents
+func (f *ExternalRefFetcher) Start() {
+	if f.pollInterval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(f.pollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				f.pollJsonDocuments()
+			case <-f.stopPolling:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops polling and waits for background refreshes and recalculations, new ones are not started
+func (f *ExternalRefFetcher) Close() {
+	close(f.stopPolling)
+
+	f.mutex.Lock()
+	f.closed = true
+	f.mutex.Unlock()
+
+	f.background.Wait()
+}
+
+func (f *ExternalRefFetcher) pollJsonDocuments() {
+	f.mutex.Lock()
+	keys := make([]string, 0)
+	for key := range f.watchers {
+		if strings.HasPrefix(key, externalJsonKeyPrefix) {
+			keys = append(keys, key)
+		}
+	}
+	f.mutex.Unlock()
+
+	for _, key := range keys {
+		f.refreshInBackground(key)
+	}
+}
+
+func (f *ExternalRefFetcher) refresh(key string) {
+	cached := f.cache.Get(key)
+	entry := f.request(key, cached)
+
+	f.mutex.Lock()
+	origin := f.origins[key]
+	delete(f.origins, key)
+	f.mutex.Unlock()
+
+	if !entry.IsSameResult(cached) {
+		f.notifyWatchers(key, origin)
+	}
+}
+
+func (f *ExternalRefFetcher) refreshInBackground(key string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.closed || f.inFlight[key] {
+		return
+	}
+	f.inFlight[key] = true
+
+	f.background.Add(1)
+	go func() {
+		defer f.background.Done()
+
+		f.refresh(key)
+
+		f.mutex.Lock()
+		delete(f.inFlight, key)
+		f.mutex.Unlock()
+	}()
+}
+
+func (f *ExternalRefFetcher) runInBackground(task func()) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.closed {
+		return
+	}
+
+	f.background.Add(1)
+	go func() {
+		defer f.background.Done()
+		task()
+	}()
+}
+
+func (f *ExternalRefFetcher) notifyWatchers(key string, origin contracts.ChangeOrigin) {
+	f.mutex.Lock()
+	watchers := make([]ExternalRefWatcher, 0, len(f.watchers[key]))
+	for watcher := range f.watchers[key] {
+		watchers = append(watchers, watcher)
+	}
+	f.mutex.Unlock()
+
+	for _, watcher := range watchers {
+		f.onUpdate(watcher.SheetId, watcher.CellId, origin)
+	}
+}
+
+func (f *ExternalRefFetcher) request(key string, cached *ExternalRefCacheEntry) *ExternalRefCacheEntry {
+	url, isJson := strings.CutPrefix(key, externalJsonKeyPrefix)
+
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return f.cache.Set(key, nil, err, "", "")
+	}
+
+	if cached != nil && cached.ETag != "" {
+		request.Header.Set("If-None-Match", cached.ETag)
+	}
+	if cached != nil && cached.LastModified != "" {
+		request.Header.Set("If-Modified-Since", cached.LastModified)
+	}
+
+	response, err := f.client.Do(request)
+	if err != nil {
+		return f.cache.Set(key, nil, err, "", "")
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusNotModified && cached != nil {
+		return f.cache.Set(key, cached.Value, cached.Err, cached.ETag, cached.LastModified)
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return f.cache.Set(key, nil, fmt.Errorf("fetchExternalRef url %s: %s", url, response.Status), "", "")
+	}
+
+	var value any
+	if isJson {
+		value, err = decodeJsonDocument(response.Body)
+	} else {
+		value, err = decodeCellResult(response.Body)
+	}
+	if err != nil {
+		return f.cache.Set(key, nil, err, "", "")
+	}
+
+	return f.cache.Set(key, value, nil, response.Header.Get("ETag"), response.Header.Get("Last-Modified"))
+}
+
+func decodeCellResult(body io.Reader) (any, error) {
+	var responsePayload contracts.Cell
+	err := json.ConfigDefault.NewDecoder(body).Decode(&responsePayload)
+	if err != nil {
+		return nil, err
+	}
+
+	// external cell waits for its own external_ref
+	if responsePayload.Result == PendingResult {
+		return nil, ExternalRefPendingError
+	}
+
+	return parseValue(responsePayload.Result), nil
+}
+
+func decodeJsonDocument(body io.Reader) (document any, err error) {
+	decoder := json.ConfigDefault.NewDecoder(body)
+	decoder.UseNumber()
+	err = decoder.Decode(&document)
+
+	return
+}
+
+// jsonValueToVar converts selected JSON value into expression variable
+func jsonValueToVar(value any, path string) (any, error) {
+	switch typedValue := value.(type) {
+	case encodingJson.Number:
+		return parseValue(typedValue.String()), nil
+	case string:
+		return parseValue(typedValue), nil
+	case bool:
+		return typedValue, nil
+	case nil:
+		return nil, fmt.Errorf("%w: %s: value is null", JsonPathError, path)
+	}
+
+	return nil, fmt.Errorf("%w: %s: value is not a number, string or boolean", JsonPathError, path)
+}
+
+// Function external_ref(url) for expression executor
+func (f *ExternalRefFetcher) Function() expr.Option {
+	return expr.Function(ExternalRefFunctionName, func(args ...any) (any, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("%s: expected single argument (url)", ExternalRefFunctionName)
+		}
+
+		url, ok := args[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("%s: url should be a string", ExternalRefFunctionName)
+		}
+
+		return f.Fetch(url)
+	})
+}
+
+// JsonFunction external_json(url, path) for expression executor
+func (f *ExternalRefFetcher) JsonFunction() expr.Option {
+	return expr.Function(ExternalJsonFunctionName, func(args ...any) (any, error) {
+		if len(args) != 2 {
+			return nil, fmt.Errorf("%s: expected two arguments (url, path)", ExternalJsonFunctionName)
+		}
+
+		url, ok := args[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("%s: url should be a string", ExternalJsonFunctionName)
+		}
+
+		path, ok := args[1].(string)
+		if !ok {
+			return nil, fmt.Errorf("%s: path should be a string", ExternalJsonFunctionName)
+		}
+
+		return f.FetchJson(url, path)
+	})
+}
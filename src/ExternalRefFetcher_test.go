@@ -0,0 +1,373 @@
+package main
+
+import (
+	"devChallengeExcel/contracts"
+	"devChallengeExcel/mocks"
+	"fmt"
+	"github.com/expr-lang/expr"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestExternalRefFetcher_Fetch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// other instance of API serves the cell
+	var requestsCount atomic.Int32
+	var lastIfNoneMatch atomic.Value
+	sheetRepository := mocks.NewSheetRepository(t)
+	sheetRepository.On("GetCell", "sheet1", "cell1").Return(&contracts.Cell{Value: "=5*2", Result: "10"}, nil)
+	sheetRepository.On("GetCell", "sheet1", "cell2").Return(&contracts.Cell{Value: "text", Result: "text"}, nil).Maybe()
+	sheetRepository.On("GetCell", "sheet1", "pending").Return(&contracts.Cell{Value: "=external_ref(url)", Result: PendingResult}, nil).Maybe()
+	router := SetupRouter(NewApiController(sheetRepository, nil, nil, nil, nil, nil, nil, nil))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestsCount.Add(1)
+		lastIfNoneMatch.Store(r.Header.Get("If-None-Match"))
+		router.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	url := server.URL + "/api/" + ApiVersion + "/sheet1/cell1"
+
+	t.Run("pending_then_cached", func(t *testing.T) {
+		requestsCount.Store(0)
+		fetcher := NewExternalRefFetcher(time.Minute, 0, _makeLoopbackOutboundClient(t), NewCanonicalizer())
+
+		_, err := fetcher.Fetch(url)
+		assert.ErrorIs(t, err, ExternalRefPendingError)
+
+		assert.Eventually(t, func() bool {
+			value, err := fetcher.Fetch(url)
+			return err == nil && value == int64(10)
+		}, time.Second, time.Millisecond*5)
+
+		for i := 0; i < 3; i++ {
+			value, err := fetcher.Fetch(url)
+			assert.NoError(t, err)
+			assert.Equal(t, int64(10), value)
+		}
+
+		assert.Equal(t, int32(1), requestsCount.Load())
+	})
+
+	t.Run("invalidate_returns_last_known_value", func(t *testing.T) {
+		requestsCount.Store(0)
+		fetcher := NewExternalRefFetcher(time.Minute, 0, _makeLoopbackOutboundClient(t), NewCanonicalizer())
+		fetcher.Refresh(url)
+
+		fetcher.Invalidate(url, contracts.ChangeOrigin{})
+		value, err := fetcher.Fetch(url)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(10), value)
+
+		assert.Eventually(t, func() bool {
+			return fetcher.cache.Get(url).IsFresh()
+		}, time.Second, time.Millisecond*5)
+		assert.Equal(t, int32(2), requestsCount.Load())
+	})
+
+	t.Run("origin_and_circular", func(t *testing.T) {
+		fetcher := NewExternalRefFetcher(time.Minute, 0, _makeLoopbackOutboundClient(t), NewCanonicalizer())
+
+		origins := make(chan contracts.ChangeOrigin, 10)
+		fetcher.OnUpdate(func(sheetId string, cellId string, origin contracts.ChangeOrigin) {
+			origins <- origin
+		})
+		fetcher.WatchCell("sheet2", "a1", []string{url}, nil)
+
+		// origin of the change is passed to watchers
+		origin := contracts.ChangeOrigin{Trace: []string{"remote/sheet1/cell1"}}
+		fetcher.Invalidate(url, origin)
+		fetcher.Refresh(url)
+		assert.Equal(t, origin, <-origins)
+
+		fetcher.MarkCircular(url, fmt.Errorf("%w: test", ExternalRefCircularError))
+		_, err := fetcher.Fetch(url)
+		assert.ErrorIs(t, err, ExternalRefCircularError)
+
+		// next change breaks the cycle
+		fetcher.Invalidate(url, contracts.ChangeOrigin{})
+		value, err := fetcher.Fetch(url)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(10), value)
+		assert.Eventually(t, func() bool {
+			return fetcher.cache.Get(url).IsFresh()
+		}, time.Second, time.Millisecond*5)
+	})
+
+	t.Run("revalidate_with_etag", func(t *testing.T) {
+		requestsCount.Store(0)
+		fetcher := NewExternalRefFetcher(0, 0, _makeLoopbackOutboundClient(t), NewCanonicalizer())
+
+		fetcher.Refresh(url)
+		assert.Empty(t, lastIfNoneMatch.Load())
+
+		fetcher.Refresh(url)
+		value, err := fetcher.cache.Get(url).Value, fetcher.cache.Get(url).Err
+		assert.NoError(t, err)
+		assert.Equal(t, int64(10), value)
+		assert.Equal(t, int32(2), requestsCount.Load())
+		assert.NotEmpty(t, lastIfNoneMatch.Load())
+		assert.Equal(t, fetcher.cache.Get(url).ETag, lastIfNoneMatch.Load())
+	})
+
+	t.Run("string_result", func(t *testing.T) {
+		fetcher := NewExternalRefFetcher(time.Minute, 0, _makeLoopbackOutboundClient(t), NewCanonicalizer())
+
+		stringUrl := server.URL + "/api/" + ApiVersion + "/sheet1/cell2"
+		fetcher.Refresh(stringUrl)
+		value, err := fetcher.Fetch(stringUrl)
+		assert.NoError(t, err)
+		assert.Equal(t, "text", value)
+	})
+
+	t.Run("external_cell_is_pending", func(t *testing.T) {
+		fetcher := NewExternalRefFetcher(time.Minute, 0, _makeLoopbackOutboundClient(t), NewCanonicalizer())
+
+		pendingUrl := server.URL + "/api/" + ApiVersion + "/sheet1/pending"
+		fetcher.Refresh(pendingUrl)
+		_, err := fetcher.Fetch(pendingUrl)
+		assert.ErrorIs(t, err, ExternalRefPendingError)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		fetcher := NewExternalRefFetcher(time.Minute, 0, _makeLoopbackOutboundClient(t), NewCanonicalizer())
+
+		for _, errorUrl := range []string{server.URL + "/not-found", "http://127.0.0.1:0/unreachable", ":not-url"} {
+			fetcher.Refresh(errorUrl)
+			_, err := fetcher.Fetch(errorUrl)
+			assert.Error(t, err)
+			assert.NotErrorIs(t, err, ExternalRefPendingError)
+		}
+
+		_, err := fetcher.Fetch(server.URL + "/not-found")
+		assert.ErrorContains(t, err, "404")
+	})
+
+	t.Run("circuit_open", func(t *testing.T) {
+		client := NewOutboundClient(_makeLoopbackEgressPolicy(t).NewClient(time.Second), OutboundClientConfig{
+			MaxAttempts:                1,
+			CircuitBreakerThreshold:    1,
+			CircuitBreakerOpenDuration: time.Minute,
+		})
+		fetcher := NewExternalRefFetcher(0, 0, client, NewCanonicalizer())
+
+		unreachableUrl := "http://127.0.0.1:0/unreachable"
+		fetcher.Refresh(unreachableUrl)
+		_, err := fetcher.Fetch(unreachableUrl)
+		assert.NotErrorIs(t, err, CircuitOpenError)
+
+		fetcher.Refresh(unreachableUrl)
+		_, err = fetcher.Fetch(unreachableUrl)
+		assert.ErrorIs(t, err, CircuitOpenError)
+	})
+
+	t.Run("watchers", func(t *testing.T) {
+		fetcher := NewExternalRefFetcher(0, 0, _makeLoopbackOutboundClient(t), NewCanonicalizer())
+
+		updates := make(chan ExternalRefWatcher, 10)
+		fetcher.OnUpdate(func(sheetId string, cellId string, origin contracts.ChangeOrigin) {
+			updates <- ExternalRefWatcher{SheetId: sheetId, CellId: cellId}
+		})
+
+		fetcher.WatchCell("sheet2", "a1", []string{url}, nil)
+		fetcher.WatchCell("sheet2", "a2", []string{url}, nil)
+		fetcher.WatchCell("sheet2", "a3", []string{url}, nil)
+		fetcher.WatchCell("sheet2", "a3", []string{}, nil)
+
+		// first result
+		fetcher.Refresh(url)
+		assert.Len(t, updates, 2)
+		assert.ElementsMatch(t, []ExternalRefWatcher{{"sheet2", "a1"}, {"sheet2", "a2"}}, []ExternalRefWatcher{<-updates, <-updates})
+
+		// not modified
+		fetcher.Refresh(url)
+		assert.Len(t, updates, 0)
+
+		// cell starts to watch already fetched url
+		fetcher.WatchCell("sheet2", "a4", []string{url}, nil)
+		assert.Equal(t, ExternalRefWatcher{"sheet2", "a4"}, <-updates)
+		fetcher.WatchCell("sheet2", "a4", []string{url}, nil)
+		assert.Len(t, updates, 0)
+	})
+
+	t.Run("watchers_canonical_ids", func(t *testing.T) {
+		var result atomic.Int32
+		changingServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = fmt.Fprintf(w, `{"value":"1","result":"%d"}`, result.Add(1))
+		}))
+		defer changingServer.Close()
+		changingUrl := changingServer.URL + "/api/v1/remote/a1"
+
+		fetcher := NewExternalRefFetcher(0, 0, _makeLoopbackOutboundClient(t), NewCanonicalizer())
+		updates := make(chan ExternalRefWatcher, 10)
+		fetcher.OnUpdate(func(sheetId string, cellId string, origin contracts.ChangeOrigin) {
+			updates <- ExternalRefWatcher{SheetId: sheetId, CellId: cellId}
+		})
+
+		// the same cell requested with different case has single watcher
+		fetcher.WatchCell("Sheet2", "A1", []string{changingUrl}, nil)
+		fetcher.WatchCell("sheet2", "a1", []string{changingUrl}, nil)
+		fetcher.WatchCell("SHEET2", "B1", []string{changingUrl}, nil)
+		fetcher.WatchCell("sheet3", "a1", []string{changingUrl}, nil)
+		fetcher.Refresh(changingUrl)
+		assert.Len(t, updates, 3)
+		assert.ElementsMatch(t,
+			[]ExternalRefWatcher{{"sheet2", "a1"}, {"sheet2", "b1"}, {"sheet3", "a1"}},
+			[]ExternalRefWatcher{<-updates, <-updates, <-updates},
+		)
+
+		// delete with other case removes the watcher, deleted sheet is not watched anymore
+		fetcher.WatchCell("sheet2", "B1", []string{}, nil)
+		fetcher.UnwatchSheet("SHEET3")
+		fetcher.Refresh(changingUrl)
+		assert.Len(t, updates, 1)
+		assert.Equal(t, ExternalRefWatcher{"sheet2", "a1"}, <-updates)
+	})
+
+	t.Run("function", func(t *testing.T) {
+		fetcher := NewExternalRefFetcher(time.Minute, 0, _makeLoopbackOutboundClient(t), NewCanonicalizer())
+		fetcher.Refresh(url)
+		options := []expr.Option{fetcher.Function()}
+
+		_, err := expr.Eval(`external_ref("`+url+`") + 1`, nil)
+		assert.Error(t, err, "function is not registered")
+
+		program, err := expr.Compile(`external_ref("`+url+`") * 2`, options...)
+		assert.NoError(t, err)
+		output, err := expr.Run(program, nil)
+		assert.NoError(t, err)
+		assert.EqualValues(t, 20, output)
+
+		program, err = expr.Compile(`external_ref(1)`, options...)
+		assert.NoError(t, err)
+		_, err = expr.Run(program, nil)
+		assert.ErrorContains(t, err, "url should be a string")
+	})
+}
+
+func TestExternalRefFetcher_FetchJson(t *testing.T) {
+	var rate atomic.Value
+	rate.Store("0.92")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/not-json" {
+			_, _ = w.Write([]byte(`not json`))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"base": "USD", "rates": {"EUR": ` + rate.Load().(string) + `, "GBP": "0.79"}, "stock": [{"sku": "a", "count": 12}]}`))
+	}))
+	defer server.Close()
+
+	url := server.URL + "/rates"
+
+	t.Run("pending_then_cached", func(t *testing.T) {
+		fetcher := NewExternalRefFetcher(time.Minute, 0, _makeLoopbackOutboundClient(t), NewCanonicalizer())
+
+		_, err := fetcher.FetchJson(url, "$.rates.EUR")
+		assert.ErrorIs(t, err, ExternalRefPendingError)
+
+		assert.Eventually(t, func() bool {
+			value, err := fetcher.FetchJson(url, "$.rates.EUR")
+			return err == nil && value == 0.92
+		}, time.Second, time.Millisecond*5)
+
+		value, err := fetcher.FetchJson(url, "$.rates.GBP")
+		assert.NoError(t, err)
+		assert.Equal(t, 0.79, value)
+
+		value, err = fetcher.FetchJson(url, "stock[0].count")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(12), value)
+
+		value, err = fetcher.FetchJson(url, "$.base")
+		assert.NoError(t, err)
+		assert.Equal(t, "USD", value)
+
+		// same url is cached separately for external_ref
+		assert.Nil(t, fetcher.cache.Get(url))
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		fetcher := NewExternalRefFetcher(time.Minute, 0, _makeLoopbackOutboundClient(t), NewCanonicalizer())
+		fetcher.RefreshJson(url)
+
+		_, err := fetcher.FetchJson(url, "$.rates")
+		assert.ErrorIs(t, err, JsonPathError)
+		assert.ErrorContains(t, err, "not a number")
+
+		_, err = fetcher.FetchJson(url, "$.rates.JPY")
+		assert.ErrorIs(t, err, JsonPathError)
+
+		fetcher.RefreshJson(server.URL + "/not-json")
+		_, err = fetcher.FetchJson(server.URL+"/not-json", "$")
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, JsonPathError)
+
+		fetcher.RefreshJson(":not-url")
+		_, err = fetcher.FetchJson(":not-url", "$")
+		assert.Error(t, err)
+	})
+
+	t.Run("polling", func(t *testing.T) {
+		rate.Store("0.92")
+		fetcher := NewExternalRefFetcher(time.Minute, time.Millisecond*10, _makeLoopbackOutboundClient(t), NewCanonicalizer())
+		fetcher.RefreshJson(url)
+
+		updates := make(chan ExternalRefWatcher, 10)
+		fetcher.OnUpdate(func(sheetId string, cellId string, origin contracts.ChangeOrigin) {
+			updates <- ExternalRefWatcher{SheetId: sheetId, CellId: cellId}
+		})
+		fetcher.WatchCell("sheet1", "a1", nil, []string{url})
+		assert.Equal(t, ExternalRefWatcher{"sheet1", "a1"}, <-updates, "document is already fetched")
+
+		fetcher.Start()
+		defer fetcher.Close()
+
+		rate.Store("0.95")
+		select {
+		case update := <-updates:
+			assert.Equal(t, ExternalRefWatcher{"sheet1", "a1"}, update)
+		case <-time.After(time.Second):
+			assert.Fail(t, "watcher is not notified")
+		}
+
+		value, err := fetcher.FetchJson(url, "$.rates.EUR")
+		assert.NoError(t, err)
+		assert.Equal(t, 0.95, value)
+	})
+
+	t.Run("function", func(t *testing.T) {
+		fetcher := NewExternalRefFetcher(time.Minute, 0, _makeLoopbackOutboundClient(t), NewCanonicalizer())
+		rate.Store("0.92")
+		fetcher.RefreshJson(url)
+		options := []expr.Option{fetcher.JsonFunction()}
+
+		output, err := expr.Eval(`external_json("`+url+`", "$.stock[0].count") * 2`, nil)
+		assert.Error(t, err, "function is not registered")
+
+		program, err := expr.Compile(`external_json("`+url+`", "$.stock[0].count") * 2`, options...)
+		assert.NoError(t, err)
+		output, err = expr.Run(program, nil)
+		assert.NoError(t, err)
+		assert.EqualValues(t, 24, output)
+
+		for expression, expectedError := range map[string]string{
+			`external_json("` + url + `")`:    "expected two arguments",
+			`external_json(1, "$")`:           "url should be a string",
+			`external_json("` + url + `", 1)`: "path should be a string",
+		} {
+			program, err = expr.Compile(expression, options...)
+			assert.NoError(t, err)
+			_, err = expr.Run(program, nil)
+			assert.ErrorContains(t, err, expectedError)
+		}
+	})
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"devChallengeExcel/contracts"
+	json "github.com/bytedance/sonic"
+)
+
+// ExternalRefSubscriptionStorage keeps urls of external cells which the cell is subscribed to (outgoing subscriptions).
+// Single bucket with nested bucket per sheet (key is canonical cell id, value is list of urls)
+type ExternalRefSubscriptionStorage struct{}
+
+var subscriptionsBucketId = []byte("__subscriptions")
+
+func (s *ExternalRefSubscriptionStorage) Get(tx contracts.StorageTx, sheetId []byte, cellId []byte) []string {
+	urls := make([]string, 0)
+
+	bucket := tx.Bucket(subscriptionsBucketId)
+	if bucket != nil {
+		bucket = bucket.Bucket(sheetId)
+	}
+	if bucket == nil {
+		return urls
+	}
+
+	if data := bucket.Get(cellId); data != nil {
+		_ = json.Unmarshal(data, &urls)
+	}
+
+	return urls
+}
+
+// Set replaces urls of the cell. Empty list removes the cell
+func (s *ExternalRefSubscriptionStorage) Set(tx contracts.StorageTx, sheetId []byte, cellId []byte, urls []string) error {
+	bucket, err := tx.CreateBucketIfNotExists(subscriptionsBucketId)
+	if err != nil {
+		return err
+	}
+
+	bucket, err = bucket.CreateBucketIfNotExists(sheetId)
+	if err != nil {
+		return err
+	}
+
+	if len(urls) == 0 {
+		return bucket.Delete(cellId)
+	}
+
+	data, err := json.Marshal(urls)
+	if err != nil {
+		return err
+	}
+
+	return bucket.Put(cellId, data)
+}
+
+// DeleteSheet removes all cells of the sheet, returns their urls
+func (s *ExternalRefSubscriptionStorage) DeleteSheet(tx contracts.StorageTx, sheetId []byte) (contracts.ExternalRefSubscriptions, error) {
+	urls := contracts.ExternalRefSubscriptions{}
+
+	bucket := tx.Bucket(subscriptionsBucketId)
+	if bucket == nil || bucket.Bucket(sheetId) == nil {
+		return urls, nil
+	}
+
+	_ = bucket.Bucket(sheetId).ForEach(func(cellId []byte, data []byte) error {
+		cellUrls := make([]string, 0)
+		_ = json.Unmarshal(data, &cellUrls)
+		urls[string(cellId)] = cellUrls
+		return nil
+	})
+
+	return urls, ignoreBucketNotFound(bucket.DeleteBucket(sheetId))
+}
@@ -4,8 +4,10 @@ import (
 	"github.com/expr-lang/expr/ast"
 )
 
+// FindExternalRefsVisitor collects urls of external_ref and external_json calls with constant url
 type FindExternalRefsVisitor struct {
-	externalRefs []string
+	externalRefs     []string
+	externalJsonUrls []string
 }
 
 func (v *FindExternalRefsVisitor) Visit(node *ast.Node) {
@@ -15,9 +17,14 @@ func (v *FindExternalRefsVisitor) Visit(node *ast.Node) {
 	var stringNode *ast.StringNode
 
 	if callNode, ok = (*node).(*ast.CallNode); ok && len(callNode.Arguments) > 0 && callNode.Callee != nil {
-		if identifierNode, ok = callNode.Callee.(*ast.IdentifierNode); ok && identifierNode.Value == "external_ref" {
+		if identifierNode, ok = callNode.Callee.(*ast.IdentifierNode); ok {
 			if stringNode, ok = callNode.Arguments[0].(*ast.StringNode); ok {
-				v.externalRefs = append(v.externalRefs, stringNode.Value)
+				switch identifierNode.Value {
+				case ExternalRefFunctionName:
+					v.externalRefs = append(v.externalRefs, stringNode.Value)
+				case ExternalJsonFunctionName:
+					v.externalJsonUrls = append(v.externalJsonUrls, stringNode.Value)
+				}
 			}
 		}
 	}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"devChallengeExcel/contracts"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+)
+
+// IterativeExpressionExecutor Excel-style iterative calculation.
+// Cells are evaluated once in order of their dependencies, only cells of cycles (strongly connected components
+// of dependency graph) are evaluated repeatedly until their results change less than epsilon.
+// Cell which is met in cycle gets result of previous iteration (0 at first one).
+type IterativeExpressionExecutor struct {
+	*ExpressionExecutor
+	maxIterations int
+	epsilon       float64
+}
+
+// iteration keeps results of previous iteration to resolve circular references
+type iteration struct {
+	previous map[string]any
+	hasCycle bool
+}
+
+func newIteration() *iteration {
+	return &iteration{
+		previous: map[string]any{},
+	}
+}
+
+func (it *iteration) previousValue(variableName string) any {
+	if value, ok := it.previous[variableName]; ok {
+		return value
+	}
+
+	return int64(0)
+}
+
+// changedVars returns names of variables which changed more than epsilon since previous iteration
+func (it *iteration) changedVars(vars map[string]any, epsilon float64) []string {
+	changed := make([]string, 0)
+
+	for variableName, value := range vars {
+		previous, ok := it.previous[variableName]
+		if !ok {
+			changed = append(changed, variableName)
+			continue
+		}
+
+		previousFloat, isPreviousNumber := toFloat(previous)
+		valueFloat, isValueNumber := toFloat(value)
+		if isPreviousNumber && isValueNumber {
+			if math.Abs(valueFloat-previousFloat) >= epsilon {
+				changed = append(changed, variableName)
+			}
+		} else if previous != value {
+			changed = append(changed, variableName)
+		}
+	}
+
+	return changed
+}
+
+// dependencyGraph formula cells which are reachable from evaluated cells with their dependencies (canonical cell ids)
+type dependencyGraph struct {
+	sources      map[string]string
+	dependencies map[string][]string
+}
+
+func (e *IterativeExpressionExecutor) Evaluate(expression string, sheet contracts.CellValuesGetter) (string, error) {
+	// not formula
+	if !e.IsFormula(expression) {
+		return expression, nil
+	}
+
+	dependencies := e.ExtractDependingOnList(expression)
+	graph := e.makeDependencyGraph(dependencies, sheet)
+	vars := make(map[string]any)
+	errs := e.evaluateGraph(graph, sheet, vars)
+
+	// dependencies are evaluated already, so the expression is evaluated once
+	var output any
+	err := getFailedError(dependencies, errs)
+	if err == nil {
+		output, err = e.doEvaluate(expression, sheet, vars, nil)
+	}
+	if err == nil {
+		err = graph.getNotConvergedError(expression, errs)
+	}
+
+	if errors.Is(err, ExternalRefPendingError) {
+		return PendingResult, nil
+	} else if err != nil {
+		err = fmt.Errorf("%s: %w", expression, err)
+	}
+
+	return e.outputToString(output, err), err
+}
+
+func (e *IterativeExpressionExecutor) MultiEvaluate(expressions contracts.ExpressionsMap, sheetGetter contracts.CellValuesGetter, breakOnError bool) error {
+	// MultiEvaluate overrides expressions with results, dependencies are read from their sources
+	sources := make(contracts.ExpressionsMap, len(expressions))
+	for cellId, expression := range expressions {
+		source := *expression
+		sources[cellId] = &source
+	}
+	sheet := NewCellValuesGetterChain(NewExpressionsMapsValuesGetter(&sources), sheetGetter)
+
+	cellIds := slices.Sorted(maps.Keys(expressions))
+	graph := e.makeDependencyGraph(cellIds, sheet)
+	vars := make(map[string]any)
+	errs := e.evaluateGraph(graph, sheet, vars)
+
+	var firstErr error
+	for _, cellId := range cellIds {
+		err, failed := errs[cellId]
+		if _, isFormula := graph.sources[cellId]; isFormula {
+			*expressions[cellId] = e.outputToString(vars[cellId], err)
+		}
+
+		if !failed {
+			err = checkCellResult(cellId, *expressions[cellId])
+		}
+
+		if firstErr == nil && err != nil {
+			firstErr = fmt.Errorf("cell %s: %w", cellId, err)
+			if breakOnError {
+				break
+			}
+		}
+	}
+
+	return firstErr
+}
+
+// makeDependencyGraph reads formulas of the cells and of their dependencies recursively.
+// Dependencies are read level by level, so the sheet is called once per level
+func (e *IterativeExpressionExecutor) makeDependencyGraph(cellIds []string, sheet contracts.CellValuesGetter) *dependencyGraph {
+	graph := &dependencyGraph{
+		sources:      map[string]string{},
+		dependencies: map[string][]string{},
+	}
+
+	visited := make(map[string]bool, len(cellIds))
+	next := make([]string, 0, len(cellIds))
+	for _, cellId := range cellIds {
+		if !visited[cellId] {
+			visited[cellId] = true
+			next = append(next, cellId)
+		}
+	}
+
+	for len(next) != 0 && sheet != nil {
+		current := next
+		next = make([]string, 0)
+
+		for index, value := range sheet(current) {
+			if value == nil || !e.IsFormula(*value) {
+				continue
+			}
+
+			cellId := current[index]
+			graph.sources[cellId] = *value
+			graph.dependencies[cellId] = e.ExtractDependingOnList(*value)
+			for _, dependency := range graph.dependencies[cellId] {
+				if !visited[dependency] {
+					visited[dependency] = true
+					next = append(next, dependency)
+				}
+			}
+		}
+	}
+
+	return graph
+}
+
+// evaluateGraph evaluates cells of the graph into vars in order of dependencies: cells which are not in cycle once,
+// cells of cycle repeatedly until their results converge. Cell which depends on failed one fails with the same error,
+// while cells of not converged cycle keep results of the last iteration for their dependants (as in Excel).
+// Returns errors of failed cells
+func (e *IterativeExpressionExecutor) evaluateGraph(graph *dependencyGraph, sheet contracts.CellValuesGetter, vars map[string]any) map[string]error {
+	errs := map[string]error{}
+
+	for _, component := range graph.getComponents() {
+		if err := graph.getDependencyError(component, errs); err != nil {
+			for _, cellId := range component {
+				errs[cellId] = err
+			}
+			continue
+		}
+
+		if !graph.isCycle(component) {
+			if err := e.evaluateCell(component[0], graph.sources[component[0]], sheet, vars, nil); err != nil {
+				errs[component[0]] = err
+			}
+			continue
+		}
+
+		notConverged := e.iterate(func(it *iteration) (map[string]any, bool) {
+			// cells of cycle are evaluated again, the cell which is met in cycle gets result of previous iteration
+			for _, cellId := range component {
+				delete(vars, cellId)
+				delete(errs, cellId)
+			}
+
+			failed := false
+			results := make(map[string]any, len(component))
+			for _, cellId := range component {
+				if err := e.evaluateCell(cellId, graph.sources[cellId], sheet, vars, it); err != nil {
+					errs[cellId] = err
+					failed = true
+				}
+				results[cellId] = vars[cellId]
+			}
+
+			return results, failed
+		})
+
+		// each cell of cycle depends on the others, so all of them are not converged
+		if len(notConverged) != 0 {
+			for _, cellId := range component {
+				errs[cellId] = e.makeNotConvergedError()
+			}
+		}
+	}
+
+	return errs
+}
+
+// iterate evaluates until there is no cycle or results change less than epsilon since previous iteration.
+// evaluate returns results of the iteration and whether to stop (on error).
+// Returns cells which still change after maxIterations
+func (e *IterativeExpressionExecutor) iterate(evaluate func(it *iteration) (results map[string]any, stop bool)) []string {
+	it := newIteration()
+	for i := 1; ; i++ {
+		it.hasCycle = false
+
+		results, stop := evaluate(it)
+		if stop || !it.hasCycle {
+			return nil
+		}
+
+		changed := it.changedVars(results, e.epsilon)
+		if i > 1 && len(changed) == 0 {
+			return nil
+		}
+
+		if i >= e.maxIterations {
+			return changed
+		}
+
+		it.previous = results
+	}
+}
+
+// getComponents returns strongly connected components of the graph (Tarjan's algorithm),
+// components of dependencies go before components of their dependants
+func (graph *dependencyGraph) getComponents() [][]string {
+	indexes := make(map[string]int, len(graph.sources))
+	lowLinks := make(map[string]int, len(graph.sources))
+	onStack := make(map[string]bool, len(graph.sources))
+	stack := make([]string, 0)
+	components := make([][]string, 0, len(graph.sources))
+
+	var connect func(cellId string)
+	connect = func(cellId string) {
+		indexes[cellId] = len(indexes)
+		lowLinks[cellId] = indexes[cellId]
+		stack = append(stack, cellId)
+		onStack[cellId] = true
+
+		for _, dependency := range graph.dependencies[cellId] {
+			if _, isFormula := graph.sources[dependency]; !isFormula {
+				continue
+			}
+
+			if _, visited := indexes[dependency]; !visited {
+				connect(dependency)
+				lowLinks[cellId] = min(lowLinks[cellId], lowLinks[dependency])
+			} else if onStack[dependency] {
+				lowLinks[cellId] = min(lowLinks[cellId], indexes[dependency])
+			}
+		}
+
+		if lowLinks[cellId] != indexes[cellId] {
+			return
+		}
+
+		component := make([]string, 0, 1)
+		for {
+			last := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[last] = false
+			component = append(component, last)
+			if last == cellId {
+				break
+			}
+		}
+		slices.Sort(component)
+		components = append(components, component)
+	}
+
+	for _, cellId := range slices.Sorted(maps.Keys(graph.sources)) {
+		if _, visited := indexes[cellId]; !visited {
+			connect(cellId)
+		}
+	}
+
+	return components
+}
+
+// isCycle checks whether cells of the component depend on each other (or the single cell on itself)
+func (graph *dependencyGraph) isCycle(component []string) bool {
+	return len(component) > 1 || slices.Contains(graph.dependencies[component[0]], component[0])
+}
+
+// getDependencyError returns error of failed dependency of the cells
+func (graph *dependencyGraph) getDependencyError(cellIds []string, errs map[string]error) error {
+	for _, cellId := range cellIds {
+		if err := getFailedError(graph.dependencies[cellId], errs); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getNotConvergedError returns error when the expression is formula of the cell of not converged cycle
+// (or of the cell with the same formula, it has the same result)
+func (graph *dependencyGraph) getNotConvergedError(expression string, errs map[string]error) error {
+	for cellId, source := range graph.sources {
+		if err, failed := errs[cellId]; failed && source == expression && errors.Is(err, IterationNotConvergedError) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getFailedError returns error of the first failed cell, not converged cells are not failed for their dependants
+func getFailedError(cellIds []string, errs map[string]error) error {
+	for _, cellId := range cellIds {
+		if err, failed := errs[cellId]; failed && !errors.Is(err, IterationNotConvergedError) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (e *IterativeExpressionExecutor) makeNotConvergedError() error {
+	return fmt.Errorf("%w (max iterations: %d, epsilon: %g)", IterationNotConvergedError, e.maxIterations, e.epsilon)
+}
+
+func toFloat(value any) (float64, bool) {
+	switch value.(type) {
+	case int64:
+		return float64(value.(int64)), true
+	case int:
+		return float64(value.(int)), true
+	case float64:
+		return value.(float64), true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"devChallengeExcel/contracts"
+	"github.com/expr-lang/expr"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestIterativeExpressionExecutor_Evaluate(t *testing.T) {
+	sheet := func(values map[string]string) contracts.CellValuesGetter {
+		return func(cellIds []string) []*string {
+			result := make([]*string, len(cellIds))
+			for index, cellId := range cellIds {
+				if value, ok := values[cellId]; ok {
+					result[index] = _makeStringRef(value)
+				}
+			}
+			return result
+		}
+	}
+
+	t.Run("converged", func(t *testing.T) {
+		executor := NewExpressionExecutor(NewCanonicalizer()).WithIteration(100, 0.001)
+
+		// interest on average balance: a1 = 100 + a2, a2 = a1 * 0.1 => a1 = 111.(1)
+		actual, err := executor.Evaluate("=A1", sheet(map[string]string{
+			"a1": "=100 + A2",
+			"a2": "=A1 * 0.1",
+		}))
+
+		assert.NoError(t, err)
+		actualFloat, parseErr := strconv.ParseFloat(actual, 64)
+		assert.NoError(t, parseErr)
+		assert.InDelta(t, 111.111, actualFloat, 0.01)
+	})
+
+	t.Run("without_cycle", func(t *testing.T) {
+		executor := NewExpressionExecutor(NewCanonicalizer()).WithIteration(1, 0.001)
+
+		actual, err := executor.Evaluate("=A1+A2", sheet(map[string]string{
+			"a1": "5",
+			"a2": "=A1*2",
+		}))
+
+		assert.NoError(t, err)
+		assert.Equal(t, "15", actual)
+
+		actual, err = executor.Evaluate("not formula", nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "not formula", actual)
+	})
+
+	t.Run("not_converged", func(t *testing.T) {
+		executor := NewExpressionExecutor(NewCanonicalizer()).WithIteration(10, 0.001)
+
+		actual, err := executor.Evaluate("=A1", sheet(map[string]string{
+			"a1": "=A2 + 1",
+			"a2": "=A1",
+		}))
+
+		assert.Error(t, err)
+		assert.ErrorIs(t, err, IterationNotConvergedError)
+		assert.ErrorIs(t, err, ExpressionError)
+		assert.True(t, strings.HasPrefix(actual, "ERROR: "))
+	})
+
+	t.Run("depends_on_not_converged", func(t *testing.T) {
+		executor := NewExpressionExecutor(NewCanonicalizer()).WithIteration(10, 0.001)
+
+		// the cell is not in cycle: it gets results of the last iteration
+		actual, err := executor.Evaluate("=A1 * 0 + 7", sheet(map[string]string{
+			"a1": "=A2 + 1",
+			"a2": "=A1",
+		}))
+
+		assert.NoError(t, err)
+		assert.Equal(t, "7", actual)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		executor := NewExpressionExecutor(NewCanonicalizer()).WithIteration(10, 0.001)
+
+		actual, err := executor.Evaluate("=A1", sheet(map[string]string{
+			"a1":      "=A2 + 1",
+			"a2":      "=A1 + awesome",
+			"awesome": "awesome",
+		}))
+
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, IterationNotConvergedError)
+		assert.True(t, strings.HasPrefix(actual, "ERROR: "))
+	})
+}
+
+func TestIterativeExpressionExecutor_MultiEvaluate(t *testing.T) {
+	t.Run("converged", func(t *testing.T) {
+		expressions := contracts.ExpressionsMap{
+			"a1": _makeStringRef("=100 + A2"),
+			"a2": _makeStringRef("=A1 * 0.1"),
+			"a3": _makeStringRef("=5"),
+		}
+
+		executor := NewExpressionExecutor(NewCanonicalizer()).WithIteration(100, 0.001)
+		err := executor.MultiEvaluate(expressions, nil, true)
+
+		assert.NoError(t, err)
+
+		a1, _ := strconv.ParseFloat(*expressions["a1"], 64)
+		a2, _ := strconv.ParseFloat(*expressions["a2"], 64)
+		assert.InDelta(t, 111.111, a1, 0.01)
+		assert.InDelta(t, 11.111, a2, 0.01)
+		assert.Equal(t, "5", *expressions["a3"])
+	})
+
+	t.Run("only_cycle_is_iterated", func(t *testing.T) {
+		calls := 0
+		executor := NewExpressionExecutor(NewCanonicalizer(), expr.Function("counted", func(args ...any) (any, error) {
+			calls++
+			return args[0], nil
+		})).WithIteration(100, 0.001)
+
+		expressions := contracts.ExpressionsMap{
+			"a1": _makeStringRef("=100 + A2"),
+			"a2": _makeStringRef("=A1 * 0.1"),
+			"a3": _makeStringRef("=counted(A1) + 1"),
+			"a4": _makeStringRef("=counted(A3) * 2"),
+			"a5": _makeStringRef("=counted(5)"),
+		}
+		err := executor.MultiEvaluate(expressions, nil, true)
+
+		assert.NoError(t, err)
+		// cells which are not in cycle are evaluated once
+		assert.Equal(t, 3, calls)
+
+		a3, _ := strconv.ParseFloat(*expressions["a3"], 64)
+		a4, _ := strconv.ParseFloat(*expressions["a4"], 64)
+		assert.InDelta(t, 112.111, a3, 0.01)
+		assert.InDelta(t, 224.222, a4, 0.02)
+		assert.Equal(t, "5", *expressions["a5"])
+	})
+
+	t.Run("not_converged", func(t *testing.T) {
+		expressions := contracts.ExpressionsMap{
+			"a1": _makeStringRef("=A2 + 1"),
+			"a2": _makeStringRef("=A1"),
+			"a3": _makeStringRef("=5"),
+			"a4": _makeStringRef("=A1 * 0 + 7"),
+		}
+
+		executor := NewExpressionExecutor(NewCanonicalizer()).WithIteration(10, 0.001)
+		err := executor.MultiEvaluate(expressions, nil, false)
+
+		assert.Error(t, err)
+		assert.ErrorIs(t, err, IterationNotConvergedError)
+		assert.True(t, strings.HasPrefix(err.Error(), "cell "), err.Error())
+
+		assert.True(t, strings.HasPrefix(*expressions["a1"], "ERROR: "))
+		assert.True(t, strings.HasPrefix(*expressions["a2"], "ERROR: "))
+		assert.Equal(t, "5", *expressions["a3"])
+		// dependant of the cycle gets results of the last iteration
+		assert.Equal(t, "7", *expressions["a4"])
+	})
+
+	t.Run("break_on_error", func(t *testing.T) {
+		expressions := contracts.ExpressionsMap{
+			"a1": _makeStringRef("=A2 + 1"),
+			"a2": _makeStringRef("=A1 + (1"),
+		}
+
+		executor := NewExpressionExecutor(NewCanonicalizer()).WithIteration(100, 0.001)
+		err := executor.MultiEvaluate(expressions, nil, true)
+
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, IterationNotConvergedError)
+	})
+}
+
+func TestIterativeExpressionExecutor_SameMaxIterations(t *testing.T) {
+	// a1 = 1, 2, 3 at first iterations, the 4th one has the same result: converged at 4th iteration
+	expression := "=min(A1 + 1, 3)"
+	sheet := func(cellIds []string) []*string {
+		result := make([]*string, len(cellIds))
+		for index, cellId := range cellIds {
+			if cellId == "a1" {
+				result[index] = _makeStringRef(expression)
+			}
+		}
+		return result
+	}
+
+	for maxIterations, converged := range map[int]bool{3: false, 4: true} {
+		executor := NewExpressionExecutor(NewCanonicalizer()).WithIteration(maxIterations, 0.001)
+
+		actual, err := executor.Evaluate(expression, sheet)
+		expressions := contracts.ExpressionsMap{"a1": _makeStringRef(expression)}
+		multiErr := executor.MultiEvaluate(expressions, nil, true)
+
+		if converged {
+			assert.NoError(t, err)
+			assert.NoError(t, multiErr)
+			assert.Equal(t, "3", actual)
+			assert.Equal(t, "3", *expressions["a1"])
+		} else {
+			assert.ErrorIs(t, err, IterationNotConvergedError)
+			assert.ErrorIs(t, multiErr, IterationNotConvergedError)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var JsonPathError = errors.New("json path error")
+
+// SelectJsonPath selects value from decoded JSON document with JSONPath-style selector.
+// Supported: `$` root, `.key`, `['key']` / `["key"]`, `[index]` (negative index counts from the end).
+// Examples: `$.rates.EUR`, `data.items[0].count`, `$['exchange rates']['USD']`.
+func SelectJsonPath(document any, path string) (any, error) {
+	segments, err := parseJsonPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	current := document
+	for _, segment := range segments {
+		switch node := current.(type) {
+		case map[string]any:
+			key, ok := segment.(string)
+			if !ok {
+				return nil, fmt.Errorf("%w: %s: index [%d] of object", JsonPathError, path, segment)
+			}
+			if current, ok = node[key]; !ok {
+				return nil, fmt.Errorf("%w: %s: key '%s' is not found", JsonPathError, path, key)
+			}
+		case []any:
+			index, ok := segment.(int)
+			if !ok {
+				return nil, fmt.Errorf("%w: %s: key '%s' of array", JsonPathError, path, segment)
+			}
+			if index < 0 {
+				index += len(node)
+			}
+			if index < 0 || index >= len(node) {
+				return nil, fmt.Errorf("%w: %s: index [%d] is out of range", JsonPathError, path, segment)
+			}
+			current = node[index]
+		default:
+			return nil, fmt.Errorf("%w: %s: %v is not object or array", JsonPathError, path, segment)
+		}
+	}
+
+	return current, nil
+}
+
+// parseJsonPath returns list of segments: string (object key) or int (array index)
+func parseJsonPath(path string) ([]any, error) {
+	segments := make([]any, 0, 4)
+	rest := strings.TrimPrefix(strings.TrimSpace(path), "$")
+
+	// path without root: `rates.EUR`
+	if rest != "" && rest[0] != '.' && rest[0] != '[' {
+		rest = "." + rest
+	}
+
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end == -1 {
+				end = len(rest) - 1
+			}
+			key := rest[1 : end+1]
+			if key == "" {
+				return nil, fmt.Errorf("%w: %s: empty key", JsonPathError, path)
+			}
+			segments = append(segments, key)
+			rest = rest[end+1:]
+		case '[':
+			end := strings.Index(rest, "]")
+			if end == -1 {
+				return nil, fmt.Errorf("%w: %s: ']' is expected", JsonPathError, path)
+			}
+			selector := strings.TrimSpace(rest[1:end])
+
+			if len(selector) >= 2 && (selector[0] == '\'' || selector[0] == '"') && selector[len(selector)-1] == selector[0] {
+				segments = append(segments, selector[1:len(selector)-1])
+			} else if index, err := strconv.Atoi(selector); err == nil {
+				segments = append(segments, index)
+			} else {
+				return nil, fmt.Errorf("%w: %s: invalid selector [%s]", JsonPathError, path, selector)
+			}
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("%w: %s: unexpected '%c'", JsonPathError, path, rest[0])
+		}
+	}
+
+	return segments, nil
+}
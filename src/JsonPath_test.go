@@ -0,0 +1,49 @@
+package main
+
+import (
+	encodingJson "encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSelectJsonPath(t *testing.T) {
+	var document any
+	err := encodingJson.Unmarshal([]byte(`{
+		"rates": {"EUR": 0.92, "exchange rate": "1.5"},
+		"items": [{"count": 3}, {"count": 7}],
+		"ok": true
+	}`), &document)
+	assert.NoError(t, err)
+
+	t.Run("success", func(t *testing.T) {
+		for path, expected := range map[string]any{
+			"$.rates.EUR":                 0.92,
+			"rates.EUR":                   0.92,
+			"$['rates']['exchange rate']": "1.5",
+			`$["items"][1].count`:         float64(7),
+			"items[-2].count":             float64(3),
+			"$.ok":                        true,
+			"$":                           document,
+		} {
+			actual, err := SelectJsonPath(document, path)
+			assert.NoError(t, err, path)
+			assert.Equal(t, expected, actual, path)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		for _, path := range []string{
+			"$.rates.USD",
+			"$.items[2].count",
+			"$.items.count",
+			"$.rates[0]",
+			"$.ok.value",
+			"$.items[first]",
+			"$.items[0",
+			"$..rates",
+		} {
+			_, err := SelectJsonPath(document, path)
+			assert.ErrorIs(t, err, JsonPathError, path)
+		}
+	})
+}
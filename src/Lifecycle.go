@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const DefaultShutdownTimeout = 30 * time.Second
+
+// Lifecycle serves HTTP until the context is done (e.g. SIGTERM is received), then shuts the server down
+// (it stops accepting connections and waits for active requests) and stops services in the order they are added.
+// All of it is limited by shutdown timeout
+type Lifecycle struct {
+	server          *http.Server
+	shutdownTimeout time.Duration
+	services        []lifecycleService
+}
+
+type lifecycleService struct {
+	name string
+	stop func(ctx context.Context) error
+}
+
+func NewLifecycle(server *http.Server, shutdownTimeout time.Duration) *Lifecycle {
+	return &Lifecycle{
+		server:          server,
+		shutdownTimeout: shutdownTimeout,
+	}
+}
+
+// OnShutdown adds service which is stopped after HTTP server. Services are stopped even when the deadline is exceeded,
+// the context tells them to give up waiting
+func (l *Lifecycle) OnShutdown(name string, stop func(ctx context.Context) error) {
+	l.services = append(l.services, lifecycleService{name: name, stop: stop})
+}
+
+// Run serves until ctx is done. It returns error of the server (e.g. the port is busy) or errors of the shutdown
+func (l *Lifecycle) Run(ctx context.Context) error {
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- l.server.ListenAndServe()
+	}()
+
+	var err error
+	select {
+	case err = <-serverErr:
+	case <-ctx.Done():
+		logger.Printf("Shutting down (timeout %s)", l.shutdownTimeout)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), l.shutdownTimeout)
+	defer cancel()
+
+	if err == nil {
+		err = l.server.Shutdown(shutdownCtx)
+	}
+
+	errs := []error{err}
+	for _, service := range l.services {
+		if err := service.stop(shutdownCtx); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", service.name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
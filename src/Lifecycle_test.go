@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestLifecycle_Run(t *testing.T) {
+	t.Run("graceful", func(t *testing.T) {
+		address := _getFreeAddress(t)
+		requestStarted := make(chan struct{})
+		server := &http.Server{Addr: address, Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(requestStarted)
+			time.Sleep(100 * time.Millisecond)
+			_, _ = w.Write([]byte("done"))
+		})}
+
+		stopped := make([]string, 0)
+		lifecycle := NewLifecycle(server, time.Second)
+		lifecycle.OnShutdown("first", func(ctx context.Context) error {
+			_, ok := ctx.Deadline()
+			assert.True(t, ok)
+			stopped = append(stopped, "first")
+			return nil
+		})
+		lifecycle.OnShutdown("second", func(ctx context.Context) error {
+			stopped = append(stopped, "second")
+			return errors.New("test")
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		runErr := make(chan error, 1)
+		go func() {
+			runErr <- lifecycle.Run(ctx)
+		}()
+
+		// active request is completed before services are stopped
+		response := make(chan string, 1)
+		go func() {
+			var res *http.Response
+			var err error
+			for i := 0; i < 20; i++ {
+				if res, err = http.Get("http://" + address); err == nil {
+					break
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			if !assert.NoError(t, err) {
+				close(requestStarted)
+				response <- ""
+				return
+			}
+			body, _ := io.ReadAll(res.Body)
+			response <- string(body)
+		}()
+		<-requestStarted
+		cancel()
+
+		assert.Equal(t, "done", <-response)
+		err := <-runErr
+		assert.EqualError(t, err, "second: test")
+		assert.Equal(t, []string{"first", "second"}, stopped)
+
+		// new connections are not accepted
+		_, err = http.Get("http://" + address)
+		assert.Error(t, err)
+	})
+
+	t.Run("deadline", func(t *testing.T) {
+		address := _getFreeAddress(t)
+		release := make(chan struct{})
+		defer close(release)
+		requestStarted := make(chan struct{})
+		server := &http.Server{Addr: address, Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(requestStarted)
+			<-release
+		})}
+
+		stopped := false
+		lifecycle := NewLifecycle(server, 50*time.Millisecond)
+		lifecycle.OnShutdown("service", func(ctx context.Context) error {
+			stopped = true
+			return ctx.Err()
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		runErr := make(chan error, 1)
+		go func() {
+			runErr <- lifecycle.Run(ctx)
+		}()
+
+		go func() {
+			for i := 0; i < 20; i++ {
+				if _, err := http.Get("http://" + address); err == nil {
+					return
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		}()
+		<-requestStarted
+		cancel()
+
+		// services are stopped even when the server is not shut down in time
+		err := <-runErr
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.True(t, stopped)
+	})
+
+	t.Run("server_error", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(t, err)
+		defer listener.Close()
+
+		stopped := false
+		lifecycle := NewLifecycle(&http.Server{Addr: listener.Addr().String()}, time.Second)
+		lifecycle.OnShutdown("service", func(ctx context.Context) error {
+			stopped = true
+			return nil
+		})
+
+		err = lifecycle.Run(context.Background())
+		assert.ErrorContains(t, err, "address already in use")
+		assert.True(t, stopped)
+	})
+}
+
+func _getFreeAddress(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+
+	return listener.Addr().String()
+}
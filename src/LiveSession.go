@@ -0,0 +1,213 @@
+package main
+
+import (
+	"devChallengeExcel/contracts"
+	"errors"
+	"fmt"
+	json "github.com/bytedance/sonic"
+	"golang.org/x/net/websocket"
+	"slices"
+	"sync"
+)
+
+const (
+	// LiveRequestSet sets value of the cell (cell_id, value)
+	LiveRequestSet = "set"
+	// LiveRequestGet returns the cell (cell_id)
+	LiveRequestGet = "get"
+	// LiveRequestSubscribe subscribes to changes of cells or A1 range (all cells of the sheet when both are empty)
+	LiveRequestSubscribe = "subscribe"
+	// LiveRequestUnsubscribe removes subscription (subscription_id)
+	LiveRequestUnsubscribe = "unsubscribe"
+)
+
+const (
+	LiveResponseResult = "result"
+	LiveResponseError  = "error"
+)
+
+// LiveRequest message of the client, id is returned in the response to match them
+type LiveRequest struct {
+	Id             string   `json:"id"`
+	Type           string   `json:"type"`
+	CellId         string   `json:"cell_id"`
+	Value          string   `json:"value"`
+	Cells          []string `json:"cells"`
+	Range          string   `json:"range"`
+	SubscriptionId string   `json:"subscription_id"`
+}
+
+// LiveResponse answer to the request. Changes of subscribed cells are sent as WebhookEvent messages (type `cell.changed`)
+type LiveResponse struct {
+	Id             string          `json:"id"`
+	Type           string          `json:"type"`
+	CellId         string          `json:"cell_id,omitempty"`
+	Cell           *contracts.Cell `json:"cell,omitempty"`
+	SubscriptionId string          `json:"subscription_id,omitempty"`
+	Error          string          `json:"error,omitempty"`
+}
+
+var LiveRequestTypeError = errors.New("unknown request type")
+var LiveSubscriptionNotFoundError = errors.New("subscription not found")
+
+// LiveSession WebSocket session of the sheet: the client sets and gets cells, subscribes to cells or ranges
+// and receives recalculated results of subscribed cells, including changes made by other clients
+type LiveSession struct {
+	api              *ApiController
+	conn             *websocket.Conn
+	sheetId          string
+	canonicalSheetId string
+
+	writeMutex sync.Mutex
+
+	mutex         sync.Mutex
+	subscriptions map[string]liveSubscription
+}
+
+type liveSubscription struct {
+	cells     []string
+	cellRange *CellRange
+}
+
+func NewLiveSession(api *ApiController, conn *websocket.Conn, sheetId string) *LiveSession {
+	return &LiveSession{
+		api:              api,
+		conn:             conn,
+		sheetId:          sheetId,
+		canonicalSheetId: api.SheetRepository.GetCanonicalSheetId(sheetId),
+		subscriptions:    map[string]liveSubscription{},
+	}
+}
+
+// Run handles requests until the client disconnects
+func (session *LiveSession) Run() {
+	events := session.api.ChangeEventStream.Subscribe(session.canonicalSheetId, nil, 0)
+	defer session.api.ChangeEventStream.Unsubscribe(events)
+	go session.forwardEvents(events)
+
+	for {
+		var message []byte
+		if err := websocket.Message.Receive(session.conn, &message); err != nil {
+			return
+		}
+
+		request := LiveRequest{}
+		if err := json.Unmarshal(message, &request); err != nil {
+			session.send(LiveResponse{Type: LiveResponseError, Error: err.Error()})
+			continue
+		}
+
+		session.send(session.handle(&request))
+	}
+}
+
+func (session *LiveSession) handle(request *LiveRequest) LiveResponse {
+	response := LiveResponse{Id: request.Id, Type: LiveResponseResult}
+
+	var err error
+	switch request.Type {
+	case LiveRequestSet:
+		response.CellId = request.CellId
+		response.Cell, err = session.set(request)
+	case LiveRequestGet:
+		response.CellId = request.CellId
+		response.Cell, err = session.api.SheetRepository.GetCell(session.sheetId, request.CellId)
+	case LiveRequestSubscribe:
+		response.SubscriptionId, err = session.subscribe(request)
+	case LiveRequestUnsubscribe:
+		response.SubscriptionId = request.SubscriptionId
+		err = session.unsubscribe(request.SubscriptionId)
+	default:
+		err = fmt.Errorf("%w: `%s`", LiveRequestTypeError, request.Type)
+	}
+
+	if err != nil {
+		response.Type = LiveResponseError
+		response.Error = err.Error()
+	}
+
+	return response
+}
+
+// set works as SetCellAction, result of the cell is the error message when it can not be evaluated
+func (session *LiveSession) set(request *LiveRequest) (*contracts.Cell, error) {
+	if request.CellId == "" || request.Value == "" {
+		return nil, errors.New("cell_id and value are required")
+	}
+
+	if err := session.api.validateCellValue(request.Value); err != nil {
+		return nil, err
+	}
+
+	return session.api.setCell(&CellEndpointParams{SheetId: session.sheetId, CellId: request.CellId}, request.Value)
+}
+
+func (session *LiveSession) subscribe(request *LiveRequest) (string, error) {
+	scope, err := session.api.makeWebhookScope(&SheetWebhookConfig{Cells: request.Cells, Range: request.Range})
+	if err != nil {
+		return "", err
+	}
+
+	subscription := liveSubscription{cells: scope.Cells}
+	if scope.Range != "" {
+		subscription.cellRange, _ = ParseCellRange(scope.Range)
+	}
+
+	subscriptionId := newRandomId(8)
+
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+	session.subscriptions[subscriptionId] = subscription
+
+	return subscriptionId, nil
+}
+
+func (session *LiveSession) unsubscribe(subscriptionId string) error {
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+
+	if _, ok := session.subscriptions[subscriptionId]; !ok {
+		return LiveSubscriptionNotFoundError
+	}
+	delete(session.subscriptions, subscriptionId)
+
+	return nil
+}
+
+// forwardEvents sends changes of subscribed cells. When the client falls behind the stream, the session is closed
+func (session *LiveSession) forwardEvents(events *contracts.ChangeStreamSubscription) {
+	for event := range events.Events {
+		if session.isSubscribed(event.CanonicalCellId) {
+			session.send(event.WebhookEvent)
+		}
+	}
+
+	_ = session.conn.Close()
+}
+
+func (session *LiveSession) isSubscribed(canonicalCellId string) bool {
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+
+	for _, subscription := range session.subscriptions {
+		if subscription.cellRange != nil && subscription.cellRange.Contains(canonicalCellId) ||
+			subscription.cellRange == nil && (len(subscription.cells) == 0 || slices.Contains(subscription.cells, canonicalCellId)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (session *LiveSession) send(message any) {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return
+	}
+
+	session.writeMutex.Lock()
+	defer session.writeMutex.Unlock()
+
+	// write error means the client is gone, Run returns on the next read
+	_ = websocket.Message.Send(session.conn, string(data))
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"devChallengeExcel/contracts"
+	"devChallengeExcel/mocks"
+	json "github.com/bytedance/sonic"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/net/websocket"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLiveSession(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db, dbClose := _createTmpDb()
+	defer dbClose()
+
+	stream := NewChangeEventStream(10)
+	canonicalizer := NewCanonicalizer()
+	executor := NewExpressionExecutor(canonicalizer)
+	sheetRepository := NewSheetRepository(
+		db, executor, NewCellBinarySerializer(), canonicalizer,
+		NewWebhookDispatcher(db, _makeLoopbackEgressPolicy(t), NewWebhookSigner("", time.Minute), nil, _makeTestWebhookRetryConfig(), DefaultWebhookDeliveryLogSize),
+		stream, CellHistoryConfig{}, DefaultUndoStackSize,
+	)
+	fetcher := mocks.NewExternalRefFetcher(t)
+	fetcher.On("WatchCell", "Sheet1", mock.Anything, mock.Anything, mock.Anything).Return().Maybe()
+
+	server := httptest.NewServer(SetupRouter(NewApiController(sheetRepository, nil, executor, fetcher, nil, nil, nil, stream)))
+	defer server.Close()
+
+	connect := func(t *testing.T) *websocket.Conn {
+		url := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/" + ApiVersion + "/Sheet1/" + liveSessionPath
+		conn, err := websocket.Dial(url, "", server.URL)
+		assert.NoError(t, err)
+		return conn
+	}
+	send := func(t *testing.T, conn *websocket.Conn, request string) {
+		assert.NoError(t, websocket.Message.Send(conn, request))
+	}
+	// receive returns the next messages (responses and events in any order)
+	receive := func(t *testing.T, conn *websocket.Conn, count int) (messages []map[string]any) {
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+		for i := 0; i < count; i++ {
+			var data string
+			if !assert.NoError(t, websocket.Message.Receive(conn, &data)) {
+				return
+			}
+			message := map[string]any{}
+			assert.NoError(t, json.UnmarshalString(data, &message))
+			messages = append(messages, message)
+		}
+		return
+	}
+	byType := func(messages []map[string]any) map[string]map[string]any {
+		result := map[string]map[string]any{}
+		for _, message := range messages {
+			result[message["type"].(string)] = message
+		}
+		return result
+	}
+
+	conn := connect(t)
+	defer conn.Close()
+	other := connect(t)
+	defer other.Close()
+
+	send(t, conn, `{"id": "1", "type": "subscribe", "range": "b1:b5"}`)
+	subscribed := receive(t, conn, 1)[0]
+	assert.Equal(t, "1", subscribed["id"])
+	assert.Equal(t, LiveResponseResult, subscribed["type"])
+	subscriptionId := subscribed["subscription_id"].(string)
+	assert.Len(t, subscriptionId, 16)
+
+	send(t, conn, `{"id": "2", "type": "set", "cell_id": "A1", "value": "1"}`)
+	assert.Equal(t, []map[string]any{{
+		"id": "2", "type": "result", "cell_id": "A1", "cell": map[string]any{"value": "1", "result": "1"},
+	}}, receive(t, conn, 1))
+
+	send(t, conn, `{"id": "3", "type": "set", "cell_id": "B1", "value": "=A1*2"}`)
+	messages := byType(receive(t, conn, 2))
+	assert.Equal(t, map[string]any{"value": "=A1*2", "result": "2"}, messages["result"]["cell"])
+	assert.Equal(t, "B1", messages[contracts.CellChangedEventType]["cell_id"])
+
+	// change of other client recalculates subscribed cell
+	send(t, other, `{"id": "1", "type": "set", "cell_id": "a1", "value": "5"}`)
+	assert.Equal(t, "result", receive(t, other, 1)[0]["type"])
+	event := receive(t, conn, 1)[0]
+	assert.Equal(t, contracts.CellChangedEventType, event["type"])
+	assert.Equal(t, string(contracts.ChangeCauseDependency), event["cause"])
+	assert.Equal(t, "a1", event["caused_by"])
+	assert.Equal(t, map[string]any{"value": "=A1*2", "result": "10"}, event["current"])
+
+	send(t, conn, `{"id": "4", "type": "get", "cell_id": "b1"}`)
+	assert.Equal(t, map[string]any{"value": "=A1*2", "result": "10"}, receive(t, conn, 1)[0]["cell"])
+
+	send(t, conn, `{"id": "5", "type": "unsubscribe", "subscription_id": "`+subscriptionId+`"}`)
+	assert.Equal(t, "result", receive(t, conn, 1)[0]["type"])
+	send(t, conn, `{"id": "6", "type": "set", "cell_id": "A1", "value": "6"}`)
+	send(t, conn, `{"id": "7", "type": "get", "cell_id": "b1"}`)
+	responses := receive(t, conn, 2)
+	assert.Equal(t, "6", responses[0]["id"])
+	assert.Equal(t, "7", responses[1]["id"])
+
+	// errors
+	for request, expectedError := range map[string]string{
+		`{"id": "8", "type": "unsubscribe", "subscription_id": "unknown"}`: "subscription not found",
+		`{"id": "8", "type": "set", "cell_id": "a1+b1", "value": "1"}`:     "cell_id `a1+b1`: " + contracts.CellIdBlacklistError.Error(),
+		`{"id": "8", "type": "set", "cell_id": "a1"}`:                      "cell_id and value are required",
+		`{"id": "8", "type": "get", "cell_id": "unknown"}`:                 contracts.CellNotFoundError.Error(),
+		`{"id": "8", "type": "subscribe", "range": "A1:B"}`:                CellRangeError.Error(),
+		`{"id": "8", "type": "rename"}`:                                    "unknown request type: `rename`",
+	} {
+		send(t, conn, request)
+		response := receive(t, conn, 1)[0]
+		assert.Equal(t, "8", response["id"], request)
+		assert.Equal(t, LiveResponseError, response["type"], request)
+		assert.Contains(t, response["error"], expectedError, request)
+	}
+
+	// evaluation error is the result of the cell
+	send(t, conn, `{"id": "9", "type": "set", "cell_id": "c1", "value": "=C1+1"}`)
+	response := receive(t, conn, 1)[0]
+	assert.Equal(t, LiveResponseError, response["type"])
+	assert.Equal(t, "=C1+1", response["cell"].(map[string]any)["value"])
+	assert.Equal(t, response["error"], response["cell"].(map[string]any)["result"])
+
+	send(t, conn, `not json`)
+	assert.Equal(t, LiveResponseError, receive(t, conn, 1)[0]["type"])
+}
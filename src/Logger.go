@@ -0,0 +1,9 @@
+package main
+
+import (
+	"log"
+	"os"
+)
+
+// logger prints errors of background work (webhooks, subscriptions to external refs) and messages of the lifecycle
+var logger = log.New(os.Stdout, "", log.LstdFlags)
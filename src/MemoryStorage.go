@@ -0,0 +1,329 @@
+package main
+
+import (
+	"devChallengeExcel/contracts"
+	"slices"
+	"sync"
+)
+
+// MemoryStorage is contracts.Storage which keeps data in memory only (tests and ephemeral deployments).
+// Transactions are serialized with RW mutex: views are concurrent, updates are exclusive.
+// Update records undo operations, so changes are rolled back when it fails
+type MemoryStorage struct {
+	mutex  sync.RWMutex
+	root   *memoryBucket
+	closed bool
+}
+
+type memoryBucket struct {
+	// keys of values in sorted order to iterate with cursor
+	keys     []string
+	values   map[string][]byte
+	buckets  map[string]*memoryBucket
+	sequence uint64
+}
+
+type memoryStorageTx struct {
+	writable bool
+	undo     []func()
+}
+
+type memoryStorageBucket struct {
+	tx     *memoryStorageTx
+	bucket *memoryBucket
+}
+
+// memoryStorageCursor keeps current key instead of index, so the bucket can be modified during iteration
+type memoryStorageCursor struct {
+	bucket  *memoryBucket
+	current string
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{root: newMemoryBucket()}
+}
+
+func newMemoryBucket() *memoryBucket {
+	return &memoryBucket{
+		keys:    make([]string, 0),
+		values:  map[string][]byte{},
+		buckets: map[string]*memoryBucket{},
+	}
+}
+
+func (s *MemoryStorage) View(fn func(tx contracts.StorageTx) error) error {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if s.closed {
+		return contracts.StorageClosedError
+	}
+
+	return fn(s.makeTx(&memoryStorageTx{writable: false}))
+}
+
+func (s *MemoryStorage) Update(fn func(tx contracts.StorageTx) error) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		return contracts.StorageClosedError
+	}
+
+	// changes are rolled back on panic as well
+	tx := &memoryStorageTx{writable: true}
+	committed := false
+	defer func() {
+		if !committed {
+			tx.rollback()
+		}
+	}()
+
+	err := fn(s.makeTx(tx))
+	committed = err == nil
+
+	return err
+}
+
+func (s *MemoryStorage) Batch(fn func(tx contracts.StorageTx) error) error {
+	return s.Update(fn)
+}
+
+func (s *MemoryStorage) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.closed = true
+	s.root = newMemoryBucket()
+
+	return nil
+}
+
+// makeTx returns root bucket, it has only nested buckets
+func (s *MemoryStorage) makeTx(tx *memoryStorageTx) contracts.StorageTx {
+	return &memoryStorageBucket{tx: tx, bucket: s.root}
+}
+
+func (t *memoryStorageTx) rollback() {
+	for i := len(t.undo) - 1; i >= 0; i-- {
+		t.undo[i]()
+	}
+	t.undo = nil
+}
+
+func (b *memoryStorageBucket) Get(key []byte) []byte {
+	return b.bucket.values[string(key)]
+}
+
+func (b *memoryStorageBucket) Put(key []byte, value []byte) error {
+	if err := b.checkWritable(key, contracts.StorageKeyRequiredError); err != nil {
+		return err
+	}
+
+	k := string(key)
+	if _, ok := b.bucket.buckets[k]; ok {
+		return contracts.StorageIncompatibleValueError
+	}
+
+	bucket := b.bucket
+	if previous, ok := bucket.values[k]; ok {
+		b.tx.undo = append(b.tx.undo, func() {
+			bucket.values[k] = previous
+		})
+	} else {
+		bucket.insertKey(k)
+		b.tx.undo = append(b.tx.undo, func() {
+			bucket.deleteKey(k)
+		})
+	}
+	bucket.values[k] = append([]byte{}, value...)
+
+	return nil
+}
+
+func (b *memoryStorageBucket) Delete(key []byte) error {
+	if !b.tx.writable {
+		return contracts.StorageTxNotWritableError
+	}
+
+	k := string(key)
+	if _, ok := b.bucket.buckets[k]; ok {
+		return contracts.StorageIncompatibleValueError
+	}
+
+	bucket := b.bucket
+	previous, ok := bucket.values[k]
+	if !ok {
+		return nil
+	}
+
+	bucket.deleteKey(k)
+	b.tx.undo = append(b.tx.undo, func() {
+		bucket.insertKey(k)
+		bucket.values[k] = previous
+	})
+
+	return nil
+}
+
+func (b *memoryStorageBucket) Bucket(name []byte) contracts.StorageBucket {
+	if bucket, ok := b.bucket.buckets[string(name)]; ok {
+		return &memoryStorageBucket{tx: b.tx, bucket: bucket}
+	}
+
+	return nil
+}
+
+func (b *memoryStorageBucket) CreateBucketIfNotExists(name []byte) (contracts.StorageBucket, error) {
+	if err := b.checkWritable(name, contracts.StorageBucketNameRequiredError); err != nil {
+		return nil, err
+	}
+
+	if existing := b.Bucket(name); existing != nil {
+		return existing, nil
+	}
+
+	k := string(name)
+	if _, ok := b.bucket.values[k]; ok {
+		return nil, contracts.StorageIncompatibleValueError
+	}
+
+	parent := b.bucket
+	bucket := newMemoryBucket()
+	parent.buckets[k] = bucket
+	b.tx.undo = append(b.tx.undo, func() {
+		delete(parent.buckets, k)
+	})
+
+	return &memoryStorageBucket{tx: b.tx, bucket: bucket}, nil
+}
+
+func (b *memoryStorageBucket) DeleteBucket(name []byte) error {
+	if err := b.checkWritable(name, contracts.StorageBucketNameRequiredError); err != nil {
+		return err
+	}
+
+	k := string(name)
+	parent := b.bucket
+	bucket, ok := parent.buckets[k]
+	if !ok {
+		if _, ok = parent.values[k]; ok {
+			return contracts.StorageIncompatibleValueError
+		}
+		return contracts.StorageBucketNotFoundError
+	}
+
+	delete(parent.buckets, k)
+	b.tx.undo = append(b.tx.undo, func() {
+		parent.buckets[k] = bucket
+	})
+
+	return nil
+}
+
+func (b *memoryStorageBucket) ForEach(fn func(key []byte, value []byte) error) error {
+	for _, key := range slices.Clone(b.bucket.keys) {
+		if err := fn([]byte(key), b.bucket.values[key]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (b *memoryStorageBucket) ForEachBucket(fn func(name []byte) error) error {
+	names := make([]string, 0, len(b.bucket.buckets))
+	for name := range b.bucket.buckets {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		if err := fn([]byte(name)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (b *memoryStorageBucket) Cursor() contracts.StorageCursor {
+	return &memoryStorageCursor{bucket: b.bucket}
+}
+
+func (b *memoryStorageBucket) NextSequence() (uint64, error) {
+	if !b.tx.writable {
+		return 0, contracts.StorageTxNotWritableError
+	}
+
+	bucket := b.bucket
+	bucket.sequence++
+	b.tx.undo = append(b.tx.undo, func() {
+		bucket.sequence--
+	})
+
+	return bucket.sequence, nil
+}
+
+func (b *memoryStorageBucket) checkWritable(key []byte, requiredError error) error {
+	if !b.tx.writable {
+		return contracts.StorageTxNotWritableError
+	}
+	if len(key) == 0 {
+		return requiredError
+	}
+
+	return nil
+}
+
+func (b *memoryBucket) insertKey(key string) {
+	if index, found := slices.BinarySearch(b.keys, key); !found {
+		b.keys = slices.Insert(b.keys, index, key)
+	}
+}
+
+func (b *memoryBucket) deleteKey(key string) {
+	if index, found := slices.BinarySearch(b.keys, key); found {
+		b.keys = slices.Delete(b.keys, index, index+1)
+	}
+	delete(b.values, key)
+}
+
+func (c *memoryStorageCursor) First() ([]byte, []byte) {
+	return c.moveTo(0)
+}
+
+func (c *memoryStorageCursor) Last() ([]byte, []byte) {
+	return c.moveTo(len(c.bucket.keys) - 1)
+}
+
+func (c *memoryStorageCursor) Next() ([]byte, []byte) {
+	index, found := slices.BinarySearch(c.bucket.keys, c.current)
+	if found {
+		index++
+	}
+
+	return c.moveTo(index)
+}
+
+func (c *memoryStorageCursor) Prev() ([]byte, []byte) {
+	index, _ := slices.BinarySearch(c.bucket.keys, c.current)
+
+	return c.moveTo(index - 1)
+}
+
+func (c *memoryStorageCursor) Seek(seek []byte) ([]byte, []byte) {
+	index, _ := slices.BinarySearch(c.bucket.keys, string(seek))
+
+	return c.moveTo(index)
+}
+
+func (c *memoryStorageCursor) moveTo(index int) ([]byte, []byte) {
+	if index < 0 || index >= len(c.bucket.keys) {
+		return nil, nil
+	}
+
+	c.current = c.bucket.keys[index]
+
+	return []byte(c.current), c.bucket.values[c.current]
+}
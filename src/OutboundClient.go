@@ -0,0 +1,143 @@
+package main
+
+import (
+	"devChallengeExcel/contracts"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+var CircuitOpenError = errors.New("circuit breaker is open")
+
+type OutboundClientConfig struct {
+	// MaxAttempts of single request (first attempt + retries)
+	MaxAttempts int
+	// RetryBackoff base delay before retry. It is doubled with each retry and jittered
+	RetryBackoff time.Duration
+	// CircuitBreakerThreshold consecutive failures which open circuit breaker of the host
+	CircuitBreakerThreshold int
+	// CircuitBreakerOpenDuration how long requests to the host fail fast
+	CircuitBreakerOpenDuration time.Duration
+}
+
+const (
+	DefaultOutboundMaxAttempts                = 3
+	DefaultOutboundRetryBackoff               = 100 * time.Millisecond
+	DefaultOutboundCircuitBreakerThreshold    = 5
+	DefaultOutboundCircuitBreakerOpenDuration = 30 * time.Second
+)
+
+// OutboundClient shared client of outbound requests to other API instances.
+// Transport errors, 5xx and 429 responses are retried with jittered exponential backoff
+// and counted by circuit breaker of the host.
+type OutboundClient struct {
+	client   *http.Client
+	config   OutboundClientConfig
+	mutex    sync.Mutex
+	breakers map[string]*CircuitBreaker
+}
+
+func NewOutboundClient(client *http.Client, config OutboundClientConfig) *OutboundClient {
+	if config.MaxAttempts < 1 {
+		config.MaxAttempts = 1
+	}
+	if config.CircuitBreakerThreshold < 1 {
+		config.CircuitBreakerThreshold = DefaultOutboundCircuitBreakerThreshold
+	}
+
+	return &OutboundClient{
+		client:   client,
+		config:   config,
+		breakers: map[string]*CircuitBreaker{},
+	}
+}
+
+func (c *OutboundClient) Do(request *http.Request) (response *http.Response, err error) {
+	breaker := c.getBreaker(request.URL.Host)
+
+	for attempt := 0; attempt < c.config.MaxAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(c.backoff(attempt))
+		}
+
+		if !breaker.Allow() {
+			return nil, fmt.Errorf("%w: host %s", CircuitOpenError, request.URL.Host)
+		}
+
+		attemptRequest := request.Clone(request.Context())
+		if request.GetBody != nil {
+			if attemptRequest.Body, err = request.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+
+		response, err = c.client.Do(attemptRequest)
+		if errors.Is(err, EgressPolicyError) {
+			// not a failure of the host
+			breaker.Success()
+			return nil, err
+		}
+
+		if !isRetryableResponse(response, err) {
+			breaker.Success()
+			return response, nil
+		}
+
+		breaker.Failure()
+		if attempt < c.config.MaxAttempts-1 && response != nil {
+			response.Body.Close()
+		}
+	}
+
+	return
+}
+
+func (c *OutboundClient) CircuitBreakers() []contracts.CircuitBreakerStatus {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	statuses := make([]contracts.CircuitBreakerStatus, 0, len(c.breakers))
+	for host, breaker := range c.breakers {
+		statuses = append(statuses, breaker.Status(host))
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Host < statuses[j].Host
+	})
+
+	return statuses
+}
+
+func (c *OutboundClient) getBreaker(host string) *CircuitBreaker {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	breaker, ok := c.breakers[host]
+	if !ok {
+		breaker = NewCircuitBreaker(c.config.CircuitBreakerThreshold, c.config.CircuitBreakerOpenDuration)
+		c.breakers[host] = breaker
+	}
+
+	return breaker
+}
+
+// backoff "full jitter": random delay up to RetryBackoff * 2^(attempt-1)
+func (c *OutboundClient) backoff(attempt int) time.Duration {
+	maxDelay := c.config.RetryBackoff << (attempt - 1)
+	if maxDelay <= 0 {
+		return 0
+	}
+
+	return time.Duration(rand.Int63n(int64(maxDelay)))
+}
+
+func isRetryableResponse(response *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+
+	return response.StatusCode >= http.StatusInternalServerError || response.StatusCode == http.StatusTooManyRequests
+}
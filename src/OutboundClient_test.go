@@ -0,0 +1,169 @@
+package main
+
+import (
+	"devChallengeExcel/contracts"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestOutboundClient_Do(t *testing.T) {
+	var requestsCount atomic.Int32
+	var failuresLeft atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestsCount.Add(1)
+		body, _ := io.ReadAll(r.Body)
+
+		if r.URL.Path == "/not-found" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if failuresLeft.Add(-1) >= 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(body)
+	}))
+	defer server.Close()
+
+	newClient := func(maxAttempts int, threshold int, openDuration time.Duration) *OutboundClient {
+		return NewOutboundClient(_makeLoopbackEgressPolicy(t).NewClient(time.Second), OutboundClientConfig{
+			MaxAttempts:                maxAttempts,
+			RetryBackoff:               time.Millisecond,
+			CircuitBreakerThreshold:    threshold,
+			CircuitBreakerOpenDuration: openDuration,
+		})
+	}
+
+	t.Run("retry_until_success", func(t *testing.T) {
+		requestsCount.Store(0)
+		failuresLeft.Store(2)
+		client := newClient(3, 5, time.Minute)
+
+		request, _ := http.NewRequest(http.MethodPost, server.URL, strings.NewReader("payload"))
+		response, err := client.Do(request)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		body, _ := io.ReadAll(response.Body)
+		assert.Equal(t, "payload", string(body), "body is sent with each attempt")
+		assert.Equal(t, int32(3), requestsCount.Load())
+		assert.Equal(t, contracts.CircuitBreakerClosed, client.CircuitBreakers()[0].State)
+		assert.Equal(t, 0, client.CircuitBreakers()[0].Failures)
+	})
+
+	t.Run("not_retryable", func(t *testing.T) {
+		requestsCount.Store(0)
+		client := newClient(3, 5, time.Minute)
+
+		request, _ := http.NewRequest(http.MethodGet, server.URL+"/not-found", nil)
+		response, err := client.Do(request)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, response.StatusCode)
+		assert.Equal(t, int32(1), requestsCount.Load())
+	})
+
+	t.Run("attempts_exhausted", func(t *testing.T) {
+		requestsCount.Store(0)
+		failuresLeft.Store(10)
+		client := newClient(2, 5, time.Minute)
+
+		request, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		response, err := client.Do(request)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusServiceUnavailable, response.StatusCode)
+		assert.Equal(t, int32(2), requestsCount.Load())
+		assert.Equal(t, 2, client.CircuitBreakers()[0].Failures)
+	})
+
+	t.Run("circuit_breaker", func(t *testing.T) {
+		requestsCount.Store(0)
+		failuresLeft.Store(10)
+		client := newClient(2, 3, time.Millisecond*50)
+
+		// 2 failures, then 1 failure opens the breaker and the second attempt fails fast
+		for i := 0; i < 2; i++ {
+			request, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+			_, _ = client.Do(request)
+		}
+		assert.Equal(t, int32(3), requestsCount.Load())
+
+		request, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		_, err := client.Do(request)
+		assert.ErrorIs(t, err, CircuitOpenError)
+		assert.Equal(t, int32(3), requestsCount.Load())
+
+		statuses := client.CircuitBreakers()
+		assert.Len(t, statuses, 1)
+		assert.Equal(t, request.URL.Host, statuses[0].Host)
+		assert.Equal(t, contracts.CircuitBreakerOpen, statuses[0].State)
+		assert.NotNil(t, statuses[0].OpenedAt)
+
+		// half-open: trial request succeeds and closes the breaker
+		failuresLeft.Store(0)
+		time.Sleep(time.Millisecond * 60)
+		request, _ = http.NewRequest(http.MethodGet, server.URL, nil)
+		response, err := client.Do(request)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		assert.Equal(t, contracts.CircuitBreakerClosed, client.CircuitBreakers()[0].State)
+	})
+
+	t.Run("transport_error", func(t *testing.T) {
+		client := newClient(2, 1, time.Minute)
+
+		request, _ := http.NewRequest(http.MethodGet, "http://127.0.0.1:0/unreachable", nil)
+		_, err := client.Do(request)
+		assert.ErrorIs(t, err, CircuitOpenError)
+	})
+
+	t.Run("egress_policy_error", func(t *testing.T) {
+		policy, _ := NewEgressPolicy(EgressPolicyConfig{AllowedSchemes: DefaultEgressAllowedSchemes, DeniedCidrs: DefaultEgressDeniedCidrs})
+		client := NewOutboundClient(policy.NewClient(time.Second), OutboundClientConfig{MaxAttempts: 3, CircuitBreakerThreshold: 1})
+
+		request, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		_, err := client.Do(request)
+		assert.ErrorIs(t, err, EgressPolicyError)
+		assert.Equal(t, contracts.CircuitBreakerClosed, client.CircuitBreakers()[0].State)
+	})
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	breaker := NewCircuitBreaker(2, time.Millisecond*20)
+
+	assert.True(t, breaker.Allow())
+	breaker.Failure()
+	assert.True(t, breaker.Allow())
+	breaker.Failure()
+	assert.False(t, breaker.Allow())
+	assert.Equal(t, contracts.CircuitBreakerOpen, breaker.Status("host").State)
+
+	time.Sleep(time.Millisecond * 30)
+	assert.True(t, breaker.Allow(), "trial request")
+	assert.False(t, breaker.Allow(), "only single trial request")
+	assert.Equal(t, contracts.CircuitBreakerHalfOpen, breaker.Status("host").State)
+
+	breaker.Failure()
+	assert.False(t, breaker.Allow(), "failed trial opens breaker again")
+
+	time.Sleep(time.Millisecond * 30)
+	assert.True(t, breaker.Allow())
+	breaker.Success()
+	assert.True(t, breaker.Allow())
+	assert.Equal(t, contracts.CircuitBreakerStatus{Host: "host", State: contracts.CircuitBreakerClosed}, breaker.Status("host"))
+}
+
+// _makeLoopbackOutboundClient client without retries to test servers on loopback
+func _makeLoopbackOutboundClient(t *testing.T) *OutboundClient {
+	return NewOutboundClient(_makeLoopbackEgressPolicy(t).NewClient(time.Second*4), OutboundClientConfig{MaxAttempts: 1})
+}
@@ -2,34 +2,117 @@ package main
 
 import (
 	"devChallengeExcel/contracts"
+	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
-	"go.etcd.io/bbolt"
+	"time"
 )
 
 type ServiceContainer struct {
-	Database           *bbolt.DB
+	Database           contracts.Storage
 	ApiController      contracts.ApiController
 	SheetRepository    contracts.SheetRepository
 	ExpressionExecutor contracts.ExpressionExecutor
 	WebhookDispatcher  contracts.WebhookDispatcher
+	ChangeEventStream  contracts.ChangeEventStream
+	ExternalRefFetcher contracts.ExternalRefFetcher
+	EgressPolicy       contracts.EgressPolicy
+	OutboundClient     contracts.OutboundClient
+	WebhookSigner      contracts.WebhookSigner
 	Router             *gin.Engine
 }
 
-func BuildServiceContainer(configDbPath string) (container ServiceContainer, err error) {
-	container.Database, err = bbolt.Open(configDbPath, 0600, nil)
+func BuildServiceContainer(config Config) (container ServiceContainer, err error) {
+	egressPolicy, err := NewEgressPolicy(config.Egress)
+	if err != nil {
+		return
+	}
+	container.EgressPolicy = egressPolicy
+
+	container.Database, err = OpenStorage(config.StorageBackend, config.DatabaseFilepath)
+	if err != nil {
+		return
+	}
 	serializer := NewCellBinarySerializer()
 	canonicalizer := NewCanonicalizer()
 
-	container.ExpressionExecutor = NewExpressionExecutor(canonicalizer)
-	container.WebhookDispatcher = NewWebhookDispatcher()
+	container.OutboundClient = NewOutboundClient(egressPolicy.NewClient(time.Second*4), config.Outbound)
+
+	externalRefFetcher := NewExternalRefFetcher(
+		config.ExternalRefCacheTtl, config.ExternalJsonPollInterval, container.OutboundClient, canonicalizer,
+	)
+	container.ExternalRefFetcher = externalRefFetcher
+
+	container.ExpressionExecutor = NewExpressionExecutor(
+		canonicalizer, externalRefFetcher.Function(), externalRefFetcher.JsonFunction(),
+	)
+	container.WebhookSigner = NewWebhookSigner(config.WebhookSecret, config.WebhookSignatureTolerance)
+	webhookDispatcher := NewWebhookDispatcher(
+		container.Database, egressPolicy, container.WebhookSigner, container.ExpressionExecutor, config.WebhookRetry,
+		config.WebhookDeliveryLogSize,
+	)
+	if err = webhookDispatcher.Load(); err != nil {
+		return
+	}
+	container.WebhookDispatcher = webhookDispatcher
+	container.ChangeEventStream = NewChangeEventStream(config.ChangeEventStreamBufferSize)
 	container.SheetRepository = NewSheetRepository(
 		container.Database, container.ExpressionExecutor,
 		serializer, canonicalizer,
-		container.WebhookDispatcher,
+		container.WebhookDispatcher, container.ChangeEventStream,
+		config.CellHistory, config.UndoStackSize,
+	)
+	externalRefFetcher.OnUpdate(makeExternalRefUpdateHandler(container.SheetRepository))
+	if err = watchStoredCells(container.SheetRepository, container.ExpressionExecutor, externalRefFetcher); err != nil {
+		return
+	}
+
+	container.ApiController = NewApiController(
+		container.SheetRepository, container.WebhookDispatcher,
+		container.ExpressionExecutor, container.ExternalRefFetcher,
+		container.EgressPolicy, container.OutboundClient, container.WebhookSigner,
+		container.ChangeEventStream,
 	)
-	container.ApiController = NewApiController(container.SheetRepository, container.WebhookDispatcher, container.ExpressionExecutor)
 
 	container.Router = SetupRouter(container.ApiController)
 
 	return
 }
+
+var UnknownStorageBackendError = errors.New("unknown storage backend")
+
+// OpenStorage opens database of the backend (bbolt by default), path is not used by memory storage
+func OpenStorage(backend string, path string) (contracts.Storage, error) {
+	switch backend {
+	case "", StorageBackendBolt:
+		return OpenBoltStorage(path)
+	case StorageBackendSqlite:
+		return OpenSqliteStorage(path)
+	case StorageBackendMemory:
+		return NewMemoryStorage(), nil
+	}
+
+	return nil, fmt.Errorf("`%s`: %w", backend, UnknownStorageBackendError)
+}
+
+// watchStoredCells restores watchers of external refs of stored cells, they are kept in memory only.
+// Must be called before the fetcher is started, otherwise stored cells are not refreshed until they are written again
+func watchStoredCells(
+	sheetRepository contracts.SheetRepository, executor contracts.ExpressionExecutor, fetcher contracts.ExternalRefFetcher,
+) error {
+	return sheetRepository.ForEachCell(func(sheetId string, cellId string, value string) {
+		urls := executor.ExtractExternalRefs(value)
+		jsonUrls := executor.ExtractExternalJsonUrls(value)
+		if len(urls) > 0 || len(jsonUrls) > 0 {
+			fetcher.WatchCell(sheetId, cellId, urls, jsonUrls)
+		}
+	})
+}
+
+// makeExternalRefUpdateHandler recalculates the cell (and its dependants) with fresh result of external_ref
+// The origin of the change is passed further to webhooks of the cell
+func makeExternalRefUpdateHandler(sheetRepository contracts.SheetRepository) func(sheetId string, cellId string, origin contracts.ChangeOrigin) {
+	return func(sheetId string, cellId string, origin contracts.ChangeOrigin) {
+		_, _ = sheetRepository.RecalculateCell(sheetId, cellId, origin)
+	}
+}
@@ -1,10 +1,11 @@
 package main
 
 import (
+	"devChallengeExcel/contracts"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
-	"go.etcd.io/bbolt"
 	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -14,13 +15,13 @@ func TestBuildServiceContainer(t *testing.T) {
 	f, err := os.CreateTemp("", "db_*.db")
 	defer os.Remove(f.Name())
 
-	serviceContainer, err := BuildServiceContainer(f.Name())
+	serviceContainer, err := BuildServiceContainer(Config{DatabaseFilepath: f.Name()})
 
 	assert.NoError(t, err)
 
 	// check database
 	assert.NotNil(t, serviceContainer.Database)
-	assert.IsType(t, &bbolt.DB{}, serviceContainer.Database)
+	assert.IsType(t, &BoltStorage{}, serviceContainer.Database)
 	assert.NoError(t, serviceContainer.Database.Close())
 
 	// check expression executor
@@ -60,6 +61,20 @@ func TestBuildServiceContainer(t *testing.T) {
 	assert.Equal(t, serviceContainer.SheetRepository, apiController.SheetRepository)
 	assert.NotNil(t, apiController.WebhookDispatcher)
 	assert.Equal(t, serviceContainer.WebhookDispatcher, apiController.WebhookDispatcher)
+	assert.NotNil(t, apiController.ExternalRefFetcher)
+	assert.IsType(t, &ExternalRefFetcher{}, apiController.ExternalRefFetcher)
+	assert.Equal(t, serviceContainer.ExternalRefFetcher, apiController.ExternalRefFetcher)
+
+	assert.NotNil(t, apiController.EgressPolicy)
+	assert.IsType(t, &EgressPolicy{}, apiController.EgressPolicy)
+	assert.Equal(t, serviceContainer.EgressPolicy, apiController.EgressPolicy)
+
+	assert.NotNil(t, apiController.OutboundClient)
+	assert.IsType(t, &OutboundClient{}, apiController.OutboundClient)
+	assert.Equal(t, serviceContainer.OutboundClient, apiController.OutboundClient)
+
+	assert.NotNil(t, apiController.WebhookSigner)
+	assert.Equal(t, serviceContainer.WebhookSigner, apiController.WebhookSigner)
 
 	// check router
 	assert.NotNil(t, serviceContainer.Router)
@@ -71,3 +86,71 @@ func TestBuildServiceContainer(t *testing.T) {
 	// 3 api route + health check
 	assert.GreaterOrEqual(t, len(routes), 4)
 }
+
+func TestBuildServiceContainer_RestoresWebhooks(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	f, _ := os.CreateTemp("", "db_*.db")
+	defer os.Remove(f.Name())
+
+	serviceContainer, err := BuildServiceContainer(Config{DatabaseFilepath: f.Name()})
+	assert.NoError(t, err)
+	subscription, err := serviceContainer.WebhookDispatcher.Subscribe("sheet1", "a1", "http://remote/webhook", "test", contracts.WebhookOptions{})
+	assert.NoError(t, err)
+	assert.NoError(t, serviceContainer.Database.Close())
+
+	// restart
+	serviceContainer, err = BuildServiceContainer(Config{DatabaseFilepath: f.Name()})
+	assert.NoError(t, err)
+	assert.Equal(t, []contracts.WebhookSubscription{*subscription}, serviceContainer.WebhookDispatcher.GetSubscriptions("sheet1", "a1"))
+	assert.NoError(t, serviceContainer.Database.Close())
+}
+
+func TestBuildServiceContainer_RestoresExternalRefWatchers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	f, _ := os.CreateTemp("", "db_*.db")
+	defer os.Remove(f.Name())
+
+	refUrl := "http://remote/ref"
+	jsonUrl := "http://remote/json"
+	serviceContainer, err := BuildServiceContainer(Config{DatabaseFilepath: f.Name()})
+	assert.NoError(t, err)
+	_, err, _ = serviceContainer.SheetRepository.SetCell("Sheet1", "A1", `=external_ref("`+refUrl+`")`, false)
+	assert.NoError(t, err)
+	_, err, _ = serviceContainer.SheetRepository.SetCell("sheet2", "b1", `=external_json("`+jsonUrl+`", "$.a")`, false)
+	assert.NoError(t, err)
+	_, err, _ = serviceContainer.SheetRepository.SetCell("sheet2", "b2", "1", false)
+	assert.NoError(t, err)
+	assert.NoError(t, serviceContainer.Database.Close())
+
+	// restart
+	serviceContainer, err = BuildServiceContainer(Config{DatabaseFilepath: f.Name()})
+	assert.NoError(t, err)
+	defer serviceContainer.Database.Close()
+
+	fetcher := serviceContainer.ExternalRefFetcher.(*ExternalRefFetcher)
+	assert.Equal(t, map[ExternalRefWatcher][]string{
+		{SheetId: "sheet1", CellId: "a1"}: {refUrl},
+		{SheetId: "sheet2", CellId: "b1"}: {externalJsonKeyPrefix + jsonUrl},
+	}, fetcher.watched)
+}
+
+func TestOpenStorage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage.db")
+
+	for backend, expectedType := range map[string]contracts.Storage{
+		"":                   &BoltStorage{},
+		StorageBackendBolt:   &BoltStorage{},
+		StorageBackendSqlite: &SqliteStorage{},
+		StorageBackendMemory: &MemoryStorage{},
+	} {
+		storage, err := OpenStorage(backend, path+backend)
+		assert.NoError(t, err)
+		assert.IsType(t, expectedType, storage)
+		assert.NoError(t, storage.Close())
+	}
+
+	_, err := OpenStorage("unknown", path)
+	assert.ErrorIs(t, err, UnknownStorageBackendError)
+}
@@ -4,25 +4,31 @@ import (
 	"bytes"
 	"devChallengeExcel/contracts"
 	"fmt"
-	"go.etcd.io/bbolt"
 	"strings"
+	"time"
 )
 
 type SheetRepository struct {
-	db                *bbolt.DB
+	db                contracts.Storage
 	executor          contracts.ExpressionExecutor
 	serializer        contracts.CellSerializer
 	canonicalizer     contracts.Canonicalizer
 	dependencyTree    contracts.CellDependencyTree
 	webhookDispatcher contracts.WebhookDispatcher
+	changeEventStream contracts.ChangeEventStream
+	settingsStorage   SheetSettingsStorage
+	subscriptions     ExternalRefSubscriptionStorage
+	history           CellHistoryStorage
+	undoStack         UndoStackStorage
 }
 
 var errorNoChanges = fmt.Errorf("no changes")
 
 func NewSheetRepository(
-	db *bbolt.DB, executor contracts.ExpressionExecutor,
+	db contracts.Storage, executor contracts.ExpressionExecutor,
 	serializer contracts.CellSerializer, canonicalizer contracts.Canonicalizer,
-	webhookDispatcher contracts.WebhookDispatcher,
+	webhookDispatcher contracts.WebhookDispatcher, changeEventStream contracts.ChangeEventStream,
+	historyConfig CellHistoryConfig, undoStackSize int,
 ) *SheetRepository {
 	return &SheetRepository{
 		db:                db,
@@ -31,6 +37,9 @@ func NewSheetRepository(
 		canonicalizer:     canonicalizer,
 		dependencyTree:    &CellDependencyTree{},
 		webhookDispatcher: webhookDispatcher,
+		changeEventStream: changeEventStream,
+		history:           CellHistoryStorage{config: historyConfig},
+		undoStack:         UndoStackStorage{size: undoStackSize},
 	}
 }
 
@@ -38,7 +47,46 @@ func (s *SheetRepository) GetCanonicalSheetId(sheetId string) string {
 	return strings.ToLower(sheetId)
 }
 
+func (s *SheetRepository) GetCanonicalCellId(cellId string) string {
+	return s.canonicalizer.Canonicalize(cellId)
+}
+
 func (s *SheetRepository) SetCell(sheetId string, cellId string, value string, skipNotChanged bool) (cell *contracts.Cell, err error, isUpdated bool) {
+	return s.setCell(sheetId, cellId, value, skipNotChanged, contracts.ChangeCauseDirectEdit, contracts.ChangeOrigin{})
+}
+
+// RecalculateCell evaluates stored value of the cell again (e.g. result of its external_ref is changed).
+// Dependants are notified with the origin of the change. The cell keeps its stored id, cellId may differ in case
+func (s *SheetRepository) RecalculateCell(sheetId string, cellId string, origin contracts.ChangeOrigin) (*contracts.Cell, error) {
+	canonicalSheetId := s.GetCanonicalSheetId(sheetId)
+	canonicalKey := []byte(s.canonicalizer.Canonicalize(cellId))
+
+	var storedCellId, value string
+	err := s.db.View(func(tx contracts.StorageTx) (err error) {
+		bucket := tx.Bucket([]byte(canonicalSheetId))
+		if bucket == nil {
+			return fmt.Errorf("%s: %w", canonicalSheetId, contracts.SheetNotFoundError)
+		}
+
+		byteValue := bucket.Get(canonicalKey)
+		if byteValue == nil {
+			return fmt.Errorf("%s: %w", cellId, contracts.CellNotFoundError)
+		}
+
+		storedCellId, value, err = s.serializer.Unmarshal(byteValue)
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cell, err, _ := s.setCell(sheetId, storedCellId, value, false, contracts.ChangeCauseExternalRef, origin)
+	return cell, err
+}
+
+func (s *SheetRepository) setCell(
+	sheetId string, cellId string, value string, skipNotChanged bool, cause contracts.ChangeCause, origin contracts.ChangeOrigin,
+) (cell *contracts.Cell, err error, isUpdated bool) {
 	sheetId = s.GetCanonicalSheetId(sheetId)
 	sheetIdByte := []byte(sheetId)
 
@@ -60,14 +108,16 @@ func (s *SheetRepository) SetCell(sheetId string, cellId string, value string, s
 
 	var dependants []string
 	var dependantsCellList []*contracts.Cell
+	var changes []contracts.CellChange
 
-	err = s.db.View(func(tx *bbolt.Tx) (err error) {
+	err = s.db.View(func(tx contracts.StorageTx) (err error) {
+		executor := s.getExecutor(tx, sheetIdByte)
 		readBucket := tx.Bucket(sheetIdByte)
 		if readBucket == nil {
 			dependants = make([]string, 0)
 		} else {
 			if skipNotChanged && bytes.Equal(readBucket.Get(cellCanonicalKeyByte), serializedData) {
-				cell.Result, err = s.executor.Evaluate(cell.Value, s.makeValuesGetter(tx, sheetIdByte))
+				cell.Result, err = executor.Evaluate(cell.Value, s.makeValuesGetter(tx, sheetIdByte))
 				return errorNoChanges
 			}
 
@@ -75,16 +125,16 @@ func (s *SheetRepository) SetCell(sheetId string, cellId string, value string, s
 		}
 
 		dependantsCellList = s.makeDependantsCellList(tx, sheetIdByte, cell, dependants)
+		changes = s.makeCellChanges(tx, sheetIdByte, executor, dependantsCellList, cellId, cause)
 		expressions := make(contracts.ExpressionsMap, len(dependantsCellList))
 		for i := range dependantsCellList {
 			expressions[dependantsCellList[i].CanonicalKey] = &dependantsCellList[i].Result
 		}
 
-		err = s.executor.MultiEvaluate(expressions, s.makeValuesGetter(tx, sheetIdByte), true)
+		err = executor.MultiEvaluate(expressions, s.makeValuesGetter(tx, sheetIdByte), true)
 		return err
 	})
 
-	isUpdated = err == nil
 	if err != nil {
 		if err == errorNoChanges {
 			err = nil
@@ -94,34 +144,316 @@ func (s *SheetRepository) SetCell(sheetId string, cellId string, value string, s
 
 	dependingOnList := s.executor.ExtractDependingOnList(value)
 
-	err = s.db.Batch(func(tx *bbolt.Tx) (err error) {
-		var bucket *bbolt.Bucket
+	err = s.db.Batch(func(tx contracts.StorageTx) (err error) {
+		var bucket contracts.StorageBucket
 		bucket, err = tx.CreateBucketIfNotExists(sheetIdByte)
 		if err != nil {
 			return err
 		}
 
+		// the stored cell is read in the same transaction, so undo restores exactly the overwritten state
+		undo := &undoEntry{
+			CanonicalKey:    cellCanonicalKey,
+			Before:          bytes.Clone(bucket.Get(cellCanonicalKeyByte)),
+			BeforeDependsOn: s.dependencyTree.GetDependsOn(tx, sheetIdByte, cellCanonicalKey),
+			After:           serializedData,
+			AfterDependsOn:  dependingOnList,
+		}
+
 		err = s.dependencyTree.SetDependsOn(tx, sheetIdByte, cellCanonicalKey, dependingOnList)
 		if err != nil {
 			return
 		}
 
-		return bucket.Put(cellCanonicalKeyByte, serializedData)
+		err = bucket.Put(cellCanonicalKeyByte, serializedData)
+		if err != nil {
+			return
+		}
+
+		// writes caused by external refs keep the value of the cell, there is nothing to undo
+		if cause == contracts.ChangeCauseDirectEdit && !bytes.Equal(undo.Before, undo.After) {
+			err = s.undoStack.Push(tx, sheetIdByte, undo)
+			if err != nil {
+				return
+			}
+		}
+
+		// recalculation with the same result (e.g. refresh of external refs) is not a new version of the cell
+		if latest := s.history.Get(tx, sheetIdByte, cellCanonicalKeyByte, 1); len(latest) != 0 && !latest[0].Deleted &&
+			latest[0].CellId == cellId && latest[0].Value == value && latest[0].Result == cell.Result {
+			return nil
+		}
+
+		return s.history.Add(tx, sheetIdByte, cellCanonicalKeyByte, &contracts.CellVersion{
+			CellId:    cellId,
+			Value:     value,
+			Result:    cell.Result,
+			Source:    cause,
+			Timestamp: time.Now().UTC(),
+		})
 	})
+	// nothing is stored, so webhooks and event streams must not see the changes
+	if err != nil {
+		return
+	}
+	isUpdated = true
 
-	s.webhookDispatcher.Notify(sheetId, dependantsCellList)
+	s.webhookDispatcher.Notify(sheetId, changes, origin)
+	if s.changeEventStream != nil {
+		s.changeEventStream.Notify(sheetId, changes, origin)
+	}
 
 	return
 }
 
-func (s *SheetRepository) makeDependantsCellList(tx *bbolt.Tx, sheetId []byte, thisCell *contracts.Cell, dependants []string) []*contracts.Cell {
+// DeleteCell removes the cell with its dependencies and webhooks.
+// Returns urls of external cells which the cell was subscribed to
+func (s *SheetRepository) DeleteCell(sheetId string, cellId string) (externalRefs contracts.ExternalRefSubscriptions, err error) {
+	sheetId = s.GetCanonicalSheetId(sheetId)
+	sheetIdByte := []byte(sheetId)
+	cellCanonicalKey := s.canonicalizer.Canonicalize(cellId)
+	cellCanonicalKeyByte := []byte(cellCanonicalKey)
+
+	err = s.db.Update(func(tx contracts.StorageTx) (err error) {
+		bucket := tx.Bucket(sheetIdByte)
+		if bucket == nil {
+			return fmt.Errorf("%s: %w", sheetId, contracts.SheetNotFoundError)
+		}
+
+		undo := &undoEntry{
+			CanonicalKey:    cellCanonicalKey,
+			Before:          bytes.Clone(bucket.Get(cellCanonicalKeyByte)),
+			BeforeDependsOn: s.dependencyTree.GetDependsOn(tx, sheetIdByte, cellCanonicalKey),
+			AfterDependsOn:  []string{},
+		}
+		if undo.Before == nil {
+			return fmt.Errorf("%s: %w", cellId, contracts.CellNotFoundError)
+		}
+
+		err = bucket.Delete(cellCanonicalKeyByte)
+		if err != nil {
+			return
+		}
+
+		err = s.undoStack.Push(tx, sheetIdByte, undo)
+		if err != nil {
+			return
+		}
+
+		err = s.dependencyTree.SetDependsOn(tx, sheetIdByte, cellCanonicalKey, []string{})
+		if err != nil {
+			return
+		}
+
+		externalRefs = contracts.ExternalRefSubscriptions{
+			cellCanonicalKey: s.subscriptions.Get(tx, sheetIdByte, cellCanonicalKeyByte),
+		}
+		err = s.subscriptions.Set(tx, sheetIdByte, cellCanonicalKeyByte, []string{})
+		if err != nil {
+			return
+		}
+
+		return s.history.Add(tx, sheetIdByte, cellCanonicalKeyByte, &contracts.CellVersion{
+			CellId:    cellId,
+			Deleted:   true,
+			Source:    contracts.ChangeCauseDirectEdit,
+			Timestamp: time.Now().UTC(),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return externalRefs, s.webhookDispatcher.DeleteWebhooks(sheetId, cellCanonicalKey)
+}
+
+// DeleteSheet removes all cells of the sheet with settings and webhooks.
+// Returns urls of external cells which the cells were subscribed to
+func (s *SheetRepository) DeleteSheet(sheetId string) (externalRefs contracts.ExternalRefSubscriptions, err error) {
+	sheetId = s.GetCanonicalSheetId(sheetId)
+	sheetIdByte := []byte(sheetId)
+
+	err = s.db.Update(func(tx contracts.StorageTx) (err error) {
+		if tx.Bucket(sheetIdByte) == nil {
+			return fmt.Errorf("%s: %w", sheetId, contracts.SheetNotFoundError)
+		}
+
+		err = tx.DeleteBucket(sheetIdByte)
+		if err != nil {
+			return
+		}
+
+		err = s.dependencyTree.DeleteSheet(tx, sheetIdByte)
+		if err != nil {
+			return
+		}
+
+		err = s.settingsStorage.Delete(tx, sheetIdByte)
+		if err != nil {
+			return
+		}
+
+		err = s.history.DeleteSheet(tx, sheetIdByte)
+		if err != nil {
+			return
+		}
+
+		err = s.undoStack.DeleteSheet(tx, sheetIdByte)
+		if err != nil {
+			return
+		}
+
+		externalRefs, err = s.subscriptions.DeleteSheet(tx, sheetIdByte)
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return externalRefs, s.webhookDispatcher.DeleteSheetWebhooks(sheetId)
+}
+
+func (s *SheetRepository) Undo(sheetId string, steps int) ([]contracts.CellVersion, error) {
+	return s.applyUndoStack(sheetId, steps, contracts.ChangeCauseUndo)
+}
+
+func (s *SheetRepository) Redo(sheetId string, steps int) ([]contracts.CellVersion, error) {
+	return s.applyUndoStack(sheetId, steps, contracts.ChangeCauseRedo)
+}
+
+// applyUndoStack pops up to steps entries of undo (or redo) stack and writes the cells back as they were before
+// (or after) the writes. All steps are applied in single transaction, so concurrent writes of other cells are either
+// before or after them. Webhooks are notified about changed results when the transaction is committed
+func (s *SheetRepository) applyUndoStack(sheetId string, steps int, cause contracts.ChangeCause) (versions []contracts.CellVersion, err error) {
+	sheetId = s.GetCanonicalSheetId(sheetId)
+	sheetIdByte := []byte(sheetId)
+	pop, emptyError := s.undoStack.PopUndo, contracts.NothingToUndoError
+	if cause == contracts.ChangeCauseRedo {
+		pop, emptyError = s.undoStack.PopRedo, contracts.NothingToRedoError
+	}
+
+	var changesList [][]contracts.CellChange
+
+	err = s.db.Update(func(tx contracts.StorageTx) error {
+		versions = make([]contracts.CellVersion, 0, steps)
+		changesList = make([][]contracts.CellChange, 0, steps)
+		executor := s.getExecutor(tx, sheetIdByte)
+
+		for len(versions) < steps {
+			entry, err := pop(tx, sheetIdByte)
+			if err != nil {
+				return err
+			}
+			if entry == nil {
+				break
+			}
+
+			data, dependsOn := entry.Before, entry.BeforeDependsOn
+			if cause == contracts.ChangeCauseRedo {
+				data, dependsOn = entry.After, entry.AfterDependsOn
+			}
+
+			version, changes, err := s.restoreCell(tx, sheetIdByte, executor, entry.CanonicalKey, data, dependsOn, cause)
+			if err != nil {
+				return err
+			}
+			versions = append(versions, *version)
+			changesList = append(changesList, changes)
+		}
+
+		if len(versions) != 0 {
+			return nil
+		}
+		if tx.Bucket(sheetIdByte) == nil {
+			return fmt.Errorf("%s: %w", sheetId, contracts.SheetNotFoundError)
+		}
+		return emptyError
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, changes := range changesList {
+		s.webhookDispatcher.Notify(sheetId, changes, contracts.ChangeOrigin{})
+		if s.changeEventStream != nil {
+			s.changeEventStream.Notify(sheetId, changes, contracts.ChangeOrigin{})
+		}
+	}
+
+	return versions, nil
+}
+
+// restoreCell writes serialized data of the cell (nil removes the cell) with its dependencies and evaluates dependants.
+// Returns written version of the cell and changes of the cell and its dependants (the removed cell is not included)
+func (s *SheetRepository) restoreCell(
+	tx contracts.StorageTx, sheetId []byte, executor contracts.ExpressionExecutor,
+	canonicalKey string, data []byte, dependsOn []string, cause contracts.ChangeCause,
+) (*contracts.CellVersion, []contracts.CellChange, error) {
+	bucket, err := tx.CreateBucketIfNotExists(sheetId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cellId, value := canonicalKey, ""
+	if data != nil {
+		cellId, value, err = s.serializer.Unmarshal(data)
+	} else if current := bucket.Get([]byte(canonicalKey)); current != nil {
+		// id of the removed cell is taken from its current state
+		cellId, _, err = s.serializer.Unmarshal(current)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cell := &contracts.Cell{CanonicalKey: canonicalKey, Value: value, Result: value}
+	dependants := s.dependencyTree.GetDependants(tx, sheetId, canonicalKey)
+	cells := s.makeDependantsCellList(tx, sheetId, cell, dependants)
+	changes := s.makeCellChanges(tx, sheetId, executor, cells, cellId, cause)
+
+	err = s.dependencyTree.SetDependsOn(tx, sheetId, canonicalKey, dependsOn)
+	if err == nil && data == nil {
+		err = bucket.Delete([]byte(canonicalKey))
+		cells, changes = cells[1:], changes[1:]
+	} else if err == nil {
+		err = bucket.Put([]byte(canonicalKey), data)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// cells are evaluated after the write, so dependants do not see the removed cell.
+	// Restored cell is rejected as a write which can not be evaluated, while dependants of the removed cell
+	// keep error results (as after DeleteCell)
+	expressions := make(contracts.ExpressionsMap, len(cells))
+	for i := range cells {
+		expressions[cells[i].CanonicalKey] = &cells[i].Result
+	}
+	err = executor.MultiEvaluate(expressions, s.makeValuesGetter(tx, sheetId), data != nil)
+	if err != nil && data != nil {
+		return nil, nil, fmt.Errorf("%w: %w", contracts.UndoEvaluationError, err)
+	}
+
+	version := &contracts.CellVersion{
+		CellId:    cellId,
+		Value:     value,
+		Result:    cell.Result,
+		Deleted:   data == nil,
+		Source:    cause,
+		Timestamp: time.Now().UTC(),
+	}
+
+	return version, changes, s.history.Add(tx, sheetId, []byte(canonicalKey), version)
+}
+
+func (s *SheetRepository) makeDependantsCellList(tx contracts.StorageTx, sheetId []byte, thisCell *contracts.Cell, dependants []string) []*contracts.Cell {
 	values := s.getCellValues(tx, sheetId, dependants)
 
 	dependantsCellList := make([]*contracts.Cell, 0, len(dependants)+1)
 	dependantsCellList = append(dependantsCellList, thisCell)
 
 	for index, dependantCanonicalCellId := range dependants {
-		if values[index] != nil {
+		// with iterative calculation the cell can be a dependant of itself
+		if values[index] != nil && dependantCanonicalCellId != thisCell.CanonicalKey {
 			dependantsCellList = append(dependantsCellList, &contracts.Cell{
 				CanonicalKey: dependantCanonicalCellId,
 				Value:        *values[index],
@@ -133,6 +465,46 @@ func (s *SheetRepository) makeDependantsCellList(tx *bbolt.Tx, sheetId []byte, t
 	return dependantsCellList
 }
 
+// makeCellChanges reads previous state of the changed cell (the first one) and its dependants before the change is stored.
+// Changes refer to the cells, so they get new results when the cells are evaluated
+func (s *SheetRepository) makeCellChanges(
+	tx contracts.StorageTx, sheetId []byte, executor contracts.ExpressionExecutor,
+	cells []*contracts.Cell, cellId string, cause contracts.ChangeCause,
+) []contracts.CellChange {
+	changes := make([]contracts.CellChange, len(cells))
+	previousExpressions := contracts.ExpressionsMap{}
+	bucket := tx.Bucket(sheetId)
+
+	for i, cell := range cells {
+		changes[i] = contracts.CellChange{
+			Cell:     cell,
+			CellId:   cell.CanonicalKey,
+			Cause:    contracts.ChangeCauseDependency,
+			CausedBy: cellId,
+		}
+		if bucket == nil {
+			continue
+		}
+
+		if byteValue := bucket.Get([]byte(cell.CanonicalKey)); byteValue != nil {
+			if key, value, err := s.serializer.Unmarshal(byteValue); err == nil {
+				changes[i].CellId = key
+				changes[i].Previous = &contracts.Cell{CanonicalKey: cell.CanonicalKey, Value: value, Result: value}
+				previousExpressions[cell.CanonicalKey] = &changes[i].Previous.Result
+			}
+		}
+	}
+	changes[0].CellId = cellId
+	changes[0].Cause = cause
+	changes[0].CausedBy = ""
+
+	if len(previousExpressions) != 0 {
+		_ = executor.MultiEvaluate(previousExpressions, s.makeValuesGetter(tx, sheetId), false)
+	}
+
+	return changes
+}
+
 func (s *SheetRepository) GetCell(sheetId string, cellId string) (cell *contracts.Cell, err error) {
 	sheetId = s.GetCanonicalSheetId(sheetId)
 
@@ -142,7 +514,7 @@ func (s *SheetRepository) GetCell(sheetId string, cellId string) (cell *contract
 		CanonicalKey: s.canonicalizer.Canonicalize(cellId),
 	}
 	canonicalKey := []byte(cell.CanonicalKey)
-	err = s.db.View(func(tx *bbolt.Tx) error {
+	err = s.db.View(func(tx contracts.StorageTx) error {
 		bucket := tx.Bucket(sheetIdByte)
 		if bucket == nil {
 			return fmt.Errorf("%s: %w", sheetId, contracts.SheetNotFoundError)
@@ -159,7 +531,7 @@ func (s *SheetRepository) GetCell(sheetId string, cellId string) (cell *contract
 			return err
 		}
 
-		cell.Result, err = s.executor.Evaluate(cell.Value, s.makeValuesGetter(tx, sheetIdByte))
+		cell.Result, err = s.getExecutor(tx, sheetIdByte).Evaluate(cell.Value, s.makeValuesGetter(tx, sheetIdByte))
 
 		return err
 	})
@@ -172,12 +544,14 @@ func (s *SheetRepository) GetCellList(sheetId string) (*contracts.CellList, erro
 
 	cellList := contracts.CellList{}
 	expressions := contracts.ExpressionsMap{}
+	executor := s.executor
 
-	err := s.db.View(func(tx *bbolt.Tx) error {
+	err := s.db.View(func(tx contracts.StorageTx) error {
 		bucket := tx.Bucket([]byte(sheetId))
 		if bucket == nil {
 			return fmt.Errorf("%s: %w", sheetId, contracts.SheetNotFoundError)
 		}
+		executor = s.getExecutor(tx, []byte(sheetId))
 
 		c := bucket.Cursor()
 		for k, v := c.First(); k != nil; k, v = c.Next() {
@@ -196,19 +570,165 @@ func (s *SheetRepository) GetCellList(sheetId string) (*contracts.CellList, erro
 	})
 
 	if err == nil {
-		err = s.executor.MultiEvaluate(expressions, nil, false)
+		err = executor.MultiEvaluate(expressions, nil, false)
 	}
 
 	return &cellList, err
 }
 
-func (s *SheetRepository) makeValuesGetter(tx *bbolt.Tx, sheetId []byte) contracts.CellValuesGetter {
+// ForEachCell calls fn for each cell of all sheets with its stored id. Values are collected within the transaction
+// and fn is called after it, so fn may use the repository
+func (s *SheetRepository) ForEachCell(fn func(sheetId string, cellId string, value string)) error {
+	type storedCell struct {
+		sheetId string
+		cellId  string
+		value   string
+	}
+	cells := make([]storedCell, 0)
+
+	err := s.db.View(func(tx contracts.StorageTx) error {
+		return tx.ForEachBucket(func(name []byte) error {
+			if bytes.HasPrefix(name, []byte("__")) {
+				return nil
+			}
+
+			return tx.Bucket(name).ForEach(func(key []byte, value []byte) error {
+				cellId, cellValue, err := s.serializer.Unmarshal(value)
+				if err == nil {
+					cells = append(cells, storedCell{sheetId: string(name), cellId: cellId, value: cellValue})
+				}
+				return nil
+			})
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, cell := range cells {
+		fn(cell.sheetId, cell.cellId, cell.value)
+	}
+
+	return nil
+}
+
+// GetCellHistory returns up to limit recent versions of the cell, newest first
+func (s *SheetRepository) GetCellHistory(sheetId string, cellId string, limit int) (versions []contracts.CellVersion, err error) {
+	sheetId = s.GetCanonicalSheetId(sheetId)
+	sheetIdByte := []byte(sheetId)
+	cellIdByte := []byte(s.canonicalizer.Canonicalize(cellId))
+
+	err = s.db.View(func(tx contracts.StorageTx) error {
+		versions = s.history.Get(tx, sheetIdByte, cellIdByte, limit)
+		if len(versions) != 0 {
+			return nil
+		}
+
+		if tx.Bucket(sheetIdByte) == nil {
+			return fmt.Errorf("%s: %w", sheetId, contracts.SheetNotFoundError)
+		}
+		return fmt.Errorf("%s: %w", cellId, contracts.CellNotFoundError)
+	})
+
+	return
+}
+
+// GetCellListAt evaluates cells of the sheet as they were at the moment (the latest versions written before it).
+// Current settings of the sheet are used
+func (s *SheetRepository) GetCellListAt(sheetId string, at time.Time) (*contracts.CellList, error) {
+	sheetId = s.GetCanonicalSheetId(sheetId)
+	sheetIdByte := []byte(sheetId)
+
+	cellList := contracts.CellList{}
+	expressions := contracts.ExpressionsMap{}
+	executor := s.executor
+
+	err := s.db.View(func(tx contracts.StorageTx) error {
+		if tx.Bucket(sheetIdByte) == nil {
+			return fmt.Errorf("%s: %w", sheetId, contracts.SheetNotFoundError)
+		}
+		executor = s.getExecutor(tx, sheetIdByte)
+
+		for canonicalCellId, version := range s.history.GetAt(tx, sheetIdByte, at) {
+			cellList[version.CellId] = &contracts.Cell{
+				CanonicalKey: canonicalCellId,
+				Value:        version.Value,
+				Result:       version.Value,
+			}
+			expressions[canonicalCellId] = &cellList[version.CellId].Result
+		}
+		return nil
+	})
+
+	if err == nil {
+		err = executor.MultiEvaluate(expressions, nil, false)
+	}
+
+	return &cellList, err
+}
+
+func (s *SheetRepository) GetSettings(sheetId string) (settings *contracts.SheetSettings, err error) {
+	sheetIdByte := []byte(s.GetCanonicalSheetId(sheetId))
+	settings = &contracts.SheetSettings{}
+
+	err = s.db.View(func(tx contracts.StorageTx) error {
+		*settings = s.settingsStorage.Get(tx, sheetIdByte)
+		return nil
+	})
+
+	return
+}
+
+func (s *SheetRepository) SetSettings(sheetId string, settings contracts.SheetSettings) (*contracts.SheetSettings, error) {
+	sheetIdByte := []byte(s.GetCanonicalSheetId(sheetId))
+
+	err := s.db.Update(func(tx contracts.StorageTx) error {
+		return s.settingsStorage.Set(tx, sheetIdByte, settings)
+	})
+
+	return &settings, err
+}
+
+func (s *SheetRepository) GetExternalRefSubscriptions(sheetId string, cellId string) (urls []string, err error) {
+	sheetIdByte := []byte(s.GetCanonicalSheetId(sheetId))
+	cellIdByte := []byte(s.canonicalizer.Canonicalize(cellId))
+
+	err = s.db.View(func(tx contracts.StorageTx) error {
+		urls = s.subscriptions.Get(tx, sheetIdByte, cellIdByte)
+		return nil
+	})
+
+	return
+}
+
+func (s *SheetRepository) SetExternalRefSubscriptions(sheetId string, cellId string, urls []string) (previousUrls []string, err error) {
+	sheetIdByte := []byte(s.GetCanonicalSheetId(sheetId))
+	cellIdByte := []byte(s.canonicalizer.Canonicalize(cellId))
+
+	err = s.db.Update(func(tx contracts.StorageTx) error {
+		previousUrls = s.subscriptions.Get(tx, sheetIdByte, cellIdByte)
+		return s.subscriptions.Set(tx, sheetIdByte, cellIdByte, urls)
+	})
+
+	return
+}
+
+// getExecutor returns executor configured according to sheet settings
+func (s *SheetRepository) getExecutor(tx contracts.StorageTx, sheetId []byte) contracts.ExpressionExecutor {
+	if settings := s.settingsStorage.Get(tx, sheetId); settings.Iterative {
+		return s.executor.WithIteration(settings.MaxIterations, settings.Epsilon)
+	}
+
+	return s.executor
+}
+
+func (s *SheetRepository) makeValuesGetter(tx contracts.StorageTx, sheetId []byte) contracts.CellValuesGetter {
 	return func(cellIds []string) []*string {
 		return s.getCellValues(tx, sheetId, cellIds)
 	}
 }
 
-func (s *SheetRepository) getCellValues(tx *bbolt.Tx, sheetId []byte, canonicalCellIds []string) []*string {
+func (s *SheetRepository) getCellValues(tx contracts.StorageTx, sheetId []byte, canonicalCellIds []string) []*string {
 	values := make([]*string, len(canonicalCellIds))
 
 	bucket := tx.Bucket(sheetId)
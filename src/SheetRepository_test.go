@@ -5,23 +5,30 @@ import (
 	"devChallengeExcel/mocks"
 	"errors"
 	"fmt"
+	json "github.com/bytedance/sonic"
+	"github.com/expr-lang/expr"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
-	"go.etcd.io/bbolt"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestSheet_SetCell(t *testing.T) {
 	expectedCellsMatcher := func(expectedCells ...contracts.Cell) interface{} {
-		return mock.MatchedBy(func(cells []*contracts.Cell) bool {
-			if len(cells) != len(expectedCells) {
-				fmt.Fprintf(os.Stderr, "len(cells): %d, len(expectedCells): %d\n", len(cells), len(expectedCells))
+		return mock.MatchedBy(func(changes []contracts.CellChange) bool {
+			if len(changes) != len(expectedCells) {
+				fmt.Fprintf(os.Stderr, "len(changes): %d, len(expectedCells): %d\n", len(changes), len(expectedCells))
 				return false
 			}
 
-			for i, cell := range cells {
+			for i, change := range changes {
+				cell := change.Cell
 				if cell == nil {
 					fmt.Fprintf(os.Stderr, "cell[%d] is nil\n", i)
 					return false
@@ -91,7 +98,7 @@ func TestSheet_SetCell(t *testing.T) {
 				Value:        value,
 				Result:       "result",
 			}
-			webhookDispatcher.On("Notify", sheetId, expectedCellsMatcher(expectCell)).Return()
+			webhookDispatcher.On("Notify", sheetId, expectedCellsMatcher(expectCell), contracts.ChangeOrigin{}).Return()
 
 			cell, err, _ := sheetRepository.SetCell(sheetId, cell1, value, true)
 
@@ -150,7 +157,7 @@ func TestSheet_SetCell(t *testing.T) {
 			CanonicalKey: canonical2,
 			Value:        value2,
 			Result:       "result2",
-		})).Return().Once()
+		}), contracts.ChangeOrigin{}).Return().Once()
 
 		cell, err, _ := sheetRepository.SetCell(sheetId, cell2, value2, true)
 		assert.NotNil(t, cell)
@@ -171,6 +178,8 @@ func TestSheet_SetCell(t *testing.T) {
 				return nil
 			})
 		executor.On("ExtractDependingOnList", value3).Return([]string{""})
+		// previous result of the stored dependant
+		executor.On("MultiEvaluate", contracts.ExpressionsMap{canonical2: &value2}, mock.Anything, false).Return(nil).Once()
 
 		webhookDispatcher = mocks.NewWebhookDispatcher(t)
 		sheetRepository.webhookDispatcher = webhookDispatcher
@@ -186,7 +195,7 @@ func TestSheet_SetCell(t *testing.T) {
 			Result:       "cell3_result",
 		}
 
-		webhookDispatcher.On("Notify", sheetId, expectedCellsMatcher(expectedCell3, expectedCell2)).Return().Once()
+		webhookDispatcher.On("Notify", sheetId, expectedCellsMatcher(expectedCell3, expectedCell2), contracts.ChangeOrigin{}).Return().Once()
 
 		cell, err, _ = sheetRepository.SetCell(sheetId, cell3, value3, true)
 
@@ -215,10 +224,11 @@ func TestSheet_SetCell(t *testing.T) {
 
 		executor.On("ExtractDependingOnList", value).Return([]string{}).Maybe()
 
-		cell, err, _ := sheet.SetCell(sheetId, cell1, value, true)
+		cell, err, isUpdated := sheet.SetCell(sheetId, cell1, value, true)
 
 		assert.NotNil(t, cell)
 		assert.Error(t, err)
+		assert.False(t, isUpdated)
 
 		assert.Equal(t, value, cell.Value)
 		assert.Equal(t, value, cell.Result)
@@ -238,16 +248,13 @@ func TestSheet_SetCell(t *testing.T) {
 			})
 		executor.On("ExtractDependingOnList", "value").Return([]string{}).Maybe()
 
+		// nothing is stored, so there is no notification
 		webhookDispatcher := mocks.NewWebhookDispatcher(t)
-		webhookDispatcher.On("Notify", sheetId, expectedCellsMatcher(contracts.Cell{
-			CanonicalKey: canonical1,
-			Value:        value,
-			Result:       "result",
-		})).Return().Once()
 
 		tree := mocks.NewCellDependencyTree(t)
 		tree.On("SetDependsOn", mock.Anything, []byte(sheetId), canonical1, []string{}).Return(expectedErr)
 		tree.On("GetDependants", mock.Anything, []byte(sheetId), canonical1).Return([]string{}).Maybe()
+		tree.On("GetDependsOn", mock.Anything, []byte(sheetId), canonical1).Return([]string{})
 
 		sheet := &SheetRepository{
 			db:                isolatedDb,
@@ -258,10 +265,11 @@ func TestSheet_SetCell(t *testing.T) {
 			webhookDispatcher: webhookDispatcher,
 		}
 
-		cell, err, _ := sheet.SetCell(sheetId, cell1, value, true)
+		cell, err, isUpdated := sheet.SetCell(sheetId, cell1, value, true)
 
 		assert.NotNil(t, cell)
 		assert.Error(t, err)
+		assert.False(t, isUpdated)
 		assert.Equal(t, expectedErr, err)
 
 		assert.Equal(t, value, cell.Value)
@@ -272,11 +280,11 @@ func TestSheet_SetCell(t *testing.T) {
 		dbWithError, closeWithError := _createTmpDb()
 		defer closeWithError()
 
-		_ = dbWithError.Update(func(tx *bbolt.Tx) error {
-			bucket, err := tx.CreateBucket([]byte(sheetId))
+		_ = dbWithError.Update(func(tx contracts.StorageTx) error {
+			bucket, err := tx.CreateBucketIfNotExists([]byte(sheetId))
 			assert.NoError(t, err)
 
-			_, err = bucket.CreateBucket([]byte(canonical1))
+			_, err = bucket.CreateBucketIfNotExists([]byte(canonical1))
 			assert.NoError(t, err)
 
 			return nil
@@ -290,16 +298,13 @@ func TestSheet_SetCell(t *testing.T) {
 			})
 		executor.On("ExtractDependingOnList", "value").Return([]string{})
 
+		// nothing is stored, so there is no notification
 		webhookDispatcher := mocks.NewWebhookDispatcher(t)
-		webhookDispatcher.On("Notify", sheetId, expectedCellsMatcher(contracts.Cell{
-			CanonicalKey: canonical1,
-			Value:        value,
-			Result:       "result",
-		})).Return().Once()
 
 		tree := mocks.NewCellDependencyTree(t)
 		tree.On("SetDependsOn", mock.Anything, []byte(sheetId), canonical1, []string{}).Return(nil)
 		tree.On("GetDependants", mock.Anything, []byte(sheetId), canonical1).Return([]string{})
+		tree.On("GetDependsOn", mock.Anything, []byte(sheetId), canonical1).Return([]string{})
 
 		sheet := &SheetRepository{
 			db:                dbWithError,
@@ -310,10 +315,11 @@ func TestSheet_SetCell(t *testing.T) {
 			webhookDispatcher: webhookDispatcher,
 		}
 
-		cell, err, _ := sheet.SetCell(sheetId, cell1, value, true)
+		cell, err, isUpdated := sheet.SetCell(sheetId, cell1, value, true)
 
 		assert.NotNil(t, cell)
 		assert.Error(t, err)
+		assert.False(t, isUpdated)
 		assert.Contains(t, err.Error(), "incompatible value")
 
 		assert.Equal(t, value, cell.Value)
@@ -336,14 +342,9 @@ func TestSheet_SetCell(t *testing.T) {
 		defer dbClose()
 
 		executor := mocks.NewExpressionExecutor(t)
+		// nothing is stored, so there is no notification
 		webhookDispatcher := mocks.NewWebhookDispatcher(t)
 
-		webhookDispatcher.On("Notify", "", expectedCellsMatcher(contracts.Cell{
-			CanonicalKey: canonical1,
-			Value:        value,
-			Result:       "result",
-		})).Return().Once()
-
 		sheet := &SheetRepository{
 			db:                db,
 			executor:          executor,
@@ -361,10 +362,11 @@ func TestSheet_SetCell(t *testing.T) {
 
 		executor.On("ExtractDependingOnList", value).Return([]string{}).Maybe()
 
-		cell, err, _ := sheet.SetCell("", "cell1", "value", true)
+		cell, err, isUpdated := sheet.SetCell("", "cell1", "value", true)
 
 		assert.NotNil(t, cell)
 		assert.Error(t, err)
+		assert.False(t, isUpdated)
 
 		assert.EqualError(t, err, "bucket name required")
 	})
@@ -475,7 +477,7 @@ func TestSheet_GetCell(t *testing.T) {
 
 		errorCell := NewCanonicalizer().Canonicalize("errorCell")
 
-		err := db.Update(func(tx *bbolt.Tx) error {
+		err := db.Update(func(tx contracts.StorageTx) error {
 			bucket, err := tx.CreateBucketIfNotExists([]byte(errorSheet))
 			assert.NoError(t, err)
 
@@ -603,7 +605,846 @@ func TestSheet_GetCellList(t *testing.T) {
 	})
 }
 
-func _prepareSheet(t *testing.T, sheetId string) *bbolt.DB {
+func TestSheet_Settings(t *testing.T) {
+	db, dbClose := _createTmpDb()
+	defer dbClose()
+
+	sheet := &SheetRepository{db: db}
+
+	settings, err := sheet.GetSettings("Sheet1")
+	assert.NoError(t, err)
+	assert.Equal(t, contracts.NewSheetSettings(), *settings)
+
+	expected := contracts.SheetSettings{
+		Iterative:     true,
+		MaxIterations: 10,
+		Epsilon:       0.5,
+	}
+	settings, err = sheet.SetSettings("SHEET1", expected)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, *settings)
+
+	settings, err = sheet.GetSettings("sheet1")
+	assert.NoError(t, err)
+	assert.Equal(t, expected, *settings)
+
+	_, err = sheet.SetSettings("", expected)
+	assert.Error(t, err)
+}
+
+func TestSheet_ExternalRefSubscriptions(t *testing.T) {
+	db, dbClose := _createTmpDb()
+	defer dbClose()
+
+	sheet := &SheetRepository{db: db, canonicalizer: NewCanonicalizer()}
+
+	urls, err := sheet.GetExternalRefSubscriptions("Sheet1", "A1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{}, urls)
+
+	previousUrls, err := sheet.SetExternalRefSubscriptions("Sheet1", "A1", []string{"http://remote/a1", "http://remote/a2"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{}, previousUrls)
+
+	previousUrls, err = sheet.SetExternalRefSubscriptions("SHEET1", "a1", []string{"http://remote/a3"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"http://remote/a1", "http://remote/a2"}, previousUrls)
+
+	urls, err = sheet.GetExternalRefSubscriptions("sheet1", "a1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"http://remote/a3"}, urls)
+
+	// other cell
+	urls, err = sheet.GetExternalRefSubscriptions("sheet1", "a2")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{}, urls)
+
+	previousUrls, err = sheet.SetExternalRefSubscriptions("sheet1", "a1", []string{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"http://remote/a3"}, previousUrls)
+
+	urls, err = sheet.GetExternalRefSubscriptions("sheet1", "a1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{}, urls)
+
+	_, err = sheet.SetExternalRefSubscriptions("", "a1", []string{"http://remote/a1"})
+	assert.Error(t, err)
+}
+
+func TestSheet_ForEachCell(t *testing.T) {
+	db := _prepareSheet(t, "Sheet1")
+	defer db.Close()
+
+	sheet := &SheetRepository{
+		db:            db,
+		canonicalizer: NewCanonicalizer(),
+		serializer:    NewCellBinarySerializer(),
+	}
+	_, err := sheet.SetSettings("sheet1", contracts.SheetSettings{})
+	assert.NoError(t, err)
+
+	cells := map[string]string{}
+	err = sheet.ForEachCell(func(sheetId string, cellId string, value string) {
+		cells[sheetId+"/"+cellId] = value
+	})
+	assert.NoError(t, err)
+	// internal buckets (dependency tree, settings) are skipped
+	assert.Equal(t, map[string]string{"sheet1/cell1": "value1", "sheet1/cell2": "value2"}, cells)
+}
+
+func TestSheet_IterativeCalculation(t *testing.T) {
+	db, dbClose := _createTmpDb()
+	defer dbClose()
+
+	webhookDispatcher := mocks.NewWebhookDispatcher(t)
+	webhookDispatcher.On("Notify", mock.Anything, mock.Anything, mock.Anything).Return().Maybe()
+
+	sheet := &SheetRepository{
+		db:                db,
+		executor:          NewExpressionExecutor(NewCanonicalizer()),
+		canonicalizer:     NewCanonicalizer(),
+		serializer:        NewCellBinarySerializer(),
+		dependencyTree:    &CellDependencyTree{},
+		webhookDispatcher: webhookDispatcher,
+	}
+
+	_, err, _ := sheet.SetCell("sheet1", "interest", "0", true)
+	assert.NoError(t, err)
+
+	_, err, _ = sheet.SetCell("sheet1", "balance", "=100 + interest", true)
+	assert.NoError(t, err)
+
+	_, err, _ = sheet.SetCell("sheet1", "interest", "=balance * 0.1", true)
+	assert.ErrorIs(t, err, CircularReferenceError)
+
+	_, err = sheet.SetSettings("sheet1", contracts.SheetSettings{Iterative: true, MaxIterations: 100, Epsilon: 0.001})
+	assert.NoError(t, err)
+
+	cell, err, isUpdated := sheet.SetCell("sheet1", "interest", "=balance * 0.1", true)
+	assert.NoError(t, err)
+	assert.True(t, isUpdated)
+	assert.True(t, strings.HasPrefix(cell.Result, "11.11"), cell.Result)
+
+	cell, err = sheet.GetCell("sheet1", "balance")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(cell.Result, "111.1"), cell.Result)
+
+	cellList, err := sheet.GetCellList("sheet1")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix((*cellList)["balance"].Result, "111.1"))
+	assert.True(t, strings.HasPrefix((*cellList)["interest"].Result, "11.11"))
+
+	_, err = sheet.SetSettings("sheet1", contracts.SheetSettings{Iterative: true, MaxIterations: 2, Epsilon: 0.001})
+	assert.NoError(t, err)
+
+	cell, err = sheet.GetCell("sheet1", "balance")
+	assert.ErrorIs(t, err, IterationNotConvergedError)
+}
+
+func TestSheet_RecalculateCell(t *testing.T) {
+	db, dbClose := _createTmpDb()
+	defer dbClose()
+
+	var externalErr error
+	externalFunction := expr.Function(ExternalRefFunctionName, func(args ...any) (any, error) {
+		if externalErr != nil {
+			return nil, externalErr
+		}
+		return 5, nil
+	})
+
+	origin := contracts.ChangeOrigin{Trace: []string{"remote/sheet1/a1", "local/sheet1/b1"}}
+	webhookDispatcher := mocks.NewWebhookDispatcher(t)
+	webhookDispatcher.On("Notify", "sheet1", mock.Anything, contracts.ChangeOrigin{}).Return().Twice()
+
+	sheet := &SheetRepository{
+		db:                db,
+		executor:          NewExpressionExecutor(NewCanonicalizer(), externalFunction),
+		canonicalizer:     NewCanonicalizer(),
+		serializer:        NewCellBinarySerializer(),
+		dependencyTree:    &CellDependencyTree{},
+		webhookDispatcher: webhookDispatcher,
+	}
+
+	_, err := sheet.RecalculateCell("sheet1", "b1", origin)
+	assert.ErrorIs(t, err, contracts.SheetNotFoundError)
+
+	_, err, _ = sheet.SetCell("sheet1", "B1", `=external_ref("http://remote/api/v1/sheet1/a1")`, true)
+	assert.NoError(t, err)
+	_, err, _ = sheet.SetCell("sheet1", "b2", "=B1 * 2", true)
+	assert.NoError(t, err)
+
+	_, err = sheet.RecalculateCell("sheet1", "b3", origin)
+	assert.ErrorIs(t, err, contracts.CellNotFoundError)
+
+	// dependants are notified with origin of the change, the cell keeps its stored id
+	webhookDispatcher.On("Notify", "sheet1", mock.MatchedBy(func(changes []contracts.CellChange) bool {
+		return len(changes) == 2 && *changes[0].Cell == contracts.Cell{CanonicalKey: "b1", Value: `=external_ref("http://remote/api/v1/sheet1/a1")`, Result: "5"} &&
+			changes[0].Cause == contracts.ChangeCauseExternalRef && changes[0].CellId == "B1" &&
+			*changes[1].Cell == contracts.Cell{CanonicalKey: "b2", Value: "=B1 * 2", Result: "10"} &&
+			changes[1].Cause == contracts.ChangeCauseDependency && changes[1].CausedBy == "B1"
+	}), origin).Return().Once()
+
+	cell, err := sheet.RecalculateCell("SHEET1", "b1", origin)
+	assert.NoError(t, err)
+	assert.Equal(t, "5", cell.Result)
+	cells, err := sheet.GetCellList("sheet1")
+	assert.NoError(t, err)
+	assert.Contains(t, *cells, "B1")
+
+	// the result is unchanged, so there is no new version
+	versions, err := sheet.GetCellHistory("sheet1", "b1", 10)
+	assert.NoError(t, err)
+	assert.Len(t, versions, 1)
+
+	// circular chain is not propagated
+	externalErr = fmt.Errorf("%w: test", ExternalRefCircularError)
+	_, err = sheet.RecalculateCell("sheet1", "b1", origin)
+	assert.ErrorIs(t, err, ExternalRefCircularError)
+
+	cell, err = sheet.GetCell("sheet1", "b1")
+	assert.ErrorIs(t, err, ExternalRefCircularError)
+	assert.Equal(t, CircularResult, cell.Result)
+}
+
+func TestSheet_SetCell_Changes(t *testing.T) {
+	db, dbClose := _createTmpDb()
+	defer dbClose()
+
+	var changes []contracts.CellChange
+	webhookDispatcher := mocks.NewWebhookDispatcher(t)
+	webhookDispatcher.On("Notify", "sheet1", mock.Anything, contracts.ChangeOrigin{}).
+		Run(func(args mock.Arguments) {
+			changes = args.Get(1).([]contracts.CellChange)
+		}).Return()
+
+	sheet := &SheetRepository{
+		db:                db,
+		executor:          NewExpressionExecutor(NewCanonicalizer()),
+		canonicalizer:     NewCanonicalizer(),
+		serializer:        NewCellBinarySerializer(),
+		dependencyTree:    &CellDependencyTree{},
+		webhookDispatcher: webhookDispatcher,
+		changeEventStream: NewChangeEventStream(10),
+	}
+	events := sheet.changeEventStream.Subscribe("sheet1", []string{"total"}, 0)
+
+	_, err, _ := sheet.SetCell("Sheet1", "Price", "10", true)
+	assert.NoError(t, err)
+	assert.Equal(t, []contracts.CellChange{{
+		Cell:   &contracts.Cell{CanonicalKey: "price", Value: "10", Result: "10"},
+		CellId: "Price",
+		Cause:  contracts.ChangeCauseDirectEdit,
+	}}, changes)
+
+	_, err, _ = sheet.SetCell("sheet1", "Total", "=price * 2", true)
+	assert.NoError(t, err)
+
+	_, err, _ = sheet.SetCell("sheet1", "PRICE", "15", true)
+	assert.NoError(t, err)
+	assert.Equal(t, []contracts.CellChange{
+		{
+			Cell:     &contracts.Cell{CanonicalKey: "price", Value: "15", Result: "15"},
+			CellId:   "PRICE",
+			Previous: &contracts.Cell{CanonicalKey: "price", Value: "10", Result: "10"},
+			Cause:    contracts.ChangeCauseDirectEdit,
+		},
+		{
+			Cell:     &contracts.Cell{CanonicalKey: "total", Value: "=price * 2", Result: "30"},
+			CellId:   "Total",
+			Previous: &contracts.Cell{CanonicalKey: "total", Value: "=price * 2", Result: "20"},
+			Cause:    contracts.ChangeCauseDependency,
+			CausedBy: "PRICE",
+		},
+	}, changes)
+
+	// the same changes are published to the event stream
+	assert.Equal(t, "20", (<-events.Events).Current.Result)
+	event := <-events.Events
+	assert.Equal(t, uint64(4), event.Sequence)
+	assert.Equal(t, contracts.ChangeCauseDependency, event.Cause)
+	assert.Equal(t, "30", event.Current.Result)
+}
+
+func TestSheet_NotifiesOnlyChangedResults(t *testing.T) {
+	received := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		event := contracts.WebhookEvent{}
+		body, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &event)
+		received <- r.URL.Path + " " + event.CellId + "=" + event.Current.Result
+	}))
+	defer server.Close()
+
+	db, dbClose := _createTmpDb()
+	defer dbClose()
+
+	webhookDispatcher := NewWebhookDispatcher(db, _makeLoopbackEgressPolicy(t), NewWebhookSigner("", time.Minute), nil, _makeTestWebhookRetryConfig(), DefaultWebhookDeliveryLogSize)
+	webhookDispatcher.Start()
+	defer webhookDispatcher.Close()
+
+	sheet := &SheetRepository{
+		db:                db,
+		executor:          NewExpressionExecutor(NewCanonicalizer()),
+		canonicalizer:     NewCanonicalizer(),
+		serializer:        NewCellBinarySerializer(),
+		dependencyTree:    &CellDependencyTree{},
+		webhookDispatcher: webhookDispatcher,
+	}
+
+	_, err, _ := sheet.SetCell("sheet1", "A1", "1", true)
+	assert.NoError(t, err)
+	_, err, _ = sheet.SetCell("sheet1", "A2", "=max(A1, 100)", true)
+	assert.NoError(t, err)
+
+	_, err = webhookDispatcher.Subscribe("sheet1", "a2", server.URL+"/changed", "", contracts.WebhookOptions{})
+	assert.NoError(t, err)
+	_, err = webhookDispatcher.Subscribe("sheet1", "a2", server.URL+"/always", "", contracts.WebhookOptions{AlwaysNotify: true})
+	assert.NoError(t, err)
+
+	// result of A2 is still 100
+	_, err, _ = sheet.SetCell("sheet1", "A1", "2", true)
+	assert.NoError(t, err)
+	assert.Equal(t, "/always A2=100", <-received)
+
+	_, err, _ = sheet.SetCell("sheet1", "A1", "200", true)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"/changed A2=200", "/always A2=200"}, []string{<-received, <-received})
+
+	assert.Never(t, func() bool {
+		return len(received) != 0
+	}, time.Millisecond*50, time.Millisecond*10)
+}
+
+func TestSheet_Delete(t *testing.T) {
+	db, dbClose := _createTmpDb()
+	defer dbClose()
+
+	webhookDispatcher := NewWebhookDispatcher(db, nil, nil, nil, WebhookRetryConfig{}, 0)
+	sheet := &SheetRepository{
+		db:                db,
+		executor:          NewExpressionExecutor(NewCanonicalizer()),
+		canonicalizer:     NewCanonicalizer(),
+		serializer:        NewCellBinarySerializer(),
+		dependencyTree:    &CellDependencyTree{},
+		webhookDispatcher: webhookDispatcher,
+	}
+
+	for _, sheetId := range []string{"sheet1", "sheet2"} {
+		_, err, _ := sheet.SetCell(sheetId, "a1", "1", true)
+		assert.NoError(t, err)
+		_, err, _ = sheet.SetCell(sheetId, "a2", "=A1 + 1", true)
+		assert.NoError(t, err)
+		_, err = sheet.SetExternalRefSubscriptions(sheetId, "a1", []string{"http://remote/b1"})
+		assert.NoError(t, err)
+		_, err = sheet.SetExternalRefSubscriptions(sheetId, "a2", []string{"http://remote/b1", "http://remote/b2"})
+		assert.NoError(t, err)
+		_, err = sheet.SetSettings(sheetId, contracts.SheetSettings{Iterative: true, MaxIterations: 10, Epsilon: 0.1})
+		assert.NoError(t, err)
+		_, err = webhookDispatcher.Subscribe(sheetId, "a1", "http://remote/webhook1", "", contracts.WebhookOptions{})
+		assert.NoError(t, err)
+		_, err = webhookDispatcher.Subscribe(sheetId, "a2", "http://remote/webhook2", "", contracts.WebhookOptions{})
+		assert.NoError(t, err)
+	}
+
+	t.Run("cell", func(t *testing.T) {
+		externalRefs, err := sheet.DeleteCell("SHEET1", "A1")
+		assert.NoError(t, err)
+		assert.Equal(t, contracts.ExternalRefSubscriptions{"a1": {"http://remote/b1"}}, externalRefs)
+
+		_, err = sheet.GetCell("sheet1", "a1")
+		assert.ErrorIs(t, err, contracts.CellNotFoundError)
+		assert.Empty(t, webhookDispatcher.GetSubscriptions("sheet1", "a1"))
+		assert.Len(t, webhookDispatcher.GetSubscriptions("sheet1", "a2"), 1)
+
+		urls, err := sheet.GetExternalRefSubscriptions("sheet1", "a1")
+		assert.NoError(t, err)
+		assert.Empty(t, urls)
+
+		_, err = sheet.DeleteCell("sheet1", "a1")
+		assert.ErrorIs(t, err, contracts.CellNotFoundError)
+		_, err = sheet.DeleteCell("unknown", "a1")
+		assert.ErrorIs(t, err, contracts.SheetNotFoundError)
+	})
+
+	t.Run("sheet", func(t *testing.T) {
+		externalRefs, err := sheet.DeleteSheet("Sheet2")
+		assert.NoError(t, err)
+		assert.Equal(t, contracts.ExternalRefSubscriptions{
+			"a1": {"http://remote/b1"},
+			"a2": {"http://remote/b1", "http://remote/b2"},
+		}, externalRefs)
+
+		_, err = sheet.GetCellList("sheet2")
+		assert.ErrorIs(t, err, contracts.SheetNotFoundError)
+		assert.Empty(t, webhookDispatcher.GetSubscriptions("sheet2", "a2"))
+
+		settings, err := sheet.GetSettings("sheet2")
+		assert.NoError(t, err)
+		assert.Equal(t, contracts.NewSheetSettings(), *settings)
+
+		// other sheet is untouched
+		cell, _ := sheet.GetCell("sheet1", "a2")
+		assert.Equal(t, "=A1 + 1", cell.Value)
+
+		_, err = sheet.DeleteSheet("sheet2")
+		assert.ErrorIs(t, err, contracts.SheetNotFoundError)
+
+		// new sheet with the same id starts from scratch
+		_, err, _ = sheet.SetCell("sheet2", "a2", "5", true)
+		assert.NoError(t, err)
+		urls, err := sheet.GetExternalRefSubscriptions("sheet2", "a2")
+		assert.NoError(t, err)
+		assert.Empty(t, urls)
+	})
+
+	// webhooks of deleted cells are not restored
+	restored := NewWebhookDispatcher(db, nil, nil, nil, WebhookRetryConfig{}, 0)
+	assert.NoError(t, restored.Load())
+	assert.Empty(t, restored.GetSubscriptions("sheet1", "a1"))
+	assert.Equal(t, webhookDispatcher.GetSubscriptions("sheet1", "a2"), restored.GetSubscriptions("sheet1", "a2"))
+	assert.Empty(t, restored.GetSubscriptions("sheet2", "a2"))
+}
+
+func TestSheet_CellHistory(t *testing.T) {
+	db, dbClose := _createTmpDb()
+	defer dbClose()
+
+	webhookDispatcher := mocks.NewWebhookDispatcher(t)
+	webhookDispatcher.On("Notify", mock.Anything, mock.Anything, mock.Anything).Return().Maybe()
+	webhookDispatcher.On("DeleteWebhooks", mock.Anything, mock.Anything).Return(nil).Maybe()
+
+	sheet := NewSheetRepository(
+		db, NewExpressionExecutor(NewCanonicalizer()), NewCellBinarySerializer(), NewCanonicalizer(),
+		webhookDispatcher, nil, CellHistoryConfig{MaxVersions: 3}, 0,
+	)
+
+	_, err, _ := sheet.SetCell("sheet1", "A1", "1", true)
+	assert.NoError(t, err)
+	_, err, _ = sheet.SetCell("sheet1", "A2", "=A1 + 1", true)
+	assert.NoError(t, err)
+	_, err, _ = sheet.SetCell("sheet1", "a1", "5", true)
+	assert.NoError(t, err)
+	_, err = sheet.RecalculateCell("sheet1", "A1", contracts.ChangeOrigin{})
+	assert.NoError(t, err)
+	_, err, _ = sheet.SetCell("sheet1", "a1", "6", true)
+	assert.NoError(t, err)
+	_, err = sheet.DeleteCell("sheet1", "A1")
+	assert.NoError(t, err)
+
+	t.Run("versions", func(t *testing.T) {
+		versions, err := sheet.GetCellHistory("SHEET1", "a1", 10)
+		assert.NoError(t, err)
+
+		// the first version is removed by retention policy
+		assert.Len(t, versions, 3)
+		assert.Equal(t, uint64(4), versions[0].Version)
+		assert.True(t, versions[0].Deleted)
+		// recalculation with unchanged result is not a version
+		assert.Equal(t, uint64(3), versions[1].Version)
+		assert.Equal(t, "6", versions[1].Value)
+		assert.Equal(t, contracts.ChangeCauseDirectEdit, versions[1].Source)
+		assert.Equal(t, uint64(2), versions[2].Version)
+		assert.Equal(t, "a1", versions[2].CellId)
+		assert.Equal(t, "5", versions[2].Value)
+		assert.Equal(t, "5", versions[2].Result)
+		assert.Equal(t, contracts.ChangeCauseDirectEdit, versions[2].Source)
+
+		versions, err = sheet.GetCellHistory("sheet1", "A1", 1)
+		assert.NoError(t, err)
+		assert.Len(t, versions, 1)
+		assert.Equal(t, uint64(4), versions[0].Version)
+
+		versions, err = sheet.GetCellHistory("sheet1", "A2", 10)
+		assert.NoError(t, err)
+		assert.Len(t, versions, 1)
+		assert.Equal(t, "2", versions[0].Result)
+	})
+
+	t.Run("not_found", func(t *testing.T) {
+		_, err := sheet.GetCellHistory("sheet1", "A3", 10)
+		assert.ErrorIs(t, err, contracts.CellNotFoundError)
+		_, err = sheet.GetCellHistory("unknown", "A1", 10)
+		assert.ErrorIs(t, err, contracts.SheetNotFoundError)
+	})
+
+	t.Run("delete_sheet", func(t *testing.T) {
+		webhookDispatcher.On("DeleteSheetWebhooks", "sheet2").Return(nil)
+
+		_, err, _ := sheet.SetCell("sheet2", "A1", "1", true)
+		assert.NoError(t, err)
+		_, err = sheet.DeleteSheet("sheet2")
+		assert.NoError(t, err)
+
+		_, err, _ = sheet.SetCell("sheet2", "A2", "1", true)
+		assert.NoError(t, err)
+		_, err = sheet.GetCellHistory("sheet2", "A1", 10)
+		assert.ErrorIs(t, err, contracts.CellNotFoundError)
+	})
+}
+
+func TestSheet_CellHistoryRetentionByAge(t *testing.T) {
+	db, dbClose := _createTmpDb()
+	defer dbClose()
+
+	history := CellHistoryStorage{config: CellHistoryConfig{MaxAge: time.Hour}}
+	now := time.Now().UTC()
+
+	err := db.Update(func(tx contracts.StorageTx) error {
+		for _, timestamp := range []time.Time{now.Add(-3 * time.Hour), now.Add(-2 * time.Hour), now.Add(-time.Minute)} {
+			if err := history.Add(tx, []byte("sheet1"), []byte("a1"), &contracts.CellVersion{Timestamp: timestamp}); err != nil {
+				return err
+			}
+		}
+		// the latest version is kept regardless of its age
+		return history.Add(tx, []byte("sheet1"), []byte("a2"), &contracts.CellVersion{Timestamp: now.Add(-3 * time.Hour)})
+	})
+	assert.NoError(t, err)
+
+	_ = db.View(func(tx contracts.StorageTx) error {
+		versions := history.Get(tx, []byte("sheet1"), []byte("a1"), 10)
+		assert.Len(t, versions, 1)
+		assert.Equal(t, uint64(3), versions[0].Version)
+
+		assert.Len(t, history.Get(tx, []byte("sheet1"), []byte("a2"), 10), 1)
+		return nil
+	})
+}
+
+func TestSheet_GetCellListAt(t *testing.T) {
+	db, dbClose := _createTmpDb()
+	defer dbClose()
+
+	webhookDispatcher := mocks.NewWebhookDispatcher(t)
+	webhookDispatcher.On("Notify", mock.Anything, mock.Anything, mock.Anything).Return().Maybe()
+	webhookDispatcher.On("DeleteWebhooks", mock.Anything, mock.Anything).Return(nil).Maybe()
+
+	sheet := NewSheetRepository(
+		db, NewExpressionExecutor(NewCanonicalizer()), NewCellBinarySerializer(), NewCanonicalizer(),
+		webhookDispatcher, nil, CellHistoryConfig{}, 0,
+	)
+	// timestamps of versions have to differ between the moments
+	tick := func() time.Time {
+		time.Sleep(time.Millisecond * 2)
+		at := time.Now()
+		time.Sleep(time.Millisecond * 2)
+		return at
+	}
+
+	beforeAll := tick()
+	_, err, _ := sheet.SetCell("sheet1", "A1", "1", true)
+	assert.NoError(t, err)
+	_, err, _ = sheet.SetCell("sheet1", "A2", "=A1 + 1", true)
+	assert.NoError(t, err)
+	afterCreate := tick()
+	_, err, _ = sheet.SetCell("sheet1", "A1", "10", true)
+	assert.NoError(t, err)
+	afterUpdate := tick()
+	_, err = sheet.DeleteCell("sheet1", "A2")
+	assert.NoError(t, err)
+
+	cells, err := sheet.GetCellListAt("sheet1", beforeAll)
+	assert.NoError(t, err)
+	assert.Empty(t, *cells)
+
+	cells, err = sheet.GetCellListAt("SHEET1", afterCreate)
+	assert.NoError(t, err)
+	assert.Len(t, *cells, 2)
+	assert.Equal(t, "1", (*cells)["A1"].Result)
+	assert.Equal(t, "=A1 + 1", (*cells)["A2"].Value)
+	assert.Equal(t, "2", (*cells)["A2"].Result)
+
+	cells, err = sheet.GetCellListAt("sheet1", afterUpdate)
+	assert.NoError(t, err)
+	assert.Equal(t, "11", (*cells)["A2"].Result)
+
+	cells, err = sheet.GetCellListAt("sheet1", time.Now())
+	assert.NoError(t, err)
+	assert.Len(t, *cells, 1)
+	assert.Equal(t, "10", (*cells)["A1"].Result)
+
+	_, err = sheet.GetCellListAt("unknown", time.Now())
+	assert.ErrorIs(t, err, contracts.SheetNotFoundError)
+}
+
+func TestSheet_Undo(t *testing.T) {
+	db, dbClose := _createTmpDb()
+	defer dbClose()
+
+	var notified [][]contracts.CellChange
+	webhookDispatcher := mocks.NewWebhookDispatcher(t)
+	webhookDispatcher.On("Notify", "sheet1", mock.Anything, contracts.ChangeOrigin{}).
+		Run(func(args mock.Arguments) {
+			notified = append(notified, args.Get(1).([]contracts.CellChange))
+		}).
+		Return()
+	webhookDispatcher.On("DeleteWebhooks", "sheet1", mock.Anything).Return(nil).Maybe()
+
+	sheet := NewSheetRepository(
+		db, NewExpressionExecutor(NewCanonicalizer()), NewCellBinarySerializer(), NewCanonicalizer(),
+		webhookDispatcher, nil, CellHistoryConfig{}, DefaultUndoStackSize,
+	)
+	setCell := func(cellId string, value string) {
+		_, err, _ := sheet.SetCell("sheet1", cellId, value, true)
+		assert.NoError(t, err)
+	}
+	getResult := func(cellId string) string {
+		cell, err := sheet.GetCell("sheet1", cellId)
+		if errors.Is(err, contracts.CellNotFoundError) {
+			return "<not found>"
+		}
+		return cell.Result
+	}
+
+	setCell("A1", "1")
+	setCell("A2", "=A1 + 1")
+	setCell("B1", "100")
+	// the formula is broken: it depends on B1 instead of A1
+	setCell("A2", "=B1 * 10")
+	assert.Equal(t, "1000", getResult("A2"))
+
+	t.Run("undo", func(t *testing.T) {
+		notified = nil
+		versions, err := sheet.Undo("SHEET1", 1)
+		assert.NoError(t, err)
+
+		assert.Len(t, versions, 1)
+		assert.Equal(t, "A2", versions[0].CellId)
+		assert.Equal(t, "=A1 + 1", versions[0].Value)
+		assert.Equal(t, "2", versions[0].Result)
+		assert.Equal(t, contracts.ChangeCauseUndo, versions[0].Source)
+		assert.Equal(t, "2", getResult("A2"))
+
+		assert.Len(t, notified, 1)
+		assert.Len(t, notified[0], 1)
+		assert.Equal(t, contracts.ChangeCauseUndo, notified[0][0].Cause)
+		assert.Equal(t, "1000", notified[0][0].Previous.Result)
+		assert.Equal(t, "2", notified[0][0].Cell.Result)
+
+		// dependency edges are restored: A2 depends on A1 again
+		notified = nil
+		setCell("A1", "5")
+		setCell("B1", "200")
+		assert.Equal(t, "6", getResult("A2"))
+		assert.Len(t, notified[0], 2)
+		assert.Len(t, notified[1], 1)
+
+		history, err := sheet.GetCellHistory("sheet1", "A2", 1)
+		assert.NoError(t, err)
+		assert.Equal(t, contracts.ChangeCauseUndo, history[0].Source)
+	})
+
+	t.Run("undo_several_steps", func(t *testing.T) {
+		notified = nil
+		versions, err := sheet.Undo("sheet1", 2)
+		assert.NoError(t, err)
+
+		assert.Len(t, versions, 2)
+		assert.Equal(t, "B1", versions[0].CellId)
+		assert.Equal(t, "100", versions[0].Value)
+		assert.Equal(t, "A1", versions[1].CellId)
+		assert.Equal(t, "1", versions[1].Value)
+		assert.Equal(t, "2", getResult("A2"))
+
+		// A2 is notified when A1 is changed back
+		assert.Len(t, notified, 2)
+		assert.Equal(t, "A2", notified[1][1].CellId)
+		assert.Equal(t, contracts.ChangeCauseDependency, notified[1][1].Cause)
+		assert.Equal(t, "6", notified[1][1].Previous.Result)
+		assert.Equal(t, "2", notified[1][1].Cell.Result)
+	})
+
+	t.Run("redo", func(t *testing.T) {
+		versions, err := sheet.Redo("sheet1", 10)
+		assert.NoError(t, err)
+
+		assert.Len(t, versions, 2)
+		assert.Equal(t, "A1", versions[0].CellId)
+		assert.Equal(t, "B1", versions[1].CellId)
+		assert.Equal(t, contracts.ChangeCauseRedo, versions[1].Source)
+		assert.Equal(t, "6", getResult("A2"))
+		assert.Equal(t, "200", getResult("B1"))
+
+		_, err = sheet.Redo("sheet1", 1)
+		assert.ErrorIs(t, err, contracts.NothingToRedoError)
+	})
+
+	t.Run("new_write_clears_redo", func(t *testing.T) {
+		_, err := sheet.Undo("sheet1", 1)
+		assert.NoError(t, err)
+		setCell("C1", "1")
+
+		_, err = sheet.Redo("sheet1", 1)
+		assert.ErrorIs(t, err, contracts.NothingToRedoError)
+	})
+
+	t.Run("created_and_deleted_cells", func(t *testing.T) {
+		_, err := sheet.Undo("sheet1", 1)
+		assert.NoError(t, err)
+		assert.Equal(t, "<not found>", getResult("C1"))
+
+		_, err = sheet.DeleteCell("sheet1", "A1")
+		assert.NoError(t, err)
+		assert.Contains(t, getResult("A2"), "invalid operation")
+
+		versions, err := sheet.Undo("sheet1", 1)
+		assert.NoError(t, err)
+		assert.Equal(t, "A1", versions[0].CellId)
+		assert.False(t, versions[0].Deleted)
+		assert.Equal(t, "5", getResult("A1"))
+		assert.Equal(t, "6", getResult("A2"))
+
+		notified = nil
+		versions, err = sheet.Redo("sheet1", 1)
+		assert.NoError(t, err)
+		assert.True(t, versions[0].Deleted)
+		assert.Equal(t, "<not found>", getResult("A1"))
+
+		// the deleted cell itself is not notified, only its dependants
+		assert.Len(t, notified[0], 1)
+		assert.Equal(t, "A2", notified[0][0].CellId)
+	})
+
+	t.Run("nothing_to_undo", func(t *testing.T) {
+		versions, err := sheet.Undo("sheet1", 1000)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, versions)
+
+		_, err = sheet.Undo("sheet1", 1)
+		assert.ErrorIs(t, err, contracts.NothingToUndoError)
+		_, err = sheet.Undo("unknown", 1)
+		assert.ErrorIs(t, err, contracts.SheetNotFoundError)
+	})
+}
+
+func TestSheet_UndoEvaluationError(t *testing.T) {
+	db, dbClose := _createTmpDb()
+	defer dbClose()
+
+	webhookDispatcher := mocks.NewWebhookDispatcher(t)
+	webhookDispatcher.On("Notify", mock.Anything, mock.Anything, mock.Anything).Return()
+
+	sheet := NewSheetRepository(
+		db, NewExpressionExecutor(NewCanonicalizer()), NewCellBinarySerializer(), NewCanonicalizer(),
+		webhookDispatcher, nil, CellHistoryConfig{}, DefaultUndoStackSize,
+	)
+
+	_, err := sheet.SetSettings("sheet1", contracts.SheetSettings{Iterative: true, MaxIterations: 100, Epsilon: 0.001})
+	assert.NoError(t, err)
+	for _, cell := range [][2]string{{"interest", "0"}, {"balance", "=100 + interest"}, {"interest", "=balance * 0.1"}, {"interest", "5"}} {
+		_, err, _ = sheet.SetCell("sheet1", cell[0], cell[1], true)
+		assert.NoError(t, err)
+	}
+
+	// the circular formula can not be restored without iterative calculation
+	_, err = sheet.SetSettings("sheet1", contracts.SheetSettings{})
+	assert.NoError(t, err)
+	_, err = sheet.Undo("sheet1", 1)
+	assert.ErrorIs(t, err, contracts.UndoEvaluationError)
+	assert.ErrorIs(t, err, CircularReferenceError)
+
+	// nothing is applied, the step stays in the stack
+	cell, err := sheet.GetCell("sheet1", "interest")
+	assert.NoError(t, err)
+	assert.Equal(t, "5", cell.Value)
+
+	_, err = sheet.SetSettings("sheet1", contracts.SheetSettings{Iterative: true, MaxIterations: 100, Epsilon: 0.001})
+	assert.NoError(t, err)
+	versions, err := sheet.Undo("sheet1", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "=balance * 0.1", versions[0].Value)
+}
+
+func TestSheet_UndoStackSize(t *testing.T) {
+	db, dbClose := _createTmpDb()
+	defer dbClose()
+
+	webhookDispatcher := mocks.NewWebhookDispatcher(t)
+	webhookDispatcher.On("Notify", mock.Anything, mock.Anything, mock.Anything).Return()
+	webhookDispatcher.On("DeleteSheetWebhooks", "sheet1").Return(nil)
+
+	sheet := NewSheetRepository(
+		db, NewExpressionExecutor(NewCanonicalizer()), NewCellBinarySerializer(), NewCanonicalizer(),
+		webhookDispatcher, nil, CellHistoryConfig{}, 2,
+	)
+
+	for _, value := range []string{"1", "2", "3", "4"} {
+		_, err, _ := sheet.SetCell("sheet1", "A1", value, true)
+		assert.NoError(t, err)
+	}
+
+	versions, err := sheet.Undo("sheet1", 10)
+	assert.NoError(t, err)
+	assert.Len(t, versions, 2)
+	assert.Equal(t, "2", versions[1].Value)
+
+	// stacks are deleted with the sheet
+	_, err = sheet.DeleteSheet("sheet1")
+	assert.NoError(t, err)
+	_, err = sheet.Redo("sheet1", 1)
+	assert.ErrorIs(t, err, contracts.SheetNotFoundError)
+}
+
+func TestSheet_UndoWithConcurrentWrites(t *testing.T) {
+	db, dbClose := _createTmpDb()
+	defer dbClose()
+
+	webhookDispatcher := mocks.NewWebhookDispatcher(t)
+	webhookDispatcher.On("Notify", mock.Anything, mock.Anything, mock.Anything).Return()
+
+	sheet := NewSheetRepository(
+		db, NewExpressionExecutor(NewCanonicalizer()), NewCellBinarySerializer(), NewCanonicalizer(),
+		webhookDispatcher, nil, CellHistoryConfig{}, DefaultUndoStackSize,
+	)
+
+	_, err, _ := sheet.SetCell("sheet1", "A1", "1", true)
+	assert.NoError(t, err)
+	for i := 2; i <= 20; i++ {
+		_, err, _ = sheet.SetCell("sheet1", "A1", fmt.Sprintf("=%d", i), true)
+		assert.NoError(t, err)
+	}
+
+	done := make(chan bool)
+	go func() {
+		defer close(done)
+		for i := 1; i <= 20; i++ {
+			_, err, _ := sheet.SetCell("sheet1", fmt.Sprintf("B%d", i), fmt.Sprintf("=A1 + %d", i), true)
+			assert.NoError(t, err)
+		}
+	}()
+
+	// writes of B cells are pushed in between, so undo reverts some of them instead of A1
+	undone := 0
+	for undone < 10 {
+		versions, err := sheet.Undo("sheet1", 1)
+		assert.NoError(t, err)
+		undone += len(versions)
+	}
+	<-done
+
+	// results of dependants are consistent with the restored A1
+	a1, err := sheet.GetCell("sheet1", "A1")
+	assert.NoError(t, err)
+	cells, err := sheet.GetCellList("sheet1")
+	assert.NoError(t, err)
+	for cellId, cell := range *cells {
+		if number, found := strings.CutPrefix(cellId, "B"); found {
+			expected, _ := strconv.Atoi(a1.Result)
+			delta, _ := strconv.Atoi(number)
+			assert.Equal(t, strconv.Itoa(expected+delta), cell.Result, cellId)
+		}
+	}
+
+	// no write is lost: all the rest are undone up to empty sheet
+	versions, err := sheet.Undo("sheet1", 100)
+	assert.NoError(t, err)
+	assert.Len(t, versions, 40-undone)
+	cells, err = sheet.GetCellList("sheet1")
+	assert.NoError(t, err)
+	assert.Empty(t, *cells)
+}
+
+func _prepareSheet(t *testing.T, sheetId string) *BoltStorage {
 	db, dbClose := _createTmpDb()
 	defer dbClose()
 
@@ -612,7 +1453,7 @@ func _prepareSheet(t *testing.T, sheetId string) *bbolt.DB {
 	executor.On("ExtractDependingOnList", mock.Anything).Return([]string{})
 
 	webhookDispatcher := mocks.NewWebhookDispatcher(t)
-	webhookDispatcher.On("Notify", mock.Anything, mock.Anything).Return().Maybe()
+	webhookDispatcher.On("Notify", mock.Anything, mock.Anything, mock.Anything).Return().Maybe()
 
 	sheet := &SheetRepository{
 		db:                db,
@@ -634,17 +1475,17 @@ func _prepareSheet(t *testing.T, sheetId string) *bbolt.DB {
 	path := db.Path()
 	db.Close()
 	// re-open DB to ensure it stored at disk
-	db, err = bbolt.Open(path, 0600, nil)
+	db, err = OpenBoltStorage(path)
 	assert.NoError(t, err)
 
 	return db
 }
 
-func _createTmpDb() (*bbolt.DB, func()) {
+func _createTmpDb() (*BoltStorage, func()) {
 	f, _ := os.CreateTemp("", "db_*.db")
 	os.Remove(f.Name())
 
-	db, dbErr := bbolt.Open(f.Name(), 0600, nil)
+	db, dbErr := OpenBoltStorage(f.Name())
 	if dbErr != nil {
 		panic(dbErr)
 	}
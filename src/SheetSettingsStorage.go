@@ -0,0 +1,50 @@
+package main
+
+import (
+	"devChallengeExcel/contracts"
+	json "github.com/bytedance/sonic"
+)
+
+// SheetSettingsStorage keeps settings of all sheets in single bucket (key is canonical sheet id)
+type SheetSettingsStorage struct{}
+
+var settingsBucketId = []byte("__settings")
+
+// Get returns stored settings of sheet or default settings
+func (s *SheetSettingsStorage) Get(tx contracts.StorageTx, sheetId []byte) contracts.SheetSettings {
+	settings := contracts.NewSheetSettings()
+
+	bucket := tx.Bucket(settingsBucketId)
+	if bucket == nil {
+		return settings
+	}
+
+	if data := bucket.Get(sheetId); data != nil {
+		_ = json.Unmarshal(data, &settings)
+	}
+
+	return settings
+}
+
+func (s *SheetSettingsStorage) Set(tx contracts.StorageTx, sheetId []byte, settings contracts.SheetSettings) error {
+	bucket, err := tx.CreateBucketIfNotExists(settingsBucketId)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+
+	return bucket.Put(sheetId, data)
+}
+
+func (s *SheetSettingsStorage) Delete(tx contracts.StorageTx, sheetId []byte) error {
+	bucket := tx.Bucket(settingsBucketId)
+	if bucket == nil {
+		return nil
+	}
+
+	return bucket.Delete(sheetId)
+}
@@ -0,0 +1,384 @@
+package main
+
+import (
+	"database/sql"
+	"devChallengeExcel/contracts"
+	"errors"
+	_ "github.com/mattn/go-sqlite3"
+	"sync/atomic"
+)
+
+// SqliteStorage is contracts.Storage in embedded SQLite database (requires cgo).
+// Buckets are rows of `buckets` table which refer to the parent bucket (0 is the root), values are rows of `items` table.
+// Keys are BLOBs, so they are ordered bytewise as in other storages.
+// Database is in WAL mode: views use separate connections and do not wait for updates, updates are serialized
+type SqliteStorage struct {
+	reader *sql.DB
+	writer *sql.DB
+	closed atomic.Bool
+}
+
+type sqliteStorageTx struct {
+	tx       *sql.Tx
+	writable bool
+	// err is the first error of reading, e.g. of Get which does not return errors. The transaction fails with it
+	err error
+}
+
+type sqliteStorageBucket struct {
+	tx *sqliteStorageTx
+	id int64
+}
+
+// sqliteStorageCursor keeps current key, each move is a query of the next (previous) key
+type sqliteStorageCursor struct {
+	bucket  *sqliteStorageBucket
+	current []byte
+}
+
+const sqliteRootBucketId = 0
+
+const sqliteStorageSchema = `
+CREATE TABLE IF NOT EXISTS buckets (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	parent INTEGER NOT NULL,
+	name BLOB NOT NULL,
+	sequence INTEGER NOT NULL DEFAULT 0,
+	UNIQUE (parent, name)
+);
+CREATE TABLE IF NOT EXISTS items (
+	bucket INTEGER NOT NULL,
+	key BLOB NOT NULL,
+	value BLOB NOT NULL,
+	PRIMARY KEY (bucket, key)
+) WITHOUT ROWID;
+`
+
+// sqliteNestedBucketsQuery selects id of the bucket and ids of all its nested buckets
+const sqliteNestedBucketsQuery = `
+WITH RECURSIVE nested(id) AS (
+	SELECT ? UNION ALL SELECT buckets.id FROM buckets JOIN nested ON buckets.parent = nested.id
+)`
+
+func OpenSqliteStorage(path string) (*SqliteStorage, error) {
+	const options = "?_busy_timeout=5000&_journal_mode=WAL"
+
+	writer, err := sql.Open("sqlite3", path+options+"&_txlock=immediate")
+	if err != nil {
+		return nil, err
+	}
+	writer.SetMaxOpenConns(1)
+
+	if _, err = writer.Exec(sqliteStorageSchema); err != nil {
+		_ = writer.Close()
+		return nil, err
+	}
+
+	reader, err := sql.Open("sqlite3", path+options)
+	if err != nil {
+		_ = writer.Close()
+		return nil, err
+	}
+
+	return &SqliteStorage{reader: reader, writer: writer}, nil
+}
+
+func (s *SqliteStorage) View(fn func(tx contracts.StorageTx) error) error {
+	return s.run(s.reader, false, fn)
+}
+
+func (s *SqliteStorage) Update(fn func(tx contracts.StorageTx) error) error {
+	return s.run(s.writer, true, fn)
+}
+
+func (s *SqliteStorage) Batch(fn func(tx contracts.StorageTx) error) error {
+	return s.Update(fn)
+}
+
+func (s *SqliteStorage) Close() error {
+	s.closed.Store(true)
+
+	return errors.Join(s.reader.Close(), s.writer.Close())
+}
+
+// run commits transaction of updates when fn succeeds, otherwise (and for views) transaction is rolled back
+func (s *SqliteStorage) run(db *sql.DB, writable bool, fn func(tx contracts.StorageTx) error) error {
+	if s.closed.Load() {
+		return contracts.StorageClosedError
+	}
+
+	sqlTx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	// transaction is rolled back on panic as well, otherwise the connection is not released
+	committed := false
+	defer func() {
+		if !committed {
+			_ = sqlTx.Rollback()
+		}
+	}()
+
+	tx := &sqliteStorageTx{tx: sqlTx, writable: writable}
+	err = fn(&sqliteStorageBucket{tx: tx, id: sqliteRootBucketId})
+	if err == nil {
+		err = tx.err
+	}
+	if err == nil && writable {
+		err = sqlTx.Commit()
+		committed = err == nil
+	}
+
+	return err
+}
+
+func (t *sqliteStorageTx) fail(err error) {
+	if t.err == nil && err != nil {
+		t.err = err
+	}
+}
+
+func (b *sqliteStorageBucket) Get(key []byte) []byte {
+	var value []byte
+	err := b.tx.tx.QueryRow("SELECT value FROM items WHERE bucket = ? AND key = ?", b.id, key).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	b.tx.fail(err)
+
+	return value
+}
+
+func (b *sqliteStorageBucket) Put(key []byte, value []byte) error {
+	if err := b.checkWritable(key, contracts.StorageKeyRequiredError); err != nil {
+		return err
+	}
+
+	if id, err := b.findBucket(key); err != nil {
+		return err
+	} else if id != 0 {
+		return contracts.StorageIncompatibleValueError
+	}
+
+	if value == nil {
+		value = []byte{}
+	}
+	_, err := b.tx.tx.Exec(
+		"INSERT INTO items (bucket, key, value) VALUES (?, ?, ?) ON CONFLICT (bucket, key) DO UPDATE SET value = excluded.value",
+		b.id, key, value,
+	)
+
+	return err
+}
+
+func (b *sqliteStorageBucket) Delete(key []byte) error {
+	if !b.tx.writable {
+		return contracts.StorageTxNotWritableError
+	}
+
+	if id, err := b.findBucket(key); err != nil {
+		return err
+	} else if id != 0 {
+		return contracts.StorageIncompatibleValueError
+	}
+
+	_, err := b.tx.tx.Exec("DELETE FROM items WHERE bucket = ? AND key = ?", b.id, key)
+
+	return err
+}
+
+func (b *sqliteStorageBucket) Bucket(name []byte) contracts.StorageBucket {
+	id, err := b.findBucket(name)
+	b.tx.fail(err)
+	if id == 0 {
+		return nil
+	}
+
+	return &sqliteStorageBucket{tx: b.tx, id: id}
+}
+
+func (b *sqliteStorageBucket) CreateBucketIfNotExists(name []byte) (contracts.StorageBucket, error) {
+	if err := b.checkWritable(name, contracts.StorageBucketNameRequiredError); err != nil {
+		return nil, err
+	}
+
+	id, err := b.findBucket(name)
+	if err != nil {
+		return nil, err
+	}
+	if id != 0 {
+		return &sqliteStorageBucket{tx: b.tx, id: id}, nil
+	}
+
+	if b.Get(name) != nil {
+		return nil, contracts.StorageIncompatibleValueError
+	}
+
+	result, err := b.tx.tx.Exec("INSERT INTO buckets (parent, name) VALUES (?, ?)", b.id, name)
+	if err == nil {
+		id, err = result.LastInsertId()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &sqliteStorageBucket{tx: b.tx, id: id}, nil
+}
+
+func (b *sqliteStorageBucket) DeleteBucket(name []byte) error {
+	if err := b.checkWritable(name, contracts.StorageBucketNameRequiredError); err != nil {
+		return err
+	}
+
+	id, err := b.findBucket(name)
+	if err != nil {
+		return err
+	}
+	if id == 0 {
+		if b.Get(name) != nil {
+			return contracts.StorageIncompatibleValueError
+		}
+		return contracts.StorageBucketNotFoundError
+	}
+
+	_, err = b.tx.tx.Exec(sqliteNestedBucketsQuery+" DELETE FROM items WHERE bucket IN nested", id)
+	if err != nil {
+		return err
+	}
+
+	_, err = b.tx.tx.Exec(sqliteNestedBucketsQuery+" DELETE FROM buckets WHERE id IN nested", id)
+
+	return err
+}
+
+func (b *sqliteStorageBucket) ForEach(fn func(key []byte, value []byte) error) error {
+	// rows are read before fn is called, so fn can query the transaction
+	keys := make([][]byte, 0)
+	values := make([][]byte, 0)
+	err := b.queryRows("SELECT key, value FROM items WHERE bucket = ? ORDER BY key", func(rows *sql.Rows) error {
+		var key, value []byte
+		err := rows.Scan(&key, &value)
+		keys = append(keys, key)
+		values = append(values, value)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	for i := range keys {
+		if err = fn(keys[i], values[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (b *sqliteStorageBucket) ForEachBucket(fn func(name []byte) error) error {
+	names := make([][]byte, 0)
+	err := b.queryRows("SELECT name FROM buckets WHERE parent = ? ORDER BY name", func(rows *sql.Rows) error {
+		var name []byte
+		err := rows.Scan(&name)
+		names = append(names, name)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		if err = fn(name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (b *sqliteStorageBucket) Cursor() contracts.StorageCursor {
+	return &sqliteStorageCursor{bucket: b}
+}
+
+func (b *sqliteStorageBucket) NextSequence() (sequence uint64, err error) {
+	if !b.tx.writable {
+		return 0, contracts.StorageTxNotWritableError
+	}
+
+	err = b.tx.tx.QueryRow("UPDATE buckets SET sequence = sequence + 1 WHERE id = ? RETURNING sequence", b.id).Scan(&sequence)
+
+	return
+}
+
+func (b *sqliteStorageBucket) checkWritable(key []byte, requiredError error) error {
+	if !b.tx.writable {
+		return contracts.StorageTxNotWritableError
+	}
+	if len(key) == 0 {
+		return requiredError
+	}
+
+	return nil
+}
+
+// findBucket returns id of nested bucket or 0 when it does not exist
+func (b *sqliteStorageBucket) findBucket(name []byte) (id int64, err error) {
+	err = b.tx.tx.QueryRow("SELECT id FROM buckets WHERE parent = ? AND name = ?", b.id, name).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+
+	return
+}
+
+func (b *sqliteStorageBucket) queryRows(query string, scan func(rows *sql.Rows) error) error {
+	rows, err := b.tx.tx.Query(query, b.id)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err = scan(rows); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func (c *sqliteStorageCursor) First() ([]byte, []byte) {
+	return c.move("SELECT key, value FROM items WHERE bucket = ? ORDER BY key LIMIT 1")
+}
+
+func (c *sqliteStorageCursor) Last() ([]byte, []byte) {
+	return c.move("SELECT key, value FROM items WHERE bucket = ? ORDER BY key DESC LIMIT 1")
+}
+
+func (c *sqliteStorageCursor) Next() ([]byte, []byte) {
+	return c.move("SELECT key, value FROM items WHERE bucket = ? AND key > ? ORDER BY key LIMIT 1", c.current)
+}
+
+func (c *sqliteStorageCursor) Prev() ([]byte, []byte) {
+	return c.move("SELECT key, value FROM items WHERE bucket = ? AND key < ? ORDER BY key DESC LIMIT 1", c.current)
+}
+
+func (c *sqliteStorageCursor) Seek(seek []byte) ([]byte, []byte) {
+	return c.move("SELECT key, value FROM items WHERE bucket = ? AND key >= ? ORDER BY key LIMIT 1", seek)
+}
+
+func (c *sqliteStorageCursor) move(query string, args ...any) ([]byte, []byte) {
+	var key, value []byte
+	err := c.bucket.tx.tx.QueryRow(query, append([]any{c.bucket.id}, args...)...).Scan(&key, &value)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			c.bucket.tx.fail(err)
+		}
+		return nil, nil
+	}
+
+	c.current = key
+
+	return key, value
+}
@@ -0,0 +1,549 @@
+package main
+
+import (
+	"devChallengeExcel/contracts"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// storageBackend opens storage of the backend, persistent storages are reopened with the same path
+type storageBackend struct {
+	name       string
+	persistent bool
+	open       func(path string) (contracts.Storage, error)
+}
+
+var storageBackends = []storageBackend{
+	{name: StorageBackendBolt, persistent: true, open: func(path string) (contracts.Storage, error) {
+		return OpenBoltStorage(path)
+	}},
+	{name: StorageBackendSqlite, persistent: true, open: func(path string) (contracts.Storage, error) {
+		return OpenSqliteStorage(path)
+	}},
+	{name: StorageBackendMemory, open: func(path string) (contracts.Storage, error) {
+		return NewMemoryStorage(), nil
+	}},
+}
+
+// TestStorage_Conformance runs the same suite for all storages, so they are interchangeable
+func TestStorage_Conformance(t *testing.T) {
+	for _, backend := range storageBackends {
+		t.Run(backend.name, func(t *testing.T) {
+			_testStorageConformance(t, backend)
+		})
+	}
+}
+
+func _testStorageConformance(t *testing.T, backend storageBackend) {
+	openStorage := func(t *testing.T) (contracts.Storage, string) {
+		path := filepath.Join(t.TempDir(), "storage.db")
+		storage, err := backend.open(path)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		t.Cleanup(func() {
+			_ = storage.Close()
+		})
+
+		return storage, path
+	}
+
+	t.Run("values", func(t *testing.T) {
+		storage, _ := openStorage(t)
+
+		err := storage.Update(func(tx contracts.StorageTx) error {
+			bucket, err := tx.CreateBucketIfNotExists([]byte("sheet"))
+			assert.NoError(t, err)
+
+			assert.NoError(t, bucket.Put([]byte("a1"), []byte("value1")))
+			assert.NoError(t, bucket.Put([]byte("a2"), []byte("value2")))
+			assert.NoError(t, bucket.Put([]byte("a2"), []byte("replaced")))
+			assert.NoError(t, bucket.Put([]byte("empty"), []byte{}))
+			assert.NoError(t, bucket.Put([]byte("deleted"), []byte("value")))
+			assert.NoError(t, bucket.Delete([]byte("deleted")))
+			assert.NoError(t, bucket.Delete([]byte("not-existing")))
+
+			// changes are visible within the transaction
+			assert.Equal(t, []byte("replaced"), bucket.Get([]byte("a2")))
+
+			assert.ErrorIs(t, bucket.Put([]byte{}, []byte("value")), contracts.StorageKeyRequiredError)
+			assert.NoError(t, bucket.Delete(nil))
+			return nil
+		})
+		assert.NoError(t, err)
+
+		err = storage.View(func(tx contracts.StorageTx) error {
+			assert.Nil(t, tx.Bucket([]byte("not-existing")))
+
+			bucket := tx.Bucket([]byte("sheet"))
+			if !assert.NotNil(t, bucket) {
+				return nil
+			}
+			assert.Equal(t, []byte("value1"), bucket.Get([]byte("a1")))
+			assert.Equal(t, []byte("replaced"), bucket.Get([]byte("a2")))
+			assert.Nil(t, bucket.Get([]byte("deleted")))
+			assert.Nil(t, bucket.Get([]byte("not-existing")))
+
+			// empty value differs from not existing one
+			assert.NotNil(t, bucket.Get([]byte("empty")))
+			assert.Empty(t, bucket.Get([]byte("empty")))
+			return nil
+		})
+		assert.NoError(t, err)
+	})
+
+	t.Run("nested_buckets", func(t *testing.T) {
+		storage, _ := openStorage(t)
+
+		err := storage.Update(func(tx contracts.StorageTx) error {
+			root, err := tx.CreateBucketIfNotExists([]byte("root"))
+			assert.NoError(t, err)
+
+			nested, err := root.CreateBucketIfNotExists([]byte("nested"))
+			assert.NoError(t, err)
+			assert.NoError(t, nested.Put([]byte("key"), []byte("nested value")))
+
+			deep, err := nested.CreateBucketIfNotExists([]byte("deep"))
+			assert.NoError(t, err)
+			assert.NoError(t, deep.Put([]byte("key"), []byte("deep value")))
+
+			// existing bucket is returned
+			existing, err := root.CreateBucketIfNotExists([]byte("nested"))
+			assert.NoError(t, err)
+			assert.Equal(t, []byte("nested value"), existing.Get([]byte("key")))
+
+			// same name in other bucket is other bucket
+			other, err := tx.CreateBucketIfNotExists([]byte("nested"))
+			assert.NoError(t, err)
+			assert.Nil(t, other.Get([]byte("key")))
+
+			assert.NoError(t, root.Put([]byte("value"), []byte("value")))
+			_, err = tx.CreateBucketIfNotExists(nil)
+			assert.ErrorIs(t, err, contracts.StorageBucketNameRequiredError)
+			return nil
+		})
+		assert.NoError(t, err)
+
+		err = storage.Update(func(tx contracts.StorageTx) error {
+			root := tx.Bucket([]byte("root"))
+
+			// name of bucket can not be used by value and vice versa
+			assert.Nil(t, root.Get([]byte("nested")))
+			assert.Nil(t, root.Bucket([]byte("value")))
+			assert.ErrorIs(t, root.Put([]byte("nested"), []byte("value")), contracts.StorageIncompatibleValueError)
+			assert.ErrorIs(t, root.Delete([]byte("nested")), contracts.StorageIncompatibleValueError)
+			_, err := root.CreateBucketIfNotExists([]byte("value"))
+			assert.ErrorIs(t, err, contracts.StorageIncompatibleValueError)
+			assert.ErrorIs(t, root.DeleteBucket([]byte("value")), contracts.StorageIncompatibleValueError)
+
+			assert.ErrorIs(t, root.DeleteBucket([]byte("not-existing")), contracts.StorageBucketNotFoundError)
+			assert.ErrorIs(t, tx.DeleteBucket([]byte("not-existing")), contracts.StorageBucketNotFoundError)
+
+			// nested buckets are removed with the bucket
+			assert.NoError(t, root.DeleteBucket([]byte("nested")))
+			assert.Nil(t, root.Bucket([]byte("nested")))
+			assert.Equal(t, []byte("value"), root.Get([]byte("value")))
+
+			nested, err := root.CreateBucketIfNotExists([]byte("nested"))
+			assert.NoError(t, err)
+			assert.Nil(t, nested.Get([]byte("key")))
+			assert.Nil(t, nested.Bucket([]byte("deep")))
+			return nil
+		})
+		assert.NoError(t, err)
+
+		err = storage.Update(func(tx contracts.StorageTx) error {
+			assert.NoError(t, tx.DeleteBucket([]byte("root")))
+			return nil
+		})
+		assert.NoError(t, err)
+
+		err = storage.View(func(tx contracts.StorageTx) error {
+			assert.Nil(t, tx.Bucket([]byte("root")))
+			assert.NotNil(t, tx.Bucket([]byte("nested")))
+			return nil
+		})
+		assert.NoError(t, err)
+	})
+
+	t.Run("iteration", func(t *testing.T) {
+		storage, _ := openStorage(t)
+
+		// keys are ordered bytewise: prefix goes first, 0x00 delimiter goes before other chars
+		keys := []string{"b", "a\x00c", "a", "\xff", "a\x00b", "ab", "A"}
+		sorted := []string{"A", "a", "a\x00b", "a\x00c", "ab", "b", "\xff"}
+
+		err := storage.Update(func(tx contracts.StorageTx) error {
+			bucket, err := tx.CreateBucketIfNotExists([]byte("bucket"))
+			assert.NoError(t, err)
+
+			for _, key := range keys {
+				assert.NoError(t, bucket.Put([]byte(key), []byte("value of "+key)))
+			}
+			for _, name := range []string{"nested2", "aa", "nested1"} {
+				_, err = bucket.CreateBucketIfNotExists([]byte(name))
+				assert.NoError(t, err)
+			}
+			_, err = tx.CreateBucketIfNotExists([]byte("empty"))
+			assert.NoError(t, err)
+			return nil
+		})
+		assert.NoError(t, err)
+
+		err = storage.View(func(tx contracts.StorageTx) error {
+			bucket := tx.Bucket([]byte("bucket"))
+
+			// nested buckets are skipped
+			iterated := make([]string, 0)
+			assert.NoError(t, bucket.ForEach(func(key []byte, value []byte) error {
+				assert.Equal(t, "value of "+string(key), string(value))
+				iterated = append(iterated, string(key))
+				return nil
+			}))
+			assert.Equal(t, sorted, iterated)
+
+			names := make([]string, 0)
+			assert.NoError(t, bucket.ForEachBucket(func(name []byte) error {
+				names = append(names, string(name))
+				return nil
+			}))
+			assert.Equal(t, []string{"aa", "nested1", "nested2"}, names)
+
+			// error stops iteration
+			stopErr := errors.New("stop")
+			count := 0
+			assert.ErrorIs(t, bucket.ForEach(func(key []byte, value []byte) error {
+				count++
+				return stopErr
+			}), stopErr)
+			assert.Equal(t, 1, count)
+			assert.ErrorIs(t, bucket.ForEachBucket(func(name []byte) error {
+				return stopErr
+			}), stopErr)
+
+			empty := tx.Bucket([]byte("empty"))
+			assert.NoError(t, empty.ForEach(func(key []byte, value []byte) error {
+				assert.Fail(t, "empty bucket has no values")
+				return nil
+			}))
+			return nil
+		})
+		assert.NoError(t, err)
+	})
+
+	t.Run("cursor", func(t *testing.T) {
+		storage, _ := openStorage(t)
+
+		err := storage.Update(func(tx contracts.StorageTx) error {
+			bucket, err := tx.CreateBucketIfNotExists([]byte("bucket"))
+			assert.NoError(t, err)
+
+			for _, key := range []string{"cell1\x00a1", "cell1\x00a2", "cell2\x00a1", "cell10\x00a3"} {
+				assert.NoError(t, bucket.Put([]byte(key), []byte{}))
+			}
+			// buckets at the beginning, in the middle and at the end are skipped
+			for _, name := range []string{"0", "cell1\x00b", "z"} {
+				_, err = bucket.CreateBucketIfNotExists([]byte(name))
+				assert.NoError(t, err)
+			}
+			_, err = tx.CreateBucketIfNotExists([]byte("empty"))
+			assert.NoError(t, err)
+			return nil
+		})
+		assert.NoError(t, err)
+
+		err = storage.View(func(tx contracts.StorageTx) error {
+			cursor := tx.Bucket([]byte("bucket")).Cursor()
+
+			forward := make([]string, 0)
+			for key, value := cursor.First(); key != nil; key, value = cursor.Next() {
+				assert.NotNil(t, value)
+				forward = append(forward, string(key))
+			}
+			assert.Equal(t, []string{"cell1\x00a1", "cell1\x00a2", "cell10\x00a3", "cell2\x00a1"}, forward)
+
+			backward := make([]string, 0)
+			for key, _ := cursor.Last(); key != nil; key, _ = cursor.Prev() {
+				backward = append(backward, string(key))
+			}
+			assert.Equal(t, []string{"cell2\x00a1", "cell10\x00a3", "cell1\x00a2", "cell1\x00a1"}, backward)
+
+			// prefix scan
+			prefix := []byte("cell1\x00")
+			scanned := make([]string, 0)
+			for key, _ := cursor.Seek(prefix); key != nil && string(key[:min(len(key), len(prefix))]) == string(prefix); key, _ = cursor.Next() {
+				scanned = append(scanned, string(key))
+			}
+			assert.Equal(t, []string{"cell1\x00a1", "cell1\x00a2"}, scanned)
+
+			// seek moves to the next key when the key does not exist
+			key, _ := cursor.Seek([]byte("cell1\x00a"))
+			assert.Equal(t, "cell1\x00a1", string(key))
+			key, _ = cursor.Seek([]byte("cell1\x00a2"))
+			assert.Equal(t, "cell1\x00a2", string(key))
+			key, _ = cursor.Next()
+			assert.Equal(t, "cell10\x00a3", string(key))
+			key, _ = cursor.Seek([]byte("cell3"))
+			assert.Nil(t, key)
+
+			empty := tx.Bucket([]byte("empty")).Cursor()
+			key, _ = empty.First()
+			assert.Nil(t, key)
+			key, _ = empty.Last()
+			assert.Nil(t, key)
+			key, _ = empty.Seek([]byte("a"))
+			assert.Nil(t, key)
+			return nil
+		})
+		assert.NoError(t, err)
+	})
+
+	t.Run("cursor_after_deletes", func(t *testing.T) {
+		storage, _ := openStorage(t)
+		makeKey := func(i int) []byte {
+			return []byte(fmt.Sprintf("%016x", i))
+		}
+
+		// values span several pages, so deleted keys leave empty pages until commit
+		err := storage.Update(func(tx contracts.StorageTx) error {
+			bucket, err := tx.CreateBucketIfNotExists([]byte("bucket"))
+			assert.NoError(t, err)
+			for i := 0; i < 40; i++ {
+				assert.NoError(t, bucket.Put(makeKey(i), make([]byte, 500)))
+			}
+			return nil
+		})
+		assert.NoError(t, err)
+
+		err = storage.Update(func(tx contracts.StorageTx) error {
+			bucket := tx.Bucket([]byte("bucket"))
+			for i := 5; i < 35; i++ {
+				assert.NoError(t, bucket.Delete(makeKey(i)))
+			}
+
+			cursor := bucket.Cursor()
+			backward := 0
+			for key, _ := cursor.Last(); key != nil; key, _ = cursor.Prev() {
+				backward++
+			}
+			assert.Equal(t, 10, backward)
+
+			// pop from the end until the bucket is empty
+			for i := 0; i < 10; i++ {
+				key, _ := bucket.Cursor().Last()
+				if assert.NotNil(t, key) {
+					assert.NoError(t, bucket.Delete(key))
+				}
+			}
+			key, _ := bucket.Cursor().Last()
+			assert.Nil(t, key)
+			key, _ = bucket.Cursor().First()
+			assert.Nil(t, key)
+			return nil
+		})
+		assert.NoError(t, err)
+	})
+
+	t.Run("sequence", func(t *testing.T) {
+		storage, _ := openStorage(t)
+
+		for i := uint64(1); i <= 3; i++ {
+			err := storage.Update(func(tx contracts.StorageTx) error {
+				bucket, err := tx.CreateBucketIfNotExists([]byte("bucket"))
+				assert.NoError(t, err)
+
+				sequence, err := bucket.NextSequence()
+				assert.NoError(t, err)
+				assert.Equal(t, i, sequence)
+
+				// each bucket has own sequence
+				nested, err := bucket.CreateBucketIfNotExists([]byte("nested" + fmt.Sprint(i)))
+				assert.NoError(t, err)
+				sequence, err = nested.NextSequence()
+				assert.NoError(t, err)
+				assert.Equal(t, uint64(1), sequence)
+				return nil
+			})
+			assert.NoError(t, err)
+		}
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		storage, _ := openStorage(t)
+
+		err := storage.Update(func(tx contracts.StorageTx) error {
+			bucket, err := tx.CreateBucketIfNotExists([]byte("bucket"))
+			assert.NoError(t, err)
+			assert.NoError(t, bucket.Put([]byte("kept"), []byte("value")))
+			assert.NoError(t, bucket.Put([]byte("deleted"), []byte("value")))
+			_, err = bucket.CreateBucketIfNotExists([]byte("nested"))
+			assert.NoError(t, err)
+			_, err = bucket.NextSequence()
+			return err
+		})
+		assert.NoError(t, err)
+
+		updateErr := errors.New("update error")
+		changeAll := func(tx contracts.StorageTx) {
+			bucket := tx.Bucket([]byte("bucket"))
+			assert.NoError(t, bucket.Put([]byte("kept"), []byte("changed")))
+			assert.NoError(t, bucket.Put([]byte("added"), []byte("value")))
+			assert.NoError(t, bucket.Delete([]byte("deleted")))
+			assert.NoError(t, bucket.DeleteBucket([]byte("nested")))
+			_, err := bucket.CreateBucketIfNotExists([]byte("created"))
+			assert.NoError(t, err)
+			_, err = tx.CreateBucketIfNotExists([]byte("created"))
+			assert.NoError(t, err)
+			sequence, err := bucket.NextSequence()
+			assert.NoError(t, err)
+			assert.Equal(t, uint64(2), sequence)
+		}
+
+		err = storage.Update(func(tx contracts.StorageTx) error {
+			changeAll(tx)
+			return updateErr
+		})
+		assert.ErrorIs(t, err, updateErr)
+
+		assert.Panics(t, func() {
+			_ = storage.Update(func(tx contracts.StorageTx) error {
+				changeAll(tx)
+				panic("update panic")
+			})
+		})
+
+		err = storage.Update(func(tx contracts.StorageTx) error {
+			assert.Nil(t, tx.Bucket([]byte("created")))
+
+			bucket := tx.Bucket([]byte("bucket"))
+			assert.Equal(t, []byte("value"), bucket.Get([]byte("kept")))
+			assert.Equal(t, []byte("value"), bucket.Get([]byte("deleted")))
+			assert.Nil(t, bucket.Get([]byte("added")))
+			assert.NotNil(t, bucket.Bucket([]byte("nested")))
+			assert.Nil(t, bucket.Bucket([]byte("created")))
+
+			sequence, err := bucket.NextSequence()
+			assert.NoError(t, err)
+			assert.Equal(t, uint64(2), sequence)
+			return nil
+		})
+		assert.NoError(t, err)
+	})
+
+	t.Run("view_is_read_only", func(t *testing.T) {
+		storage, _ := openStorage(t)
+
+		err := storage.Update(func(tx contracts.StorageTx) error {
+			_, err := tx.CreateBucketIfNotExists([]byte("bucket"))
+			return err
+		})
+		assert.NoError(t, err)
+
+		viewErr := errors.New("view error")
+		err = storage.View(func(tx contracts.StorageTx) error {
+			bucket := tx.Bucket([]byte("bucket"))
+			assert.ErrorIs(t, bucket.Put([]byte("key"), []byte("value")), contracts.StorageTxNotWritableError)
+			assert.ErrorIs(t, bucket.Delete([]byte("key")), contracts.StorageTxNotWritableError)
+			_, err := bucket.CreateBucketIfNotExists([]byte("nested"))
+			assert.ErrorIs(t, err, contracts.StorageTxNotWritableError)
+			_, err = tx.CreateBucketIfNotExists([]byte("other"))
+			assert.ErrorIs(t, err, contracts.StorageTxNotWritableError)
+			assert.ErrorIs(t, tx.DeleteBucket([]byte("bucket")), contracts.StorageTxNotWritableError)
+			_, err = bucket.NextSequence()
+			assert.ErrorIs(t, err, contracts.StorageTxNotWritableError)
+			return viewErr
+		})
+		assert.ErrorIs(t, err, viewErr)
+	})
+
+	t.Run("concurrent_updates", func(t *testing.T) {
+		storage, _ := openStorage(t)
+
+		const workers = 20
+		counterKey := []byte("counter")
+		wg := sync.WaitGroup{}
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+
+				update := storage.Update
+				if i%2 == 0 {
+					update = storage.Batch
+				}
+				err := update(func(tx contracts.StorageTx) error {
+					bucket, err := tx.CreateBucketIfNotExists([]byte("bucket"))
+					if err != nil {
+						return err
+					}
+
+					counter := 0
+					if value := bucket.Get(counterKey); value != nil {
+						counter = int(value[0])
+					}
+					return bucket.Put(counterKey, []byte{byte(counter + 1)})
+				})
+				assert.NoError(t, err)
+
+				assert.NoError(t, storage.View(func(tx contracts.StorageTx) error {
+					assert.NotNil(t, tx.Bucket([]byte("bucket")).Get(counterKey))
+					return nil
+				}))
+			}(i)
+		}
+		wg.Wait()
+
+		err := storage.View(func(tx contracts.StorageTx) error {
+			assert.Equal(t, []byte{workers}, tx.Bucket([]byte("bucket")).Get(counterKey))
+			return nil
+		})
+		assert.NoError(t, err)
+	})
+
+	t.Run("close", func(t *testing.T) {
+		storage, path := openStorage(t)
+
+		err := storage.Update(func(tx contracts.StorageTx) error {
+			bucket, err := tx.CreateBucketIfNotExists([]byte("bucket"))
+			assert.NoError(t, err)
+			nested, err := bucket.CreateBucketIfNotExists([]byte("nested"))
+			assert.NoError(t, err)
+			_, err = nested.NextSequence()
+			assert.NoError(t, err)
+			return nested.Put([]byte("key"), []byte("value"))
+		})
+		assert.NoError(t, err)
+		assert.NoError(t, storage.Close())
+
+		err = storage.View(func(tx contracts.StorageTx) error {
+			return nil
+		})
+		assert.ErrorIs(t, err, contracts.StorageClosedError)
+
+		if !backend.persistent {
+			return
+		}
+
+		// data is restored after reopen
+		storage, err = backend.open(path)
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer storage.Close()
+
+		err = storage.Update(func(tx contracts.StorageTx) error {
+			nested := tx.Bucket([]byte("bucket")).Bucket([]byte("nested"))
+			assert.Equal(t, []byte("value"), nested.Get([]byte("key")))
+
+			sequence, err := nested.NextSequence()
+			assert.NoError(t, err)
+			assert.Equal(t, uint64(2), sequence)
+			return nil
+		})
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"devChallengeExcel/contracts"
+	"fmt"
+	json "github.com/bytedance/sonic"
+)
+
+const DefaultUndoStackSize = 100
+
+// UndoStackStorage keeps undo and redo stacks of direct writes of cells per sheet.
+// Single bucket with nested bucket per sheet, which has nested undo and redo buckets. Key of the entry is zero padded hex
+// sequence number of the sheet bucket, so the last key is the top of the stack
+type UndoStackStorage struct {
+	// size max number of entries in undo stack of the sheet, the oldest ones are removed. Zero disables undo
+	size int
+}
+
+// undoEntry single write of the cell: serialized data of the cell before and after the write (nil when there is no cell)
+// with the cells which it depends on
+type undoEntry struct {
+	CanonicalKey    string   `json:"canonical_key"`
+	Before          []byte   `json:"before"`
+	BeforeDependsOn []string `json:"before_depends_on"`
+	After           []byte   `json:"after"`
+	AfterDependsOn  []string `json:"after_depends_on"`
+}
+
+var undoBucketId = []byte("__undo")
+var undoStackId = []byte("undo")
+var redoStackId = []byte("redo")
+
+// Push adds new write to undo stack and clears redo stack: redone writes would overwrite the new one
+func (s *UndoStackStorage) Push(tx contracts.StorageTx, sheetId []byte, entry *undoEntry) error {
+	if s.size <= 0 {
+		return nil
+	}
+
+	sheetBucket, err := s.createSheetBucket(tx, sheetId)
+	if err != nil {
+		return err
+	}
+
+	err = ignoreBucketNotFound(sheetBucket.DeleteBucket(redoStackId))
+	if err == nil {
+		err = s.put(sheetBucket, undoStackId, entry)
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.prune(sheetBucket.Bucket(undoStackId))
+}
+
+// PopUndo removes the last write from undo stack and moves it to redo stack. Returns nil when the stack is empty
+func (s *UndoStackStorage) PopUndo(tx contracts.StorageTx, sheetId []byte) (*undoEntry, error) {
+	return s.move(tx, sheetId, undoStackId, redoStackId)
+}
+
+// PopRedo removes the last undone write from redo stack and moves it back to undo stack. Returns nil when the stack is empty
+func (s *UndoStackStorage) PopRedo(tx contracts.StorageTx, sheetId []byte) (*undoEntry, error) {
+	return s.move(tx, sheetId, redoStackId, undoStackId)
+}
+
+// DeleteSheet removes both stacks of the sheet
+func (s *UndoStackStorage) DeleteSheet(tx contracts.StorageTx, sheetId []byte) error {
+	bucket := tx.Bucket(undoBucketId)
+	if bucket == nil {
+		return nil
+	}
+
+	return ignoreBucketNotFound(bucket.DeleteBucket(sheetId))
+}
+
+func (s *UndoStackStorage) move(tx contracts.StorageTx, sheetId []byte, fromStackId []byte, toStackId []byte) (*undoEntry, error) {
+	bucket := tx.Bucket(undoBucketId)
+	if bucket == nil {
+		return nil, nil
+	}
+	sheetBucket := bucket.Bucket(sheetId)
+	if sheetBucket == nil {
+		return nil, nil
+	}
+	from := sheetBucket.Bucket(fromStackId)
+	if from == nil {
+		return nil, nil
+	}
+
+	key, data := from.Cursor().Last()
+	if key == nil {
+		return nil, nil
+	}
+
+	entry := &undoEntry{}
+	if err := json.Unmarshal(data, entry); err != nil {
+		return nil, err
+	}
+
+	err := from.Delete(key)
+	if err == nil {
+		err = s.put(sheetBucket, toStackId, entry)
+	}
+
+	return entry, err
+}
+
+func (s *UndoStackStorage) put(sheetBucket contracts.StorageBucket, stackId []byte, entry *undoEntry) error {
+	stack, err := sheetBucket.CreateBucketIfNotExists(stackId)
+	if err != nil {
+		return err
+	}
+
+	// sequence of the sheet bucket, so moved entries stay on top of the other stack
+	sequence, err := sheetBucket.NextSequence()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	return stack.Put([]byte(fmt.Sprintf("%016x", sequence)), data)
+}
+
+// prune removes the oldest entries beyond the size of the stack
+func (s *UndoStackStorage) prune(stack contracts.StorageBucket) error {
+	keys := make([][]byte, 0, s.size+1)
+	_ = stack.ForEach(func(key []byte, value []byte) error {
+		keys = append(keys, key)
+		return nil
+	})
+
+	for i := 0; i < len(keys)-s.size; i++ {
+		if err := stack.Delete(keys[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *UndoStackStorage) createSheetBucket(tx contracts.StorageTx, sheetId []byte) (contracts.StorageBucket, error) {
+	bucket, err := tx.CreateBucketIfNotExists(undoBucketId)
+	if err != nil {
+		return nil, err
+	}
+
+	return bucket.CreateBucketIfNotExists(sheetId)
+}
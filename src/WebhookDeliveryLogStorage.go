@@ -0,0 +1,147 @@
+package main
+
+import (
+	"devChallengeExcel/contracts"
+	"fmt"
+	json "github.com/bytedance/sonic"
+	"slices"
+)
+
+const DefaultWebhookDeliveryLogSize = 100
+
+// WebhookDeliveryLogStorage keeps recent delivery attempts of each subscription.
+// Single bucket with nested bucket per sheet and per subscription, key is zero padded hex sequence of the attempt,
+// so attempts are ordered by time. Only the last `size` attempts of the subscription are kept
+type WebhookDeliveryLogStorage struct {
+	size int
+}
+
+var webhookDeliveriesBucketId = []byte("__webhook_deliveries")
+
+// Add stores the attempt and removes the oldest ones beyond the size of the log
+func (s *WebhookDeliveryLogStorage) Add(tx contracts.StorageTx, sheetId []byte, attempt *contracts.WebhookDeliveryAttempt) error {
+	if s.size <= 0 {
+		return nil
+	}
+
+	bucket, err := tx.CreateBucketIfNotExists(webhookDeliveriesBucketId)
+	if err == nil {
+		bucket, err = bucket.CreateBucketIfNotExists(sheetId)
+	}
+	if err == nil {
+		bucket, err = bucket.CreateBucketIfNotExists([]byte(attempt.SubscriptionId))
+	}
+	if err != nil {
+		return err
+	}
+
+	sequence, err := bucket.NextSequence()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(attempt)
+	if err != nil {
+		return err
+	}
+
+	if err = bucket.Put(makeDeliveryAttemptKey(sequence), data); err != nil {
+		return err
+	}
+
+	if sequence <= uint64(s.size) {
+		return nil
+	}
+
+	// cursor is not used for deletion: deleting with cursor skips the next key
+	oldest := makeDeliveryAttemptKey(sequence - uint64(s.size))
+	expired := make([][]byte, 0)
+	cursor := bucket.Cursor()
+	for key, _ := cursor.First(); key != nil && string(key) <= string(oldest); key, _ = cursor.Next() {
+		expired = append(expired, key)
+	}
+	for _, key := range expired {
+		if err = bucket.Delete(key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Get returns up to limit recent attempts of the subscription, newest first
+func (s *WebhookDeliveryLogStorage) Get(tx contracts.StorageTx, sheetId []byte, subscriptionId string, limit int) []contracts.WebhookDeliveryAttempt {
+	attempts := make([]contracts.WebhookDeliveryAttempt, 0)
+
+	bucket := s.getSheetBucket(tx, sheetId)
+	if bucket != nil {
+		bucket = bucket.Bucket([]byte(subscriptionId))
+	}
+	if bucket == nil {
+		return attempts
+	}
+
+	cursor := bucket.Cursor()
+	for key, data := cursor.Last(); key != nil && len(attempts) < limit; key, data = cursor.Prev() {
+		attempt := contracts.WebhookDeliveryAttempt{}
+		if json.Unmarshal(data, &attempt) == nil {
+			attempts = append(attempts, attempt)
+		}
+	}
+
+	return attempts
+}
+
+// GetSheet returns up to limit recent attempts of all subscriptions of the sheet, newest first
+func (s *WebhookDeliveryLogStorage) GetSheet(tx contracts.StorageTx, sheetId []byte, limit int) []contracts.WebhookDeliveryAttempt {
+	attempts := make([]contracts.WebhookDeliveryAttempt, 0)
+
+	bucket := s.getSheetBucket(tx, sheetId)
+	if bucket == nil {
+		return attempts
+	}
+
+	_ = bucket.ForEachBucket(func(subscriptionId []byte) error {
+		attempts = append(attempts, s.Get(tx, sheetId, string(subscriptionId), limit)...)
+		return nil
+	})
+
+	slices.SortStableFunc(attempts, func(a, b contracts.WebhookDeliveryAttempt) int {
+		return b.AttemptedAt.Compare(a.AttemptedAt)
+	})
+
+	return attempts[:min(limit, len(attempts))]
+}
+
+// Delete removes log of the subscription
+func (s *WebhookDeliveryLogStorage) Delete(tx contracts.StorageTx, sheetId []byte, subscriptionId string) error {
+	bucket := s.getSheetBucket(tx, sheetId)
+	if bucket == nil {
+		return nil
+	}
+
+	return ignoreBucketNotFound(bucket.DeleteBucket([]byte(subscriptionId)))
+}
+
+// DeleteSheet removes logs of all subscriptions of the sheet
+func (s *WebhookDeliveryLogStorage) DeleteSheet(tx contracts.StorageTx, sheetId []byte) error {
+	bucket := tx.Bucket(webhookDeliveriesBucketId)
+	if bucket == nil {
+		return nil
+	}
+
+	return ignoreBucketNotFound(bucket.DeleteBucket(sheetId))
+}
+
+func (s *WebhookDeliveryLogStorage) getSheetBucket(tx contracts.StorageTx, sheetId []byte) contracts.StorageBucket {
+	bucket := tx.Bucket(webhookDeliveriesBucketId)
+	if bucket == nil {
+		return nil
+	}
+
+	return bucket.Bucket(sheetId)
+}
+
+func makeDeliveryAttemptKey(sequence uint64) []byte {
+	return []byte(fmt.Sprintf("%016x", sequence))
+}
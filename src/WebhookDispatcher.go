@@ -2,107 +2,1144 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
 	"devChallengeExcel/contracts"
+	"encoding/hex"
 	"fmt"
 	json "github.com/bytedance/sonic"
 	"net/http"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 )
 
 const WebhookWorkersCount = 5
 
-type SheetWebhooks map[string]string
+// WebhookOutboxPollInterval max delay between checks of outbox for due webhooks
+const WebhookOutboxPollInterval = time.Second
 
-type WebhookSendCommand struct {
-	Webhook string
-	Cell    *contracts.Cell
+// webhookDrainPollInterval how often Shutdown checks that due webhooks are sent
+const webhookDrainPollInterval = 10 * time.Millisecond
+
+const (
+	DefaultWebhookMaxAttempts     = 8
+	DefaultWebhookRetryBackoff    = time.Second
+	DefaultWebhookMaxRetryBackoff = 10 * time.Minute
+)
+
+type WebhookRetryConfig struct {
+	// MaxAttempts of webhook delivery (first attempt + retries), then webhook is moved to dead letters
+	MaxAttempts int
+	// RetryBackoff delay before the first retry. It is doubled with each retry
+	RetryBackoff time.Duration
+	// MaxRetryBackoff max delay between retries
+	MaxRetryBackoff time.Duration
 }
 
+// CellWebhooks subscriptions of the cell (key is subscription id)
+type CellWebhooks map[string]*contracts.WebhookSubscription
+
+// SheetWebhooks subscriptions of cells of the sheet (key is canonical cell id).
+// Subscriptions of the sheet itself are under sheetScopeKey
+type SheetWebhooks map[string]CellWebhooks
+
+// sheetScopeKey key of sheet subscriptions in SheetWebhooks, cell ids are never empty
+const sheetScopeKey = ""
+
+// sequencedSubscription copy of subscription with the next sequence, it replaces cached one when outbox entry is stored
+type sequencedSubscription struct {
+	cellId       string
+	subscription contracts.WebhookSubscription
+}
+
+// pendingWebhookKey debounced subscription: canonical cell id (sheetScopeKey for sheet subscriptions) and subscription id
+type pendingWebhookKey struct {
+	sheetId        string
+	cellId         string
+	subscriptionId string
+}
+
+// pendingWebhook changes of debounced subscription which are collected during its window,
+// repeated changes of the same cell are coalesced into one (see coalesce)
+type pendingWebhook struct {
+	changes []contracts.CellChange
+	// indexes of changes by canonical cell id
+	indexes map[string]int
+	origin  contracts.ChangeOrigin
+	timer   *time.Timer
+}
+
+// WebhookDispatcher sends changed cells to their webhooks.
+// Subscriptions are stored in database (see WebhookStorage) and cached in memory.
+// Webhooks are stored in outbox (see WebhookOutbox) and retried with exponential backoff until they are delivered
 type WebhookDispatcher struct {
-	queue    chan WebhookSendCommand
-	webhooks map[string]SheetWebhooks
+	queue    chan contracts.WebhookOutboxEntry
+	wake     chan struct{}
+	stop     chan struct{}
+	stopOnce sync.Once
+	// workers and scheduler, Close waits for them
+	workers      sync.WaitGroup
+	db           contracts.Storage
+	storage      WebhookStorage
+	outbox       WebhookOutbox
+	deliveryLog  WebhookDeliveryLogStorage
+	egressPolicy contracts.EgressPolicy
+	signer       contracts.WebhookSigner
+	executor     contracts.ExpressionExecutor
+	retry        WebhookRetryConfig
+
+	mutex sync.RWMutex
+	// storeMutex serializes writes of subscriptions to database. It is locked while mutex is held, then mutex may be
+	// released before the write (see storeAndUnlock), so readers of subscriptions don't wait for the database
+	storeMutex sync.Mutex
+	closed     bool
+	webhooks   map[string]SheetWebhooks
+	// pending webhooks of debounced subscriptions, guarded by mutex
+	pending map[pendingWebhookKey]*pendingWebhook
+
+	// inFlight ids of outbox entries which are being sent
+	inFlightMutex sync.Mutex
+	inFlight      map[string]bool
 }
 
-func NewWebhookDispatcher() *WebhookDispatcher {
+func NewWebhookDispatcher(
+	db contracts.Storage, egressPolicy contracts.EgressPolicy, signer contracts.WebhookSigner, executor contracts.ExpressionExecutor,
+	retry WebhookRetryConfig, deliveryLogSize int,
+) *WebhookDispatcher {
 	return &WebhookDispatcher{
-		queue:    make(chan WebhookSendCommand, 20),
-		webhooks: map[string]SheetWebhooks{},
+		queue:        make(chan contracts.WebhookOutboxEntry),
+		wake:         make(chan struct{}, 1),
+		stop:         make(chan struct{}),
+		db:           db,
+		webhooks:     map[string]SheetWebhooks{},
+		pending:      map[pendingWebhookKey]*pendingWebhook{},
+		inFlight:     map[string]bool{},
+		egressPolicy: egressPolicy,
+		signer:       signer,
+		executor:     executor,
+		retry:        retry,
+		deliveryLog:  WebhookDeliveryLogStorage{size: deliveryLogSize},
 	}
 }
 
-func (manager *WebhookDispatcher) SetWebhookUrl(canonicalSheetId string, canonicalCellId string, webhookUrl string) {
-	if _, ok := manager.webhooks[canonicalSheetId]; !ok {
-		manager.webhooks[canonicalSheetId] = SheetWebhooks{}
+// Load restores stored subscriptions, e.g. after restart. Outbox stored without index is indexed
+func (manager *WebhookDispatcher) Load() error {
+	err := manager.db.Update(manager.outbox.RebuildIndex)
+	if err != nil {
+		return err
 	}
 
-	if webhookUrl == "" {
-		delete(manager.webhooks[canonicalSheetId], canonicalCellId)
+	return manager.db.View(func(tx contracts.StorageTx) error {
+		webhooks := manager.storage.GetAll(tx)
+
+		manager.mutex.Lock()
+		manager.webhooks = webhooks
+		manager.mutex.Unlock()
+
+		return nil
+	})
+}
+
+func (manager *WebhookDispatcher) Subscribe(
+	canonicalSheetId string, canonicalCellId string, webhookUrl string, description string, options contracts.WebhookOptions,
+) (*contracts.WebhookSubscription, error) {
+	return manager.subscribe(canonicalSheetId, canonicalCellId, webhookUrl, description, contracts.WebhookScope{}, options)
+}
+
+func (manager *WebhookDispatcher) SubscribeSheet(
+	canonicalSheetId string, webhookUrl string, description string, scope contracts.WebhookScope, options contracts.WebhookOptions,
+) (*contracts.WebhookSubscription, error) {
+	return manager.subscribe(canonicalSheetId, sheetScopeKey, webhookUrl, description, scope, options)
+}
+
+func (manager *WebhookDispatcher) GetSheetSubscriptions(canonicalSheetId string) []contracts.WebhookSubscription {
+	return manager.GetSubscriptions(canonicalSheetId, sheetScopeKey)
+}
+
+func (manager *WebhookDispatcher) UnsubscribeSheet(canonicalSheetId string, subscriptionId string) error {
+	return manager.Unsubscribe(canonicalSheetId, sheetScopeKey, subscriptionId)
+}
+
+func (manager *WebhookDispatcher) UnsubscribeSheetUrl(canonicalSheetId string, webhookUrl string) error {
+	return manager.UnsubscribeUrl(canonicalSheetId, sheetScopeKey, webhookUrl)
+}
+
+func (manager *WebhookDispatcher) subscribe(
+	canonicalSheetId string, canonicalCellId string, webhookUrl string, description string,
+	scope contracts.WebhookScope, options contracts.WebhookOptions,
+) (*contracts.WebhookSubscription, error) {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+
+	var subscription contracts.WebhookSubscription
+	if existing := manager.findByUrl(canonicalSheetId, canonicalCellId, webhookUrl); existing != nil {
+		subscription = *existing
+		if description != "" {
+			subscription.Description = description
+		}
 	} else {
-		manager.webhooks[canonicalSheetId][canonicalCellId] = webhookUrl
+		subscription = contracts.WebhookSubscription{
+			Id:          newWebhookSubscriptionId(),
+			WebhookUrl:  webhookUrl,
+			Description: description,
+			CreatedAt:   time.Now().UTC(),
+		}
+	}
+	subscription.WebhookScope = scope
+	subscription.WebhookOptions = options
+
+	err := manager.put(canonicalSheetId, canonicalCellId, &subscription)
+	if err != nil {
+		return nil, err
 	}
+
+	result := subscription
+	return &result, nil
 }
 
-func (manager *WebhookDispatcher) GetWebhookUrl(canonicalSheetId string, canonicalCellId string) string {
-	if _, ok := manager.webhooks[canonicalSheetId]; !ok {
-		return ""
+func (manager *WebhookDispatcher) GetSubscriptions(canonicalSheetId string, canonicalCellId string) []contracts.WebhookSubscription {
+	manager.mutex.RLock()
+	defer manager.mutex.RUnlock()
+
+	return manager.getSortedSubscriptions(canonicalSheetId, canonicalCellId)
+}
+
+// getSortedSubscriptions copies subscriptions of the cell ordered by creation time, the caller holds the mutex
+func (manager *WebhookDispatcher) getSortedSubscriptions(canonicalSheetId string, canonicalCellId string) []contracts.WebhookSubscription {
+	subscriptions := make([]contracts.WebhookSubscription, 0, len(manager.webhooks[canonicalSheetId][canonicalCellId]))
+	for _, subscription := range manager.webhooks[canonicalSheetId][canonicalCellId] {
+		subscriptions = append(subscriptions, *subscription)
+	}
+
+	slices.SortFunc(subscriptions, func(a, b contracts.WebhookSubscription) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Id, b.Id)
+	})
+
+	return subscriptions
+}
+
+func (manager *WebhookDispatcher) Unsubscribe(canonicalSheetId string, canonicalCellId string, subscriptionId string) error {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+
+	if _, ok := manager.webhooks[canonicalSheetId][canonicalCellId][subscriptionId]; !ok {
+		return fmt.Errorf("%s: %w", subscriptionId, contracts.WebhookSubscriptionNotFoundError)
+	}
+
+	return manager.delete(canonicalSheetId, canonicalCellId, []string{subscriptionId})
+}
+
+func (manager *WebhookDispatcher) UnsubscribeUrl(canonicalSheetId string, canonicalCellId string, webhookUrl string) error {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+
+	subscriptionIds := make([]string, 0)
+	for subscriptionId, subscription := range manager.webhooks[canonicalSheetId][canonicalCellId] {
+		if subscription.WebhookUrl == webhookUrl {
+			subscriptionIds = append(subscriptionIds, subscriptionId)
+		}
 	}
 
-	if webhook, ok := manager.webhooks[canonicalSheetId][canonicalCellId]; ok {
-		return webhook
+	if len(subscriptionIds) == 0 {
+		return fmt.Errorf("%s: %w", webhookUrl, contracts.WebhookSubscriptionNotFoundError)
 	}
 
-	return ""
+	return manager.delete(canonicalSheetId, canonicalCellId, subscriptionIds)
 }
 
-func (manager *WebhookDispatcher) Notify(canonicalSheetId string, cells []*contracts.Cell) {
-	if _, ok := manager.webhooks[canonicalSheetId]; !ok {
+// DeleteWebhooks removes subscriptions of deleted cell
+func (manager *WebhookDispatcher) DeleteWebhooks(canonicalSheetId string, canonicalCellId string) error {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	manager.storeMutex.Lock()
+	defer manager.storeMutex.Unlock()
+
+	err := manager.db.Update(func(tx contracts.StorageTx) error {
+		for subscriptionId := range manager.webhooks[canonicalSheetId][canonicalCellId] {
+			if err := manager.deliveryLog.Delete(tx, []byte(canonicalSheetId), subscriptionId); err != nil {
+				return err
+			}
+		}
+
+		return manager.storage.DeleteCell(tx, []byte(canonicalSheetId), []byte(canonicalCellId))
+	})
+	if err != nil {
+		return err
+	}
+
+	delete(manager.webhooks[canonicalSheetId], canonicalCellId)
+
+	return nil
+}
+
+// DeleteSheetWebhooks removes subscriptions of deleted sheet and all its cells
+func (manager *WebhookDispatcher) DeleteSheetWebhooks(canonicalSheetId string) error {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	manager.storeMutex.Lock()
+	defer manager.storeMutex.Unlock()
+
+	err := manager.db.Update(func(tx contracts.StorageTx) error {
+		if err := manager.deliveryLog.DeleteSheet(tx, []byte(canonicalSheetId)); err != nil {
+			return err
+		}
+
+		return manager.storage.DeleteSheet(tx, []byte(canonicalSheetId))
+	})
+	if err != nil {
+		return err
+	}
+
+	delete(manager.webhooks, canonicalSheetId)
+
+	return nil
+}
+
+// Notify stores webhooks of changed cells in outbox. Each subscription numbers its webhooks (sequence in payload),
+// webhooks of one subscription are delivered one by one in this order.
+// Changes for debounced subscriptions are collected during their window and stored in outbox when it ends
+func (manager *WebhookDispatcher) Notify(canonicalSheetId string, changes []contracts.CellChange, origin contracts.ChangeOrigin) {
+	now := time.Now().UTC()
+
+	// write lock: sequences of subscriptions are assigned in order of outbox entries
+	manager.mutex.Lock()
+
+	if manager.closed {
+		manager.mutex.Unlock()
+		logger.Printf("Webhooks of sheet %s are not stored: dispatcher is closed", canonicalSheetId)
 		return
 	}
 
-	go manager.addToQueue(canonicalSheetId, cells)
+	sheetWebhooks, ok := manager.webhooks[canonicalSheetId]
+	if !ok {
+		manager.mutex.Unlock()
+		return
+	}
+
+	sequenced := make([]sequencedSubscription, 0)
+	entries := make([]contracts.WebhookOutboxEntry, 0)
+	for _, change := range changes {
+		// the same event (and event id) is sent to all subscribers of the cell
+		var event *contracts.WebhookEvent
+		for _, subscription := range sheetWebhooks[change.Cell.CanonicalKey] {
+			// debounced changes are checked when the window ends, so a change and its revert are coalesced first
+			if subscription.Debounce() > 0 {
+				manager.debounce(canonicalSheetId, change.Cell.CanonicalKey, subscription, []contracts.CellChange{change}, origin)
+				continue
+			}
+
+			if !manager.shouldNotify(subscription, change) {
+				continue
+			}
+
+			if event == nil {
+				cellEvent := newWebhookEvent(canonicalSheetId, change, now)
+				event = &cellEvent
+			}
+			next := nextSubscriptionSequence(change.Cell.CanonicalKey, subscription)
+			sequenced = append(sequenced, next)
+			event.Sequence = next.subscription.Sequence
+			payload, _ := json.Marshal(event)
+			entries = append(entries, newWebhookOutboxEntry(canonicalSheetId, change.Cell.CanonicalKey, subscription, payload, origin, now))
+		}
+	}
+
+	for _, subscription := range sheetWebhooks[sheetScopeKey] {
+		scopeChanges := manager.getScopeChanges(subscription, changes)
+		if len(scopeChanges) != 0 && subscription.Debounce() > 0 {
+			manager.debounce(canonicalSheetId, sheetScopeKey, subscription, scopeChanges, origin)
+			continue
+		}
+
+		scopeChanges = manager.filterNotifiedChanges(subscription, scopeChanges)
+		if len(scopeChanges) == 0 {
+			continue
+		}
+
+		next := nextSubscriptionSequence(sheetScopeKey, subscription)
+		sequenced = append(sequenced, next)
+		event := newWebhookBatchEvent(canonicalSheetId, scopeChanges, now)
+		event.Sequence = next.subscription.Sequence
+		payload, _ := json.Marshal(event)
+		entries = append(entries, newWebhookOutboxEntry(canonicalSheetId, sheetScopeKey, subscription, payload, origin, now))
+	}
+
+	manager.storeAndUnlock(canonicalSheetId, entries, sequenced)
 }
 
-func (manager *WebhookDispatcher) addToQueue(canonicalSheetId string, cells []*contracts.Cell) {
-	var ok bool
-	if _, ok = manager.webhooks[canonicalSheetId]; ok {
-		var webhook string
-		for _, cell := range cells {
-			if webhook, ok = manager.webhooks[canonicalSheetId][cell.CanonicalKey]; ok {
-				manager.queue <- WebhookSendCommand{
-					Webhook: webhook,
-					Cell:    cell,
-				}
+// storeAndUnlock adds entries to outbox and saves sequences of their subscriptions. The caller holds write lock,
+// it is released after the sequences are cached and storeMutex is locked: the database is written without blocking
+// readers, while outbox entries are still stored in order of sequences. When the write fails, the sequences are skipped
+func (manager *WebhookDispatcher) storeAndUnlock(
+	canonicalSheetId string, entries []contracts.WebhookOutboxEntry, sequenced []sequencedSubscription,
+) {
+	if len(entries) == 0 {
+		manager.mutex.Unlock()
+		return
+	}
+
+	sheetWebhooks := manager.webhooks[canonicalSheetId]
+	for i := range sequenced {
+		cached := sequenced[i].subscription
+		sheetWebhooks[sequenced[i].cellId][cached.Id] = &cached
+	}
+
+	manager.storeMutex.Lock()
+	defer manager.storeMutex.Unlock()
+	manager.mutex.Unlock()
+
+	err := manager.db.Update(func(tx contracts.StorageTx) (err error) {
+		for i := range entries {
+			err = manager.outbox.Add(tx, &entries[i])
+			if err != nil {
+				return
+			}
+		}
+		for i := range sequenced {
+			err = manager.storage.Put(tx, []byte(canonicalSheetId), []byte(sequenced[i].cellId), &sequenced[i].subscription)
+			if err != nil {
+				return
 			}
 		}
+		return
+	})
+	if err != nil {
+		logger.Printf("Webhooks are not stored in outbox: %s", err)
+		return
+	}
+
+	manager.wakeUp()
+}
+
+// debounce collects changes of the subscription until its window (started by the first change) ends,
+// then they are sent as one webhook. The caller holds write lock
+func (manager *WebhookDispatcher) debounce(
+	canonicalSheetId string, canonicalCellId string, subscription *contracts.WebhookSubscription,
+	changes []contracts.CellChange, origin contracts.ChangeOrigin,
+) {
+	key := pendingWebhookKey{sheetId: canonicalSheetId, cellId: canonicalCellId, subscriptionId: subscription.Id}
+
+	pending, ok := manager.pending[key]
+	if !ok {
+		pending = &pendingWebhook{indexes: map[string]int{}}
+		pending.timer = time.AfterFunc(subscription.Debounce(), func() {
+			manager.flushPending(key)
+		})
+		manager.pending[key] = pending
+	}
+
+	pending.coalesce(changes)
+	pending.origin = origin
+}
+
+// coalesce replaces collected change of the same cell: it has the latest state and previous state before the window
+func (pending *pendingWebhook) coalesce(changes []contracts.CellChange) {
+	for _, change := range changes {
+		i, ok := pending.indexes[change.Cell.CanonicalKey]
+		if !ok {
+			pending.indexes[change.Cell.CanonicalKey] = len(pending.changes)
+			pending.changes = append(pending.changes, change)
+			continue
+		}
+
+		change.Previous = pending.changes[i].Previous
+		pending.changes[i] = change
 	}
 }
 
+// flushPending stores collected changes of debounced subscription in outbox, unless it is removed meanwhile.
+// Coalesced changes are checked as single ones: a change which is reverted within the window is not sent
+func (manager *WebhookDispatcher) flushPending(key pendingWebhookKey) {
+	manager.mutex.Lock()
+
+	pending, ok := manager.pending[key]
+	if ok {
+		delete(manager.pending, key)
+	}
+
+	subscription, subscribed := manager.webhooks[key.sheetId][key.cellId][key.subscriptionId]
+	if !ok || !subscribed {
+		manager.mutex.Unlock()
+		return
+	}
+
+	changes := manager.filterNotifiedChanges(subscription, pending.changes)
+	if len(changes) == 0 {
+		manager.mutex.Unlock()
+		return
+	}
+
+	now := time.Now().UTC()
+	next := nextSubscriptionSequence(key.cellId, subscription)
+
+	var payload []byte
+	if key.cellId == sheetScopeKey {
+		event := newWebhookBatchEvent(key.sheetId, changes, now)
+		event.Sequence = next.subscription.Sequence
+		payload, _ = json.Marshal(event)
+	} else {
+		event := newWebhookEvent(key.sheetId, changes[0], now)
+		event.Sequence = next.subscription.Sequence
+		payload, _ = json.Marshal(event)
+	}
+
+	manager.storeAndUnlock(
+		key.sheetId,
+		[]contracts.WebhookOutboxEntry{newWebhookOutboxEntry(key.sheetId, key.cellId, subscription, payload, pending.origin, now)},
+		[]sequencedSubscription{next},
+	)
+}
+
+// flushAllPending stores changes of debounced subscriptions without waiting for the end of their windows
+func (manager *WebhookDispatcher) flushAllPending() {
+	manager.mutex.Lock()
+	keys := make([]pendingWebhookKey, 0, len(manager.pending))
+	for key, pending := range manager.pending {
+		pending.timer.Stop()
+		keys = append(keys, key)
+	}
+	manager.mutex.Unlock()
+
+	for _, key := range keys {
+		manager.flushPending(key)
+	}
+}
+
+func (manager *WebhookDispatcher) GetDeadLetters() (deadLetters []contracts.WebhookOutboxEntry, err error) {
+	err = manager.db.View(func(tx contracts.StorageTx) error {
+		deadLetters = manager.outbox.GetDeadLetters(tx)
+		return nil
+	})
+
+	return
+}
+
+// ReplayDeadLetter adds dead letter to outbox as new entry (with new id), so it is sent after webhooks of the subscription
+// which are already pending: its original position is taken by later webhooks, and putting it back would reorder them.
+// The payload keeps original sequence, so the receiver can recognize the replayed event
+func (manager *WebhookDispatcher) ReplayDeadLetter(id string) error {
+	err := manager.db.Update(func(tx contracts.StorageTx) error {
+		entry := manager.outbox.GetDeadLetter(tx, id)
+		if entry == nil {
+			return fmt.Errorf("%s: %w", id, contracts.WebhookDeadLetterNotFoundError)
+		}
+
+		err := manager.outbox.DeleteDeadLetter(tx, id)
+		if err != nil {
+			return err
+		}
+
+		entry.Attempts = 0
+		entry.NextAttemptAt = time.Now().UTC()
+
+		return manager.outbox.Add(tx, entry)
+	})
+	if err != nil {
+		return err
+	}
+
+	manager.wakeUp()
+
+	return nil
+}
+
+func (manager *WebhookDispatcher) DeleteDeadLetter(id string) error {
+	return manager.db.Update(func(tx contracts.StorageTx) error {
+		if manager.outbox.GetDeadLetter(tx, id) == nil {
+			return fmt.Errorf("%s: %w", id, contracts.WebhookDeadLetterNotFoundError)
+		}
+
+		return manager.outbox.DeleteDeadLetter(tx, id)
+	})
+}
+
+// Start runs workers which send webhooks of outbox, including webhooks stored before restart
 func (manager *WebhookDispatcher) Start() {
+	manager.workers.Add(WebhookWorkersCount + 1)
 	for i := 0; i < WebhookWorkersCount; i++ {
-		go manager.runWebhookSenderWorker()
+		go func() {
+			defer manager.workers.Done()
+			manager.runWebhookSenderWorker()
+		}()
 	}
+	go func() {
+		defer manager.workers.Done()
+		manager.runOutboxScheduler()
+	}()
 }
 
+// Close stops accepting notifications, stops workers when their current attempts are completed
+// and stores debounced webhooks in outbox. Webhooks which are not delivered yet stay in outbox
 func (manager *WebhookDispatcher) Close() {
-	close(manager.queue)
+	manager.mutex.Lock()
+	manager.closed = true
+	manager.mutex.Unlock()
+
+	manager.stopOnce.Do(func() {
+		close(manager.stop)
+	})
+	manager.workers.Wait()
+
+	// workers are stopped before, so debounced webhooks stay in outbox until restart
+	manager.flushAllPending()
+}
+
+// Shutdown stops accepting notifications and waits until due webhooks of outbox are sent (failed ones are scheduled
+// for retry), then closes the dispatcher. When ctx is done before, the rest stays in outbox and ctx error is returned
+func (manager *WebhookDispatcher) Shutdown(ctx context.Context) error {
+	manager.mutex.Lock()
+	manager.closed = true
+	manager.mutex.Unlock()
+
+	manager.flushAllPending()
+	err := manager.drain(ctx)
+	manager.Close()
+
+	return err
+}
+
+func (manager *WebhookDispatcher) drain(ctx context.Context) error {
+	ticker := time.NewTicker(webhookDrainPollInterval)
+	defer ticker.Stop()
+
+	for !manager.isDrained(time.Now()) {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+
+	return nil
+}
+
+// isDrained checks that no webhook is being sent and the oldest webhooks of subscriptions are not due
+func (manager *WebhookDispatcher) isDrained(now time.Time) bool {
+	manager.inFlightMutex.Lock()
+	defer manager.inFlightMutex.Unlock()
+
+	if len(manager.inFlight) != 0 {
+		return false
+	}
+
+	drained := true
+	_ = manager.db.View(func(tx contracts.StorageTx) error {
+		due, _ := manager.outbox.GetDue(tx, now, func(string) bool { return false })
+		drained = len(due) == 0
+		return nil
+	})
+
+	return drained
+}
+
+// wakeUp tells scheduler to check outbox without waiting for poll interval
+func (manager *WebhookDispatcher) wakeUp() {
+	select {
+	case manager.wake <- struct{}{}:
+	default:
+	}
+}
+
+// runOutboxScheduler passes due entries of outbox to workers
+func (manager *WebhookDispatcher) runOutboxScheduler() {
+	defer close(manager.queue)
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		entries, nextAttemptAt := manager.takeDueEntries(time.Now())
+		for _, entry := range entries {
+			select {
+			case manager.queue <- entry:
+			case <-manager.stop:
+				return
+			}
+		}
+
+		delay := WebhookOutboxPollInterval
+		if !nextAttemptAt.IsZero() {
+			delay = min(delay, time.Until(nextAttemptAt))
+		}
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(delay)
+
+		select {
+		case <-manager.stop:
+			return
+		case <-manager.wake:
+		case <-timer.C:
+		}
+	}
+}
+
+// takeDueEntries returns entries of outbox to send now (they are marked as in flight)
+// and time of the next attempt of other entries (zero time when there are none)
+func (manager *WebhookDispatcher) takeDueEntries(now time.Time) (entries []contracts.WebhookOutboxEntry, nextAttemptAt time.Time) {
+	// outbox is read under the lock: delivered entry is deleted from outbox before it leaves inFlight,
+	// so it is never sent twice
+	manager.inFlightMutex.Lock()
+	defer manager.inFlightMutex.Unlock()
+
+	// FIFO per subscription: only the oldest pending entry of the subscription (its head) can be sent,
+	// the next one waits until it is delivered or moved to dead letters
+	err := manager.db.View(func(tx contracts.StorageTx) error {
+		entries, nextAttemptAt = manager.outbox.GetDue(tx, now, func(id string) bool {
+			return manager.inFlight[id]
+		})
+		return nil
+	})
+	if err != nil {
+		logger.Printf("Webhook outbox is not read: %s", err)
+		return
+	}
+
+	for _, entry := range entries {
+		manager.inFlight[entry.Id] = true
+	}
+
+	return
 }
 
 func (manager *WebhookDispatcher) runWebhookSenderWorker() {
-	client := &http.Client{
-		Timeout: time.Second * 5,
+	client := manager.egressPolicy.NewClient(time.Second * 5)
+
+	for entry := range manager.queue {
+		// subscription is removed while webhook is waiting in outbox
+		if !manager.hasSubscription(entry) {
+			manager.completeDelivery(entry, nil, false)
+			continue
+		}
+
+		startedAt := time.Now()
+		statusCode, err, retryable := manager.send(client, entry)
+		manager.recordDelivery(entry, statusCode, time.Since(startedAt), err)
+		manager.completeDelivery(entry, err, retryable)
+	}
+}
+
+// send makes single attempt of webhook delivery
+func (manager *WebhookDispatcher) send(client *http.Client, entry contracts.WebhookOutboxEntry) (statusCode int, err error, retryable bool) {
+	request, err := http.NewRequest(http.MethodPost, entry.WebhookUrl, bytes.NewBuffer(entry.Payload))
+	if err != nil {
+		return
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if len(entry.Trace) != 0 {
+		request.Header.Set(ChangeTraceHeader, formatChangeTrace(entry.Trace))
 	}
+	manager.signer.Sign(request, entry.Payload)
 
-	var response *http.Response
-	var err error
+	response, err := client.Do(request)
+	retryable = isRetryableResponse(response, err)
+	if err != nil {
+		return
+	}
+	response.Body.Close()
+
+	statusCode = response.StatusCode
+	if statusCode >= 300 {
+		err = fmt.Errorf("unexpected response status: %s", response.Status)
+	}
+
+	return
+}
+
+// completeDelivery removes delivered entry from outbox. Failed entry is scheduled for retry
+// or moved to dead letters when it is not retryable or max attempts are reached
+func (manager *WebhookDispatcher) completeDelivery(entry contracts.WebhookOutboxEntry, deliveryErr error, retryable bool) {
+	defer func() {
+		manager.inFlightMutex.Lock()
+		delete(manager.inFlight, entry.Id)
+		manager.inFlightMutex.Unlock()
+
+		manager.wakeUp()
+	}()
+
+	err := manager.db.Update(func(tx contracts.StorageTx) error {
+		if deliveryErr == nil {
+			return manager.outbox.Delete(tx, entry.Id)
+		}
 
-	for command := range manager.queue {
-		payload, _ := json.Marshal(command.Cell)
-		response, err = client.Post(command.Webhook, "application/json", bytes.NewBuffer(payload))
+		now := time.Now().UTC()
+		entry.Attempts++
+		entry.LastAttemptAt = now
+		entry.LastError = deliveryErr.Error()
 
+		if !retryable || entry.Attempts >= manager.retry.MaxAttempts {
+			return manager.outbox.MoveToDeadLetters(tx, &entry)
+		}
+
+		entry.NextAttemptAt = now.Add(manager.backoff(entry.Attempts))
+		return manager.outbox.Put(tx, &entry)
+	})
+	if err != nil {
+		logger.Printf("Webhook outbox is not updated: %s", err)
+	}
+}
+
+// backoff delay after failed attempt: RetryBackoff * 2^(attempt-1) up to MaxRetryBackoff
+func (manager *WebhookDispatcher) backoff(attempt int) time.Duration {
+	delay := manager.retry.RetryBackoff
+	for i := 1; i < attempt && delay < manager.retry.MaxRetryBackoff; i++ {
+		delay *= 2
+	}
+
+	return min(delay, manager.retry.MaxRetryBackoff)
+}
+
+func (manager *WebhookDispatcher) hasSubscription(entry contracts.WebhookOutboxEntry) bool {
+	manager.mutex.RLock()
+	defer manager.mutex.RUnlock()
+
+	_, ok := manager.webhooks[entry.SheetId][entry.CellId][entry.SubscriptionId]
+
+	return ok
+}
+
+// recordDelivery stores status of the last delivery of subscription and adds the attempt to delivery log
+func (manager *WebhookDispatcher) recordDelivery(
+	entry contracts.WebhookOutboxEntry, statusCode int, latency time.Duration, err error,
+) {
+	now := time.Now().UTC()
+	delivery := &contracts.WebhookDelivery{
+		DeliveredAt: now,
+		Success:     err == nil,
+		StatusCode:  statusCode,
+	}
+	if err != nil {
+		delivery.Error = err.Error()
+	}
+
+	payloadHash := sha256.Sum256(entry.Payload)
+	attempt := &contracts.WebhookDeliveryAttempt{
+		SubscriptionId: entry.SubscriptionId,
+		CellId:         entry.CellId,
+		WebhookId:      entry.Id,
+		Attempt:        entry.Attempts + 1,
+		WebhookUrl:     entry.WebhookUrl,
+		AttemptedAt:    now,
+		Success:        delivery.Success,
+		StatusCode:     statusCode,
+		LatencyMs:      latency.Milliseconds(),
+		Error:          delivery.Error,
+		PayloadHash:    hex.EncodeToString(payloadHash[:]),
+	}
+
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	manager.storeMutex.Lock()
+	defer manager.storeMutex.Unlock()
+
+	// subscription is removed while webhook is being sent
+	existing, ok := manager.webhooks[entry.SheetId][entry.CellId][entry.SubscriptionId]
+	if !ok {
+		return
+	}
+
+	subscription := *existing
+	delivery.SuccessStreak, delivery.FailureStreak = nextDeliveryStreak(subscription.LastDelivery, delivery.Success)
+	subscription.LastDelivery = delivery
+
+	err = manager.db.Update(func(tx contracts.StorageTx) error {
+		err := manager.storage.Put(tx, []byte(entry.SheetId), []byte(entry.CellId), &subscription)
 		if err != nil {
-			fmt.Printf("Webhook send error: %s\n", err)
-		} else if response.StatusCode >= 300 {
-			fmt.Printf("Unexpect wbhook response HTTP status: %s\n", response.Status)
+			return err
+		}
+
+		return manager.deliveryLog.Add(tx, []byte(entry.SheetId), attempt)
+	})
+	if err != nil {
+		logger.Printf("Webhook delivery status is not stored: %s", err)
+		return
+	}
+
+	manager.webhooks[entry.SheetId][entry.CellId][entry.SubscriptionId] = &subscription
+}
+
+// nextDeliveryStreak continues the streak of the last delivery when it has the same result, otherwise starts a new one
+func nextDeliveryStreak(last *contracts.WebhookDelivery, success bool) (successStreak int, failureStreak int) {
+	if last != nil && last.Success == success {
+		successStreak, failureStreak = last.SuccessStreak, last.FailureStreak
+	}
+
+	if success {
+		return successStreak + 1, 0
+	}
+
+	return 0, failureStreak + 1
+}
+
+func (manager *WebhookDispatcher) GetDeliveryLog(
+	canonicalSheetId string, canonicalCellId string, subscriptionId string, limit int,
+) (*contracts.WebhookDeliveryLog, error) {
+	manager.mutex.RLock()
+	subscription, ok := manager.webhooks[canonicalSheetId][canonicalCellId][subscriptionId]
+	var status contracts.WebhookSubscriptionStatus
+	if ok {
+		status = newWebhookSubscriptionStatus(canonicalCellId, subscription)
+	}
+	manager.mutex.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", subscriptionId, contracts.WebhookSubscriptionNotFoundError)
+	}
+
+	deliveryLog := &contracts.WebhookDeliveryLog{Subscriptions: []contracts.WebhookSubscriptionStatus{status}}
+	err := manager.db.View(func(tx contracts.StorageTx) error {
+		deliveryLog.Deliveries = manager.deliveryLog.Get(tx, []byte(canonicalSheetId), subscriptionId, limit)
+		return nil
+	})
+
+	return deliveryLog, err
+}
+
+func (manager *WebhookDispatcher) GetSheetSubscriptionDeliveryLog(
+	canonicalSheetId string, subscriptionId string, limit int,
+) (*contracts.WebhookDeliveryLog, error) {
+	return manager.GetDeliveryLog(canonicalSheetId, sheetScopeKey, subscriptionId, limit)
+}
+
+func (manager *WebhookDispatcher) GetSheetDeliveryLog(canonicalSheetId string, limit int) (*contracts.WebhookDeliveryLog, error) {
+	manager.mutex.RLock()
+	cellIds := make([]string, 0, len(manager.webhooks[canonicalSheetId]))
+	for cellId := range manager.webhooks[canonicalSheetId] {
+		cellIds = append(cellIds, cellId)
+	}
+	slices.Sort(cellIds)
+
+	// sheet subscriptions are the first ones (sheetScopeKey is empty)
+	statuses := make([]contracts.WebhookSubscriptionStatus, 0)
+	for _, cellId := range cellIds {
+		for _, subscription := range manager.getSortedSubscriptions(canonicalSheetId, cellId) {
+			statuses = append(statuses, newWebhookSubscriptionStatus(cellId, &subscription))
+		}
+	}
+	manager.mutex.RUnlock()
+
+	deliveryLog := &contracts.WebhookDeliveryLog{Subscriptions: statuses}
+	err := manager.db.View(func(tx contracts.StorageTx) error {
+		deliveryLog.Deliveries = manager.deliveryLog.GetSheet(tx, []byte(canonicalSheetId), limit)
+		return nil
+	})
+
+	return deliveryLog, err
+}
+
+func newWebhookSubscriptionStatus(canonicalCellId string, subscription *contracts.WebhookSubscription) contracts.WebhookSubscriptionStatus {
+	return contracts.WebhookSubscriptionStatus{
+		Id:           subscription.Id,
+		CellId:       canonicalCellId,
+		WebhookUrl:   subscription.WebhookUrl,
+		LastDelivery: subscription.LastDelivery,
+	}
+}
+
+// findByUrl the caller holds the mutex
+func (manager *WebhookDispatcher) findByUrl(canonicalSheetId string, canonicalCellId string, webhookUrl string) *contracts.WebhookSubscription {
+	for _, subscription := range manager.webhooks[canonicalSheetId][canonicalCellId] {
+		if subscription.WebhookUrl == webhookUrl {
+			return subscription
+		}
+	}
+
+	return nil
+}
+
+// put stores subscription in database and memory, the caller holds the mutex
+func (manager *WebhookDispatcher) put(canonicalSheetId string, canonicalCellId string, subscription *contracts.WebhookSubscription) error {
+	manager.storeMutex.Lock()
+	defer manager.storeMutex.Unlock()
+
+	err := manager.db.Update(func(tx contracts.StorageTx) error {
+		return manager.storage.Put(tx, []byte(canonicalSheetId), []byte(canonicalCellId), subscription)
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, ok := manager.webhooks[canonicalSheetId]; !ok {
+		manager.webhooks[canonicalSheetId] = SheetWebhooks{}
+	}
+	if _, ok := manager.webhooks[canonicalSheetId][canonicalCellId]; !ok {
+		manager.webhooks[canonicalSheetId][canonicalCellId] = CellWebhooks{}
+	}
+	manager.webhooks[canonicalSheetId][canonicalCellId][subscription.Id] = subscription
+
+	return nil
+}
+
+// delete removes subscriptions from database and memory, the caller holds the mutex
+func (manager *WebhookDispatcher) delete(canonicalSheetId string, canonicalCellId string, subscriptionIds []string) error {
+	manager.storeMutex.Lock()
+	defer manager.storeMutex.Unlock()
+
+	err := manager.db.Update(func(tx contracts.StorageTx) (err error) {
+		for _, subscriptionId := range subscriptionIds {
+			err = manager.storage.Delete(tx, []byte(canonicalSheetId), []byte(canonicalCellId), subscriptionId)
+			if err == nil {
+				err = manager.deliveryLog.Delete(tx, []byte(canonicalSheetId), subscriptionId)
+			}
+			if err != nil {
+				return
+			}
 		}
+		return
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, subscriptionId := range subscriptionIds {
+		delete(manager.webhooks[canonicalSheetId][canonicalCellId], subscriptionId)
+	}
+
+	return nil
+}
+
+func newWebhookOutboxEntry(
+	canonicalSheetId string, canonicalCellId string, subscription *contracts.WebhookSubscription,
+	payload []byte, origin contracts.ChangeOrigin, now time.Time,
+) contracts.WebhookOutboxEntry {
+	return contracts.WebhookOutboxEntry{
+		SheetId:        canonicalSheetId,
+		CellId:         canonicalCellId,
+		SubscriptionId: subscription.Id,
+		WebhookUrl:     subscription.WebhookUrl,
+		Payload:        payload,
+		Trace:          origin.Trace,
+		CreatedAt:      now,
+		NextAttemptAt:  now,
 	}
 }
+
+func nextSubscriptionSequence(canonicalCellId string, subscription *contracts.WebhookSubscription) sequencedSubscription {
+	next := sequencedSubscription{cellId: canonicalCellId, subscription: *subscription}
+	next.subscription.Sequence++
+
+	return next
+}
+
+// shouldNotify checks whether the change is sent to the subscriber: result is changed (unless subscription is always
+// notified) and condition of the subscription holds. Condition which can not be evaluated does not hold
+func (manager *WebhookDispatcher) shouldNotify(subscription *contracts.WebhookSubscription, change contracts.CellChange) bool {
+	if !subscription.AlwaysNotify && !change.IsResultChanged() {
+		return false
+	}
+
+	if subscription.Condition == "" || manager.executor == nil {
+		return true
+	}
+
+	var previousResult *string
+	if change.Previous != nil {
+		previousResult = &change.Previous.Result
+	}
+
+	holds, err := manager.executor.EvaluateCondition(subscription.Condition, previousResult, change.Cell.Result)
+
+	return err == nil && holds
+}
+
+// getScopeChanges returns changes of cells which are in the scope of sheet subscription
+func (manager *WebhookDispatcher) getScopeChanges(
+	subscription *contracts.WebhookSubscription, changes []contracts.CellChange,
+) []contracts.CellChange {
+	var cellRange *CellRange
+	if subscription.Range != "" {
+		var err error
+		if cellRange, err = ParseCellRange(subscription.Range); err != nil {
+			return nil
+		}
+	}
+
+	scopeChanges := make([]contracts.CellChange, 0, len(changes))
+	for _, change := range changes {
+		inScope := true
+		if cellRange != nil {
+			inScope = cellRange.Contains(change.Cell.CanonicalKey)
+		} else if len(subscription.Cells) != 0 {
+			inScope = slices.Contains(subscription.Cells, change.Cell.CanonicalKey)
+		}
+
+		if inScope {
+			scopeChanges = append(scopeChanges, change)
+		}
+	}
+
+	return scopeChanges
+}
+
+// filterNotifiedChanges returns changes which should be sent to the subscriber (see shouldNotify)
+func (manager *WebhookDispatcher) filterNotifiedChanges(
+	subscription *contracts.WebhookSubscription, changes []contracts.CellChange,
+) []contracts.CellChange {
+	notified := make([]contracts.CellChange, 0, len(changes))
+	for _, change := range changes {
+		if manager.shouldNotify(subscription, change) {
+			notified = append(notified, change)
+		}
+	}
+
+	return notified
+}
+
+func newWebhookEvent(canonicalSheetId string, change contracts.CellChange, timestamp time.Time) contracts.WebhookEvent {
+	return contracts.WebhookEvent{
+		Version:           contracts.WebhookEventVersion,
+		Id:                newRandomId(16),
+		Type:              contracts.CellChangedEventType,
+		Timestamp:         timestamp,
+		SheetId:           canonicalSheetId,
+		WebhookCellChange: newWebhookCellChange(change),
+	}
+}
+
+func newWebhookBatchEvent(canonicalSheetId string, changes []contracts.CellChange, timestamp time.Time) contracts.WebhookBatchEvent {
+	event := contracts.WebhookBatchEvent{
+		Version:   contracts.WebhookEventVersion,
+		Id:        newRandomId(16),
+		Type:      contracts.CellsChangedEventType,
+		Timestamp: timestamp,
+		SheetId:   canonicalSheetId,
+		Changes:   make([]contracts.WebhookCellChange, 0, len(changes)),
+	}
+	for _, change := range changes {
+		event.Changes = append(event.Changes, newWebhookCellChange(change))
+	}
+
+	return event
+}
+
+func newWebhookCellChange(change contracts.CellChange) contracts.WebhookCellChange {
+	return contracts.WebhookCellChange{
+		CellId:   change.CellId,
+		Cause:    change.Cause,
+		CausedBy: change.CausedBy,
+		Previous: change.Previous,
+		Current:  change.Cell,
+	}
+}
+
+func newWebhookSubscriptionId() string {
+	return newRandomId(8)
+}
+
+// newRandomId returns hex encoded random bytes
+func newRandomId(size int) string {
+	idBytes := make([]byte, size)
+	_, _ = rand.Read(idBytes)
+
+	return hex.EncodeToString(idBytes)
+}
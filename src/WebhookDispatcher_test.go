@@ -0,0 +1,1156 @@
+package main
+
+import (
+	"context"
+	"devChallengeExcel/contracts"
+	"fmt"
+	json "github.com/bytedance/sonic"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWebhookDispatcher_SignedDelivery(t *testing.T) {
+	signer := NewWebhookSigner("secret", time.Minute)
+
+	received := make(chan error, 1)
+	traces := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		traces <- r.Header.Get(ChangeTraceHeader)
+		received <- NewWebhookSigner("secret", time.Minute).Verify(r, body)
+	}))
+	defer server.Close()
+
+	db, dbClose := _createTmpDb()
+	defer dbClose()
+
+	dispatcher := NewWebhookDispatcher(db, _makeLoopbackEgressPolicy(t), signer, nil, _makeTestWebhookRetryConfig(), DefaultWebhookDeliveryLogSize)
+	dispatcher.Start()
+	defer dispatcher.Close()
+
+	_, err := dispatcher.Subscribe("sheet1", "a1", server.URL+"/webhook", "", contracts.WebhookOptions{})
+	assert.NoError(t, err)
+	origin := contracts.ChangeOrigin{Trace: []string{"remote:8080/sheet1/a1"}}
+	dispatcher.Notify("sheet1", _makeCellChanges([]*contracts.Cell{{CanonicalKey: "a1", Value: "1", Result: "1"}}), origin)
+
+	select {
+	case err := <-received:
+		assert.NoError(t, err)
+		assert.Equal(t, origin.Trace, parseChangeTrace(<-traces))
+	case <-time.After(time.Second):
+		assert.Fail(t, "webhook is not delivered")
+	}
+}
+
+func TestWebhookDispatcher_MultipleSubscriptions(t *testing.T) {
+	received := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.URL.Path
+		if r.URL.Path == "/failed" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	db, dbClose := _createTmpDb()
+	defer dbClose()
+
+	dispatcher := NewWebhookDispatcher(db, _makeLoopbackEgressPolicy(t), NewWebhookSigner("", time.Minute), nil, _makeTestWebhookRetryConfig(), DefaultWebhookDeliveryLogSize)
+	dispatcher.Start()
+	defer dispatcher.Close()
+
+	first, err := dispatcher.Subscribe("sheet1", "a1", server.URL+"/first", "first team", contracts.WebhookOptions{})
+	assert.NoError(t, err)
+	failed, err := dispatcher.Subscribe("sheet1", "a1", server.URL+"/failed", "", contracts.WebhookOptions{})
+	assert.NoError(t, err)
+	assert.NotEqual(t, first.Id, failed.Id)
+
+	dispatcher.Notify("sheet1", _makeCellChanges([]*contracts.Cell{{CanonicalKey: "a1", Value: "1", Result: "1"}}), contracts.ChangeOrigin{})
+	assert.ElementsMatch(t, []string{"/first", "/failed"}, []string{<-received, <-received})
+
+	assert.Eventually(t, func() bool {
+		subscriptions := dispatcher.GetSubscriptions("sheet1", "a1")
+		return subscriptions[0].LastDelivery != nil && subscriptions[1].LastDelivery != nil
+	}, time.Second, time.Millisecond*5)
+
+	subscriptions := dispatcher.GetSubscriptions("sheet1", "a1")
+	assert.Equal(t, first.Id, subscriptions[0].Id)
+	assert.Equal(t, "first team", subscriptions[0].Description)
+	assert.True(t, subscriptions[0].LastDelivery.Success)
+	assert.Equal(t, http.StatusOK, subscriptions[0].LastDelivery.StatusCode)
+
+	assert.Equal(t, failed.Id, subscriptions[1].Id)
+	assert.False(t, subscriptions[1].LastDelivery.Success)
+	assert.Equal(t, http.StatusInternalServerError, subscriptions[1].LastDelivery.StatusCode)
+	assert.Contains(t, subscriptions[1].LastDelivery.Error, "500")
+}
+
+func TestWebhookDispatcher_DeliveryLog(t *testing.T) {
+	var flakyRequests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/flaky" && flakyRequests.Add(1) <= 2 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	db, dbClose := _createTmpDb()
+	defer dbClose()
+
+	dispatcher := NewWebhookDispatcher(db, _makeLoopbackEgressPolicy(t), NewWebhookSigner("", time.Minute), nil, _makeTestWebhookRetryConfig(), 4)
+	dispatcher.Start()
+	defer dispatcher.Close()
+
+	flaky, err := dispatcher.Subscribe("sheet1", "a1", server.URL+"/flaky", "", contracts.WebhookOptions{})
+	assert.NoError(t, err)
+	sheet, err := dispatcher.SubscribeSheet("sheet1", server.URL+"/sheet", "", contracts.WebhookScope{}, contracts.WebhookOptions{})
+	assert.NoError(t, err)
+
+	waitForSuccessStreak := func(expected int) {
+		assert.Eventually(t, func() bool {
+			subscriptions := dispatcher.GetSubscriptions("sheet1", "a1")
+			sheetSubscriptions := dispatcher.GetSheetSubscriptions("sheet1")
+			return subscriptions[0].LastDelivery != nil && subscriptions[0].LastDelivery.SuccessStreak == expected &&
+				sheetSubscriptions[0].LastDelivery != nil && sheetSubscriptions[0].LastDelivery.SuccessStreak == expected
+		}, time.Second, time.Millisecond*5)
+	}
+
+	// two failed attempts, then the retry is delivered
+	dispatcher.Notify("sheet1", _makeCellChanges([]*contracts.Cell{{CanonicalKey: "a1", Value: "1", Result: "1"}}), contracts.ChangeOrigin{})
+	waitForSuccessStreak(1)
+
+	deliveryLog, err := dispatcher.GetDeliveryLog("sheet1", "a1", flaky.Id, 10)
+	assert.NoError(t, err)
+	assert.Len(t, deliveryLog.Subscriptions, 1)
+	assert.Equal(t, flaky.Id, deliveryLog.Subscriptions[0].Id)
+	assert.Equal(t, 0, deliveryLog.Subscriptions[0].LastDelivery.FailureStreak)
+
+	attempts := deliveryLog.Deliveries
+	assert.Len(t, attempts, 3)
+	for i, attempt := range attempts {
+		assert.Equal(t, 3-i, attempt.Attempt)
+		assert.Equal(t, flaky.Id, attempt.SubscriptionId)
+		assert.Equal(t, "a1", attempt.CellId)
+		assert.Equal(t, server.URL+"/flaky", attempt.WebhookUrl)
+		assert.Equal(t, attempts[0].WebhookId, attempt.WebhookId)
+		assert.Len(t, attempt.PayloadHash, 64)
+		assert.Equal(t, attempts[0].PayloadHash, attempt.PayloadHash)
+		assert.GreaterOrEqual(t, attempt.LatencyMs, int64(0))
+	}
+	assert.True(t, attempts[0].Success)
+	assert.Equal(t, http.StatusOK, attempts[0].StatusCode)
+	assert.Empty(t, attempts[0].Error)
+	assert.False(t, attempts[1].Success)
+	assert.Equal(t, http.StatusInternalServerError, attempts[1].StatusCode)
+	assert.Contains(t, attempts[1].Error, "500")
+
+	// log of the subscription is bounded
+	for i := 2; i <= 3; i++ {
+		dispatcher.Notify("sheet1", _makeCellChanges([]*contracts.Cell{{CanonicalKey: "a1", Value: "1", Result: fmt.Sprint(i)}}), contracts.ChangeOrigin{})
+		waitForSuccessStreak(i)
+	}
+
+	deliveryLog, err = dispatcher.GetDeliveryLog("sheet1", "a1", flaky.Id, 10)
+	assert.NoError(t, err)
+	assert.Len(t, deliveryLog.Deliveries, 4)
+	assert.Equal(t, 2, deliveryLog.Deliveries[3].Attempt)
+	assert.Equal(t, 3, deliveryLog.Subscriptions[0].LastDelivery.SuccessStreak)
+
+	deliveryLog, err = dispatcher.GetSheetSubscriptionDeliveryLog("sheet1", sheet.Id, 10)
+	assert.NoError(t, err)
+	assert.Len(t, deliveryLog.Deliveries, 3)
+	assert.Empty(t, deliveryLog.Deliveries[0].CellId)
+
+	// sheet log has all subscriptions of the sheet and its cells, attempts are ordered by time
+	deliveryLog, err = dispatcher.GetSheetDeliveryLog("sheet1", 100)
+	assert.NoError(t, err)
+	assert.Len(t, deliveryLog.Subscriptions, 2)
+	assert.Equal(t, sheet.Id, deliveryLog.Subscriptions[0].Id)
+	assert.Equal(t, flaky.Id, deliveryLog.Subscriptions[1].Id)
+	assert.Equal(t, "a1", deliveryLog.Subscriptions[1].CellId)
+	assert.Len(t, deliveryLog.Deliveries, 7)
+	for i := 1; i < len(deliveryLog.Deliveries); i++ {
+		assert.False(t, deliveryLog.Deliveries[i].AttemptedAt.After(deliveryLog.Deliveries[i-1].AttemptedAt))
+	}
+
+	deliveryLog, err = dispatcher.GetSheetDeliveryLog("sheet1", 2)
+	assert.NoError(t, err)
+	assert.Len(t, deliveryLog.Deliveries, 2)
+
+	// log is removed with subscription
+	assert.NoError(t, dispatcher.Unsubscribe("sheet1", "a1", flaky.Id))
+	_, err = dispatcher.GetDeliveryLog("sheet1", "a1", flaky.Id, 10)
+	assert.ErrorIs(t, err, contracts.WebhookSubscriptionNotFoundError)
+	deliveryLog, err = dispatcher.GetSheetDeliveryLog("sheet1", 100)
+	assert.NoError(t, err)
+	assert.Len(t, deliveryLog.Subscriptions, 1)
+	assert.Len(t, deliveryLog.Deliveries, 3)
+
+	assert.NoError(t, dispatcher.DeleteSheetWebhooks("sheet1"))
+	deliveryLog, err = dispatcher.GetSheetDeliveryLog("sheet1", 100)
+	assert.NoError(t, err)
+	assert.Empty(t, deliveryLog.Subscriptions)
+	assert.Empty(t, deliveryLog.Deliveries)
+}
+
+func TestWebhookDispatcher_Subscriptions(t *testing.T) {
+	db, dbClose := _createTmpDb()
+	defer dbClose()
+
+	dispatcher := NewWebhookDispatcher(db, nil, nil, nil, WebhookRetryConfig{}, 0)
+	a1, err := dispatcher.Subscribe("sheet1", "a1", "http://remote/a1", "first", contracts.WebhookOptions{})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, a1.Id)
+	assert.False(t, a1.CreatedAt.IsZero())
+	assert.Nil(t, a1.LastDelivery)
+
+	// same url is reused
+	a1Again, err := dispatcher.Subscribe("sheet1", "a1", "http://remote/a1", "", contracts.WebhookOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, a1, a1Again)
+	a1Again, err = dispatcher.Subscribe("sheet1", "a1", "http://remote/a1", "renamed", contracts.WebhookOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, a1.Id, a1Again.Id)
+	assert.Equal(t, "renamed", a1Again.Description)
+
+	a2, err := dispatcher.Subscribe("sheet1", "a1", "http://remote/a2", "", contracts.WebhookOptions{})
+	assert.NoError(t, err)
+	a3, err := dispatcher.Subscribe("sheet1", "a1", "http://remote/a3", "", contracts.WebhookOptions{})
+	assert.NoError(t, err)
+	b1, err := dispatcher.Subscribe("sheet2", "a1", "http://remote/b1", "", contracts.WebhookOptions{})
+	assert.NoError(t, err)
+
+	assert.NoError(t, dispatcher.Unsubscribe("sheet1", "a1", a3.Id))
+	assert.ErrorIs(t, dispatcher.Unsubscribe("sheet1", "a1", a3.Id), contracts.WebhookSubscriptionNotFoundError)
+	assert.ErrorIs(t, dispatcher.Unsubscribe("sheet1", "a2", a2.Id), contracts.WebhookSubscriptionNotFoundError)
+	assert.ErrorIs(t, dispatcher.UnsubscribeUrl("sheet1", "a1", "http://remote/a3"), contracts.WebhookSubscriptionNotFoundError)
+
+	// restart
+	restored := NewWebhookDispatcher(db, nil, nil, nil, WebhookRetryConfig{}, 0)
+	assert.Empty(t, restored.GetSubscriptions("sheet1", "a1"))
+	assert.NoError(t, restored.Load())
+	assert.Equal(t, []contracts.WebhookSubscription{*a1Again, *a2}, restored.GetSubscriptions("sheet1", "a1"))
+	assert.Equal(t, []contracts.WebhookSubscription{*b1}, restored.GetSubscriptions("sheet2", "a1"))
+
+	assert.NoError(t, restored.UnsubscribeUrl("sheet1", "a1", "http://remote/a2"))
+	assert.NoError(t, restored.DeleteSheetWebhooks("sheet2"))
+	assert.NoError(t, restored.DeleteSheetWebhooks("unknown"))
+	assert.Empty(t, restored.GetSubscriptions("sheet2", "a1"))
+
+	restored = NewWebhookDispatcher(db, nil, nil, nil, WebhookRetryConfig{}, 0)
+	assert.NoError(t, restored.Load())
+	assert.Equal(t, []contracts.WebhookSubscription{*a1Again}, restored.GetSubscriptions("sheet1", "a1"))
+
+	assert.NoError(t, restored.DeleteWebhooks("sheet1", "a1"))
+	assert.NoError(t, restored.DeleteWebhooks("sheet1", "unknown"))
+	assert.Empty(t, restored.GetSubscriptions("sheet1", "a1"))
+
+	restored = NewWebhookDispatcher(db, nil, nil, nil, WebhookRetryConfig{}, 0)
+	assert.NoError(t, restored.Load())
+	assert.Empty(t, restored.GetSubscriptions("sheet1", "a1"))
+}
+
+func TestWebhookDispatcher_Retry(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	db, dbClose := _createTmpDb()
+	defer dbClose()
+
+	dispatcher := NewWebhookDispatcher(db, _makeLoopbackEgressPolicy(t), NewWebhookSigner("", time.Minute), nil, _makeTestWebhookRetryConfig(), DefaultWebhookDeliveryLogSize)
+	dispatcher.Start()
+	defer dispatcher.Close()
+
+	_, err := dispatcher.Subscribe("sheet1", "a1", server.URL+"/webhook", "", contracts.WebhookOptions{})
+	assert.NoError(t, err)
+	dispatcher.Notify("sheet1", _makeCellChanges([]*contracts.Cell{{CanonicalKey: "a1", Value: "1", Result: "1"}}), contracts.ChangeOrigin{})
+
+	assert.Eventually(t, func() bool {
+		return _countPendingWebhooks(dispatcher) == 0
+	}, time.Second, time.Millisecond*5)
+
+	assert.Equal(t, int32(3), requests.Load())
+	assert.True(t, dispatcher.GetSubscriptions("sheet1", "a1")[0].LastDelivery.Success)
+	deadLetters, err := dispatcher.GetDeadLetters()
+	assert.NoError(t, err)
+	assert.Empty(t, deadLetters)
+}
+
+func TestWebhookDispatcher_DeadLetters(t *testing.T) {
+	var failing atomic.Bool
+	failing.Store(true)
+	received := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- r.URL.Path + " " + string(body)
+		if r.URL.Path == "/rejected" {
+			w.WriteHeader(http.StatusBadRequest)
+		} else if failing.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	db, dbClose := _createTmpDb()
+	defer dbClose()
+
+	dispatcher := NewWebhookDispatcher(db, _makeLoopbackEgressPolicy(t), NewWebhookSigner("", time.Minute), nil, _makeTestWebhookRetryConfig(), DefaultWebhookDeliveryLogSize)
+	dispatcher.Start()
+	defer dispatcher.Close()
+
+	failed, err := dispatcher.Subscribe("sheet1", "a1", server.URL+"/failed", "", contracts.WebhookOptions{})
+	assert.NoError(t, err)
+	rejected, err := dispatcher.Subscribe("sheet1", "a2", server.URL+"/rejected", "", contracts.WebhookOptions{})
+	assert.NoError(t, err)
+	dispatcher.Notify("sheet1", _makeCellChanges([]*contracts.Cell{
+		{CanonicalKey: "a1", Value: "1", Result: "1"},
+		{CanonicalKey: "a2", Value: "2", Result: "2"},
+	}), contracts.ChangeOrigin{Trace: []string{"remote:8080/sheet1/a1"}})
+
+	var deadLetters []contracts.WebhookOutboxEntry
+	assert.Eventually(t, func() bool {
+		deadLetters, _ = dispatcher.GetDeadLetters()
+		return len(deadLetters) == 2
+	}, time.Second, time.Millisecond*5)
+	assert.Equal(t, 0, _countPendingWebhooks(dispatcher))
+
+	// 3 attempts of failed webhook, rejected one is not retried
+	assert.Len(t, received, 4)
+
+	assert.Equal(t, failed.Id, deadLetters[0].SubscriptionId)
+	assert.Equal(t, "a1", deadLetters[0].CellId)
+	assert.Equal(t, server.URL+"/failed", deadLetters[0].WebhookUrl)
+	assert.Equal(t, 3, deadLetters[0].Attempts)
+	assert.Equal(t, []string{"remote:8080/sheet1/a1"}, deadLetters[0].Trace)
+	assert.Contains(t, deadLetters[0].LastError, "500")
+	event := contracts.WebhookEvent{}
+	assert.NoError(t, json.Unmarshal(deadLetters[0].Payload, &event))
+	assert.Equal(t, "A1", event.CellId)
+	assert.Equal(t, &contracts.Cell{Value: "1", Result: "1"}, event.Current)
+
+	assert.Equal(t, rejected.Id, deadLetters[1].SubscriptionId)
+	assert.Equal(t, 1, deadLetters[1].Attempts)
+	assert.Contains(t, deadLetters[1].LastError, "400")
+
+	assert.ErrorIs(t, dispatcher.ReplayDeadLetter("unknown"), contracts.WebhookDeadLetterNotFoundError)
+	assert.ErrorIs(t, dispatcher.DeleteDeadLetter("unknown"), contracts.WebhookDeadLetterNotFoundError)
+
+	assert.NoError(t, dispatcher.DeleteDeadLetter(deadLetters[1].Id))
+	assert.ErrorIs(t, dispatcher.DeleteDeadLetter(deadLetters[1].Id), contracts.WebhookDeadLetterNotFoundError)
+
+	for len(received) != 0 {
+		<-received
+	}
+	failing.Store(false)
+	assert.NoError(t, dispatcher.ReplayDeadLetter(deadLetters[0].Id))
+
+	select {
+	case request := <-received:
+		// the same event is replayed
+		assert.Equal(t, "/failed "+string(deadLetters[0].Payload), request)
+	case <-time.After(time.Second):
+		assert.Fail(t, "dead letter is not replayed")
+	}
+
+	assert.Eventually(t, func() bool {
+		return _countPendingWebhooks(dispatcher) == 0
+	}, time.Second, time.Millisecond*5)
+	deadLetters, err = dispatcher.GetDeadLetters()
+	assert.NoError(t, err)
+	assert.Empty(t, deadLetters)
+}
+
+func TestWebhookDispatcher_ReplayDeadLetterOrder(t *testing.T) {
+	db, dbClose := _createTmpDb()
+	defer dbClose()
+
+	// dispatcher is not started, so webhooks stay in outbox
+	dispatcher := NewWebhookDispatcher(db, _makeLoopbackEgressPolicy(t), NewWebhookSigner("", time.Minute), nil, _makeTestWebhookRetryConfig(), DefaultWebhookDeliveryLogSize)
+	_, err := dispatcher.Subscribe("sheet1", "a1", "http://remote/webhook", "", contracts.WebhookOptions{})
+	assert.NoError(t, err)
+
+	dispatcher.Notify("sheet1", _makeCellChanges([]*contracts.Cell{{CanonicalKey: "a1", Value: "1", Result: "1"}}), contracts.ChangeOrigin{})
+	dispatcher.Notify("sheet1", _makeCellChanges([]*contracts.Cell{{CanonicalKey: "a1", Value: "2", Result: "2"}}), contracts.ChangeOrigin{})
+
+	var deadLetter contracts.WebhookOutboxEntry
+	assert.NoError(t, db.Update(func(tx contracts.StorageTx) error {
+		deadLetter = dispatcher.outbox.GetPending(tx)[0]
+		return dispatcher.outbox.MoveToDeadLetters(tx, &deadLetter)
+	}))
+	assert.NoError(t, dispatcher.ReplayDeadLetter(deadLetter.Id))
+
+	var pending []contracts.WebhookOutboxEntry
+	_ = db.View(func(tx contracts.StorageTx) error {
+		pending = dispatcher.outbox.GetPending(tx)
+		return nil
+	})
+	assert.Len(t, pending, 2)
+	// the later webhook stays the head of the subscription, the replayed one is queued after it
+	assert.Equal(t, "0000000000000002", pending[0].Id)
+	assert.Equal(t, "0000000000000003", pending[1].Id)
+	assert.Equal(t, deadLetter.Payload, pending[1].Payload)
+
+	_ = db.View(func(tx contracts.StorageTx) error {
+		due, _ := dispatcher.outbox.GetDue(tx, time.Now(), func(string) bool { return false })
+		assert.Len(t, due, 1)
+		assert.Equal(t, "0000000000000002", due[0].Id)
+		return nil
+	})
+}
+
+func TestWebhookDispatcher_OutboxSurvivesRestart(t *testing.T) {
+	received := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.URL.Path
+	}))
+	defer server.Close()
+
+	db, dbClose := _createTmpDb()
+	defer dbClose()
+
+	// webhook is stored, but dispatcher is stopped before delivery
+	dispatcher := NewWebhookDispatcher(db, _makeLoopbackEgressPolicy(t), NewWebhookSigner("", time.Minute), nil, _makeTestWebhookRetryConfig(), DefaultWebhookDeliveryLogSize)
+	_, err := dispatcher.Subscribe("sheet1", "a1", server.URL+"/webhook", "", contracts.WebhookOptions{})
+	assert.NoError(t, err)
+	_, err = dispatcher.Subscribe("sheet1", "a2", server.URL+"/deleted", "", contracts.WebhookOptions{})
+	assert.NoError(t, err)
+	dispatcher.Notify("sheet1", _makeCellChanges([]*contracts.Cell{
+		{CanonicalKey: "a1", Value: "1", Result: "1"},
+		{CanonicalKey: "a2", Value: "2", Result: "2"},
+	}), contracts.ChangeOrigin{})
+	assert.Equal(t, 2, _countPendingWebhooks(dispatcher))
+	assert.NoError(t, dispatcher.DeleteWebhooks("sheet1", "a2"))
+
+	restored := NewWebhookDispatcher(db, _makeLoopbackEgressPolicy(t), NewWebhookSigner("", time.Minute), nil, _makeTestWebhookRetryConfig(), DefaultWebhookDeliveryLogSize)
+	assert.NoError(t, restored.Load())
+	restored.Start()
+	defer restored.Close()
+
+	select {
+	case path := <-received:
+		assert.Equal(t, "/webhook", path)
+	case <-time.After(time.Second):
+		assert.Fail(t, "webhook is not delivered")
+	}
+
+	// webhook of removed subscription is dropped
+	assert.Eventually(t, func() bool {
+		return _countPendingWebhooks(restored) == 0
+	}, time.Second, time.Millisecond*5)
+	assert.Empty(t, received)
+}
+
+func TestWebhookDispatcher_backoff(t *testing.T) {
+	dispatcher := NewWebhookDispatcher(nil, nil, nil, nil, WebhookRetryConfig{
+		MaxAttempts:     100,
+		RetryBackoff:    time.Second,
+		MaxRetryBackoff: time.Minute,
+	}, 0)
+
+	assert.Equal(t, time.Second, dispatcher.backoff(1))
+	assert.Equal(t, 2*time.Second, dispatcher.backoff(2))
+	assert.Equal(t, 32*time.Second, dispatcher.backoff(6))
+	assert.Equal(t, time.Minute, dispatcher.backoff(7))
+	assert.Equal(t, time.Minute, dispatcher.backoff(100))
+}
+
+func TestWebhookDispatcher_EventPayload(t *testing.T) {
+	received := make(chan []byte, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- body
+	}))
+	defer server.Close()
+
+	db, dbClose := _createTmpDb()
+	defer dbClose()
+
+	dispatcher := NewWebhookDispatcher(db, _makeLoopbackEgressPolicy(t), NewWebhookSigner("", time.Minute), nil, _makeTestWebhookRetryConfig(), DefaultWebhookDeliveryLogSize)
+	dispatcher.Start()
+	defer dispatcher.Close()
+
+	_, err := dispatcher.Subscribe("sheet1", "a2", server.URL+"/first", "", contracts.WebhookOptions{})
+	assert.NoError(t, err)
+	_, err = dispatcher.Subscribe("sheet1", "a2", server.URL+"/second", "", contracts.WebhookOptions{})
+	assert.NoError(t, err)
+
+	dispatcher.Notify("sheet1", []contracts.CellChange{
+		{
+			Cell:   &contracts.Cell{CanonicalKey: "a1", Value: "2", Result: "2"},
+			CellId: "A1",
+			Cause:  contracts.ChangeCauseDirectEdit,
+		},
+		{
+			Cell:     &contracts.Cell{CanonicalKey: "a2", Value: "=A1+1", Result: "3"},
+			CellId:   "A2",
+			Previous: &contracts.Cell{CanonicalKey: "a2", Value: "=A1+1", Result: "2"},
+			Cause:    contracts.ChangeCauseDependency,
+			CausedBy: "A1",
+		},
+	}, contracts.ChangeOrigin{})
+
+	first, second := contracts.WebhookEvent{}, contracts.WebhookEvent{}
+	assert.NoError(t, json.Unmarshal(<-received, &first))
+	assert.NoError(t, json.Unmarshal(<-received, &second))
+
+	assert.Len(t, first.Id, 32)
+	assert.Equal(t, first.Id, second.Id)
+	assert.WithinDuration(t, time.Now(), first.Timestamp, time.Second)
+	assert.Equal(t, contracts.WebhookEvent{
+		Version:   1,
+		Id:        first.Id,
+		Sequence:  1,
+		Type:      "cell.changed",
+		Timestamp: first.Timestamp,
+		SheetId:   "sheet1",
+		WebhookCellChange: contracts.WebhookCellChange{
+			CellId:   "A2",
+			Cause:    contracts.ChangeCauseDependency,
+			CausedBy: "A1",
+			Previous: &contracts.Cell{Value: "=A1+1", Result: "2"},
+			Current:  &contracts.Cell{Value: "=A1+1", Result: "3"},
+		},
+	}, first)
+
+	dispatcher.Notify("sheet1", []contracts.CellChange{{
+		Cell:   &contracts.Cell{CanonicalKey: "a2", Value: "5", Result: "5"},
+		CellId: "a2",
+		Cause:  contracts.ChangeCauseDirectEdit,
+	}}, contracts.ChangeOrigin{})
+
+	event := map[string]any{}
+	assert.NoError(t, json.Unmarshal(<-received, &event))
+	assert.NotEqual(t, first.Id, event["id"])
+	assert.Equal(t, float64(2), event["sequence"])
+	assert.Equal(t, "direct_edit", event["cause"])
+	assert.Nil(t, event["previous"])
+	assert.NotContains(t, event, "caused_by")
+}
+
+func TestWebhookDispatcher_SheetSubscriptions(t *testing.T) {
+	received := make(chan contracts.WebhookBatchEvent, 10)
+	paths := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		event := contracts.WebhookBatchEvent{}
+		body, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &event)
+		paths <- r.URL.Path
+		received <- event
+	}))
+	defer server.Close()
+
+	db, dbClose := _createTmpDb()
+	defer dbClose()
+
+	dispatcher := NewWebhookDispatcher(db, _makeLoopbackEgressPolicy(t), NewWebhookSigner("", time.Minute), nil, _makeTestWebhookRetryConfig(), DefaultWebhookDeliveryLogSize)
+	all, err := dispatcher.SubscribeSheet("sheet1", server.URL+"/all", "whole sheet", contracts.WebhookScope{}, contracts.WebhookOptions{})
+	assert.NoError(t, err)
+	cells, err := dispatcher.SubscribeSheet("sheet1", server.URL+"/cells", "", contracts.WebhookScope{Cells: []string{"a1", "price"}}, contracts.WebhookOptions{})
+	assert.NoError(t, err)
+	_, err = dispatcher.SubscribeSheet("sheet1", server.URL+"/range", "", contracts.WebhookScope{Range: "B1:B5"}, contracts.WebhookOptions{})
+	assert.NoError(t, err)
+	_, err = dispatcher.SubscribeSheet("sheet2", server.URL+"/other", "", contracts.WebhookScope{}, contracts.WebhookOptions{})
+	assert.NoError(t, err)
+	// cell subscriptions are separate
+	_, err = dispatcher.Subscribe("sheet1", "a1", server.URL+"/cell", "", contracts.WebhookOptions{})
+	assert.NoError(t, err)
+
+	// same url updates scope
+	updated, err := dispatcher.SubscribeSheet("sheet1", server.URL+"/cells", "", contracts.WebhookScope{Cells: []string{"a1", "b9"}}, contracts.WebhookOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, cells.Id, updated.Id)
+	assert.Equal(t, []string{"a1", "b9"}, updated.Cells)
+
+	// restart
+	dispatcher = NewWebhookDispatcher(db, _makeLoopbackEgressPolicy(t), NewWebhookSigner("", time.Minute), nil, _makeTestWebhookRetryConfig(), DefaultWebhookDeliveryLogSize)
+	assert.NoError(t, dispatcher.Load())
+	subscriptions := dispatcher.GetSheetSubscriptions("sheet1")
+	assert.Len(t, subscriptions, 3)
+	assert.Equal(t, *all, subscriptions[0])
+	assert.Equal(t, *updated, subscriptions[1])
+	assert.Equal(t, "B1:B5", subscriptions[2].Range)
+	assert.Len(t, dispatcher.GetSubscriptions("sheet1", "a1"), 1)
+
+	dispatcher.Start()
+	defer dispatcher.Close()
+
+	dispatcher.Notify("sheet1", _makeCellChanges([]*contracts.Cell{
+		{CanonicalKey: "a1", Value: "1", Result: "1"},
+		{CanonicalKey: "b2", Value: "=A1", Result: "1"},
+		{CanonicalKey: "c3", Value: "=B2", Result: "1"},
+	}), contracts.ChangeOrigin{})
+
+	events := map[string]contracts.WebhookBatchEvent{}
+	for i := 0; i < 4; i++ {
+		select {
+		case path := <-paths:
+			events[path] = <-received
+		case <-time.After(time.Second):
+			assert.Fail(t, "webhook is not delivered")
+			return
+		}
+	}
+
+	// cell subscription gets its own event
+	assert.Contains(t, events, "/cell")
+	assert.Equal(t, contracts.CellChangedEventType, events["/cell"].Type)
+
+	cellIds := func(event contracts.WebhookBatchEvent) (ids []string) {
+		for _, change := range event.Changes {
+			ids = append(ids, change.CellId)
+		}
+		return
+	}
+	assert.Equal(t, contracts.CellsChangedEventType, events["/all"].Type)
+	assert.Equal(t, "sheet1", events["/all"].SheetId)
+	assert.Equal(t, []string{"A1", "B2", "C3"}, cellIds(events["/all"]))
+	assert.Equal(t, &contracts.Cell{Value: "=A1", Result: "1"}, events["/all"].Changes[1].Current)
+	assert.Equal(t, []string{"A1"}, cellIds(events["/cells"]))
+	assert.Equal(t, []string{"B2"}, cellIds(events["/range"]))
+	assert.NotEqual(t, events["/all"].Id, events["/cells"].Id)
+
+	// no cells of the scope are changed
+	dispatcher.Notify("sheet1", _makeCellChanges([]*contracts.Cell{{CanonicalKey: "c3", Value: "2", Result: "2"}}), contracts.ChangeOrigin{})
+	assert.Equal(t, "/all", <-paths)
+	assert.Equal(t, []string{"C3"}, cellIds(<-received))
+
+	assert.NoError(t, dispatcher.UnsubscribeSheet("sheet1", all.Id))
+	assert.ErrorIs(t, dispatcher.UnsubscribeSheet("sheet1", all.Id), contracts.WebhookSubscriptionNotFoundError)
+	assert.NoError(t, dispatcher.UnsubscribeSheetUrl("sheet1", server.URL+"/range"))
+	assert.ErrorIs(t, dispatcher.UnsubscribeSheetUrl("sheet1", server.URL+"/range"), contracts.WebhookSubscriptionNotFoundError)
+	subscriptions = dispatcher.GetSheetSubscriptions("sheet1")
+	assert.Len(t, subscriptions, 1)
+	assert.Equal(t, updated.Id, subscriptions[0].Id)
+
+	assert.NoError(t, dispatcher.DeleteSheetWebhooks("sheet1"))
+	assert.Empty(t, dispatcher.GetSheetSubscriptions("sheet1"))
+	assert.Empty(t, dispatcher.GetSubscriptions("sheet1", "a1"))
+
+	restored := NewWebhookDispatcher(db, nil, nil, nil, WebhookRetryConfig{}, 0)
+	assert.NoError(t, restored.Load())
+	assert.Empty(t, restored.GetSheetSubscriptions("sheet1"))
+	assert.Len(t, restored.GetSheetSubscriptions("sheet2"), 1)
+}
+
+func TestWebhookDispatcher_OnlyChangedResults(t *testing.T) {
+	received := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- r.URL.Path + " " + string(body)
+	}))
+	defer server.Close()
+
+	db, dbClose := _createTmpDb()
+	defer dbClose()
+
+	dispatcher := NewWebhookDispatcher(db, _makeLoopbackEgressPolicy(t), NewWebhookSigner("", time.Minute), nil, _makeTestWebhookRetryConfig(), DefaultWebhookDeliveryLogSize)
+	dispatcher.Start()
+	defer dispatcher.Close()
+
+	always, err := dispatcher.SubscribeSheet("sheet1", server.URL+"/sheet_always", "", contracts.WebhookScope{}, contracts.WebhookOptions{AlwaysNotify: true})
+	assert.NoError(t, err)
+	assert.True(t, always.AlwaysNotify)
+	_, err = dispatcher.SubscribeSheet("sheet1", server.URL+"/sheet", "", contracts.WebhookScope{}, contracts.WebhookOptions{})
+	assert.NoError(t, err)
+	_, err = dispatcher.Subscribe("sheet1", "a2", server.URL+"/cell", "", contracts.WebhookOptions{})
+	assert.NoError(t, err)
+
+	dispatcher.Notify("sheet1", []contracts.CellChange{
+		{
+			Cell:     &contracts.Cell{CanonicalKey: "a1", Value: "2", Result: "2"},
+			CellId:   "A1",
+			Previous: &contracts.Cell{CanonicalKey: "a1", Value: "1", Result: "1"},
+			Cause:    contracts.ChangeCauseDirectEdit,
+		},
+		{
+			Cell:     &contracts.Cell{CanonicalKey: "a2", Value: "=max(A1, 100)", Result: "100"},
+			CellId:   "A2",
+			Previous: &contracts.Cell{CanonicalKey: "a2", Value: "=max(A1, 100)", Result: "100"},
+			Cause:    contracts.ChangeCauseDependency,
+			CausedBy: "A1",
+		},
+	}, contracts.ChangeOrigin{})
+
+	events := map[string]contracts.WebhookBatchEvent{}
+	for i := 0; i < 2; i++ {
+		select {
+		case request := <-received:
+			path, body, _ := strings.Cut(request, " ")
+			event := contracts.WebhookBatchEvent{}
+			assert.NoError(t, json.Unmarshal([]byte(body), &event))
+			events[path] = event
+		case <-time.After(time.Second):
+			assert.Fail(t, "webhook is not delivered")
+			return
+		}
+	}
+
+	assert.Len(t, events["/sheet_always"].Changes, 2)
+	assert.Len(t, events["/sheet"].Changes, 1)
+	assert.Equal(t, "A1", events["/sheet"].Changes[0].CellId)
+	assert.NotContains(t, events, "/cell")
+
+	assert.Never(t, func() bool {
+		return len(received) != 0
+	}, time.Millisecond*50, time.Millisecond*10)
+}
+
+func TestWebhookDispatcher_Condition(t *testing.T) {
+	received := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- r.URL.Path + " " + string(body)
+	}))
+	defer server.Close()
+
+	db, dbClose := _createTmpDb()
+	defer dbClose()
+
+	dispatcher := NewWebhookDispatcher(
+		db, _makeLoopbackEgressPolicy(t), NewWebhookSigner("", time.Minute), NewExpressionExecutor(NewCanonicalizer()),
+		_makeTestWebhookRetryConfig(), DefaultWebhookDeliveryLogSize,
+	)
+	dispatcher.Start()
+	defer dispatcher.Close()
+
+	subscription, err := dispatcher.Subscribe("sheet1", "stock", server.URL+"/cell", "", contracts.WebhookOptions{Condition: "new < 10 && old >= 10"})
+	assert.NoError(t, err)
+	assert.Equal(t, "new < 10 && old >= 10", subscription.Condition)
+	_, err = dispatcher.SubscribeSheet("sheet1", server.URL+"/sheet", "", contracts.WebhookScope{}, contracts.WebhookOptions{Condition: "new < 10 && old >= 10"})
+	assert.NoError(t, err)
+
+	stock := func(previousResult string, result string) contracts.CellChange {
+		change := contracts.CellChange{
+			Cell:   &contracts.Cell{CanonicalKey: "stock", Value: result, Result: result},
+			CellId: "Stock",
+			Cause:  contracts.ChangeCauseDirectEdit,
+		}
+		if previousResult != "" {
+			change.Previous = &contracts.Cell{CanonicalKey: "stock", Value: previousResult, Result: previousResult}
+		}
+		return change
+	}
+
+	// new cell (old is nil), above the threshold, below it and the threshold is crossed
+	for _, change := range []contracts.CellChange{stock("", "5"), stock("5", "12"), stock("12", "11"), stock("11", "9"), stock("9", "3")} {
+		dispatcher.Notify("sheet1", []contracts.CellChange{change}, contracts.ChangeOrigin{})
+	}
+
+	events := map[string]string{}
+	for i := 0; i < 2; i++ {
+		select {
+		case request := <-received:
+			path, body, _ := strings.Cut(request, " ")
+			events[path] = body
+		case <-time.After(time.Second):
+			assert.Fail(t, "webhook is not delivered")
+			return
+		}
+	}
+
+	cellEvent := contracts.WebhookEvent{}
+	assert.NoError(t, json.Unmarshal([]byte(events["/cell"]), &cellEvent))
+	assert.Equal(t, "9", cellEvent.Current.Result)
+	assert.Equal(t, "11", cellEvent.Previous.Result)
+
+	sheetEvent := contracts.WebhookBatchEvent{}
+	assert.NoError(t, json.Unmarshal([]byte(events["/sheet"]), &sheetEvent))
+	assert.Len(t, sheetEvent.Changes, 1)
+	assert.Equal(t, "9", sheetEvent.Changes[0].Current.Result)
+
+	assert.Never(t, func() bool {
+		return len(received) != 0
+	}, time.Millisecond*50, time.Millisecond*10)
+}
+
+func TestWebhookDispatcher_Debounce(t *testing.T) {
+	received := make(chan string, 100)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- r.URL.Path + " " + string(body)
+	}))
+	defer server.Close()
+
+	db, dbClose := _createTmpDb()
+	defer dbClose()
+
+	dispatcher := NewWebhookDispatcher(db, _makeLoopbackEgressPolicy(t), NewWebhookSigner("", time.Minute), nil, _makeTestWebhookRetryConfig(), DefaultWebhookDeliveryLogSize)
+	dispatcher.Start()
+
+	debounced := contracts.WebhookOptions{DebounceMs: 100}
+	_, err := dispatcher.Subscribe("sheet1", "total", server.URL+"/total", "", debounced)
+	assert.NoError(t, err)
+	_, err = dispatcher.Subscribe("sheet1", "total", server.URL+"/total_immediate", "", contracts.WebhookOptions{})
+	assert.NoError(t, err)
+	_, err = dispatcher.SubscribeSheet("sheet1", server.URL+"/sheet", "", contracts.WebhookScope{}, debounced)
+	assert.NoError(t, err)
+
+	// bulk edit: each cell feeds the total
+	const updates = 20
+	for i := 1; i <= updates; i++ {
+		cellId := fmt.Sprintf("a%d", i)
+		dispatcher.Notify("sheet1", []contracts.CellChange{
+			{
+				Cell:   &contracts.Cell{CanonicalKey: cellId, Value: "1", Result: "1"},
+				CellId: strings.ToUpper(cellId),
+				Cause:  contracts.ChangeCauseDirectEdit,
+			},
+			{
+				Cell:     &contracts.Cell{CanonicalKey: "total", Value: "=sum(A1:A20)", Result: fmt.Sprint(i)},
+				CellId:   "Total",
+				Previous: &contracts.Cell{CanonicalKey: "total", Value: "=sum(A1:A20)", Result: fmt.Sprint(i - 1)},
+				Cause:    contracts.ChangeCauseDependency,
+				CausedBy: strings.ToUpper(cellId),
+			},
+		}, contracts.ChangeOrigin{})
+	}
+
+	paths := map[string][]string{}
+	for i := 0; i < updates+2; i++ {
+		select {
+		case request := <-received:
+			path, body, _ := strings.Cut(request, " ")
+			paths[path] = append(paths[path], body)
+		case <-time.After(time.Second):
+			assert.Fail(t, "webhook is not delivered")
+			return
+		}
+	}
+	assert.Len(t, paths["/total_immediate"], updates)
+
+	// the latest result of the cell, previous one is the state before the window
+	assert.Len(t, paths["/total"], 1)
+	event := contracts.WebhookEvent{}
+	assert.NoError(t, json.Unmarshal([]byte(paths["/total"][0]), &event))
+	assert.Equal(t, uint64(1), event.Sequence)
+	assert.Equal(t, "0", event.Previous.Result)
+	assert.Equal(t, fmt.Sprint(updates), event.Current.Result)
+	assert.Equal(t, "A20", event.CausedBy)
+
+	// one batch with each changed cell once
+	assert.Len(t, paths["/sheet"], 1)
+	batch := contracts.WebhookBatchEvent{}
+	assert.NoError(t, json.Unmarshal([]byte(paths["/sheet"][0]), &batch))
+	assert.Len(t, batch.Changes, updates+1)
+	assert.Equal(t, "A1", batch.Changes[0].CellId)
+	assert.Equal(t, "Total", batch.Changes[1].CellId)
+	assert.Equal(t, "0", batch.Changes[1].Previous.Result)
+	assert.Equal(t, fmt.Sprint(updates), batch.Changes[1].Current.Result)
+
+	assert.Never(t, func() bool {
+		return len(received) != 0
+	}, time.Millisecond*150, time.Millisecond*10)
+
+	// pending changes are stored in outbox on close
+	_, err = dispatcher.Subscribe("sheet1", "total", server.URL+"/total", "", contracts.WebhookOptions{DebounceMs: 60000})
+	assert.NoError(t, err)
+	dispatcher.Notify("sheet1", _makeCellChanges([]*contracts.Cell{{CanonicalKey: "total", Result: "1"}}), contracts.ChangeOrigin{})
+	dispatcher.Close()
+
+	entries := []contracts.WebhookOutboxEntry{}
+	_ = db.View(func(tx contracts.StorageTx) error {
+		entries = dispatcher.outbox.GetPending(tx)
+		return nil
+	})
+	urls := []string{}
+	for _, entry := range entries {
+		if entry.WebhookUrl != server.URL+"/total_immediate" {
+			urls = append(urls, entry.WebhookUrl)
+		}
+	}
+	assert.ElementsMatch(t, []string{server.URL + "/total", server.URL + "/sheet"}, urls)
+}
+
+func TestWebhookDispatcher_DebounceRevert(t *testing.T) {
+	received := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- r.URL.Path + " " + string(body)
+	}))
+	defer server.Close()
+
+	db, dbClose := _createTmpDb()
+	defer dbClose()
+
+	dispatcher := NewWebhookDispatcher(
+		db, _makeLoopbackEgressPolicy(t), NewWebhookSigner("", time.Minute), NewExpressionExecutor(NewCanonicalizer()),
+		_makeTestWebhookRetryConfig(), DefaultWebhookDeliveryLogSize,
+	)
+	dispatcher.Start()
+	defer dispatcher.Close()
+
+	debounced := contracts.WebhookOptions{DebounceMs: 50}
+	_, err := dispatcher.Subscribe("sheet1", "stock", server.URL+"/cell", "", debounced)
+	assert.NoError(t, err)
+	_, err = dispatcher.Subscribe("sheet1", "stock", server.URL+"/condition", "", contracts.WebhookOptions{DebounceMs: 50, Condition: "new < 10"})
+	assert.NoError(t, err)
+	_, err = dispatcher.SubscribeSheet("sheet1", server.URL+"/sheet", "", contracts.WebhookScope{}, debounced)
+	assert.NoError(t, err)
+
+	stock := func(previousResult string, result string) contracts.CellChange {
+		return contracts.CellChange{
+			Cell:     &contracts.Cell{CanonicalKey: "stock", Value: result, Result: result},
+			CellId:   "Stock",
+			Previous: &contracts.Cell{CanonicalKey: "stock", Value: previousResult, Result: previousResult},
+			Cause:    contracts.ChangeCauseDirectEdit,
+		}
+	}
+
+	// the change is reverted within the window, the revert itself does not hold the condition
+	dispatcher.Notify("sheet1", []contracts.CellChange{stock("12", "9")}, contracts.ChangeOrigin{})
+	dispatcher.Notify("sheet1", []contracts.CellChange{stock("9", "12")}, contracts.ChangeOrigin{})
+
+	assert.Never(t, func() bool {
+		return len(received) != 0
+	}, time.Millisecond*150, time.Millisecond*10)
+
+	// the merged change holds the condition
+	dispatcher.Notify("sheet1", []contracts.CellChange{stock("12", "15")}, contracts.ChangeOrigin{})
+	dispatcher.Notify("sheet1", []contracts.CellChange{stock("15", "8")}, contracts.ChangeOrigin{})
+
+	events := map[string]string{}
+	for i := 0; i < 3; i++ {
+		select {
+		case request := <-received:
+			path, body, _ := strings.Cut(request, " ")
+			events[path] = body
+		case <-time.After(time.Second):
+			assert.Fail(t, "webhook is not delivered")
+			return
+		}
+	}
+
+	event := contracts.WebhookEvent{}
+	assert.NoError(t, json.Unmarshal([]byte(events["/condition"]), &event))
+	assert.Equal(t, "12", event.Previous.Result)
+	assert.Equal(t, "8", event.Current.Result)
+	assert.Equal(t, uint64(1), event.Sequence)
+}
+
+func TestWebhookDispatcher_Shutdown(t *testing.T) {
+	received := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow":
+			time.Sleep(200 * time.Millisecond)
+		case "/failed":
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		received <- r.URL.Path
+	}))
+	defer server.Close()
+
+	newDispatcher := func(db *BoltStorage) *WebhookDispatcher {
+		return NewWebhookDispatcher(db, _makeLoopbackEgressPolicy(t), NewWebhookSigner("", time.Minute), nil, WebhookRetryConfig{
+			MaxAttempts:     3,
+			RetryBackoff:    time.Minute,
+			MaxRetryBackoff: time.Minute,
+		}, DefaultWebhookDeliveryLogSize)
+	}
+
+	t.Run("drain", func(t *testing.T) {
+		db, dbClose := _createTmpDb()
+		defer dbClose()
+
+		dispatcher := newDispatcher(db)
+		_, err := dispatcher.Subscribe("sheet1", "a1", server.URL+"/ok", "", contracts.WebhookOptions{})
+		assert.NoError(t, err)
+		_, err = dispatcher.Subscribe("sheet1", "a1", server.URL+"/failed", "", contracts.WebhookOptions{})
+		assert.NoError(t, err)
+		_, err = dispatcher.Subscribe("sheet1", "a2", server.URL+"/debounced", "", contracts.WebhookOptions{DebounceMs: 60000})
+		assert.NoError(t, err)
+
+		// webhooks are queued before workers are started
+		dispatcher.Notify("sheet1", _makeCellChanges([]*contracts.Cell{
+			{CanonicalKey: "a1", Value: "1", Result: "1"},
+			{CanonicalKey: "a2", Value: "2", Result: "2"},
+		}), contracts.ChangeOrigin{})
+		dispatcher.Start()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		assert.NoError(t, dispatcher.Shutdown(ctx))
+		assert.ElementsMatch(t, []string{"/ok", "/failed", "/debounced"}, []string{<-received, <-received, <-received})
+
+		// failed webhook is kept for retry after restart, new notifications are not accepted
+		dispatcher.Notify("sheet1", _makeCellChanges([]*contracts.Cell{{CanonicalKey: "a1", Value: "3", Result: "3"}}), contracts.ChangeOrigin{})
+		entries := []contracts.WebhookOutboxEntry{}
+		_ = db.View(func(tx contracts.StorageTx) error {
+			entries = dispatcher.outbox.GetPending(tx)
+			return nil
+		})
+		assert.Len(t, entries, 1)
+		assert.Equal(t, server.URL+"/failed", entries[0].WebhookUrl)
+		assert.Equal(t, 1, entries[0].Attempts)
+
+		// Close after Shutdown does nothing
+		dispatcher.Close()
+	})
+
+	t.Run("deadline", func(t *testing.T) {
+		db, dbClose := _createTmpDb()
+		defer dbClose()
+
+		dispatcher := newDispatcher(db)
+		_, err := dispatcher.Subscribe("sheet1", "a1", server.URL+"/slow", "", contracts.WebhookOptions{})
+		assert.NoError(t, err)
+		dispatcher.Start()
+		dispatcher.Notify("sheet1", _makeCellChanges([]*contracts.Cell{{CanonicalKey: "a1", Value: "1", Result: "1"}}), contracts.ChangeOrigin{})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, dispatcher.Shutdown(ctx), context.DeadlineExceeded)
+
+		// in-flight request is completed before Shutdown returns
+		assert.Equal(t, "/slow", <-received)
+		assert.Equal(t, 0, _countPendingWebhooks(dispatcher))
+	})
+}
+
+func TestWebhookDispatcher_ConcurrentOrdering(t *testing.T) {
+	const writers, updates = 8, 10
+
+	type delivery struct {
+		sequence uint64
+		eventId  string
+		result   string
+	}
+	var mutex sync.Mutex
+	deliveries := map[string][]delivery{}
+	failed := map[string]bool{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		event := map[string]any{}
+		_ = json.Unmarshal(body, &event)
+
+		mutex.Lock()
+		defer mutex.Unlock()
+		// the first webhook of each subscriber is retried, next ones wait for it
+		if !failed[r.URL.Path] {
+			failed[r.URL.Path] = true
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		result := ""
+		if current, ok := event["current"].(map[string]any); ok {
+			result, _ = current["result"].(string)
+		}
+		deliveries[r.URL.Path] = append(deliveries[r.URL.Path], delivery{
+			sequence: uint64(event["sequence"].(float64)),
+			eventId:  event["id"].(string),
+			result:   result,
+		})
+	}))
+	defer server.Close()
+
+	db, dbClose := _createTmpDb()
+	defer dbClose()
+
+	dispatcher := NewWebhookDispatcher(db, _makeLoopbackEgressPolicy(t), NewWebhookSigner("", time.Minute), nil, _makeTestWebhookRetryConfig(), DefaultWebhookDeliveryLogSize)
+	cellPaths := []string{"/cell1", "/cell2", "/cell3"}
+	for _, path := range cellPaths {
+		_, err := dispatcher.Subscribe("sheet1", "a1", server.URL+path, "", contracts.WebhookOptions{})
+		assert.NoError(t, err)
+	}
+	_, err := dispatcher.SubscribeSheet("sheet1", server.URL+"/sheet", "", contracts.WebhookScope{}, contracts.WebhookOptions{})
+	assert.NoError(t, err)
+	dispatcher.Start()
+	defer dispatcher.Close()
+
+	var wg sync.WaitGroup
+	for writer := 0; writer < writers; writer++ {
+		wg.Add(1)
+		go func(writer int) {
+			defer wg.Done()
+			for i := 0; i < updates; i++ {
+				value := fmt.Sprintf("%d", writer*updates+i)
+				dispatcher.Notify("sheet1", _makeCellChanges([]*contracts.Cell{
+					{CanonicalKey: "a1", Value: value, Result: value},
+				}), contracts.ChangeOrigin{})
+			}
+		}(writer)
+	}
+	// subscriptions are managed while webhooks are sent
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < updates; i++ {
+			_, _ = dispatcher.Subscribe("sheet1", "b1", server.URL+"/other", "", contracts.WebhookOptions{})
+			_ = dispatcher.GetSubscriptions("sheet1", "a1")
+			_ = dispatcher.GetSheetSubscriptions("sheet1")
+			_ = dispatcher.UnsubscribeUrl("sheet1", "b1", server.URL+"/other")
+		}
+	}()
+	wg.Wait()
+
+	allPaths := append([]string{"/sheet"}, cellPaths...)
+	assert.Eventually(t, func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		for _, path := range allPaths {
+			if len(deliveries[path]) < writers*updates {
+				return false
+			}
+		}
+		return true
+	}, 10*time.Second, 10*time.Millisecond)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	for _, path := range allPaths {
+		assert.Len(t, deliveries[path], writers*updates, path)
+		for i, received := range deliveries[path] {
+			assert.Equal(t, uint64(i+1), received.sequence, path)
+		}
+	}
+	// subscribers of the cell get the same events in the same order
+	for _, path := range cellPaths[1:] {
+		assert.Equal(t, deliveries[cellPaths[0]], deliveries[path], path)
+	}
+
+	subscriptions := dispatcher.GetSubscriptions("sheet1", "a1")
+	assert.Equal(t, uint64(writers*updates), subscriptions[0].Sequence)
+}
+
+func _makeCellChanges(cells []*contracts.Cell) []contracts.CellChange {
+	changes := make([]contracts.CellChange, 0, len(cells))
+	for _, cell := range cells {
+		changes = append(changes, contracts.CellChange{
+			Cell:   cell,
+			CellId: strings.ToUpper(cell.CanonicalKey),
+			Cause:  contracts.ChangeCauseDirectEdit,
+		})
+	}
+
+	return changes
+}
+
+func _makeTestWebhookRetryConfig() WebhookRetryConfig {
+	return WebhookRetryConfig{
+		MaxAttempts:     3,
+		RetryBackoff:    time.Millisecond * 10,
+		MaxRetryBackoff: time.Millisecond * 50,
+	}
+}
+
+func _countPendingWebhooks(dispatcher *WebhookDispatcher) (count int) {
+	_ = dispatcher.db.View(func(tx contracts.StorageTx) error {
+		count = len(dispatcher.outbox.GetPending(tx))
+		return nil
+	})
+
+	return
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bytes"
+	"devChallengeExcel/contracts"
+	"fmt"
+	json "github.com/bytedance/sonic"
+	"strconv"
+	"time"
+)
+
+// WebhookOutbox keeps webhook requests until they are delivered (at-least-once delivery).
+// Pending requests are in outbox bucket, permanently failed ones are moved to dead letters bucket.
+// Key is id of the entry (zero padded hex sequence, so entries are ordered by creation).
+//
+// Webhooks of a subscription are sent one by one, so only the oldest entry of each subscription (its head) can be due.
+// Index bucket keeps ids of entries per subscription (queues) and heads ordered by time of the next attempt (due),
+// so due entries are read without scanning the whole outbox. Heads bucket maps subscription to its key in due bucket
+type WebhookOutbox struct{}
+
+var webhookOutboxBucketId = []byte("__outbox")
+var webhookOutboxIndexBucketId = []byte("__outbox_index")
+var webhookDeadLettersBucketId = []byte("__dead_letters")
+
+var outboxQueuesBucketId = []byte("queues")
+var outboxHeadsBucketId = []byte("heads")
+var outboxDueBucketId = []byte("due")
+
+// outboxDueTimeLength length of zero padded hex unix nano time at the beginning of the key of due bucket
+const outboxDueTimeLength = 16
+
+// Add assigns id to the entry and stores it in outbox
+func (o *WebhookOutbox) Add(tx contracts.StorageTx, entry *contracts.WebhookOutboxEntry) error {
+	bucket, err := tx.CreateBucketIfNotExists(webhookOutboxBucketId)
+	if err != nil {
+		return err
+	}
+
+	sequence, err := bucket.NextSequence()
+	if err != nil {
+		return err
+	}
+	entry.Id = fmt.Sprintf("%016x", sequence)
+
+	return o.Put(tx, entry)
+}
+
+// Put replaces pending entry, e.g. after failed attempt
+func (o *WebhookOutbox) Put(tx contracts.StorageTx, entry *contracts.WebhookOutboxEntry) error {
+	bucket, err := tx.CreateBucketIfNotExists(webhookOutboxBucketId)
+	if err != nil {
+		return err
+	}
+
+	err = o.put(bucket, entry)
+	if err != nil {
+		return err
+	}
+
+	queue, err := o.createIndexBucket(tx, outboxQueuesBucketId, []byte(entry.SubscriptionId))
+	if err == nil {
+		err = queue.Put([]byte(entry.Id), []byte{})
+	}
+	if err != nil {
+		return err
+	}
+
+	return o.reindexHead(tx, entry.SubscriptionId)
+}
+
+// GetPending returns all entries of outbox
+func (o *WebhookOutbox) GetPending(tx contracts.StorageTx) []contracts.WebhookOutboxEntry {
+	return o.getAll(tx.Bucket(webhookOutboxBucketId))
+}
+
+// GetDue returns heads of subscriptions which are due at the moment (skipped ones are ignored) in order of due time,
+// and time of the next attempt of other heads (zero time when there are none)
+func (o *WebhookOutbox) GetDue(
+	tx contracts.StorageTx, now time.Time, skip func(id string) bool,
+) (entries []contracts.WebhookOutboxEntry, nextAttemptAt time.Time) {
+	entries = make([]contracts.WebhookOutboxEntry, 0)
+	due := o.getIndexBucket(tx, outboxDueBucketId)
+	bucket := tx.Bucket(webhookOutboxBucketId)
+	if due == nil || bucket == nil {
+		return
+	}
+
+	c := due.Cursor()
+	for key, id := c.First(); key != nil; key, id = c.Next() {
+		if skip(string(id)) {
+			continue
+		}
+
+		if attemptAt := o.parseDueTime(key); attemptAt.After(now) {
+			nextAttemptAt = attemptAt
+			return
+		}
+
+		entry := contracts.WebhookOutboxEntry{}
+		if json.Unmarshal(bucket.Get(id), &entry) == nil {
+			entries = append(entries, entry)
+		}
+	}
+
+	return
+}
+
+// Delete removes delivered entry from outbox, the next entry of its subscription becomes the head
+func (o *WebhookOutbox) Delete(tx contracts.StorageTx, id string) error {
+	bucket := tx.Bucket(webhookOutboxBucketId)
+	if bucket == nil {
+		return nil
+	}
+
+	data := bucket.Get([]byte(id))
+	if data == nil {
+		return nil
+	}
+
+	entry := contracts.WebhookOutboxEntry{}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return err
+	}
+
+	err := bucket.Delete([]byte(id))
+	if err != nil {
+		return err
+	}
+
+	if queue := o.getIndexBucket(tx, outboxQueuesBucketId, []byte(entry.SubscriptionId)); queue != nil {
+		if err = queue.Delete([]byte(id)); err != nil {
+			return err
+		}
+	}
+
+	return o.reindexHead(tx, entry.SubscriptionId)
+}
+
+// RebuildIndex indexes entries of outbox when there is no index, e.g. they were stored by previous version
+func (o *WebhookOutbox) RebuildIndex(tx contracts.StorageTx) error {
+	if tx.Bucket(webhookOutboxIndexBucketId) != nil {
+		return nil
+	}
+
+	for _, entry := range o.GetPending(tx) {
+		if err := o.Put(tx, &entry); err != nil {
+			return err
+		}
+	}
+
+	_, err := tx.CreateBucketIfNotExists(webhookOutboxIndexBucketId)
+	return err
+}
+
+// MoveToDeadLetters removes entry from outbox and stores it as dead letter
+func (o *WebhookOutbox) MoveToDeadLetters(tx contracts.StorageTx, entry *contracts.WebhookOutboxEntry) error {
+	err := o.Delete(tx, entry.Id)
+	if err != nil {
+		return err
+	}
+
+	bucket, err := tx.CreateBucketIfNotExists(webhookDeadLettersBucketId)
+	if err != nil {
+		return err
+	}
+
+	return o.put(bucket, entry)
+}
+
+func (o *WebhookOutbox) GetDeadLetters(tx contracts.StorageTx) []contracts.WebhookOutboxEntry {
+	return o.getAll(tx.Bucket(webhookDeadLettersBucketId))
+}
+
+// GetDeadLetter returns nil when dead letter does not exist
+func (o *WebhookOutbox) GetDeadLetter(tx contracts.StorageTx, id string) *contracts.WebhookOutboxEntry {
+	bucket := tx.Bucket(webhookDeadLettersBucketId)
+	if bucket == nil {
+		return nil
+	}
+
+	data := bucket.Get([]byte(id))
+	if data == nil {
+		return nil
+	}
+
+	entry := &contracts.WebhookOutboxEntry{}
+	if json.Unmarshal(data, entry) != nil {
+		return nil
+	}
+
+	return entry
+}
+
+func (o *WebhookOutbox) DeleteDeadLetter(tx contracts.StorageTx, id string) error {
+	bucket := tx.Bucket(webhookDeadLettersBucketId)
+	if bucket == nil {
+		return nil
+	}
+
+	return bucket.Delete([]byte(id))
+}
+
+// reindexHead replaces key of the subscription in due bucket according to its current head (the oldest entry)
+func (o *WebhookOutbox) reindexHead(tx contracts.StorageTx, subscriptionId string) error {
+	heads, err := o.createIndexBucket(tx, outboxHeadsBucketId)
+	if err != nil {
+		return err
+	}
+	due, err := o.createIndexBucket(tx, outboxDueBucketId)
+	if err != nil {
+		return err
+	}
+
+	queues, err := o.createIndexBucket(tx, outboxQueuesBucketId)
+	if err != nil {
+		return err
+	}
+
+	var key, id []byte
+	if queue := queues.Bucket([]byte(subscriptionId)); queue != nil {
+		if id, _ = queue.Cursor().First(); id != nil {
+			id = bytes.Clone(id)
+		} else if err = queues.DeleteBucket([]byte(subscriptionId)); err != nil {
+			return err
+		}
+	}
+	if id != nil {
+		entry := contracts.WebhookOutboxEntry{}
+		if err = json.Unmarshal(tx.Bucket(webhookOutboxBucketId).Get(id), &entry); err != nil {
+			return err
+		}
+		key = o.makeDueKey(entry.NextAttemptAt, id)
+	}
+
+	previousKey := bytes.Clone(heads.Get([]byte(subscriptionId)))
+	if bytes.Equal(previousKey, key) {
+		return nil
+	}
+	if previousKey != nil {
+		if err = due.Delete(previousKey); err != nil {
+			return err
+		}
+	}
+	if key == nil {
+		return heads.Delete([]byte(subscriptionId))
+	}
+
+	err = due.Put(key, id)
+	if err == nil {
+		err = heads.Put([]byte(subscriptionId), key)
+	}
+
+	return err
+}
+
+// makeDueKey the key is ordered by time of the next attempt, then by id
+func (o *WebhookOutbox) makeDueKey(nextAttemptAt time.Time, id []byte) []byte {
+	return append([]byte(fmt.Sprintf("%0*x", outboxDueTimeLength, nextAttemptAt.UnixNano())), id...)
+}
+
+func (o *WebhookOutbox) parseDueTime(key []byte) time.Time {
+	unixNano, _ := strconv.ParseInt(string(key[:outboxDueTimeLength]), 16, 64)
+	return time.Unix(0, unixNano)
+}
+
+// getIndexBucket returns nested bucket of index by path, nil when it does not exist
+func (o *WebhookOutbox) getIndexBucket(tx contracts.StorageTx, path ...[]byte) contracts.StorageBucket {
+	bucket := tx.Bucket(webhookOutboxIndexBucketId)
+	for _, name := range path {
+		if bucket == nil {
+			return nil
+		}
+		bucket = bucket.Bucket(name)
+	}
+
+	return bucket
+}
+
+func (o *WebhookOutbox) createIndexBucket(tx contracts.StorageTx, path ...[]byte) (contracts.StorageBucket, error) {
+	bucket, err := tx.CreateBucketIfNotExists(webhookOutboxIndexBucketId)
+	for _, name := range path {
+		if err != nil {
+			return nil, err
+		}
+		bucket, err = bucket.CreateBucketIfNotExists(name)
+	}
+
+	return bucket, err
+}
+
+func (o *WebhookOutbox) put(bucket contracts.StorageBucket, entry *contracts.WebhookOutboxEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	return bucket.Put([]byte(entry.Id), data)
+}
+
+func (o *WebhookOutbox) getAll(bucket contracts.StorageBucket) []contracts.WebhookOutboxEntry {
+	entries := make([]contracts.WebhookOutboxEntry, 0)
+	if bucket == nil {
+		return entries
+	}
+
+	_ = bucket.ForEach(func(id []byte, data []byte) error {
+		entry := contracts.WebhookOutboxEntry{}
+		if json.Unmarshal(data, &entry) == nil {
+			entries = append(entries, entry)
+		}
+		return nil
+	})
+
+	return entries
+}
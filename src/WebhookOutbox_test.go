@@ -0,0 +1,115 @@
+package main
+
+import (
+	"devChallengeExcel/contracts"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestWebhookOutbox_GetDue(t *testing.T) {
+	db, dbClose := _createTmpDb()
+	defer dbClose()
+
+	outbox := WebhookOutbox{}
+	now := time.Now().UTC()
+	notInFlight := func(string) bool { return false }
+
+	getDue := func(at time.Time, skip func(string) bool) (ids []string, nextAttemptAt time.Time) {
+		ids = make([]string, 0)
+		_ = db.View(func(tx contracts.StorageTx) error {
+			var entries []contracts.WebhookOutboxEntry
+			entries, nextAttemptAt = outbox.GetDue(tx, at, skip)
+			for _, entry := range entries {
+				ids = append(ids, entry.SubscriptionId+"/"+entry.Id)
+			}
+			return nil
+		})
+		return
+	}
+
+	add := func(subscriptionId string, nextAttemptAt time.Time) *contracts.WebhookOutboxEntry {
+		entry := &contracts.WebhookOutboxEntry{SubscriptionId: subscriptionId, NextAttemptAt: nextAttemptAt}
+		assert.NoError(t, db.Update(func(tx contracts.StorageTx) error {
+			return outbox.Add(tx, entry)
+		}))
+		return entry
+	}
+
+	ids, nextAttemptAt := getDue(now, notInFlight)
+	assert.Equal(t, []string{}, ids)
+	assert.True(t, nextAttemptAt.IsZero())
+
+	first := add("s1", now.Add(-time.Second))
+	add("s1", now.Add(-time.Minute))
+	later := add("s2", now.Add(time.Minute))
+	add("s3", now.Add(-time.Minute))
+
+	// only heads of subscriptions, ordered by due time
+	ids, nextAttemptAt = getDue(now, notInFlight)
+	assert.Equal(t, []string{"s3/0000000000000004", "s1/0000000000000001"}, ids)
+	assert.True(t, later.NextAttemptAt.Equal(nextAttemptAt))
+
+	ids, _ = getDue(now, func(id string) bool { return id == first.Id })
+	assert.Equal(t, []string{"s3/0000000000000004"}, ids)
+
+	// retry of the head moves it in due order
+	first.NextAttemptAt = now.Add(time.Second)
+	assert.NoError(t, db.Update(func(tx contracts.StorageTx) error {
+		return outbox.Put(tx, first)
+	}))
+	ids, nextAttemptAt = getDue(now, notInFlight)
+	assert.Equal(t, []string{"s3/0000000000000004"}, ids)
+	assert.True(t, first.NextAttemptAt.Equal(nextAttemptAt))
+
+	// the next entry of subscription becomes the head
+	assert.NoError(t, db.Update(func(tx contracts.StorageTx) error {
+		return outbox.MoveToDeadLetters(tx, first)
+	}))
+	ids, _ = getDue(now, notInFlight)
+	assert.Equal(t, []string{"s1/0000000000000002", "s3/0000000000000004"}, ids)
+
+	assert.NoError(t, db.Update(func(tx contracts.StorageTx) error {
+		return outbox.Delete(tx, "0000000000000004")
+	}))
+	ids, _ = getDue(now.Add(time.Hour), notInFlight)
+	assert.Equal(t, []string{"s1/0000000000000002", "s2/0000000000000003"}, ids)
+}
+
+func TestWebhookOutbox_RebuildIndex(t *testing.T) {
+	db, dbClose := _createTmpDb()
+	defer dbClose()
+
+	outbox := WebhookOutbox{}
+	now := time.Now().UTC()
+
+	// entries without index, as they were stored before
+	assert.NoError(t, db.Update(func(tx contracts.StorageTx) error {
+		bucket, err := tx.CreateBucketIfNotExists(webhookOutboxBucketId)
+		if err != nil {
+			return err
+		}
+		for _, entry := range []*contracts.WebhookOutboxEntry{
+			{Id: "0000000000000001", SubscriptionId: "s1", NextAttemptAt: now},
+			{Id: "0000000000000002", SubscriptionId: "s1", NextAttemptAt: now},
+			{Id: "0000000000000003", SubscriptionId: "s2", NextAttemptAt: now},
+		} {
+			if err = outbox.put(bucket, entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+
+	assert.NoError(t, db.Update(outbox.RebuildIndex))
+
+	ids := make([]string, 0)
+	_ = db.View(func(tx contracts.StorageTx) error {
+		entries, _ := outbox.GetDue(tx, now, func(string) bool { return false })
+		for _, entry := range entries {
+			ids = append(ids, entry.Id)
+		}
+		return nil
+	})
+	assert.Equal(t, []string{"0000000000000001", "0000000000000003"}, ids)
+}
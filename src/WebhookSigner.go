@@ -0,0 +1,123 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	WebhookIdHeader        = "X-Webhook-Id"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookSignatureHeader = "X-Webhook-Signature"
+
+	webhookSignaturePrefix = "sha256="
+)
+
+const DefaultWebhookSignatureTolerance = 5 * time.Minute
+
+var WebhookSignatureError = errors.New("invalid webhook signature")
+
+// WebhookSigner signs webhooks with shared secret: HMAC-SHA256 of `id.timestamp.trace.body`, where trace is
+// X-Change-Trace header (empty when there is none). Timestamp older than tolerance and already seen id (replay) are rejected.
+// Empty secret disables signing and verification (a warning is printed on start).
+type WebhookSigner struct {
+	secret    []byte
+	tolerance time.Duration
+
+	mutex  sync.Mutex
+	seenAt map[string]time.Time
+}
+
+func NewWebhookSigner(secret string, tolerance time.Duration) *WebhookSigner {
+	return &WebhookSigner{
+		secret:    []byte(secret),
+		tolerance: tolerance,
+		seenAt:    map[string]time.Time{},
+	}
+}
+
+func (s *WebhookSigner) Sign(request *http.Request, body []byte) {
+	if len(s.secret) == 0 {
+		return
+	}
+
+	idBytes := make([]byte, 16)
+	_, _ = rand.Read(idBytes)
+	id := hex.EncodeToString(idBytes)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	request.Header.Set(WebhookIdHeader, id)
+	request.Header.Set(WebhookTimestampHeader, timestamp)
+	request.Header.Set(WebhookSignatureHeader, webhookSignaturePrefix+s.signature(id, timestamp, request, body))
+}
+
+func (s *WebhookSigner) Verify(request *http.Request, body []byte) error {
+	if len(s.secret) == 0 {
+		return nil
+	}
+
+	id := request.Header.Get(WebhookIdHeader)
+	timestamp := request.Header.Get(WebhookTimestampHeader)
+	signature := request.Header.Get(WebhookSignatureHeader)
+	if id == "" || timestamp == "" || signature == "" {
+		return fmt.Errorf("%w: signature headers are missing", WebhookSignatureError)
+	}
+
+	unixTimestamp, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: invalid timestamp", WebhookSignatureError)
+	}
+
+	sentAt := time.Unix(unixTimestamp, 0)
+	if age := time.Since(sentAt); age > s.tolerance || age < -s.tolerance {
+		return fmt.Errorf("%w: timestamp is out of tolerance", WebhookSignatureError)
+	}
+
+	expected := webhookSignaturePrefix + s.signature(id, timestamp, request, body)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return fmt.Errorf("%w: signature mismatch", WebhookSignatureError)
+	}
+
+	if !s.markSeen(id) {
+		return fmt.Errorf("%w: webhook %s is already received", WebhookSignatureError, id)
+	}
+
+	return nil
+}
+
+// signature covers the trace as well, otherwise it could be replaced to forge or hide circular external_ref chain
+func (s *WebhookSigner) signature(id string, timestamp string, request *http.Request, body []byte) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(id + "." + timestamp + "." + request.Header.Get(ChangeTraceHeader) + "."))
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// markSeen returns false for replayed id. Ids older than tolerance are forgotten: their timestamp is rejected anyway
+func (s *WebhookSigner) markSeen(id string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	for seenId, seenAt := range s.seenAt {
+		if now.Sub(seenAt) > 2*s.tolerance {
+			delete(s.seenAt, seenId)
+		}
+	}
+
+	if _, ok := s.seenAt[id]; ok {
+		return false
+	}
+	s.seenAt[id] = now
+
+	return true
+}
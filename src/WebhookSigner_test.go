@@ -0,0 +1,89 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestWebhookSigner(t *testing.T) {
+	body := []byte(`{"value":"1","result":"1"}`)
+	newRequest := func() *http.Request {
+		request, _ := http.NewRequest(http.MethodPost, "http://peer/api/v1/sheet1/a1/externalRefWebhook", nil)
+		return request
+	}
+
+	t.Run("success", func(t *testing.T) {
+		signer := NewWebhookSigner("secret", time.Minute)
+		request := newRequest()
+		signer.Sign(request, body)
+
+		assert.Len(t, request.Header.Get(WebhookIdHeader), 32)
+		assert.NotEmpty(t, request.Header.Get(WebhookTimestampHeader))
+		assert.Regexp(t, "^sha256=[0-9a-f]{64}$", request.Header.Get(WebhookSignatureHeader))
+
+		assert.NoError(t, NewWebhookSigner("secret", time.Minute).Verify(request, body))
+
+		// with trace
+		request = newRequest()
+		request.Header.Set(ChangeTraceHeader, "remote/sheet1/a1")
+		signer.Sign(request, body)
+		assert.NoError(t, NewWebhookSigner("secret", time.Minute).Verify(request, body))
+	})
+
+	t.Run("replay", func(t *testing.T) {
+		signer := NewWebhookSigner("secret", time.Minute)
+		request := newRequest()
+		signer.Sign(request, body)
+
+		assert.NoError(t, signer.Verify(request, body))
+		assert.ErrorIs(t, signer.Verify(request, body), WebhookSignatureError)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		signer := NewWebhookSigner("secret", time.Minute)
+
+		request := newRequest()
+		assert.ErrorIs(t, signer.Verify(request, body), WebhookSignatureError, "unsigned")
+
+		request = newRequest()
+		NewWebhookSigner("other", time.Minute).Sign(request, body)
+		assert.ErrorIs(t, signer.Verify(request, body), WebhookSignatureError, "other secret")
+
+		request = newRequest()
+		signer.Sign(request, body)
+		assert.ErrorIs(t, signer.Verify(request, []byte(`{"value":"2"}`)), WebhookSignatureError, "changed body")
+
+		request = newRequest()
+		signer.Sign(request, body)
+		request.Header.Set(WebhookTimestampHeader, strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10))
+		assert.ErrorContains(t, signer.Verify(request, body), "tolerance", "expired")
+
+		request = newRequest()
+		request.Header.Set(ChangeTraceHeader, "remote/sheet1/a1")
+		signer.Sign(request, body)
+		request.Header.Set(ChangeTraceHeader, "other/sheet1/a1")
+		assert.ErrorIs(t, signer.Verify(request, body), WebhookSignatureError, "changed trace")
+
+		request.Header.Del(ChangeTraceHeader)
+		assert.ErrorIs(t, signer.Verify(request, body), WebhookSignatureError, "removed trace")
+
+		request.Header.Set(WebhookTimestampHeader, "yesterday")
+		assert.ErrorContains(t, signer.Verify(request, body), "invalid timestamp")
+	})
+
+	t.Run("without_secret", func(t *testing.T) {
+		signer := NewWebhookSigner("", time.Minute)
+		request := newRequest()
+		signer.Sign(request, body)
+		assert.Empty(t, request.Header.Get(WebhookSignatureHeader))
+
+		// signatures are not verified
+		assert.NoError(t, signer.Verify(request, body))
+		request = newRequest()
+		NewWebhookSigner("secret", time.Minute).Sign(request, body)
+		assert.NoError(t, signer.Verify(request, body))
+	})
+}
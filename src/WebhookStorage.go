@@ -0,0 +1,157 @@
+package main
+
+import (
+	"devChallengeExcel/contracts"
+	"errors"
+	json "github.com/bytedance/sonic"
+)
+
+// WebhookStorage keeps webhook subscriptions of cells and sheets (incoming subscriptions).
+// Cell subscriptions: single bucket with nested bucket per sheet and per cell (key is subscription id, value is subscription).
+// Sheet subscriptions: another bucket with nested bucket per sheet. Empty cell id means sheet subscription
+type WebhookStorage struct{}
+
+var webhooksBucketId = []byte("__webhooks")
+var sheetWebhooksBucketId = []byte("__sheet_webhooks")
+
+// GetAll returns subscriptions of all sheets (key is canonical sheet id)
+func (s *WebhookStorage) GetAll(tx contracts.StorageTx) map[string]SheetWebhooks {
+	webhooks := map[string]SheetWebhooks{}
+
+	bucket := tx.Bucket(webhooksBucketId)
+	if bucket == nil {
+		return webhooks
+	}
+
+	_ = bucket.ForEachBucket(func(sheetId []byte) error {
+		sheetBucket := bucket.Bucket(sheetId)
+		sheetWebhooks := SheetWebhooks{}
+
+		_ = sheetBucket.ForEachBucket(func(cellId []byte) error {
+			cellWebhooks := CellWebhooks{}
+			_ = sheetBucket.Bucket(cellId).ForEach(func(subscriptionId []byte, data []byte) error {
+				subscription := &contracts.WebhookSubscription{}
+				if json.Unmarshal(data, subscription) == nil {
+					cellWebhooks[string(subscriptionId)] = subscription
+				}
+				return nil
+			})
+			sheetWebhooks[string(cellId)] = cellWebhooks
+
+			return nil
+		})
+		webhooks[string(sheetId)] = sheetWebhooks
+
+		return nil
+	})
+
+	bucket = tx.Bucket(sheetWebhooksBucketId)
+	if bucket == nil {
+		return webhooks
+	}
+
+	_ = bucket.ForEachBucket(func(sheetId []byte) error {
+		sheetSubscriptions := CellWebhooks{}
+		_ = bucket.Bucket(sheetId).ForEach(func(subscriptionId []byte, data []byte) error {
+			subscription := &contracts.WebhookSubscription{}
+			if json.Unmarshal(data, subscription) == nil {
+				sheetSubscriptions[string(subscriptionId)] = subscription
+			}
+			return nil
+		})
+
+		if _, ok := webhooks[string(sheetId)]; !ok {
+			webhooks[string(sheetId)] = SheetWebhooks{}
+		}
+		webhooks[string(sheetId)][sheetScopeKey] = sheetSubscriptions
+
+		return nil
+	})
+
+	return webhooks
+}
+
+// Put adds or replaces subscription of the cell (of the sheet when cell id is empty)
+func (s *WebhookStorage) Put(tx contracts.StorageTx, sheetId []byte, cellId []byte, subscription *contracts.WebhookSubscription) error {
+	bucketId := webhooksBucketId
+	if len(cellId) == 0 {
+		bucketId = sheetWebhooksBucketId
+	}
+
+	bucket, err := tx.CreateBucketIfNotExists(bucketId)
+	if err != nil {
+		return err
+	}
+
+	bucket, err = bucket.CreateBucketIfNotExists(sheetId)
+	if err == nil && len(cellId) != 0 {
+		bucket, err = bucket.CreateBucketIfNotExists(cellId)
+	}
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(subscription)
+	if err != nil {
+		return err
+	}
+
+	return bucket.Put([]byte(subscription.Id), data)
+}
+
+func (s *WebhookStorage) Delete(tx contracts.StorageTx, sheetId []byte, cellId []byte, subscriptionId string) error {
+	bucketId := webhooksBucketId
+	if len(cellId) == 0 {
+		bucketId = sheetWebhooksBucketId
+	}
+
+	bucket := tx.Bucket(bucketId)
+	if bucket != nil {
+		bucket = bucket.Bucket(sheetId)
+	}
+	if bucket != nil && len(cellId) != 0 {
+		bucket = bucket.Bucket(cellId)
+	}
+	if bucket == nil {
+		return nil
+	}
+
+	return bucket.Delete([]byte(subscriptionId))
+}
+
+// DeleteCell removes all subscriptions of the cell
+func (s *WebhookStorage) DeleteCell(tx contracts.StorageTx, sheetId []byte, cellId []byte) error {
+	bucket := tx.Bucket(webhooksBucketId)
+	if bucket != nil {
+		bucket = bucket.Bucket(sheetId)
+	}
+	if bucket == nil {
+		return nil
+	}
+
+	return ignoreBucketNotFound(bucket.DeleteBucket(cellId))
+}
+
+// DeleteSheet removes subscriptions of the sheet and all its cells
+func (s *WebhookStorage) DeleteSheet(tx contracts.StorageTx, sheetId []byte) error {
+	for _, bucketId := range [][]byte{webhooksBucketId, sheetWebhooksBucketId} {
+		bucket := tx.Bucket(bucketId)
+		if bucket == nil {
+			continue
+		}
+
+		if err := ignoreBucketNotFound(bucket.DeleteBucket(sheetId)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func ignoreBucketNotFound(err error) error {
+	if errors.Is(err, contracts.StorageBucketNotFoundError) {
+		return nil
+	}
+
+	return err
+}
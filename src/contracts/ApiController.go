@@ -1,11 +1,38 @@
 package contracts
 
-import "github.com/gin-gonic/gin"
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+)
 
 type ApiController interface {
 	SetCellAction(c *gin.Context)
 	GetCellAction(c *gin.Context)
 	GetSheetAction(c *gin.Context)
+	GetCellHistoryAction(c *gin.Context)
+	UndoAction(c *gin.Context)
+	RedoAction(c *gin.Context)
+	DeleteCellAction(c *gin.Context)
+	DeleteSheetAction(c *gin.Context)
 	SubscribeAction(c *gin.Context)
+	GetSubscriptionsAction(c *gin.Context)
+	UnsubscribeAction(c *gin.Context)
+	SubscribeSheetAction(c *gin.Context)
+	GetSheetSubscriptionsAction(c *gin.Context)
+	UnsubscribeSheetAction(c *gin.Context)
+	GetDeliveriesAction(c *gin.Context)
+	GetSheetSubscriptionDeliveriesAction(c *gin.Context)
+	GetSheetDeliveriesAction(c *gin.Context)
+	ChangeEventsAction(c *gin.Context)
+	LiveSessionAction(c *gin.Context)
 	ExternalRefWebhookAction(c *gin.Context)
+	GetSettingsAction(c *gin.Context)
+	SetSettingsAction(c *gin.Context)
+	StatusAction(c *gin.Context)
+	GetDeadLettersAction(c *gin.Context)
+	ReplayDeadLetterAction(c *gin.Context)
+	DeleteDeadLetterAction(c *gin.Context)
+	GetExternalRefSubscriptionsAction(c *gin.Context)
+	// Shutdown waits until background tasks of requests (subscriptions to external refs) are completed or ctx is done
+	Shutdown(ctx context.Context) error
 }
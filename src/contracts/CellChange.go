@@ -0,0 +1,29 @@
+package contracts
+
+// ChangeCause why the cell is changed
+type ChangeCause string
+
+const (
+	ChangeCauseDirectEdit  ChangeCause = "direct_edit"
+	ChangeCauseDependency  ChangeCause = "dependency_recalculation"
+	ChangeCauseExternalRef ChangeCause = "external_ref_update"
+	ChangeCauseUndo        ChangeCause = "undo"
+	ChangeCauseRedo        ChangeCause = "redo"
+)
+
+// CellChange new state of the cell with its previous state, it is sent to webhooks of the cell
+type CellChange struct {
+	Cell *Cell
+	// CellId original (not canonical) id of the cell
+	CellId string
+	// Previous state of the cell, nil when the cell is created
+	Previous *Cell
+	Cause    ChangeCause
+	// CausedBy original id of the changed cell when this cell is recalculated as its dependant
+	CausedBy string
+}
+
+// IsResultChanged checks whether result of the cell differs from previous one (new cell is changed)
+func (c CellChange) IsResultChanged() bool {
+	return c.Previous == nil || c.Previous.Result != c.Cell.Result
+}
@@ -1,7 +1,5 @@
 package contracts
 
-import "go.etcd.io/bbolt"
-
 type CellDependencyTree interface {
 	// SetDependsOn
 	/**
@@ -12,7 +10,7 @@ type CellDependencyTree interface {
 	 * `cell5 = cell1 * cell3`
 	 * SetCellDependsOn("cell5", []string{"cell1", "cell3"})
 	 */
-	SetDependsOn(tx *bbolt.Tx, sheetId []byte, dependantCellId string, dependingOnCellIds []string) error
+	SetDependsOn(tx StorageTx, sheetId []byte, dependantCellId string, dependingOnCellIds []string) error
 
 	// GetDependants
 	/**
@@ -25,5 +23,11 @@ type CellDependencyTree interface {
 	 * Internally, it is stored as B+tree. It uses prefixed keys to store data in B-tree.
 	 * So it is possible to get all dependants of cellId in O(log(n)) time.
 	 */
-	GetDependants(tx *bbolt.Tx, sheetId []byte, dependingOnCellId string) []string
+	GetDependants(tx StorageTx, sheetId []byte, dependingOnCellId string) []string
+
+	// GetDependsOn returns cells which the cell depends on directly, as they were passed to SetDependsOn
+	GetDependsOn(tx StorageTx, sheetId []byte, dependantCellId string) []string
+
+	// DeleteSheet removes dependencies of all cells of the sheet
+	DeleteSheet(tx StorageTx, sheetId []byte) error
 }
@@ -0,0 +1,19 @@
+package contracts
+
+import "time"
+
+// CellVersion record of the cell history: single write of the cell
+type CellVersion struct {
+	// Version sequence number of the write of the cell, starts from 1
+	Version uint64 `json:"version"`
+	// CellId original (not canonical) id of the cell
+	CellId string `json:"cell_id"`
+	Value  string `json:"value"`
+	// Result of the cell just after the write
+	Result string `json:"result"`
+	// Deleted the cell is deleted by the write, value and result are empty
+	Deleted bool `json:"deleted,omitempty"`
+	// Source what caused the write
+	Source    ChangeCause `json:"source"`
+	Timestamp time.Time   `json:"timestamp"`
+}
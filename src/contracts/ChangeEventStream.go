@@ -0,0 +1,31 @@
+package contracts
+
+// ChangeEvent event of the sheet change stream. Payload is the same as webhook of the cell,
+// Sequence is id of the event in the stream of the sheet
+type ChangeEvent struct {
+	WebhookEvent
+	// CanonicalCellId of the changed cell to filter events by cells
+	CanonicalCellId string `json:"-"`
+}
+
+// ChangeStreamSubscription listener of the sheet change stream
+type ChangeStreamSubscription struct {
+	// Replay buffered events after the last event id of the listener
+	Replay []ChangeEvent
+	// Reset is set when events after the last event id are not in the replay buffer anymore
+	// (or the stream is restarted), the listener should reload the sheet
+	Reset bool
+	// Events new events of the sheet. It is closed when the listener falls behind, unsubscribes or the stream is closed
+	Events <-chan ChangeEvent
+}
+
+type ChangeEventStream interface {
+	// Notify publishes changed results of the sheet to listeners and keeps them in the replay buffer
+	Notify(canonicalSheetId string, changes []CellChange, origin ChangeOrigin)
+	// Subscribe adds listener of the sheet. Only events of the cells are sent (all cells when it is empty).
+	// Events after lastEventId are replayed from the buffer, 0 means no replay
+	Subscribe(canonicalSheetId string, canonicalCellIds []string, lastEventId uint64) *ChangeStreamSubscription
+	Unsubscribe(subscription *ChangeStreamSubscription)
+	// Close disconnects all listeners, e.g. on shutdown
+	Close()
+}
@@ -0,0 +1,13 @@
+package contracts
+
+import "slices"
+
+// ChangeOrigin propagation metadata of the change between API instances (external_ref webhooks)
+type ChangeOrigin struct {
+	// Trace ids of cells (`host/sheet/cell`) which the change has passed through
+	Trace []string
+}
+
+func (o ChangeOrigin) Contains(originId string) bool {
+	return slices.Contains(o.Trace, originId)
+}
@@ -0,0 +1,13 @@
+package contracts
+
+import (
+	"net/http"
+	"time"
+)
+
+type EgressPolicy interface {
+	// ValidateUrl checks url of outgoing request (external_ref, webhook) before it is accepted
+	ValidateUrl(rawUrl string) error
+	// NewClient returns http client which connects only to addresses allowed by the policy
+	NewClient(timeout time.Duration) *http.Client
+}
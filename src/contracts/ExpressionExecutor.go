@@ -5,4 +5,12 @@ type ExpressionExecutor interface {
 	MultiEvaluate(expressions ExpressionsMap, sheet CellValuesGetter, breakOnError bool) error
 	ExtractDependingOnList(expression string) (dependingOnCellIds []string)
 	ExtractExternalRefs(expression string) (externalRefs []string)
+	ExtractExternalJsonUrls(expression string) (urls []string)
+	// ValidateCondition checks webhook condition: boolean expression of `old` and `new` results of the cell (e.g. `new < 10 && old >= 10`)
+	ValidateCondition(condition string) error
+	// EvaluateCondition evaluates webhook condition with results of the cell, previousResult is nil for a new cell.
+	// Numeric results are numbers in the condition
+	EvaluateCondition(condition string, previousResult *string, result string) (bool, error)
+	// WithIteration returns executor which resolves circular references by iterative calculation
+	WithIteration(maxIterations int, epsilon float64) ExpressionExecutor
 }
@@ -0,0 +1,22 @@
+package contracts
+
+type ExternalRefFetcher interface {
+	// Fetch returns result of external cell by its url
+	Fetch(url string) (any, error)
+	// Invalidate marks cached result of external cell as stale, e.g. when it is changed (webhook is received).
+	// The origin of the change is passed to OnUpdate handler
+	Invalidate(url string, origin ChangeOrigin)
+	// MarkCircular fails external cell with err (circular reference between instances) until it is invalidated
+	MarkCircular(url string, err error)
+	// WatchCell registers urls which are referenced by the cell (external_ref and external_json).
+	// The cell is recalculated when their results are changed
+	WatchCell(sheetId string, cellId string, urls []string, jsonUrls []string)
+	// UnwatchSheet stops watching urls of all cells of the sheet
+	UnwatchSheet(sheetId string)
+	// OnUpdate sets handler to recalculate the cell
+	OnUpdate(handler func(sheetId string, cellId string, origin ChangeOrigin))
+	// Start polls watched JSON documents
+	Start()
+	// Close stops polling and waits for background refreshes
+	Close()
+}
@@ -0,0 +1,26 @@
+package contracts
+
+import (
+	"net/http"
+	"time"
+)
+
+const (
+	CircuitBreakerClosed   = "closed"
+	CircuitBreakerOpen     = "open"
+	CircuitBreakerHalfOpen = "half-open"
+)
+
+type CircuitBreakerStatus struct {
+	Host     string     `json:"host"`
+	State    string     `json:"state"`
+	Failures int        `json:"failures"`
+	OpenedAt *time.Time `json:"opened_at,omitempty"`
+}
+
+type OutboundClient interface {
+	// Do sends request with retries. It fails fast while circuit breaker of the host is open
+	Do(request *http.Request) (*http.Response, error)
+	// CircuitBreakers returns state of circuit breaker per host
+	CircuitBreakers() []CircuitBreakerStatus
+}
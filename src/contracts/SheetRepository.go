@@ -1,12 +1,45 @@
 package contracts
 
-import "errors"
+import (
+	"errors"
+	"time"
+)
 
 type SheetRepository interface {
 	SetCell(sheetId string, cellId string, value string, skipNotChanged bool) (*Cell, error, bool)
+	// RecalculateCell evaluates stored value of the cell again, origin of the change is passed to webhooks
+	RecalculateCell(sheetId string, cellId string, origin ChangeOrigin) (*Cell, error)
 	GetCell(sheetId string, cellId string) (*Cell, error)
 	GetCellList(sheetId string) (*CellList, error)
+	// ForEachCell calls fn with stored value of each cell of all sheets, internal buckets are skipped
+	ForEachCell(fn func(sheetId string, cellId string, value string)) error
+	// GetCellHistory returns up to limit recent versions of the cell (newest first), deleted cell has history as well
+	GetCellHistory(sheetId string, cellId string, limit int) ([]CellVersion, error)
+	// GetCellListAt evaluates cells of the sheet as they were at the moment according to history
+	GetCellListAt(sheetId string, at time.Time) (*CellList, error)
+	// DeleteCell removes the cell with its webhooks, returns urls of external cells which the cell was subscribed to
+	DeleteCell(sheetId string, cellId string) (ExternalRefSubscriptions, error)
+	// DeleteSheet removes the sheet with its webhooks, returns urls of external cells which its cells were subscribed to
+	DeleteSheet(sheetId string) (ExternalRefSubscriptions, error)
+	// Undo reverts up to steps last direct writes of cells of the sheet (values and dependencies),
+	// returns versions of the cells written by undo in order of reverting
+	Undo(sheetId string, steps int) ([]CellVersion, error)
+	// Redo writes again up to steps last undone writes, returns versions of the cells written by redo
+	Redo(sheetId string, steps int) ([]CellVersion, error)
 	GetCanonicalSheetId(sheetId string) string
+	GetCanonicalCellId(cellId string) string
+	GetSettings(sheetId string) (*SheetSettings, error)
+	SetSettings(sheetId string, settings SheetSettings) (*SheetSettings, error)
+	// GetExternalRefSubscriptions returns urls of external cells which the cell is subscribed to
+	GetExternalRefSubscriptions(sheetId string, cellId string) ([]string, error)
+	// SetExternalRefSubscriptions replaces urls of external cells which the cell is subscribed to, returns previous urls
+	SetExternalRefSubscriptions(sheetId string, cellId string, urls []string) (previousUrls []string, err error)
 }
 
+// ExternalRefSubscriptions urls of external cells which cells are subscribed to (key is canonical cell id)
+type ExternalRefSubscriptions map[string][]string
+
 var SheetNotFoundError = errors.New("sheet not found")
+var NothingToUndoError = errors.New("nothing to undo")
+var NothingToRedoError = errors.New("nothing to redo")
+var UndoEvaluationError = errors.New("restored cells can not be evaluated")
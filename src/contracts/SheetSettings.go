@@ -0,0 +1,22 @@
+package contracts
+
+// SheetSettings calculation options of the sheet
+type SheetSettings struct {
+	// Iterative allows intentional circular references (Excel-style iterative calculation).
+	// Cells in a cycle are evaluated repeatedly until results change less than Epsilon.
+	Iterative     bool    `json:"iterative"`
+	MaxIterations int     `json:"max_iterations"`
+	Epsilon       float64 `json:"epsilon"`
+}
+
+// DefaultMaxIterations and DefaultEpsilon are the same as Excel defaults
+const DefaultMaxIterations = 100
+const DefaultEpsilon = 0.001
+
+func NewSheetSettings() SheetSettings {
+	return SheetSettings{
+		Iterative:     false,
+		MaxIterations: DefaultMaxIterations,
+		Epsilon:       DefaultEpsilon,
+	}
+}
@@ -0,0 +1,65 @@
+package contracts
+
+import "errors"
+
+// Storage is transactional key/value storage with nested buckets. Keys of the bucket are ordered bytewise.
+// Values returned within transaction are valid only until the transaction is finished and must not be modified
+type Storage interface {
+	// View runs read-only transaction, writes return StorageTxNotWritableError
+	View(fn func(tx StorageTx) error) error
+	// Update runs read-write transaction. Changes are committed when fn returns nil and rolled back otherwise
+	Update(fn func(tx StorageTx) error) error
+	// Batch is Update which may be combined with concurrent ones, so fn may be called more than once
+	Batch(fn func(tx StorageTx) error) error
+	Close() error
+}
+
+// StorageTx gives access to root buckets
+type StorageTx interface {
+	// Bucket returns nil when the bucket does not exist
+	Bucket(name []byte) StorageBucket
+	CreateBucketIfNotExists(name []byte) (StorageBucket, error)
+	// DeleteBucket removes the bucket with nested buckets, returns StorageBucketNotFoundError when it does not exist
+	DeleteBucket(name []byte) error
+	// ForEachBucket calls fn for each root bucket in order of names
+	ForEachBucket(fn func(name []byte) error) error
+}
+
+// StorageBucket keeps values and nested buckets. Name of nested bucket can not be used as key of value and vice versa
+type StorageBucket interface {
+	// Get returns nil when the value does not exist
+	Get(key []byte) []byte
+	Put(key []byte, value []byte) error
+	// Delete does nothing when the value does not exist
+	Delete(key []byte) error
+	// Bucket returns nil when the nested bucket does not exist
+	Bucket(name []byte) StorageBucket
+	CreateBucketIfNotExists(name []byte) (StorageBucket, error)
+	// DeleteBucket removes nested bucket, returns StorageBucketNotFoundError when it does not exist
+	DeleteBucket(name []byte) error
+	// ForEach calls fn for each value in order of keys (nested buckets are skipped). The bucket must not be modified within fn
+	ForEach(fn func(key []byte, value []byte) error) error
+	// ForEachBucket calls fn for each nested bucket in order of names
+	ForEachBucket(fn func(name []byte) error) error
+	// Cursor iterates values of the bucket (nested buckets are skipped)
+	Cursor() StorageCursor
+	// NextSequence returns autoincrement integer of the bucket, the first one is 1
+	NextSequence() (uint64, error)
+}
+
+// StorageCursor returns nil key when there are no more values
+type StorageCursor interface {
+	First() (key []byte, value []byte)
+	Last() (key []byte, value []byte)
+	Next() (key []byte, value []byte)
+	Prev() (key []byte, value []byte)
+	// Seek moves to the key or to the next one when it does not exist
+	Seek(seek []byte) (key []byte, value []byte)
+}
+
+var StorageBucketNotFoundError = errors.New("bucket not found")
+var StorageIncompatibleValueError = errors.New("incompatible value: key is used by bucket or by value")
+var StorageKeyRequiredError = errors.New("key required")
+var StorageBucketNameRequiredError = errors.New("bucket name required")
+var StorageTxNotWritableError = errors.New("tx not writable")
+var StorageClosedError = errors.New("storage closed")
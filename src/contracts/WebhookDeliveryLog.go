@@ -0,0 +1,36 @@
+package contracts
+
+import "time"
+
+// WebhookDeliveryAttempt record of the delivery log: single attempt to send the webhook
+type WebhookDeliveryAttempt struct {
+	SubscriptionId string `json:"subscription_id"`
+	// CellId canonical id of subscribed cell, empty for sheet subscription
+	CellId string `json:"cell_id,omitempty"`
+	// WebhookId id of outbox entry, it is the same for retries of the webhook
+	WebhookId   string    `json:"webhook_id"`
+	Attempt     int       `json:"attempt"`
+	WebhookUrl  string    `json:"webhook_url"`
+	AttemptedAt time.Time `json:"attempted_at"`
+	Success     bool      `json:"success"`
+	// StatusCode HTTP status of the response, 0 when request is failed
+	StatusCode int    `json:"status_code"`
+	LatencyMs  int64  `json:"latency_ms"`
+	Error      string `json:"error,omitempty"`
+	// PayloadHash hex SHA-256 of the payload
+	PayloadHash string `json:"payload_hash"`
+}
+
+// WebhookSubscriptionStatus delivery status of the subscription, LastDelivery has current success/failure streak
+type WebhookSubscriptionStatus struct {
+	Id           string           `json:"id"`
+	CellId       string           `json:"cell_id,omitempty"`
+	WebhookUrl   string           `json:"webhook_url"`
+	LastDelivery *WebhookDelivery `json:"last_delivery"`
+}
+
+// WebhookDeliveryLog status of subscriptions and their recent delivery attempts (newest first)
+type WebhookDeliveryLog struct {
+	Subscriptions []WebhookSubscriptionStatus `json:"subscriptions"`
+	Deliveries    []WebhookDeliveryAttempt    `json:"deliveries"`
+}
@@ -1,9 +1,50 @@
 package contracts
 
+import "context"
+
 type WebhookDispatcher interface {
-	SetWebhookUrl(canonicalSheetId string, canonicalCellId string, webhookUrl string)
-	GetWebhookUrl(canonicalSheetId string, canonicalCellId string) string
-	Notify(canonicalSheetId string, cells []*Cell)
+	// Subscribe adds subscription to the cell. Subscription with the same url is reused (description and options are updated).
+	// Only changes of the result are sent unless AlwaysNotify is set, and only when Condition (if any) holds
+	Subscribe(canonicalSheetId string, canonicalCellId string, webhookUrl string, description string, options WebhookOptions) (*WebhookSubscription, error)
+	// GetSubscriptions returns subscriptions of the cell ordered by creation time
+	GetSubscriptions(canonicalSheetId string, canonicalCellId string) []WebhookSubscription
+	// Unsubscribe removes subscription by id, returns WebhookSubscriptionNotFoundError when it does not exist
+	Unsubscribe(canonicalSheetId string, canonicalCellId string, subscriptionId string) error
+	// UnsubscribeUrl removes subscriptions with the url, returns WebhookSubscriptionNotFoundError when there are none
+	UnsubscribeUrl(canonicalSheetId string, canonicalCellId string, webhookUrl string) error
+	// SubscribeSheet adds subscription to changes of the sheet (limited to the scope). Changed cells are sent in one batched event.
+	// Subscription with the same url is reused (description, scope and options are updated). Cells of the scope are canonical
+	SubscribeSheet(canonicalSheetId string, webhookUrl string, description string, scope WebhookScope, options WebhookOptions) (*WebhookSubscription, error)
+	// GetSheetSubscriptions returns subscriptions of the sheet ordered by creation time
+	GetSheetSubscriptions(canonicalSheetId string) []WebhookSubscription
+	// UnsubscribeSheet removes subscription of the sheet by id, returns WebhookSubscriptionNotFoundError when it does not exist
+	UnsubscribeSheet(canonicalSheetId string, subscriptionId string) error
+	// UnsubscribeSheetUrl removes subscriptions of the sheet with the url, returns WebhookSubscriptionNotFoundError when there are none
+	UnsubscribeSheetUrl(canonicalSheetId string, webhookUrl string) error
+	// GetDeliveryLog returns status and recent delivery attempts (up to limit) of the subscription of the cell,
+	// returns WebhookSubscriptionNotFoundError when it does not exist
+	GetDeliveryLog(canonicalSheetId string, canonicalCellId string, subscriptionId string, limit int) (*WebhookDeliveryLog, error)
+	// GetSheetSubscriptionDeliveryLog the same as GetDeliveryLog for subscription of the sheet
+	GetSheetSubscriptionDeliveryLog(canonicalSheetId string, subscriptionId string, limit int) (*WebhookDeliveryLog, error)
+	// GetSheetDeliveryLog returns status of all subscriptions of the sheet and its cells, and their recent delivery attempts
+	GetSheetDeliveryLog(canonicalSheetId string, limit int) (*WebhookDeliveryLog, error)
+	// DeleteWebhooks removes webhooks of deleted cell
+	DeleteWebhooks(canonicalSheetId string, canonicalCellId string) error
+	// DeleteSheetWebhooks removes webhooks of deleted sheet and all its cells
+	DeleteSheetWebhooks(canonicalSheetId string) error
+	// Notify stores webhooks of the changed cells in outbox (see WebhookEvent), they are sent in background
+	// and retried on failure. Origin trace is passed with webhook to detect circular chains
+	Notify(canonicalSheetId string, changes []CellChange, origin ChangeOrigin)
+	// GetDeadLetters returns webhooks which failed permanently (oldest first)
+	GetDeadLetters() ([]WebhookOutboxEntry, error)
+	// ReplayDeadLetter moves dead letter back to outbox after pending webhooks of its subscription,
+	// returns WebhookDeadLetterNotFoundError when it does not exist
+	ReplayDeadLetter(id string) error
+	// DeleteDeadLetter removes dead letter, returns WebhookDeadLetterNotFoundError when it does not exist
+	DeleteDeadLetter(id string) error
 	Start()
+	// Close stops workers, webhooks which are not delivered yet stay in outbox
 	Close()
+	// Shutdown stops accepting notifications, sends due webhooks of outbox until ctx is done and closes the dispatcher
+	Shutdown(ctx context.Context) error
 }
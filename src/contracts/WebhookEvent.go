@@ -0,0 +1,43 @@
+package contracts
+
+import "time"
+
+const WebhookEventVersion = 1
+
+const (
+	// CellChangedEventType event of cell subscription (WebhookEvent)
+	CellChangedEventType = "cell.changed"
+	// CellsChangedEventType batched event of sheet subscription (WebhookBatchEvent)
+	CellsChangedEventType = "cells.changed"
+)
+
+// WebhookEvent versioned payload of webhook. Id is the same for all subscribers (and retries) of the change,
+// Sequence is the number of webhook of the subscription (it increases by one with each webhook of the subscriber)
+type WebhookEvent struct {
+	Version   int       `json:"version"`
+	Id        string    `json:"id"`
+	Sequence  uint64    `json:"sequence"`
+	Type      string    `json:"type"`
+	Timestamp time.Time `json:"timestamp"`
+	SheetId   string    `json:"sheet_id"`
+	WebhookCellChange
+}
+
+// WebhookBatchEvent payload of sheet subscription: all changed cells of the sheet which match the subscription
+type WebhookBatchEvent struct {
+	Version   int                 `json:"version"`
+	Id        string              `json:"id"`
+	Sequence  uint64              `json:"sequence"`
+	Type      string              `json:"type"`
+	Timestamp time.Time           `json:"timestamp"`
+	SheetId   string              `json:"sheet_id"`
+	Changes   []WebhookCellChange `json:"changes"`
+}
+
+type WebhookCellChange struct {
+	CellId   string      `json:"cell_id"`
+	Cause    ChangeCause `json:"cause"`
+	CausedBy string      `json:"caused_by,omitempty"`
+	Previous *Cell       `json:"previous"`
+	Current  *Cell       `json:"current"`
+}
@@ -0,0 +1,27 @@
+package contracts
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// WebhookOutboxEntry webhook request waiting for delivery in outbox.
+// Entry which is failed permanently is kept as dead letter until it is replayed or deleted
+type WebhookOutboxEntry struct {
+	Id             string          `json:"id"`
+	SheetId        string          `json:"sheet_id"`
+	CellId         string          `json:"cell_id"`
+	SubscriptionId string          `json:"subscription_id"`
+	WebhookUrl     string          `json:"webhook_url"`
+	Payload        json.RawMessage `json:"payload"`
+	// Trace origin trace of the change (see ChangeOrigin)
+	Trace         []string  `json:"trace,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	Attempts      int       `json:"attempts"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+	LastAttemptAt time.Time `json:"last_attempt_at"`
+	LastError     string    `json:"last_error,omitempty"`
+}
+
+var WebhookDeadLetterNotFoundError = errors.New("webhook dead letter not found")
@@ -0,0 +1,10 @@
+package contracts
+
+import "net/http"
+
+type WebhookSigner interface {
+	// Sign adds signature headers to outgoing webhook request, signed headers (change trace) must be set before
+	Sign(request *http.Request, body []byte)
+	// Verify checks signature headers of incoming webhook request (incl. timestamp and replay)
+	Verify(request *http.Request, body []byte) error
+}
@@ -0,0 +1,56 @@
+package contracts
+
+import (
+	"errors"
+	"time"
+)
+
+// WebhookSubscription subscriber of the cell or the sheet, the cell (sheet) can have several subscriptions
+type WebhookSubscription struct {
+	Id          string `json:"id"`
+	WebhookUrl  string `json:"webhook_url"`
+	Description string `json:"description"`
+	WebhookScope
+	WebhookOptions
+	// Sequence of the last webhook of the subscription, webhooks are numbered from 1
+	Sequence     uint64           `json:"sequence"`
+	CreatedAt    time.Time        `json:"created_at"`
+	LastDelivery *WebhookDelivery `json:"last_delivery"`
+}
+
+// WebhookScope cells of sheet subscription: listed cells, cells of A1 range (e.g. `A1:C10`) or all cells when it is empty
+type WebhookScope struct {
+	Cells []string `json:"cells,omitempty"`
+	Range string   `json:"range,omitempty"`
+}
+
+// WebhookOptions which changes of the cell are sent to the subscriber
+type WebhookOptions struct {
+	// AlwaysNotify sends changes of dependants even if their result is not changed
+	AlwaysNotify bool `json:"always_notify"`
+	// Condition boolean expression of `old` and `new` results of the cell (e.g. `new < 10 && old >= 10`),
+	// the change is sent only when it holds
+	Condition string `json:"condition,omitempty"`
+	// DebounceMs window (milliseconds) which starts with the first change: changes during it are sent as one webhook,
+	// repeated changes of the same cell are collapsed into one with the latest result
+	DebounceMs uint32 `json:"debounce_ms,omitempty"`
+}
+
+// Debounce window of the subscription, zero when changes are sent immediately
+func (o WebhookOptions) Debounce() time.Duration {
+	return time.Duration(o.DebounceMs) * time.Millisecond
+}
+
+// WebhookDelivery status of the webhook request
+type WebhookDelivery struct {
+	DeliveredAt time.Time `json:"delivered_at"`
+	Success     bool      `json:"success"`
+	// StatusCode HTTP status of the response, 0 when request is failed
+	StatusCode int    `json:"status_code"`
+	Error      string `json:"error,omitempty"`
+	// SuccessStreak successful deliveries in a row up to this one, FailureStreak failed ones (one of them is zero)
+	SuccessStreak int `json:"success_streak"`
+	FailureStreak int `json:"failure_streak"`
+}
+
+var WebhookSubscriptionNotFoundError = errors.New("webhook subscription not found")
@@ -3,7 +3,10 @@
 package mocks
 
 import (
+	context "context"
+
 	gin "github.com/gin-gonic/gin"
+
 	mock "github.com/stretchr/testify/mock"
 )
 
@@ -12,6 +15,26 @@ type ApiController struct {
 	mock.Mock
 }
 
+// ChangeEventsAction provides a mock function with given fields: c
+func (_m *ApiController) ChangeEventsAction(c *gin.Context) {
+	_m.Called(c)
+}
+
+// DeleteCellAction provides a mock function with given fields: c
+func (_m *ApiController) DeleteCellAction(c *gin.Context) {
+	_m.Called(c)
+}
+
+// DeleteDeadLetterAction provides a mock function with given fields: c
+func (_m *ApiController) DeleteDeadLetterAction(c *gin.Context) {
+	_m.Called(c)
+}
+
+// DeleteSheetAction provides a mock function with given fields: c
+func (_m *ApiController) DeleteSheetAction(c *gin.Context) {
+	_m.Called(c)
+}
+
 // ExternalRefWebhookAction provides a mock function with given fields: c
 func (_m *ApiController) ExternalRefWebhookAction(c *gin.Context) {
 	_m.Called(c)
@@ -22,21 +45,125 @@ func (_m *ApiController) GetCellAction(c *gin.Context) {
 	_m.Called(c)
 }
 
+// GetCellHistoryAction provides a mock function with given fields: c
+func (_m *ApiController) GetCellHistoryAction(c *gin.Context) {
+	_m.Called(c)
+}
+
+// GetDeadLettersAction provides a mock function with given fields: c
+func (_m *ApiController) GetDeadLettersAction(c *gin.Context) {
+	_m.Called(c)
+}
+
+// GetDeliveriesAction provides a mock function with given fields: c
+func (_m *ApiController) GetDeliveriesAction(c *gin.Context) {
+	_m.Called(c)
+}
+
+// GetExternalRefSubscriptionsAction provides a mock function with given fields: c
+func (_m *ApiController) GetExternalRefSubscriptionsAction(c *gin.Context) {
+	_m.Called(c)
+}
+
+// GetSettingsAction provides a mock function with given fields: c
+func (_m *ApiController) GetSettingsAction(c *gin.Context) {
+	_m.Called(c)
+}
+
 // GetSheetAction provides a mock function with given fields: c
 func (_m *ApiController) GetSheetAction(c *gin.Context) {
 	_m.Called(c)
 }
 
+// GetSheetDeliveriesAction provides a mock function with given fields: c
+func (_m *ApiController) GetSheetDeliveriesAction(c *gin.Context) {
+	_m.Called(c)
+}
+
+// GetSheetSubscriptionDeliveriesAction provides a mock function with given fields: c
+func (_m *ApiController) GetSheetSubscriptionDeliveriesAction(c *gin.Context) {
+	_m.Called(c)
+}
+
+// GetSheetSubscriptionsAction provides a mock function with given fields: c
+func (_m *ApiController) GetSheetSubscriptionsAction(c *gin.Context) {
+	_m.Called(c)
+}
+
+// GetSubscriptionsAction provides a mock function with given fields: c
+func (_m *ApiController) GetSubscriptionsAction(c *gin.Context) {
+	_m.Called(c)
+}
+
+// LiveSessionAction provides a mock function with given fields: c
+func (_m *ApiController) LiveSessionAction(c *gin.Context) {
+	_m.Called(c)
+}
+
+// RedoAction provides a mock function with given fields: c
+func (_m *ApiController) RedoAction(c *gin.Context) {
+	_m.Called(c)
+}
+
+// ReplayDeadLetterAction provides a mock function with given fields: c
+func (_m *ApiController) ReplayDeadLetterAction(c *gin.Context) {
+	_m.Called(c)
+}
+
 // SetCellAction provides a mock function with given fields: c
 func (_m *ApiController) SetCellAction(c *gin.Context) {
 	_m.Called(c)
 }
 
+// SetSettingsAction provides a mock function with given fields: c
+func (_m *ApiController) SetSettingsAction(c *gin.Context) {
+	_m.Called(c)
+}
+
+// Shutdown provides a mock function with given fields: ctx
+func (_m *ApiController) Shutdown(ctx context.Context) error {
+	ret := _m.Called(ctx)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// StatusAction provides a mock function with given fields: c
+func (_m *ApiController) StatusAction(c *gin.Context) {
+	_m.Called(c)
+}
+
 // SubscribeAction provides a mock function with given fields: c
 func (_m *ApiController) SubscribeAction(c *gin.Context) {
 	_m.Called(c)
 }
 
+// SubscribeSheetAction provides a mock function with given fields: c
+func (_m *ApiController) SubscribeSheetAction(c *gin.Context) {
+	_m.Called(c)
+}
+
+// UndoAction provides a mock function with given fields: c
+func (_m *ApiController) UndoAction(c *gin.Context) {
+	_m.Called(c)
+}
+
+// UnsubscribeAction provides a mock function with given fields: c
+func (_m *ApiController) UnsubscribeAction(c *gin.Context) {
+	_m.Called(c)
+}
+
+// UnsubscribeSheetAction provides a mock function with given fields: c
+func (_m *ApiController) UnsubscribeSheetAction(c *gin.Context) {
+	_m.Called(c)
+}
+
 type mockConstructorTestingTNewApiController interface {
 	mock.TestingT
 	Cleanup(func())
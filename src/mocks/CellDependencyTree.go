@@ -3,7 +3,7 @@
 package mocks
 
 import (
-	bbolt "go.etcd.io/bbolt"
+	contracts "devChallengeExcel/contracts"
 
 	mock "github.com/stretchr/testify/mock"
 )
@@ -13,12 +13,26 @@ type CellDependencyTree struct {
 	mock.Mock
 }
 
+// DeleteSheet provides a mock function with given fields: tx, sheetId
+func (_m *CellDependencyTree) DeleteSheet(tx contracts.StorageTx, sheetId []byte) error {
+	ret := _m.Called(tx, sheetId)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(contracts.StorageTx, []byte) error); ok {
+		r0 = rf(tx, sheetId)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // GetDependants provides a mock function with given fields: tx, sheetId, dependingOnCellId
-func (_m *CellDependencyTree) GetDependants(tx *bbolt.Tx, sheetId []byte, dependingOnCellId string) []string {
+func (_m *CellDependencyTree) GetDependants(tx contracts.StorageTx, sheetId []byte, dependingOnCellId string) []string {
 	ret := _m.Called(tx, sheetId, dependingOnCellId)
 
 	var r0 []string
-	if rf, ok := ret.Get(0).(func(*bbolt.Tx, []byte, string) []string); ok {
+	if rf, ok := ret.Get(0).(func(contracts.StorageTx, []byte, string) []string); ok {
 		r0 = rf(tx, sheetId, dependingOnCellId)
 	} else {
 		if ret.Get(0) != nil {
@@ -29,12 +43,28 @@ func (_m *CellDependencyTree) GetDependants(tx *bbolt.Tx, sheetId []byte, depend
 	return r0
 }
 
+// GetDependsOn provides a mock function with given fields: tx, sheetId, dependantCellId
+func (_m *CellDependencyTree) GetDependsOn(tx contracts.StorageTx, sheetId []byte, dependantCellId string) []string {
+	ret := _m.Called(tx, sheetId, dependantCellId)
+
+	var r0 []string
+	if rf, ok := ret.Get(0).(func(contracts.StorageTx, []byte, string) []string); ok {
+		r0 = rf(tx, sheetId, dependantCellId)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	return r0
+}
+
 // SetDependsOn provides a mock function with given fields: tx, sheetId, dependantCellId, dependingOnCellIds
-func (_m *CellDependencyTree) SetDependsOn(tx *bbolt.Tx, sheetId []byte, dependantCellId string, dependingOnCellIds []string) error {
+func (_m *CellDependencyTree) SetDependsOn(tx contracts.StorageTx, sheetId []byte, dependantCellId string, dependingOnCellIds []string) error {
 	ret := _m.Called(tx, sheetId, dependantCellId, dependingOnCellIds)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(*bbolt.Tx, []byte, string, []string) error); ok {
+	if rf, ok := ret.Get(0).(func(contracts.StorageTx, []byte, string, []string) error); ok {
 		r0 = rf(tx, sheetId, dependantCellId, dependingOnCellIds)
 	} else {
 		r0 = ret.Error(0)
@@ -0,0 +1,60 @@
+// Code generated by mockery v2.28.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	contracts "devChallengeExcel/contracts"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// ChangeEventStream is an autogenerated mock type for the ChangeEventStream type
+type ChangeEventStream struct {
+	mock.Mock
+}
+
+// Close provides a mock function with given fields:
+func (_m *ChangeEventStream) Close() {
+	_m.Called()
+}
+
+// Notify provides a mock function with given fields: canonicalSheetId, changes, origin
+func (_m *ChangeEventStream) Notify(canonicalSheetId string, changes []contracts.CellChange, origin contracts.ChangeOrigin) {
+	_m.Called(canonicalSheetId, changes, origin)
+}
+
+// Subscribe provides a mock function with given fields: canonicalSheetId, canonicalCellIds, lastEventId
+func (_m *ChangeEventStream) Subscribe(canonicalSheetId string, canonicalCellIds []string, lastEventId uint64) *contracts.ChangeStreamSubscription {
+	ret := _m.Called(canonicalSheetId, canonicalCellIds, lastEventId)
+
+	var r0 *contracts.ChangeStreamSubscription
+	if rf, ok := ret.Get(0).(func(string, []string, uint64) *contracts.ChangeStreamSubscription); ok {
+		r0 = rf(canonicalSheetId, canonicalCellIds, lastEventId)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*contracts.ChangeStreamSubscription)
+		}
+	}
+
+	return r0
+}
+
+// Unsubscribe provides a mock function with given fields: subscription
+func (_m *ChangeEventStream) Unsubscribe(subscription *contracts.ChangeStreamSubscription) {
+	_m.Called(subscription)
+}
+
+type mockConstructorTestingTNewChangeEventStream interface {
+	mock.TestingT
+	Cleanup(func())
+}
+
+// NewChangeEventStream creates a new instance of ChangeEventStream. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+func NewChangeEventStream(t mockConstructorTestingTNewChangeEventStream) *ChangeEventStream {
+	mock := &ChangeEventStream{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
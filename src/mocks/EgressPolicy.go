@@ -0,0 +1,60 @@
+// Code generated by mockery v2.28.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	http "net/http"
+	time "time"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// EgressPolicy is an autogenerated mock type for the EgressPolicy type
+type EgressPolicy struct {
+	mock.Mock
+}
+
+// NewClient provides a mock function with given fields: timeout
+func (_m *EgressPolicy) NewClient(timeout time.Duration) *http.Client {
+	ret := _m.Called(timeout)
+
+	var r0 *http.Client
+	if rf, ok := ret.Get(0).(func(time.Duration) *http.Client); ok {
+		r0 = rf(timeout)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*http.Client)
+		}
+	}
+
+	return r0
+}
+
+// ValidateUrl provides a mock function with given fields: rawUrl
+func (_m *EgressPolicy) ValidateUrl(rawUrl string) error {
+	ret := _m.Called(rawUrl)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string) error); ok {
+		r0 = rf(rawUrl)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+type mockConstructorTestingTNewEgressPolicy interface {
+	mock.TestingT
+	Cleanup(func())
+}
+
+// NewEgressPolicy creates a new instance of EgressPolicy. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+func NewEgressPolicy(t mockConstructorTestingTNewEgressPolicy) *EgressPolicy {
+	mock := &EgressPolicy{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -37,6 +37,30 @@ func (_m *ExpressionExecutor) Evaluate(expression string, sheet contracts.CellVa
 	return r0, r1
 }
 
+// EvaluateCondition provides a mock function with given fields: condition, previousResult, result
+func (_m *ExpressionExecutor) EvaluateCondition(condition string, previousResult *string, result string) (bool, error) {
+	ret := _m.Called(condition, previousResult, result)
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, *string, string) (bool, error)); ok {
+		return rf(condition, previousResult, result)
+	}
+	if rf, ok := ret.Get(0).(func(string, *string, string) bool); ok {
+		r0 = rf(condition, previousResult, result)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(string, *string, string) error); ok {
+		r1 = rf(condition, previousResult, result)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // ExtractDependingOnList provides a mock function with given fields: expression
 func (_m *ExpressionExecutor) ExtractDependingOnList(expression string) []string {
 	ret := _m.Called(expression)
@@ -53,6 +77,22 @@ func (_m *ExpressionExecutor) ExtractDependingOnList(expression string) []string
 	return r0
 }
 
+// ExtractExternalJsonUrls provides a mock function with given fields: expression
+func (_m *ExpressionExecutor) ExtractExternalJsonUrls(expression string) []string {
+	ret := _m.Called(expression)
+
+	var r0 []string
+	if rf, ok := ret.Get(0).(func(string) []string); ok {
+		r0 = rf(expression)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	return r0
+}
+
 // ExtractExternalRefs provides a mock function with given fields: expression
 func (_m *ExpressionExecutor) ExtractExternalRefs(expression string) []string {
 	ret := _m.Called(expression)
@@ -83,6 +123,36 @@ func (_m *ExpressionExecutor) MultiEvaluate(expressions contracts.ExpressionsMap
 	return r0
 }
 
+// ValidateCondition provides a mock function with given fields: condition
+func (_m *ExpressionExecutor) ValidateCondition(condition string) error {
+	ret := _m.Called(condition)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string) error); ok {
+		r0 = rf(condition)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// WithIteration provides a mock function with given fields: maxIterations, epsilon
+func (_m *ExpressionExecutor) WithIteration(maxIterations int, epsilon float64) contracts.ExpressionExecutor {
+	ret := _m.Called(maxIterations, epsilon)
+
+	var r0 contracts.ExpressionExecutor
+	if rf, ok := ret.Get(0).(func(int, float64) contracts.ExpressionExecutor); ok {
+		r0 = rf(maxIterations, epsilon)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(contracts.ExpressionExecutor)
+		}
+	}
+
+	return r0
+}
+
 type mockConstructorTestingTNewExpressionExecutor interface {
 	mock.TestingT
 	Cleanup(func())
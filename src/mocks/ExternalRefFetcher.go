@@ -0,0 +1,90 @@
+// Code generated by mockery v2.28.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	contracts "devChallengeExcel/contracts"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// ExternalRefFetcher is an autogenerated mock type for the ExternalRefFetcher type
+type ExternalRefFetcher struct {
+	mock.Mock
+}
+
+// Close provides a mock function with given fields:
+func (_m *ExternalRefFetcher) Close() {
+	_m.Called()
+}
+
+// Fetch provides a mock function with given fields: url
+func (_m *ExternalRefFetcher) Fetch(url string) (interface{}, error) {
+	ret := _m.Called(url)
+
+	var r0 interface{}
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string) (interface{}, error)); ok {
+		return rf(url)
+	}
+	if rf, ok := ret.Get(0).(func(string) interface{}); ok {
+		r0 = rf(url)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(interface{})
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(url)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Invalidate provides a mock function with given fields: url, origin
+func (_m *ExternalRefFetcher) Invalidate(url string, origin contracts.ChangeOrigin) {
+	_m.Called(url, origin)
+}
+
+// MarkCircular provides a mock function with given fields: url, err
+func (_m *ExternalRefFetcher) MarkCircular(url string, err error) {
+	_m.Called(url, err)
+}
+
+// OnUpdate provides a mock function with given fields: handler
+func (_m *ExternalRefFetcher) OnUpdate(handler func(string, string, contracts.ChangeOrigin)) {
+	_m.Called(handler)
+}
+
+// Start provides a mock function with given fields:
+func (_m *ExternalRefFetcher) Start() {
+	_m.Called()
+}
+
+// UnwatchSheet provides a mock function with given fields: sheetId
+func (_m *ExternalRefFetcher) UnwatchSheet(sheetId string) {
+	_m.Called(sheetId)
+}
+
+// WatchCell provides a mock function with given fields: sheetId, cellId, urls, jsonUrls
+func (_m *ExternalRefFetcher) WatchCell(sheetId string, cellId string, urls []string, jsonUrls []string) {
+	_m.Called(sheetId, cellId, urls, jsonUrls)
+}
+
+type mockConstructorTestingTNewExternalRefFetcher interface {
+	mock.TestingT
+	Cleanup(func())
+}
+
+// NewExternalRefFetcher creates a new instance of ExternalRefFetcher. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+func NewExternalRefFetcher(t mockConstructorTestingTNewExternalRefFetcher) *ExternalRefFetcher {
+	mock := &ExternalRefFetcher{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,72 @@
+// Code generated by mockery v2.28.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	contracts "devChallengeExcel/contracts"
+	http "net/http"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// OutboundClient is an autogenerated mock type for the OutboundClient type
+type OutboundClient struct {
+	mock.Mock
+}
+
+// CircuitBreakers provides a mock function with given fields:
+func (_m *OutboundClient) CircuitBreakers() []contracts.CircuitBreakerStatus {
+	ret := _m.Called()
+
+	var r0 []contracts.CircuitBreakerStatus
+	if rf, ok := ret.Get(0).(func() []contracts.CircuitBreakerStatus); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]contracts.CircuitBreakerStatus)
+		}
+	}
+
+	return r0
+}
+
+// Do provides a mock function with given fields: request
+func (_m *OutboundClient) Do(request *http.Request) (*http.Response, error) {
+	ret := _m.Called(request)
+
+	var r0 *http.Response
+	var r1 error
+	if rf, ok := ret.Get(0).(func(*http.Request) (*http.Response, error)); ok {
+		return rf(request)
+	}
+	if rf, ok := ret.Get(0).(func(*http.Request) *http.Response); ok {
+		r0 = rf(request)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*http.Response)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(*http.Request) error); ok {
+		r1 = rf(request)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+type mockConstructorTestingTNewOutboundClient interface {
+	mock.TestingT
+	Cleanup(func())
+}
+
+// NewOutboundClient creates a new instance of OutboundClient. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+func NewOutboundClient(t mockConstructorTestingTNewOutboundClient) *OutboundClient {
+	mock := &OutboundClient{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
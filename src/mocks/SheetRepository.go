@@ -6,6 +6,8 @@ import (
 	contracts "devChallengeExcel/contracts"
 
 	mock "github.com/stretchr/testify/mock"
+
+	time "time"
 )
 
 // SheetRepository is an autogenerated mock type for the SheetRepository type
@@ -13,6 +15,86 @@ type SheetRepository struct {
 	mock.Mock
 }
 
+// DeleteCell provides a mock function with given fields: sheetId, cellId
+func (_m *SheetRepository) DeleteCell(sheetId string, cellId string) (contracts.ExternalRefSubscriptions, error) {
+	ret := _m.Called(sheetId, cellId)
+
+	var r0 contracts.ExternalRefSubscriptions
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, string) (contracts.ExternalRefSubscriptions, error)); ok {
+		return rf(sheetId, cellId)
+	}
+	if rf, ok := ret.Get(0).(func(string, string) contracts.ExternalRefSubscriptions); ok {
+		r0 = rf(sheetId, cellId)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(contracts.ExternalRefSubscriptions)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string, string) error); ok {
+		r1 = rf(sheetId, cellId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DeleteSheet provides a mock function with given fields: sheetId
+func (_m *SheetRepository) DeleteSheet(sheetId string) (contracts.ExternalRefSubscriptions, error) {
+	ret := _m.Called(sheetId)
+
+	var r0 contracts.ExternalRefSubscriptions
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string) (contracts.ExternalRefSubscriptions, error)); ok {
+		return rf(sheetId)
+	}
+	if rf, ok := ret.Get(0).(func(string) contracts.ExternalRefSubscriptions); ok {
+		r0 = rf(sheetId)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(contracts.ExternalRefSubscriptions)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(sheetId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ForEachCell provides a mock function with given fields: fn
+func (_m *SheetRepository) ForEachCell(fn func(string, string, string)) error {
+	ret := _m.Called(fn)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(func(string, string, string)) error); ok {
+		r0 = rf(fn)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetCanonicalCellId provides a mock function with given fields: cellId
+func (_m *SheetRepository) GetCanonicalCellId(cellId string) string {
+	ret := _m.Called(cellId)
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func(string) string); ok {
+		r0 = rf(cellId)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	return r0
+}
+
 // GetCanonicalSheetId provides a mock function with given fields: sheetId
 func (_m *SheetRepository) GetCanonicalSheetId(sheetId string) string {
 	ret := _m.Called(sheetId)
@@ -53,6 +135,32 @@ func (_m *SheetRepository) GetCell(sheetId string, cellId string) (*contracts.Ce
 	return r0, r1
 }
 
+// GetCellHistory provides a mock function with given fields: sheetId, cellId, limit
+func (_m *SheetRepository) GetCellHistory(sheetId string, cellId string, limit int) ([]contracts.CellVersion, error) {
+	ret := _m.Called(sheetId, cellId, limit)
+
+	var r0 []contracts.CellVersion
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, string, int) ([]contracts.CellVersion, error)); ok {
+		return rf(sheetId, cellId, limit)
+	}
+	if rf, ok := ret.Get(0).(func(string, string, int) []contracts.CellVersion); ok {
+		r0 = rf(sheetId, cellId, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]contracts.CellVersion)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string, string, int) error); ok {
+		r1 = rf(sheetId, cellId, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetCellList provides a mock function with given fields: sheetId
 func (_m *SheetRepository) GetCellList(sheetId string) (*contracts.CellList, error) {
 	ret := _m.Called(sheetId)
@@ -79,6 +187,136 @@ func (_m *SheetRepository) GetCellList(sheetId string) (*contracts.CellList, err
 	return r0, r1
 }
 
+// GetCellListAt provides a mock function with given fields: sheetId, at
+func (_m *SheetRepository) GetCellListAt(sheetId string, at time.Time) (*contracts.CellList, error) {
+	ret := _m.Called(sheetId, at)
+
+	var r0 *contracts.CellList
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, time.Time) (*contracts.CellList, error)); ok {
+		return rf(sheetId, at)
+	}
+	if rf, ok := ret.Get(0).(func(string, time.Time) *contracts.CellList); ok {
+		r0 = rf(sheetId, at)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*contracts.CellList)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string, time.Time) error); ok {
+		r1 = rf(sheetId, at)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetExternalRefSubscriptions provides a mock function with given fields: sheetId, cellId
+func (_m *SheetRepository) GetExternalRefSubscriptions(sheetId string, cellId string) ([]string, error) {
+	ret := _m.Called(sheetId, cellId)
+
+	var r0 []string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, string) ([]string, error)); ok {
+		return rf(sheetId, cellId)
+	}
+	if rf, ok := ret.Get(0).(func(string, string) []string); ok {
+		r0 = rf(sheetId, cellId)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string, string) error); ok {
+		r1 = rf(sheetId, cellId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetSettings provides a mock function with given fields: sheetId
+func (_m *SheetRepository) GetSettings(sheetId string) (*contracts.SheetSettings, error) {
+	ret := _m.Called(sheetId)
+
+	var r0 *contracts.SheetSettings
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string) (*contracts.SheetSettings, error)); ok {
+		return rf(sheetId)
+	}
+	if rf, ok := ret.Get(0).(func(string) *contracts.SheetSettings); ok {
+		r0 = rf(sheetId)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*contracts.SheetSettings)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(sheetId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RecalculateCell provides a mock function with given fields: sheetId, cellId, origin
+func (_m *SheetRepository) RecalculateCell(sheetId string, cellId string, origin contracts.ChangeOrigin) (*contracts.Cell, error) {
+	ret := _m.Called(sheetId, cellId, origin)
+
+	var r0 *contracts.Cell
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, string, contracts.ChangeOrigin) (*contracts.Cell, error)); ok {
+		return rf(sheetId, cellId, origin)
+	}
+	if rf, ok := ret.Get(0).(func(string, string, contracts.ChangeOrigin) *contracts.Cell); ok {
+		r0 = rf(sheetId, cellId, origin)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*contracts.Cell)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string, string, contracts.ChangeOrigin) error); ok {
+		r1 = rf(sheetId, cellId, origin)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Redo provides a mock function with given fields: sheetId, steps
+func (_m *SheetRepository) Redo(sheetId string, steps int) ([]contracts.CellVersion, error) {
+	ret := _m.Called(sheetId, steps)
+
+	var r0 []contracts.CellVersion
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, int) ([]contracts.CellVersion, error)); ok {
+		return rf(sheetId, steps)
+	}
+	if rf, ok := ret.Get(0).(func(string, int) []contracts.CellVersion); ok {
+		r0 = rf(sheetId, steps)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]contracts.CellVersion)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string, int) error); ok {
+		r1 = rf(sheetId, steps)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // SetCell provides a mock function with given fields: sheetId, cellId, value, skipNotChanged
 func (_m *SheetRepository) SetCell(sheetId string, cellId string, value string, skipNotChanged bool) (*contracts.Cell, error, bool) {
 	ret := _m.Called(sheetId, cellId, value, skipNotChanged)
@@ -112,6 +350,84 @@ func (_m *SheetRepository) SetCell(sheetId string, cellId string, value string,
 	return r0, r1, r2
 }
 
+// SetExternalRefSubscriptions provides a mock function with given fields: sheetId, cellId, urls
+func (_m *SheetRepository) SetExternalRefSubscriptions(sheetId string, cellId string, urls []string) ([]string, error) {
+	ret := _m.Called(sheetId, cellId, urls)
+
+	var r0 []string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, string, []string) ([]string, error)); ok {
+		return rf(sheetId, cellId, urls)
+	}
+	if rf, ok := ret.Get(0).(func(string, string, []string) []string); ok {
+		r0 = rf(sheetId, cellId, urls)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string, string, []string) error); ok {
+		r1 = rf(sheetId, cellId, urls)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SetSettings provides a mock function with given fields: sheetId, settings
+func (_m *SheetRepository) SetSettings(sheetId string, settings contracts.SheetSettings) (*contracts.SheetSettings, error) {
+	ret := _m.Called(sheetId, settings)
+
+	var r0 *contracts.SheetSettings
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, contracts.SheetSettings) (*contracts.SheetSettings, error)); ok {
+		return rf(sheetId, settings)
+	}
+	if rf, ok := ret.Get(0).(func(string, contracts.SheetSettings) *contracts.SheetSettings); ok {
+		r0 = rf(sheetId, settings)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*contracts.SheetSettings)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string, contracts.SheetSettings) error); ok {
+		r1 = rf(sheetId, settings)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Undo provides a mock function with given fields: sheetId, steps
+func (_m *SheetRepository) Undo(sheetId string, steps int) ([]contracts.CellVersion, error) {
+	ret := _m.Called(sheetId, steps)
+
+	var r0 []contracts.CellVersion
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, int) ([]contracts.CellVersion, error)); ok {
+		return rf(sheetId, steps)
+	}
+	if rf, ok := ret.Get(0).(func(string, int) []contracts.CellVersion); ok {
+		r0 = rf(sheetId, steps)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]contracts.CellVersion)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string, int) error); ok {
+		r1 = rf(sheetId, steps)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 type mockConstructorTestingTNewSheetRepository interface {
 	mock.TestingT
 	Cleanup(func())
@@ -0,0 +1,197 @@
+// Code generated by mockery v2.28.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	contracts "devChallengeExcel/contracts"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// StorageBucket is an autogenerated mock type for the StorageBucket type
+type StorageBucket struct {
+	mock.Mock
+}
+
+// Bucket provides a mock function with given fields: name
+func (_m *StorageBucket) Bucket(name []byte) contracts.StorageBucket {
+	ret := _m.Called(name)
+
+	var r0 contracts.StorageBucket
+	if rf, ok := ret.Get(0).(func([]byte) contracts.StorageBucket); ok {
+		r0 = rf(name)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(contracts.StorageBucket)
+		}
+	}
+
+	return r0
+}
+
+// CreateBucketIfNotExists provides a mock function with given fields: name
+func (_m *StorageBucket) CreateBucketIfNotExists(name []byte) (contracts.StorageBucket, error) {
+	ret := _m.Called(name)
+
+	var r0 contracts.StorageBucket
+	var r1 error
+	if rf, ok := ret.Get(0).(func([]byte) (contracts.StorageBucket, error)); ok {
+		return rf(name)
+	}
+	if rf, ok := ret.Get(0).(func([]byte) contracts.StorageBucket); ok {
+		r0 = rf(name)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(contracts.StorageBucket)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func([]byte) error); ok {
+		r1 = rf(name)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Cursor provides a mock function with given fields:
+func (_m *StorageBucket) Cursor() contracts.StorageCursor {
+	ret := _m.Called()
+
+	var r0 contracts.StorageCursor
+	if rf, ok := ret.Get(0).(func() contracts.StorageCursor); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(contracts.StorageCursor)
+		}
+	}
+
+	return r0
+}
+
+// Delete provides a mock function with given fields: key
+func (_m *StorageBucket) Delete(key []byte) error {
+	ret := _m.Called(key)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func([]byte) error); ok {
+		r0 = rf(key)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteBucket provides a mock function with given fields: name
+func (_m *StorageBucket) DeleteBucket(name []byte) error {
+	ret := _m.Called(name)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func([]byte) error); ok {
+		r0 = rf(name)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ForEach provides a mock function with given fields: fn
+func (_m *StorageBucket) ForEach(fn func([]byte, []byte) error) error {
+	ret := _m.Called(fn)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(func([]byte, []byte) error) error); ok {
+		r0 = rf(fn)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ForEachBucket provides a mock function with given fields: fn
+func (_m *StorageBucket) ForEachBucket(fn func([]byte) error) error {
+	ret := _m.Called(fn)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(func([]byte) error) error); ok {
+		r0 = rf(fn)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Get provides a mock function with given fields: key
+func (_m *StorageBucket) Get(key []byte) []byte {
+	ret := _m.Called(key)
+
+	var r0 []byte
+	if rf, ok := ret.Get(0).(func([]byte) []byte); ok {
+		r0 = rf(key)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]byte)
+		}
+	}
+
+	return r0
+}
+
+// NextSequence provides a mock function with given fields:
+func (_m *StorageBucket) NextSequence() (uint64, error) {
+	ret := _m.Called()
+
+	var r0 uint64
+	var r1 error
+	if rf, ok := ret.Get(0).(func() (uint64, error)); ok {
+		return rf()
+	}
+	if rf, ok := ret.Get(0).(func() uint64); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(uint64)
+	}
+
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Put provides a mock function with given fields: key, value
+func (_m *StorageBucket) Put(key []byte, value []byte) error {
+	ret := _m.Called(key, value)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func([]byte, []byte) error); ok {
+		r0 = rf(key, value)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+type mockConstructorTestingTNewStorageBucket interface {
+	mock.TestingT
+	Cleanup(func())
+}
+
+// NewStorageBucket creates a new instance of StorageBucket. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+func NewStorageBucket(t mockConstructorTestingTNewStorageBucket) *StorageBucket {
+	mock := &StorageBucket{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
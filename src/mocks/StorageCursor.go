@@ -0,0 +1,165 @@
+// Code generated by mockery v2.28.1. DO NOT EDIT.
+
+package mocks
+
+import mock "github.com/stretchr/testify/mock"
+
+// StorageCursor is an autogenerated mock type for the StorageCursor type
+type StorageCursor struct {
+	mock.Mock
+}
+
+// First provides a mock function with given fields:
+func (_m *StorageCursor) First() ([]byte, []byte) {
+	ret := _m.Called()
+
+	var r0 []byte
+	var r1 []byte
+	if rf, ok := ret.Get(0).(func() ([]byte, []byte)); ok {
+		return rf()
+	}
+	if rf, ok := ret.Get(0).(func() []byte); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]byte)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func() []byte); ok {
+		r1 = rf()
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).([]byte)
+		}
+	}
+
+	return r0, r1
+}
+
+// Last provides a mock function with given fields:
+func (_m *StorageCursor) Last() ([]byte, []byte) {
+	ret := _m.Called()
+
+	var r0 []byte
+	var r1 []byte
+	if rf, ok := ret.Get(0).(func() ([]byte, []byte)); ok {
+		return rf()
+	}
+	if rf, ok := ret.Get(0).(func() []byte); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]byte)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func() []byte); ok {
+		r1 = rf()
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).([]byte)
+		}
+	}
+
+	return r0, r1
+}
+
+// Next provides a mock function with given fields:
+func (_m *StorageCursor) Next() ([]byte, []byte) {
+	ret := _m.Called()
+
+	var r0 []byte
+	var r1 []byte
+	if rf, ok := ret.Get(0).(func() ([]byte, []byte)); ok {
+		return rf()
+	}
+	if rf, ok := ret.Get(0).(func() []byte); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]byte)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func() []byte); ok {
+		r1 = rf()
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).([]byte)
+		}
+	}
+
+	return r0, r1
+}
+
+// Prev provides a mock function with given fields:
+func (_m *StorageCursor) Prev() ([]byte, []byte) {
+	ret := _m.Called()
+
+	var r0 []byte
+	var r1 []byte
+	if rf, ok := ret.Get(0).(func() ([]byte, []byte)); ok {
+		return rf()
+	}
+	if rf, ok := ret.Get(0).(func() []byte); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]byte)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func() []byte); ok {
+		r1 = rf()
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).([]byte)
+		}
+	}
+
+	return r0, r1
+}
+
+// Seek provides a mock function with given fields: seek
+func (_m *StorageCursor) Seek(seek []byte) ([]byte, []byte) {
+	ret := _m.Called(seek)
+
+	var r0 []byte
+	var r1 []byte
+	if rf, ok := ret.Get(0).(func([]byte) ([]byte, []byte)); ok {
+		return rf(seek)
+	}
+	if rf, ok := ret.Get(0).(func([]byte) []byte); ok {
+		r0 = rf(seek)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]byte)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func([]byte) []byte); ok {
+		r1 = rf(seek)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).([]byte)
+		}
+	}
+
+	return r0, r1
+}
+
+type mockConstructorTestingTNewStorageCursor interface {
+	mock.TestingT
+	Cleanup(func())
+}
+
+// NewStorageCursor creates a new instance of StorageCursor. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+func NewStorageCursor(t mockConstructorTestingTNewStorageCursor) *StorageCursor {
+	mock := &StorageCursor{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,99 @@
+// Code generated by mockery v2.28.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	contracts "devChallengeExcel/contracts"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// StorageTx is an autogenerated mock type for the StorageTx type
+type StorageTx struct {
+	mock.Mock
+}
+
+// Bucket provides a mock function with given fields: name
+func (_m *StorageTx) Bucket(name []byte) contracts.StorageBucket {
+	ret := _m.Called(name)
+
+	var r0 contracts.StorageBucket
+	if rf, ok := ret.Get(0).(func([]byte) contracts.StorageBucket); ok {
+		r0 = rf(name)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(contracts.StorageBucket)
+		}
+	}
+
+	return r0
+}
+
+// CreateBucketIfNotExists provides a mock function with given fields: name
+func (_m *StorageTx) CreateBucketIfNotExists(name []byte) (contracts.StorageBucket, error) {
+	ret := _m.Called(name)
+
+	var r0 contracts.StorageBucket
+	var r1 error
+	if rf, ok := ret.Get(0).(func([]byte) (contracts.StorageBucket, error)); ok {
+		return rf(name)
+	}
+	if rf, ok := ret.Get(0).(func([]byte) contracts.StorageBucket); ok {
+		r0 = rf(name)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(contracts.StorageBucket)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func([]byte) error); ok {
+		r1 = rf(name)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DeleteBucket provides a mock function with given fields: name
+func (_m *StorageTx) DeleteBucket(name []byte) error {
+	ret := _m.Called(name)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func([]byte) error); ok {
+		r0 = rf(name)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ForEachBucket provides a mock function with given fields: fn
+func (_m *StorageTx) ForEachBucket(fn func([]byte) error) error {
+	ret := _m.Called(fn)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(func([]byte) error) error); ok {
+		r0 = rf(fn)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+type mockConstructorTestingTNewStorageTx interface {
+	mock.TestingT
+	Cleanup(func())
+}
+
+// NewStorageTx creates a new instance of StorageTx. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+func NewStorageTx(t mockConstructorTestingTNewStorageTx) *StorageTx {
+	mock := &StorageTx{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
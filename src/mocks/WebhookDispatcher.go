@@ -3,6 +3,7 @@
 package mocks
 
 import (
+	context "context"
 	contracts "devChallengeExcel/contracts"
 
 	mock "github.com/stretchr/testify/mock"
@@ -18,28 +19,215 @@ func (_m *WebhookDispatcher) Close() {
 	_m.Called()
 }
 
-// GetWebhookUrl provides a mock function with given fields: canonicalSheetId, canonicalCellId
-func (_m *WebhookDispatcher) GetWebhookUrl(canonicalSheetId string, canonicalCellId string) string {
+// DeleteDeadLetter provides a mock function with given fields: id
+func (_m *WebhookDispatcher) DeleteDeadLetter(id string) error {
+	ret := _m.Called(id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string) error); ok {
+		r0 = rf(id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteSheetWebhooks provides a mock function with given fields: canonicalSheetId
+func (_m *WebhookDispatcher) DeleteSheetWebhooks(canonicalSheetId string) error {
+	ret := _m.Called(canonicalSheetId)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string) error); ok {
+		r0 = rf(canonicalSheetId)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteWebhooks provides a mock function with given fields: canonicalSheetId, canonicalCellId
+func (_m *WebhookDispatcher) DeleteWebhooks(canonicalSheetId string, canonicalCellId string) error {
+	ret := _m.Called(canonicalSheetId, canonicalCellId)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string) error); ok {
+		r0 = rf(canonicalSheetId, canonicalCellId)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetDeadLetters provides a mock function with given fields:
+func (_m *WebhookDispatcher) GetDeadLetters() ([]contracts.WebhookOutboxEntry, error) {
+	ret := _m.Called()
+
+	var r0 []contracts.WebhookOutboxEntry
+	var r1 error
+	if rf, ok := ret.Get(0).(func() ([]contracts.WebhookOutboxEntry, error)); ok {
+		return rf()
+	}
+	if rf, ok := ret.Get(0).(func() []contracts.WebhookOutboxEntry); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]contracts.WebhookOutboxEntry)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetDeliveryLog provides a mock function with given fields: canonicalSheetId, canonicalCellId, subscriptionId, limit
+func (_m *WebhookDispatcher) GetDeliveryLog(canonicalSheetId string, canonicalCellId string, subscriptionId string, limit int) (*contracts.WebhookDeliveryLog, error) {
+	ret := _m.Called(canonicalSheetId, canonicalCellId, subscriptionId, limit)
+
+	var r0 *contracts.WebhookDeliveryLog
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, string, string, int) (*contracts.WebhookDeliveryLog, error)); ok {
+		return rf(canonicalSheetId, canonicalCellId, subscriptionId, limit)
+	}
+	if rf, ok := ret.Get(0).(func(string, string, string, int) *contracts.WebhookDeliveryLog); ok {
+		r0 = rf(canonicalSheetId, canonicalCellId, subscriptionId, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*contracts.WebhookDeliveryLog)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string, string, string, int) error); ok {
+		r1 = rf(canonicalSheetId, canonicalCellId, subscriptionId, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetSheetDeliveryLog provides a mock function with given fields: canonicalSheetId, limit
+func (_m *WebhookDispatcher) GetSheetDeliveryLog(canonicalSheetId string, limit int) (*contracts.WebhookDeliveryLog, error) {
+	ret := _m.Called(canonicalSheetId, limit)
+
+	var r0 *contracts.WebhookDeliveryLog
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, int) (*contracts.WebhookDeliveryLog, error)); ok {
+		return rf(canonicalSheetId, limit)
+	}
+	if rf, ok := ret.Get(0).(func(string, int) *contracts.WebhookDeliveryLog); ok {
+		r0 = rf(canonicalSheetId, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*contracts.WebhookDeliveryLog)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string, int) error); ok {
+		r1 = rf(canonicalSheetId, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetSheetSubscriptionDeliveryLog provides a mock function with given fields: canonicalSheetId, subscriptionId, limit
+func (_m *WebhookDispatcher) GetSheetSubscriptionDeliveryLog(canonicalSheetId string, subscriptionId string, limit int) (*contracts.WebhookDeliveryLog, error) {
+	ret := _m.Called(canonicalSheetId, subscriptionId, limit)
+
+	var r0 *contracts.WebhookDeliveryLog
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, string, int) (*contracts.WebhookDeliveryLog, error)); ok {
+		return rf(canonicalSheetId, subscriptionId, limit)
+	}
+	if rf, ok := ret.Get(0).(func(string, string, int) *contracts.WebhookDeliveryLog); ok {
+		r0 = rf(canonicalSheetId, subscriptionId, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*contracts.WebhookDeliveryLog)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string, string, int) error); ok {
+		r1 = rf(canonicalSheetId, subscriptionId, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetSheetSubscriptions provides a mock function with given fields: canonicalSheetId
+func (_m *WebhookDispatcher) GetSheetSubscriptions(canonicalSheetId string) []contracts.WebhookSubscription {
+	ret := _m.Called(canonicalSheetId)
+
+	var r0 []contracts.WebhookSubscription
+	if rf, ok := ret.Get(0).(func(string) []contracts.WebhookSubscription); ok {
+		r0 = rf(canonicalSheetId)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]contracts.WebhookSubscription)
+		}
+	}
+
+	return r0
+}
+
+// GetSubscriptions provides a mock function with given fields: canonicalSheetId, canonicalCellId
+func (_m *WebhookDispatcher) GetSubscriptions(canonicalSheetId string, canonicalCellId string) []contracts.WebhookSubscription {
 	ret := _m.Called(canonicalSheetId, canonicalCellId)
 
-	var r0 string
-	if rf, ok := ret.Get(0).(func(string, string) string); ok {
+	var r0 []contracts.WebhookSubscription
+	if rf, ok := ret.Get(0).(func(string, string) []contracts.WebhookSubscription); ok {
 		r0 = rf(canonicalSheetId, canonicalCellId)
 	} else {
-		r0 = ret.Get(0).(string)
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]contracts.WebhookSubscription)
+		}
 	}
 
 	return r0
 }
 
-// Notify provides a mock function with given fields: canonicalSheetId, cells
-func (_m *WebhookDispatcher) Notify(canonicalSheetId string, cells []*contracts.Cell) {
-	_m.Called(canonicalSheetId, cells)
+// Notify provides a mock function with given fields: canonicalSheetId, changes, origin
+func (_m *WebhookDispatcher) Notify(canonicalSheetId string, changes []contracts.CellChange, origin contracts.ChangeOrigin) {
+	_m.Called(canonicalSheetId, changes, origin)
 }
 
-// SetWebhookUrl provides a mock function with given fields: canonicalSheetId, canonicalCellId, webhookUrl
-func (_m *WebhookDispatcher) SetWebhookUrl(canonicalSheetId string, canonicalCellId string, webhookUrl string) {
-	_m.Called(canonicalSheetId, canonicalCellId, webhookUrl)
+// ReplayDeadLetter provides a mock function with given fields: id
+func (_m *WebhookDispatcher) ReplayDeadLetter(id string) error {
+	ret := _m.Called(id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string) error); ok {
+		r0 = rf(id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Shutdown provides a mock function with given fields: ctx
+func (_m *WebhookDispatcher) Shutdown(ctx context.Context) error {
+	ret := _m.Called(ctx)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
 }
 
 // Start provides a mock function with given fields:
@@ -47,6 +235,114 @@ func (_m *WebhookDispatcher) Start() {
 	_m.Called()
 }
 
+// Subscribe provides a mock function with given fields: canonicalSheetId, canonicalCellId, webhookUrl, description, options
+func (_m *WebhookDispatcher) Subscribe(canonicalSheetId string, canonicalCellId string, webhookUrl string, description string, options contracts.WebhookOptions) (*contracts.WebhookSubscription, error) {
+	ret := _m.Called(canonicalSheetId, canonicalCellId, webhookUrl, description, options)
+
+	var r0 *contracts.WebhookSubscription
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, string, string, string, contracts.WebhookOptions) (*contracts.WebhookSubscription, error)); ok {
+		return rf(canonicalSheetId, canonicalCellId, webhookUrl, description, options)
+	}
+	if rf, ok := ret.Get(0).(func(string, string, string, string, contracts.WebhookOptions) *contracts.WebhookSubscription); ok {
+		r0 = rf(canonicalSheetId, canonicalCellId, webhookUrl, description, options)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*contracts.WebhookSubscription)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string, string, string, string, contracts.WebhookOptions) error); ok {
+		r1 = rf(canonicalSheetId, canonicalCellId, webhookUrl, description, options)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SubscribeSheet provides a mock function with given fields: canonicalSheetId, webhookUrl, description, scope, options
+func (_m *WebhookDispatcher) SubscribeSheet(canonicalSheetId string, webhookUrl string, description string, scope contracts.WebhookScope, options contracts.WebhookOptions) (*contracts.WebhookSubscription, error) {
+	ret := _m.Called(canonicalSheetId, webhookUrl, description, scope, options)
+
+	var r0 *contracts.WebhookSubscription
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, string, string, contracts.WebhookScope, contracts.WebhookOptions) (*contracts.WebhookSubscription, error)); ok {
+		return rf(canonicalSheetId, webhookUrl, description, scope, options)
+	}
+	if rf, ok := ret.Get(0).(func(string, string, string, contracts.WebhookScope, contracts.WebhookOptions) *contracts.WebhookSubscription); ok {
+		r0 = rf(canonicalSheetId, webhookUrl, description, scope, options)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*contracts.WebhookSubscription)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string, string, string, contracts.WebhookScope, contracts.WebhookOptions) error); ok {
+		r1 = rf(canonicalSheetId, webhookUrl, description, scope, options)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Unsubscribe provides a mock function with given fields: canonicalSheetId, canonicalCellId, subscriptionId
+func (_m *WebhookDispatcher) Unsubscribe(canonicalSheetId string, canonicalCellId string, subscriptionId string) error {
+	ret := _m.Called(canonicalSheetId, canonicalCellId, subscriptionId)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string, string) error); ok {
+		r0 = rf(canonicalSheetId, canonicalCellId, subscriptionId)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UnsubscribeSheet provides a mock function with given fields: canonicalSheetId, subscriptionId
+func (_m *WebhookDispatcher) UnsubscribeSheet(canonicalSheetId string, subscriptionId string) error {
+	ret := _m.Called(canonicalSheetId, subscriptionId)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string) error); ok {
+		r0 = rf(canonicalSheetId, subscriptionId)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UnsubscribeSheetUrl provides a mock function with given fields: canonicalSheetId, webhookUrl
+func (_m *WebhookDispatcher) UnsubscribeSheetUrl(canonicalSheetId string, webhookUrl string) error {
+	ret := _m.Called(canonicalSheetId, webhookUrl)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string) error); ok {
+		r0 = rf(canonicalSheetId, webhookUrl)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UnsubscribeUrl provides a mock function with given fields: canonicalSheetId, canonicalCellId, webhookUrl
+func (_m *WebhookDispatcher) UnsubscribeUrl(canonicalSheetId string, canonicalCellId string, webhookUrl string) error {
+	ret := _m.Called(canonicalSheetId, canonicalCellId, webhookUrl)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string, string) error); ok {
+		r0 = rf(canonicalSheetId, canonicalCellId, webhookUrl)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 type mockConstructorTestingTNewWebhookDispatcher interface {
 	mock.TestingT
 	Cleanup(func())
@@ -0,0 +1,48 @@
+// Code generated by mockery v2.28.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	http "net/http"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// WebhookSigner is an autogenerated mock type for the WebhookSigner type
+type WebhookSigner struct {
+	mock.Mock
+}
+
+// Sign provides a mock function with given fields: request, body
+func (_m *WebhookSigner) Sign(request *http.Request, body []byte) {
+	_m.Called(request, body)
+}
+
+// Verify provides a mock function with given fields: request, body
+func (_m *WebhookSigner) Verify(request *http.Request, body []byte) error {
+	ret := _m.Called(request, body)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*http.Request, []byte) error); ok {
+		r0 = rf(request, body)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+type mockConstructorTestingTNewWebhookSigner interface {
+	mock.TestingT
+	Cleanup(func())
+}
+
+// NewWebhookSigner creates a new instance of WebhookSigner. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+func NewWebhookSigner(t mockConstructorTestingTNewWebhookSigner) *WebhookSigner {
+	mock := &WebhookSigner{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -10,17 +10,58 @@ const ApiVersion = "v1"
 
 const externalRefWebhookPath = "externalRefWebhook"
 const subscribePath = "subscribe"
+const subscriptionsPath = "subscriptions"
+const externalRefSubscriptionsPath = "externalRefSubscriptions"
+const settingsPath = "_settings"
+const sheetSubscribePath = "_subscribe"
+const sheetSubscriptionsPath = "_subscriptions"
+const historyPath = "history"
+const undoPath = "_undo"
+const redoPath = "_redo"
+const deliveriesPath = "deliveries"
+const sheetDeliveriesPath = "_deliveries"
+const changeEventsPath = "_events"
+const liveSessionPath = "_ws"
+const statusPath = "_status"
+const deadLettersPath = "_webhooks/deadLetters"
 
 func SetupRouter(controller contracts.ApiController) *gin.Engine {
 	router := gin.New()
 
 	apiRouterGroup := router.Group("/api/" + ApiVersion)
 	apiRouterGroup.POST("/:sheet_id/:cell_id/"+subscribePath, controller.SubscribeAction)
+	apiRouterGroup.GET("/:sheet_id/:cell_id/"+subscriptionsPath, controller.GetSubscriptionsAction)
+	apiRouterGroup.DELETE("/:sheet_id/:cell_id/"+subscriptionsPath, controller.UnsubscribeAction)
+	apiRouterGroup.DELETE("/:sheet_id/:cell_id/"+subscriptionsPath+"/:subscription_id", controller.UnsubscribeAction)
+	apiRouterGroup.GET("/:sheet_id/:cell_id/"+subscriptionsPath+"/:subscription_id/"+deliveriesPath, controller.GetDeliveriesAction)
 	apiRouterGroup.POST("/:sheet_id/:cell_id/"+externalRefWebhookPath, controller.ExternalRefWebhookAction)
+	apiRouterGroup.GET("/:sheet_id/:cell_id/"+externalRefSubscriptionsPath, controller.GetExternalRefSubscriptionsAction)
+	apiRouterGroup.GET("/:sheet_id/:cell_id/"+historyPath, controller.GetCellHistoryAction)
+
+	apiRouterGroup.POST("/:sheet_id/"+sheetSubscribePath, controller.SubscribeSheetAction)
+	apiRouterGroup.GET("/:sheet_id/"+sheetSubscriptionsPath, controller.GetSheetSubscriptionsAction)
+	apiRouterGroup.DELETE("/:sheet_id/"+sheetSubscriptionsPath, controller.UnsubscribeSheetAction)
+	apiRouterGroup.DELETE("/:sheet_id/"+sheetSubscriptionsPath+"/:subscription_id", controller.UnsubscribeSheetAction)
+	apiRouterGroup.GET("/:sheet_id/"+sheetSubscriptionsPath+"/:subscription_id/"+deliveriesPath, controller.GetSheetSubscriptionDeliveriesAction)
+	apiRouterGroup.GET("/:sheet_id/"+sheetDeliveriesPath, controller.GetSheetDeliveriesAction)
+	apiRouterGroup.GET("/:sheet_id/"+changeEventsPath, controller.ChangeEventsAction)
+	apiRouterGroup.GET("/:sheet_id/"+liveSessionPath, controller.LiveSessionAction)
+	apiRouterGroup.POST("/:sheet_id/"+undoPath, controller.UndoAction)
+	apiRouterGroup.POST("/:sheet_id/"+redoPath, controller.RedoAction)
+
+	apiRouterGroup.GET("/:sheet_id/"+settingsPath, controller.GetSettingsAction)
+	apiRouterGroup.POST("/:sheet_id/"+settingsPath, controller.SetSettingsAction)
+
+	apiRouterGroup.GET("/"+statusPath, controller.StatusAction)
+	apiRouterGroup.GET("/"+deadLettersPath, controller.GetDeadLettersAction)
+	apiRouterGroup.POST("/"+deadLettersPath+"/:dead_letter_id/replay", controller.ReplayDeadLetterAction)
+	apiRouterGroup.DELETE("/"+deadLettersPath+"/:dead_letter_id", controller.DeleteDeadLetterAction)
 
 	apiRouterGroup.POST("/:sheet_id/:cell_id", controller.SetCellAction)
 	apiRouterGroup.GET("/:sheet_id/:cell_id", controller.GetCellAction)
+	apiRouterGroup.DELETE("/:sheet_id/:cell_id", controller.DeleteCellAction)
 	apiRouterGroup.GET("/:sheet_id", controller.GetSheetAction)
+	apiRouterGroup.DELETE("/:sheet_id", controller.DeleteSheetAction)
 
 	router.GET("/healthcheck", func(c *gin.Context) {
 		c.String(http.StatusOK, "health")
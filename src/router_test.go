@@ -17,6 +17,30 @@ func TestSetupRoutes(t *testing.T) {
 		{http.MethodPost, "/:sheet_id/:cell_id", "SetCellAction"},
 		{http.MethodGet, "/:sheet_id/:cell_id", "GetCellAction"},
 		{http.MethodGet, "/:sheet_id", "GetSheetAction"},
+		{http.MethodDelete, "/:sheet_id/:cell_id", "DeleteCellAction"},
+		{http.MethodDelete, "/:sheet_id", "DeleteSheetAction"},
+		{http.MethodGet, "/:sheet_id/:cell_id/subscriptions", "GetSubscriptionsAction"},
+		{http.MethodDelete, "/:sheet_id/:cell_id/subscriptions", "UnsubscribeAction"},
+		{http.MethodDelete, "/:sheet_id/:cell_id/subscriptions/:subscription_id", "UnsubscribeAction"},
+		{http.MethodGet, "/:sheet_id/:cell_id/subscriptions/:subscription_id/deliveries", "GetDeliveriesAction"},
+		{http.MethodPost, "/:sheet_id/_subscribe", "SubscribeSheetAction"},
+		{http.MethodGet, "/:sheet_id/_subscriptions", "GetSheetSubscriptionsAction"},
+		{http.MethodDelete, "/:sheet_id/_subscriptions", "UnsubscribeSheetAction"},
+		{http.MethodDelete, "/:sheet_id/_subscriptions/:subscription_id", "UnsubscribeSheetAction"},
+		{http.MethodGet, "/:sheet_id/_subscriptions/:subscription_id/deliveries", "GetSheetSubscriptionDeliveriesAction"},
+		{http.MethodGet, "/:sheet_id/_deliveries", "GetSheetDeliveriesAction"},
+		{http.MethodGet, "/:sheet_id/_events", "ChangeEventsAction"},
+		{http.MethodGet, "/:sheet_id/_ws", "LiveSessionAction"},
+		{http.MethodPost, "/:sheet_id/_undo", "UndoAction"},
+		{http.MethodPost, "/:sheet_id/_redo", "RedoAction"},
+		{http.MethodGet, "/:sheet_id/_settings", "GetSettingsAction"},
+		{http.MethodPost, "/:sheet_id/_settings", "SetSettingsAction"},
+		{http.MethodGet, "/_status", "StatusAction"},
+		{http.MethodGet, "/_webhooks/deadLetters", "GetDeadLettersAction"},
+		{http.MethodPost, "/_webhooks/deadLetters/:dead_letter_id/replay", "ReplayDeadLetterAction"},
+		{http.MethodDelete, "/_webhooks/deadLetters/:dead_letter_id", "DeleteDeadLetterAction"},
+		{http.MethodGet, "/:sheet_id/:cell_id/externalRefSubscriptions", "GetExternalRefSubscriptionsAction"},
+		{http.MethodGet, "/:sheet_id/:cell_id/history", "GetCellHistoryAction"},
 	}
 
 	for _, expectedRoute := range expectedApiRoutes {